@@ -7,4 +7,6 @@ type Config struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// TrustProxy takes client addresses from the X-Forwarded-For header set by a reverse proxy
+	TrustProxy bool
 }
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// ProjectDeletionNotifier tells the subscribers of a deleted project that it is gone
+type ProjectDeletionNotifier interface {
+	NotifyProjectDeleted(project *domain.Project, subscriptions []*domain.Subscription)
+}
+
+// publisherHandler handles a request of the management API authenticated as the publisher
+type publisherHandler func(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID)
+
+type projectResponse struct {
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	RequiresApproval bool      `json:"requires_approval"`
+	// HasSigningSecret tells whether requests can be signed, the secret itself is only shown when generated
+	HasSigningSecret bool `json:"has_signing_secret"`
+	// SigningScopes are the actions signed requests allow, omitted without a signing secret
+	SigningScopes         []domain.TokenScope `json:"signing_scopes,omitempty"`
+	RequireSignedRequests bool                `json:"require_signed_requests"`
+	CreatedAt             time.Time           `json:"created_at"`
+	UpdatedAt             time.Time           `json:"updated_at"`
+}
+
+func projectFromDomain(p *domain.Project) projectResponse {
+	return projectResponse{
+		ID:                    p.ID,
+		Name:                  p.Name,
+		Description:           p.Description,
+		RequiresApproval:      p.RequiresApproval,
+		HasSigningSecret:      p.HasSigningSecret(),
+		SigningScopes:         p.SigningScopes,
+		RequireSignedRequests: p.RequireSignedRequests,
+		CreatedAt:             p.CreatedAt,
+		UpdatedAt:             p.UpdatedAt,
+	}
+}
+
+type subscriberResponse struct {
+	UserID       domain.TelegramUserID `json:"user_id"`
+	Name         string                `json:"name,omitempty"`
+	Username     string                `json:"username,omitempty"`
+	SubscribedAt time.Time             `json:"subscribed_at"`
+	Muted        bool                  `json:"muted"`
+	Paused       bool                  `json:"paused"`
+	// Inactive is set while the subscriber can't receive messages, e.g. because they blocked the bot
+	Inactive bool `json:"inactive"`
+}
+
+// registerProjectRoutes adds the routes of the management API to the mux
+func (s *Service) registerProjectRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/projects", s.authenticated(s.handleListProjects))
+	mux.HandleFunc("POST /api/v1/projects", s.authenticated(s.handleCreateProject))
+	mux.HandleFunc("GET /api/v1/projects/{id}", s.authenticated(s.handleGetProject))
+	mux.HandleFunc("PATCH /api/v1/projects/{id}", s.authenticated(s.handleUpdateProject))
+	mux.HandleFunc("DELETE /api/v1/projects/{id}", s.authenticated(s.handleDeleteProject))
+	mux.HandleFunc("GET /api/v1/projects/{id}/subscribers", s.authenticated(s.handleListSubscribers))
+	mux.HandleFunc("POST /api/v1/projects/{id}/signing-secret", s.authenticated(s.handleGenerateSigningSecret))
+	mux.HandleFunc("DELETE /api/v1/projects/{id}/signing-secret", s.authenticated(s.handleRemoveSigningSecret))
+	mux.HandleFunc("GET /api/v1/projects/{id}/tokens", s.authenticated(s.handleListTokens))
+	mux.HandleFunc("POST /api/v1/projects/{id}/tokens", s.authenticated(s.handleCreateToken))
+	mux.HandleFunc("PATCH /api/v1/projects/{id}/tokens/{tokenID}", s.authenticated(s.handleUpdateToken))
+	mux.HandleFunc("DELETE /api/v1/projects/{id}/tokens/{tokenID}", s.authenticated(s.handleRevokeToken))
+	mux.HandleFunc("POST /api/v1/projects/{id}/tokens/{tokenID}/rotate", s.authenticated(s.handleRotateToken))
+}
+
+// authenticated checks the API key in the Authorization header before calling the handler
+func (s *Service) authenticated(next publisherHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || key == "" {
+			writeError(w, http.StatusUnauthorized, "missing bearer api key")
+			return
+		}
+
+		publisherID, err := s.apiKeyService.Authenticate(key, time.Now())
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidAPIKey) {
+				writeError(w, http.StatusUnauthorized, "invalid api key")
+				return
+			}
+			slog.Error("Failed to authenticate api key", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		next(w, r, publisherID)
+	}
+}
+
+// ownedProject returns the project from the request path, writing an error response
+// if it doesn't exist or belongs to another publisher
+func (s *Service) ownedProject(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) (*domain.Project, bool) {
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, false
+	}
+
+	project, err := s.projectService.GetByID(projectID)
+	if err != nil {
+		if errors.Is(err, domain.ErrProjectNotFound) {
+			writeError(w, http.StatusNotFound, "project not found")
+			return nil, false
+		}
+		slog.Error("Failed to get project", "error", err, "projectId", projectID)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return nil, false
+	}
+
+	// Projects of other publishers are reported as missing, so that their IDs can't be probed
+	if !project.PublisherID.Equal(publisherID) {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, false
+	}
+	return project, true
+}
+
+func (s *Service) handleListProjects(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	projects, err := s.projectService.GetByPublisher(publisherID)
+	if err != nil {
+		slog.Error("Failed to get projects", "error", err, "publisherId", publisherID)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	response := make([]projectResponse, len(projects))
+	for i, project := range projects {
+		response[i] = projectFromDomain(project)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Service) handleCreateProject(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	var request struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	project, err := s.projectService.CreateWithDescription(publisherID, request.Name, request.Description)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	// Like in the bot, a project starts with a token allowing everything. Unlike in the bot, the token
+	// is only shown in the response, so the project is removed if it can't be created and the request can be retried.
+	token, err := s.tokenService.Create(project.ID, domain.TokenOptions{Name: domain.DefaultTokenName})
+	if err != nil {
+		if _, deleteErr := s.projectService.Delete(project.ID); deleteErr != nil {
+			slog.Error("Failed to remove project without a token", "error", deleteErr, "projectId", project.ID)
+		}
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project created through the api", "projectId", project.ID, "publisherId", publisherID)
+	writeJSON(w, http.StatusCreated, struct {
+		projectResponse
+		Token tokenResponse `json:"token"`
+	}{projectFromDomain(project), tokenFromDomain(token)})
+}
+
+func (s *Service) handleGetProject(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, projectFromDomain(project))
+}
+
+// handleUpdateProject renames the project and changes its description and signed requests settings,
+// omitted fields are kept
+func (s *Service) handleUpdateProject(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name                  *string             `json:"name"`
+		Description           *string             `json:"description"`
+		RequireSignedRequests *bool               `json:"require_signed_requests"`
+		SigningScopes         []domain.TokenScope `json:"signing_scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if request.RequireSignedRequests != nil && *request.RequireSignedRequests && !project.HasSigningSecret() {
+		writeProjectError(w, domain.ErrNoSigningSecret)
+		return
+	}
+	if request.SigningScopes != nil {
+		if !project.HasSigningSecret() {
+			writeProjectError(w, domain.ErrNoSigningSecret)
+			return
+		}
+		if _, err := domain.ValidateTokenScopes(request.SigningScopes); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+
+	if request.Description != nil {
+		if _, err := domain.ValidateProjectDescription(*request.Description); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+	if request.Name != nil {
+		if err := s.projectService.UpdateName(project.ID, *request.Name); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+	if request.Description != nil {
+		if err := s.projectService.UpdateDescription(project.ID, *request.Description); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+	if request.RequireSignedRequests != nil {
+		if err := s.signingService.SetRequired(project, *request.RequireSignedRequests); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+	if request.SigningScopes != nil {
+		if err := s.signingService.SetScopes(project, request.SigningScopes); err != nil {
+			writeProjectError(w, err)
+			return
+		}
+	}
+
+	project, err := s.projectService.GetByID(project.ID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, projectFromDomain(project))
+}
+
+// handleDeleteProject deletes the project and notifies its subscribers
+func (s *Service) handleDeleteProject(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := s.projectService.Delete(project.ID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project deleted through the api", "projectId", project.ID, "publisherId", publisherID)
+	s.deletionNotifier.NotifyProjectDeleted(project, subscriptions)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleListSubscribers(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := s.subscriptionService.GetProjectSubscriptions(project.ID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	response := make([]subscriberResponse, len(subscriptions))
+	for i, sub := range subscriptions {
+		response[i] = subscriberResponse{
+			UserID:       sub.UserID,
+			SubscribedAt: sub.CreatedAt,
+			Muted:        sub.IsMuted(),
+			Paused:       sub.Paused(),
+			Inactive:     sub.Inactive,
+		}
+
+		user, err := s.userService.Get(sub.UserID)
+		switch {
+		case err == nil:
+			response[i].Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			response[i].Username = user.Username
+		case !errors.Is(err, domain.ErrUserNotFound):
+			slog.Warn("Failed to get subscriber", "error", err, "userId", sub.UserID)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeProjectError responds with the status matching a domain error
+func writeProjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidProjectName), errors.Is(err, domain.ErrInvalidProjectDescription),
+		errors.Is(err, domain.ErrInvalidTokenName), errors.Is(err, domain.ErrInvalidTokenScopes),
+		errors.Is(err, domain.ErrInvalidAllowedIPs):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrProjectNameTaken), errors.Is(err, domain.ErrNoSigningSecret):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrProjectNotFound):
+		writeError(w, http.StatusNotFound, "project not found")
+	case errors.Is(err, domain.ErrTokenNotFound):
+		writeError(w, http.StatusNotFound, "token not found")
+	default:
+		slog.Error("Failed to handle project request", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
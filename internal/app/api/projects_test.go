@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestService_HandleCreateProject(t *testing.T) {
+	tests := []struct {
+		name            string
+		description     string
+		setup           func(t *testing.T, a *testAPI)
+		wantStatus      int
+		wantDescription string
+	}{
+		{"created", " Releases ", nil, http.StatusCreated, "Releases"},
+		{"invalid description", strings.Repeat("a", domain.MaxProjectDescriptionLength+1), nil, http.StatusBadRequest, ""},
+		{"token can't be created", "Releases", func(t *testing.T, a *testAPI) {
+			require.NoError(t, a.db.Exec("CREATE TRIGGER fail_tokens BEFORE INSERT ON project_tokens "+
+				"BEGIN SELECT RAISE(ABORT, 'disk full'); END").Error)
+		}, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil)
+			key := a.newAPIKey(t, 1)
+			if tt.setup != nil {
+				tt.setup(t, a)
+			}
+
+			body := fmt.Sprintf(`{"name": "Deployments", "description": %q}`, tt.description)
+			w := a.serve(http.MethodPost, "/api/v1/projects", key, body)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			projects, err := a.projects.GetByPublisher(1)
+			require.NoError(t, err)
+			if tt.wantStatus != http.StatusCreated {
+				// No project is left behind, so the request can be retried
+				assert.Empty(t, projects)
+				return
+			}
+			require.Len(t, projects, 1)
+			assert.Equal(t, tt.wantDescription, projects[0].Description)
+			tokens, err := a.tokens.GetByProject(projects[0].ID)
+			require.NoError(t, err)
+			assert.Len(t, tokens, 1)
+		})
+	}
+}
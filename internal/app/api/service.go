@@ -3,12 +3,16 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/sergeax/noteo/internal/app/queue"
 	"github.com/sergeax/noteo/internal/domain"
 )
@@ -17,29 +21,61 @@ type Service struct {
 	config              *Config
 	messageQueue        *queue.Queue
 	projectService      *domain.ProjectService
+	tokenService        *domain.TokenService
+	signingService      *domain.SigningService
 	subscriptionService *domain.SubscriptionService
+	notificationService *domain.NotificationService
+	userService         *domain.UserService
+	apiKeyService       *domain.APIKeyService
+	deletionNotifier    ProjectDeletionNotifier
 	server              *http.Server
 }
 
-func NewService(cfg *Config, messageQueue *queue.Queue, projectService *domain.ProjectService, subscriptionService *domain.SubscriptionService) *Service {
+func NewService(
+	cfg *Config,
+	messageQueue *queue.Queue,
+	projectService *domain.ProjectService,
+	tokenService *domain.TokenService,
+	signingService *domain.SigningService,
+	subscriptionService *domain.SubscriptionService,
+	notificationService *domain.NotificationService,
+	userService *domain.UserService,
+	apiKeyService *domain.APIKeyService,
+	deletionNotifier ProjectDeletionNotifier,
+) *Service {
 	return &Service{
 		config:              cfg,
 		messageQueue:        messageQueue,
 		projectService:      projectService,
+		tokenService:        tokenService,
+		signingService:      signingService,
 		subscriptionService: subscriptionService,
+		notificationService: notificationService,
+		userService:         userService,
+		apiKeyService:       apiKeyService,
+		deletionNotifier:    deletionNotifier,
 	}
 }
 
-func (s *Service) Start(ctx context.Context) error {
+// routes returns the handler serving all the endpoints of the API
+func (s *Service) routes() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/notify", s.handleNotify)
+	mux.HandleFunc("POST /api/heartbeat", s.handleHeartbeat)
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	s.registerProjectRoutes(mux)
+	return mux
+}
 
+func (s *Service) Start(ctx context.Context) error {
 	s.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.config.Port),
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 		IdleTimeout:  s.config.IdleTimeout,
-		Handler:      mux,
+		Handler:      s.routes(),
 	}
 
 	// Start server in a goroutine so it doesn't block
@@ -79,10 +115,8 @@ func (s *Service) handleNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract token from Authorization header
-	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	if token == "" {
-		http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+	project, ok := s.authenticateProject(w, r, domain.TokenScopeNotify)
+	if !ok {
 		return
 	}
 
@@ -95,40 +129,192 @@ func (s *Service) handleNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate token
-	project, err := s.projectService.GetByToken(token)
+	// Decide how each subscriber gets the notification
+	messages, err := s.notificationService.Dispatch(project, notification.Body, time.Now())
 	if err != nil {
-		slog.Error("Failed to get project by token", "error", err)
+		slog.Error("Failed to dispatch notification", "error", err, "projectId", project.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Send notification to all subscribers. The messages are queued all at once or not at all,
+	// as the notification is already in the history and a retry would send the queued ones twice.
+	if err := s.messageQueue.PutAll(messages); err != nil {
+		s.postpone(w, project, messages, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// postpone saves the messages that the queue can't take, e.g. while Telegram is unavailable
+// or when there are more than a lane holds, they are sent once the queue takes them
+func (s *Service) postpone(w http.ResponseWriter, project *domain.Project, messages []domain.Message, reason error) {
+	if err := s.notificationService.Postpone(messages, time.Now()); err != nil {
+		slog.Error("Failed to postpone notification", "error", err, "projectId", project.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Warn("Message queue doesn't accept the notification, postponed it", "reason", reason,
+		"projectId", project.ID, "messages", len(messages))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authenticateProject returns the project the request is signed for if its signing secret allows the action,
+// or the project of the bearer token if the token allows the action from the client address,
+// writing an error response otherwise. Projects requiring signed requests reject bearer tokens.
+func (s *Service) authenticateProject(w http.ResponseWriter, r *http.Request, scope domain.TokenScope) (*domain.Project, bool) {
+	if isSigned(r) {
+		return s.authenticateSigned(w, r, scope)
+	}
+
+	project, ok := s.authenticateToken(w, r, scope)
+	if ok && project.RequireSignedRequests {
+		http.Error(w, "This project requires signed requests", http.StatusForbidden)
+		return nil, false
+	}
+	return project, ok
+}
+
+// authenticateToken returns the project of the bearer token if the token allows the action
+// from the client address, writing an error response otherwise
+func (s *Service) authenticateToken(w http.ResponseWriter, r *http.Request, scope domain.TokenScope) (*domain.Project, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	addr := s.clientAddr(r)
+	project, err := s.tokenService.Authenticate(token, scope, addr, time.Now())
+	switch {
+	case err == nil:
+		return project, true
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrTokenExpired):
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	case errors.Is(err, domain.ErrTokenScope), errors.Is(err, domain.ErrTokenSource):
+		slog.Warn("Rejected project token", "error", err, "scope", scope, "addr", addr)
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		slog.Error("Failed to authenticate project token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+	return nil, false
+}
+
+// clientAddr returns the address of the client. Behind a trusted reverse proxy it is
+// the last address the proxy appended to X-Forwarded-For.
+func (s *Service) clientAddr(r *http.Request) netip.Addr {
+	if s.config.TrustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[len(forwarded)-1])); err == nil {
+			return addr.Unmap()
+		}
+	}
+
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// handleHeartbeat records that the service behind the project is alive
+func (s *Service) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	project, ok := s.authenticateProject(w, r, domain.TokenScopeHeartbeat)
+	if !ok {
+		return
+	}
+
+	if err := s.projectService.Heartbeat(project.ID, time.Now()); err != nil {
+		slog.Error("Failed to record heartbeat", "error", err, "projectId", project.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStatus describes the project of the token and whether notifications are being delivered
+func (s *Service) handleStatus(w http.ResponseWriter, r *http.Request) {
+	project, ok := s.authenticateProject(w, r, domain.TokenScopeStatus)
+	if !ok {
 		return
 	}
 
-	// Get active subscriptions for the project
 	subscriptions, err := s.subscriptionService.GetProjectSubscriptions(project.ID)
 	if err != nil {
-		slog.Error("Failed to get active subscriptions", "error", err, "projectId", project.ID)
+		slog.Error("Failed to get project subscriptions", "error", err, "projectId", project.ID)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Send notification to all subscribers
-	for _, sub := range subscriptions {
-		if sub.Paused() {
-			continue
-		}
-		if err := s.messageQueue.Put(domain.Message{
-			UserID: sub.UserID,
-			Text:   notification.Body,
-			Muted:  sub.Muted,
-		}); err != nil {
-			if err == queue.ErrQueueFull {
-				slog.Error("Message queue is full", "projectId", project.ID)
-				http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
-				return
-			}
-			slog.Error("Failed to send notification", "error", err, "chatId", sub.UserID)
+	var status struct {
+		ID              uuid.UUID          `json:"id"`
+		Name            string             `json:"name"`
+		Subscribers     int                `json:"subscribers"`
+		LastHeartbeatAt *time.Time         `json:"last_heartbeat_at,omitempty"`
+		Telegram        queue.BreakerState `json:"telegram"`
+	}
+	status.ID = project.ID
+	status.Name = project.Name
+	status.Subscribers = len(subscriptions)
+	status.LastHeartbeatAt = project.LastHeartbeatAt
+	status.Telegram = s.messageQueue.Stats().Breaker
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleHealth reports whether notifications are being delivered.
+// The service is degraded while sending is paused because Telegram is unavailable.
+func (s *Service) handleHealth(w http.ResponseWriter, r *http.Request) {
+	stats := s.messageQueue.Stats()
+
+	var health struct {
+		Status   string             `json:"status"`
+		Queue    int                `json:"queue"`
+		Telegram queue.BreakerState `json:"telegram"`
+		PausedAt *time.Time         `json:"paused_at,omitempty"`
+	}
+	health.Status = "ok"
+	health.Queue = stats.Depth
+	health.Telegram = stats.Breaker
+	if stats.Breaker != queue.BreakerClosed {
+		health.Status = "degraded"
+		health.PausedAt = &stats.PausedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		slog.Error("Failed to write health response", "error", err)
+	}
+}
+
+// handleMetrics exposes the state of the queue in the Prometheus text format
+func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := s.messageQueue.Stats()
+
+	var b strings.Builder
+	b.WriteString("# HELP noteo_queue_depth Number of messages waiting to be sent.\n")
+	b.WriteString("# TYPE noteo_queue_depth gauge\n")
+	fmt.Fprintf(&b, "noteo_queue_depth %d\n", stats.Depth)
+
+	b.WriteString("# HELP noteo_telegram_breaker_state Current state of the circuit breaker in front of Telegram.\n")
+	b.WriteString("# TYPE noteo_telegram_breaker_state gauge\n")
+	for _, state := range []queue.BreakerState{queue.BreakerClosed, queue.BreakerOpen, queue.BreakerHalfOpen} {
+		value := 0
+		if state == stats.Breaker {
+			value = 1
 		}
+		fmt.Fprintf(&b, "noteo_telegram_breaker_state{state=%q} %d\n", state, value)
 	}
 
-	w.WriteHeader(http.StatusOK)
+	b.WriteString("# HELP noteo_telegram_breaker_opens_total Number of times sending was paused because Telegram was unavailable.\n")
+	b.WriteString("# TYPE noteo_telegram_breaker_opens_total counter\n")
+	fmt.Fprintf(&b, "noteo_telegram_breaker_opens_total %d\n", stats.Pauses)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		slog.Error("Failed to write metrics response", "error", err)
+	}
 }
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/app/db"
+	"github.com/sergeax/noteo/internal/app/queue"
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type deletionNotifierStub struct{}
+
+func (deletionNotifierStub) NotifyProjectDeleted(*domain.Project, []*domain.Subscription) {}
+
+// testAPI is the API service over a database in a temporary file and a queue that isn't started,
+// so queued messages stay in it
+type testAPI struct {
+	service       *Service
+	db            *gorm.DB
+	queue         *queue.Queue
+	projects      *domain.ProjectService
+	tokens        *domain.TokenService
+	signing       *domain.SigningService
+	subscriptions *domain.SubscriptionService
+	notifications *domain.NotificationService
+	apiKeys       *domain.APIKeyService
+}
+
+// newTestAPI creates the API service, the queue holds 100 messages if there is no queue configuration
+func newTestAPI(t *testing.T, queueCfg *queue.Config) *testAPI {
+	if queueCfg == nil {
+		queueCfg = &queue.Config{Capacity: 100, ProjectCapacity: 100, Rate: 30}
+	}
+	secret := []byte("secret")
+	hasher := domain.NewTokenHasher(secret)
+	gormDB, err := db.NewDB(&db.Config{DSN: filepath.Join(t.TempDir(), "noteo.db")}, hasher)
+	require.NoError(t, err)
+
+	projectRepo := db.NewProjectRepository(gormDB)
+	subscriptionRepo := db.NewSubscriptionRepository(gormDB)
+	a := &testAPI{
+		db:       gormDB,
+		queue:    queue.NewQueue(queueCfg, nil, nil),
+		projects: domain.NewProjectService(projectRepo, subscriptionRepo),
+		tokens:   domain.NewTokenService(db.NewProjectTokenRepository(gormDB), projectRepo, hasher),
+		signing:  domain.NewSigningService(projectRepo, db.NewNonceRepository(gormDB), domain.NewSecretBox(secret)),
+		subscriptions: domain.NewSubscriptionService(
+			subscriptionRepo, db.NewBanRepository(gormDB), db.NewSubscriptionRequestRepository(gormDB)),
+		notifications: domain.NewNotificationService(
+			projectRepo,
+			subscriptionRepo,
+			db.NewUserSettingsRepository(gormDB),
+			db.NewHeldMessageRepository(gormDB),
+			db.NewDigestRepository(gormDB),
+			db.NewHistoryRepository(gormDB),
+		),
+		apiKeys: domain.NewAPIKeyService(db.NewAPIKeyRepository(gormDB), hasher),
+	}
+	a.service = NewService(
+		&Config{},
+		a.queue,
+		a.projects,
+		a.tokens,
+		a.signing,
+		a.subscriptions,
+		a.notifications,
+		domain.NewUserService(db.NewUserRepository(gormDB), subscriptionRepo),
+		a.apiKeys,
+		deletionNotifierStub{},
+	)
+	return a
+}
+
+// newProject creates a project with the subscribers and a token allowing every action
+func (a *testAPI) newProject(t *testing.T, subscribers int) (*domain.Project, *domain.ProjectToken) {
+	project, err := a.projects.Create(1, "Deployments")
+	require.NoError(t, err)
+	for i := 0; i < subscribers; i++ {
+		require.NoError(t, a.subscriptions.Subscribe(domain.TelegramUserID(100+i), project.ID))
+	}
+	token, err := a.tokens.Create(project.ID, domain.TokenOptions{Name: domain.DefaultTokenName})
+	require.NoError(t, err)
+	return project, token
+}
+
+// newAPIKey issues an API key of the publisher for the management API
+func (a *testAPI) newAPIKey(t *testing.T, publisherID domain.TelegramUserID) string {
+	key, err := a.apiKeys.Issue(publisherID)
+	require.NoError(t, err)
+	return key.Key
+}
+
+// serve handles the request, authenticated with the token if there is one
+func (a *testAPI) serve(method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.service.routes().ServeHTTP(w, r)
+	return w
+}
+
+func TestService_HandleNotify(t *testing.T) {
+	tests := []struct {
+		name            string
+		subscribers     int
+		projectCapacity int
+		wantStatus      int
+		wantQueued      int
+		wantHeld        int
+	}{
+		{"queued", 3, 5, http.StatusOK, 3, 0},
+		// Queuing the messages that fit and failing would send them again when the request is retried
+		{"more messages than the lane holds", 3, 2, http.StatusAccepted, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, &queue.Config{Capacity: 10, ProjectCapacity: tt.projectCapacity, Rate: 30})
+			_, token := a.newProject(t, tt.subscribers)
+
+			w := a.serve(http.MethodPost, "/api/notify", token.Token, `{"body": "Deployed"}`)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, tt.wantQueued, a.queue.Stats().Depth)
+			held, err := a.notifications.GetDueHeld(time.Now().Add(time.Minute), 10)
+			require.NoError(t, err)
+			assert.Len(t, held, tt.wantHeld)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Headers of signed requests
+const (
+	headerProject   = "X-Noteo-Project"
+	headerTimestamp = "X-Noteo-Timestamp"
+	headerNonce     = "X-Noteo-Nonce"
+	headerSignature = "X-Noteo-Signature"
+)
+
+const (
+	// maxSignedBodySize limits the body read to check the signature
+	maxSignedBodySize = 1 << 20
+	// maxNonceLength limits the nonces remembered for every request
+	maxNonceLength = 128
+)
+
+// isSigned returns true if the client signed the request instead of sending a bearer token
+func isSigned(r *http.Request) bool {
+	return r.Header.Get(headerSignature) != ""
+}
+
+// authenticateSigned returns the project whose secret the request is signed with if the secret allows the action,
+// writing an error response if the signature is wrong, too old or replayed. The body is read to check the signature
+// and replaced, so that handlers can read it as usual.
+func (s *Service) authenticateSigned(w http.ResponseWriter, r *http.Request, scope domain.TokenScope) (*domain.Project, bool) {
+	projectID, err := uuid.Parse(r.Header.Get(headerProject))
+	if err != nil {
+		http.Error(w, "Missing or invalid "+headerProject+" header", http.StatusUnauthorized)
+		return nil, false
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(headerTimestamp), 10, 64)
+	if err != nil {
+		http.Error(w, "Missing or invalid "+headerTimestamp+" header", http.StatusUnauthorized)
+		return nil, false
+	}
+	nonce := r.Header.Get(headerNonce)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		http.Error(w, "Missing or invalid "+headerNonce+" header", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	project, err := s.signingService.Verify(projectID, domain.SignedRequest{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Timestamp: time.Unix(timestamp, 0),
+		Nonce:     nonce,
+		Body:      body,
+	}, r.Header.Get(headerSignature), scope, time.Now())
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrSignatureScope):
+		slog.Warn("Rejected signed request", "error", err, "scope", scope, "projectId", projectID, "addr", s.clientAddr(r))
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	case errors.Is(err, domain.ErrRequestReplayed):
+		slog.Warn("Rejected replayed request", "projectId", projectID, "addr", s.clientAddr(r))
+		http.Error(w, "Request has already been received", http.StatusUnauthorized)
+		return nil, false
+	case errors.Is(err, domain.ErrInvalidSignature), errors.Is(err, domain.ErrSignatureExpired):
+		slog.Warn("Rejected signed request", "error", err, "projectId", projectID, "addr", s.clientAddr(r))
+		// Details like a signing secret that can't be decrypted are only logged
+		message := domain.ErrInvalidSignature.Error()
+		if errors.Is(err, domain.ErrSignatureExpired) {
+			message = domain.ErrSignatureExpired.Error()
+		}
+		http.Error(w, message, http.StatusUnauthorized)
+		return nil, false
+	default:
+		slog.Error("Failed to verify signed request", "error", err, "projectId", projectID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return project, true
+}
+
+// handleGenerateSigningSecret creates a new signing secret for the project, replacing the previous one.
+// The response is the only place the secret is shown.
+func (s *Service) handleGenerateSigningSecret(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	secret, err := s.signingService.Generate(project.ID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project signing secret generated through the api", "projectId", project.ID)
+	writeJSON(w, http.StatusCreated, struct {
+		Secret string `json:"secret"`
+	}{secret})
+}
+
+// handleRemoveSigningSecret removes the signing secret of the project, which then accepts bearer tokens again
+func (s *Service) handleRemoveSigningSecret(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	if err := s.signingService.Remove(project.ID); err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project signing secret removed through the api", "projectId", project.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeax/noteo/internal/app/db"
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// serveSigned sends a heartbeat of the project signed with the secret
+func (a *testAPI) serveSigned(project *domain.Project, secret string, timestamp time.Time, nonce string) *httptest.ResponseRecorder {
+	signature := domain.SignRequest(secret, domain.SignedRequest{
+		Method:    http.MethodPost,
+		Path:      "/api/heartbeat",
+		Timestamp: timestamp,
+		Nonce:     nonce,
+	})
+	r := httptest.NewRequest(http.MethodPost, "/api/heartbeat", nil)
+	r.Header.Set(headerProject, project.ID.String())
+	r.Header.Set(headerTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	r.Header.Set(headerNonce, nonce)
+	r.Header.Set(headerSignature, signature)
+	w := httptest.NewRecorder()
+	a.service.routes().ServeHTTP(w, r)
+	return w
+}
+
+func TestService_AuthenticateSigned(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string // Empty means the secret of the project
+		age    time.Duration
+		scopes []domain.TokenScope
+		// replay sends the same request before, restart forgets everything kept in memory in between
+		replay     bool
+		restart    bool
+		wantStatus int
+	}{
+		{"valid", "", 0, nil, false, false, http.StatusNoContent},
+		{"bad signature", "other secret", 0, nil, false, false, http.StatusUnauthorized},
+		{"stale", "", domain.SignatureWindow + time.Minute, nil, false, false, http.StatusUnauthorized},
+		{"refused scope", "", 0, []domain.TokenScope{domain.TokenScopeNotify}, false, false, http.StatusForbidden},
+		{"replayed nonce", "", 0, nil, true, false, http.StatusUnauthorized},
+		{"replayed nonce after a restart", "", 0, nil, true, true, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil)
+			project, _ := a.newProject(t, 0)
+			secret, err := a.signing.Generate(project.ID)
+			require.NoError(t, err)
+			project, err = a.projects.GetByID(project.ID)
+			require.NoError(t, err)
+			if tt.scopes != nil {
+				require.NoError(t, a.signing.SetScopes(project, tt.scopes))
+			}
+			if tt.secret != "" {
+				secret = tt.secret
+			}
+			timestamp := time.Now().Add(-tt.age)
+
+			if tt.replay {
+				w := a.serveSigned(project, secret, timestamp, "8f14e45f")
+				require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+			}
+			if tt.restart {
+				a.service.signingService = domain.NewSigningService(
+					db.NewProjectRepository(a.db), db.NewNonceRepository(a.db), domain.NewSecretBox([]byte("secret")))
+			}
+			w := a.serveSigned(project, secret, timestamp, "8f14e45f")
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			// Another nonce is a new request
+			if tt.replay {
+				w = a.serveSigned(project, secret, timestamp, "c9f0f895")
+				assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+			}
+		})
+	}
+}
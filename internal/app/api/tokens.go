@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// tokenResponse describes a token, the full token is only included right after it is created or rotated
+type tokenResponse struct {
+	ID         uuid.UUID           `json:"id"`
+	Name       string              `json:"name"`
+	Token      string              `json:"token,omitempty"`
+	Prefix     string              `json:"prefix"`
+	Scopes     []domain.TokenScope `json:"scopes"`
+	AllowedIPs []netip.Prefix      `json:"allowed_ips"`
+	ExpiresAt  *time.Time          `json:"expires_at"`
+	LastUsedAt *time.Time          `json:"last_used_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+	Expired    bool                `json:"expired"`
+}
+
+func tokenFromDomain(t *domain.ProjectToken) tokenResponse {
+	return tokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Token:      t.Token,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		AllowedIPs: append([]netip.Prefix{}, t.AllowedIPs...),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+		Expired:    t.Expired(time.Now()),
+	}
+}
+
+// nullableTime tells an omitted field from an explicit null
+type nullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *nullableTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &t.Value)
+}
+
+// parseAllowedIPs parses the addresses and networks a token is limited to
+func parseAllowedIPs(list []string) ([]netip.Prefix, error) {
+	return domain.ParseAllowedIPs(strings.Join(list, ","))
+}
+
+// ownedToken returns the token from the request path, writing an error response
+// if it doesn't exist or belongs to another project or publisher
+func (s *Service) ownedToken(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) (*domain.ProjectToken, bool) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return nil, false
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "token not found")
+		return nil, false
+	}
+
+	token, err := s.tokenService.GetByID(tokenID)
+	if err != nil {
+		writeProjectError(w, err)
+		return nil, false
+	}
+	if token.ProjectID != project.ID {
+		writeError(w, http.StatusNotFound, "token not found")
+		return nil, false
+	}
+	return token, true
+}
+
+func (s *Service) handleListTokens(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	tokens, err := s.tokenService.GetByProject(project.ID)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	response := make([]tokenResponse, len(tokens))
+	for i, token := range tokens {
+		response[i] = tokenFromDomain(token)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleCreateToken creates a token for the project, omitted scopes allow everything
+func (s *Service) handleCreateToken(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	project, ok := s.ownedProject(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name       string              `json:"name"`
+		Scopes     []domain.TokenScope `json:"scopes"`
+		AllowedIPs []string            `json:"allowed_ips"`
+		ExpiresAt  *time.Time          `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	options := domain.TokenOptions{Name: request.Name, Scopes: request.Scopes}
+	allowedIPs, err := parseAllowedIPs(request.AllowedIPs)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	options.AllowedIPs = allowedIPs
+	if request.ExpiresAt != nil {
+		options.TTL = time.Until(*request.ExpiresAt)
+		if options.TTL <= 0 {
+			writeError(w, http.StatusBadRequest, "expires_at must be in the future")
+			return
+		}
+	}
+
+	token, err := s.tokenService.Create(project.ID, options)
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project token created through the api", "projectId", project.ID, "tokenId", token.ID)
+	writeJSON(w, http.StatusCreated, tokenFromDomain(token))
+}
+
+// handleUpdateToken changes the settings of the token, omitted fields are kept and a null expiry removes it
+func (s *Service) handleUpdateToken(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	token, ok := s.ownedToken(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name       *string             `json:"name"`
+		Scopes     []domain.TokenScope `json:"scopes"`
+		AllowedIPs []string            `json:"allowed_ips"`
+		ExpiresAt  nullableTime        `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if request.Name != nil {
+		token.Name = *request.Name
+	}
+	if request.Scopes != nil {
+		token.Scopes = request.Scopes
+	}
+	if request.AllowedIPs != nil {
+		allowedIPs, err := parseAllowedIPs(request.AllowedIPs)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+		token.AllowedIPs = allowedIPs
+	}
+	if request.ExpiresAt.Set {
+		if request.ExpiresAt.Value != nil && !request.ExpiresAt.Value.After(time.Now()) {
+			writeError(w, http.StatusBadRequest, "expires_at must be in the future")
+			return
+		}
+		token.ExpiresAt = request.ExpiresAt.Value
+	}
+
+	if err := s.tokenService.Update(token); err != nil {
+		writeProjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokenFromDomain(token))
+}
+
+func (s *Service) handleRevokeToken(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	token, ok := s.ownedToken(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	if err := s.tokenService.Revoke(token.ID); err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project token revoked through the api", "projectId", token.ProjectID, "tokenId", token.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRotateToken replaces the token with a new one, the old token keeps working during the grace period
+func (s *Service) handleRotateToken(w http.ResponseWriter, r *http.Request, publisherID domain.TelegramUserID) {
+	token, ok := s.ownedToken(w, r, publisherID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		// Grace is a duration like 24h, omitted means the old token stops working immediately
+		Grace string `json:"grace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var grace time.Duration
+	if request.Grace != "" {
+		var err error
+		grace, err = time.ParseDuration(request.Grace)
+		if err != nil || grace < 0 {
+			writeError(w, http.StatusBadRequest, "grace must be a duration like 24h")
+			return
+		}
+	}
+
+	rotated, err := s.tokenService.Rotate(token.ID, grace, time.Now())
+	if err != nil {
+		writeProjectError(w, err)
+		return
+	}
+
+	slog.Info("Project token rotated through the api",
+		"projectId", token.ProjectID, "tokenId", token.ID, "newTokenId", rotated.ID, "grace", grace)
+	writeJSON(w, http.StatusCreated, tokenFromDomain(rotated))
+}
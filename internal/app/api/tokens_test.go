@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestService_AuthenticateToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token the heartbeat is sent with
+		token      func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string
+		wantStatus int
+	}{
+		{"allowed", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			return token.Token
+		}, http.StatusNoContent},
+		{"missing", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			return ""
+		}, http.StatusUnauthorized},
+		{"unknown", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			return "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+		}, http.StatusUnauthorized},
+		{"refused scope", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			notifier, err := a.tokens.Create(project.ID, domain.TokenOptions{
+				Name:   "CI",
+				Scopes: []domain.TokenScope{domain.TokenScopeNotify},
+			})
+			require.NoError(t, err)
+			return notifier.Token
+		}, http.StatusForbidden},
+		{"refused address", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			token.AllowedIPs = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+			require.NoError(t, a.tokens.Update(token))
+			return token.Token
+		}, http.StatusForbidden},
+		{"expired", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			expired, err := a.tokens.Create(project.ID, domain.TokenOptions{Name: "CI", TTL: time.Hour})
+			require.NoError(t, err)
+			require.NoError(t, a.db.Exec("UPDATE project_tokens SET expires_at = ? WHERE id = ?",
+				time.Now().Add(-time.Minute).UTC(), expired.ID).Error)
+			return expired.Token
+		}, http.StatusUnauthorized},
+		{"rotated within the grace period", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			_, err := a.tokens.Rotate(token.ID, time.Hour, time.Now())
+			require.NoError(t, err)
+			return token.Token
+		}, http.StatusNoContent},
+		{"rotated after the grace period", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			_, err := a.tokens.Rotate(token.ID, time.Hour, time.Now().Add(-2*time.Hour))
+			require.NoError(t, err)
+			return token.Token
+		}, http.StatusUnauthorized},
+		{"replacing a rotated one", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			rotated, err := a.tokens.Rotate(token.ID, 0, time.Now())
+			require.NoError(t, err)
+			return rotated.Token
+		}, http.StatusNoContent},
+		{"signed requests required", func(t *testing.T, a *testAPI, project *domain.Project, token *domain.ProjectToken) string {
+			_, err := a.signing.Generate(project.ID)
+			require.NoError(t, err)
+			project, err = a.projects.GetByID(project.ID)
+			require.NoError(t, err)
+			require.NoError(t, a.signing.SetRequired(project, true))
+			return token.Token
+		}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil)
+			project, token := a.newProject(t, 0)
+
+			w := a.serve(http.MethodPost, "/api/heartbeat", tt.token(t, a, project, token), "")
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			project, err := a.projects.GetByID(project.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus == http.StatusNoContent, project.LastHeartbeatAt != nil)
+		})
+	}
+}
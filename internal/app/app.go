@@ -10,6 +10,7 @@ import (
 	"github.com/sergeax/noteo/internal/app/api"
 	"github.com/sergeax/noteo/internal/app/bot"
 	"github.com/sergeax/noteo/internal/app/queue"
+	"github.com/sergeax/noteo/internal/app/scheduler"
 )
 
 type App struct {
@@ -49,6 +50,7 @@ func (a *App) Run() error {
 		apiService *api.Service,
 		botService *bot.Service,
 		messageQueue *queue.Queue,
+		schedulerService *scheduler.Service,
 	) error {
 		// Setup signal handling for graceful shutdown
 		ctx, cancel := context.WithCancel(context.Background())
@@ -61,6 +63,9 @@ func (a *App) Run() error {
 		// Start message queue
 		messageQueue.Start()
 
+		// Start scheduled jobs
+		schedulerService.Start()
+
 		// Start bot service
 		go botService.Start()
 
@@ -86,6 +91,7 @@ func (a *App) Run() error {
 		if err := apiService.Stop(); err != nil {
 			slog.Error("Error shutting down API service", "error", err)
 		}
+		schedulerService.Stop()
 		messageQueue.Stop()
 		slog.Info("Shutdown complete")
 
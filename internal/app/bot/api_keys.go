@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for managing the publisher's API key
+var (
+	btnAPIKey                  = telebot.InlineButton{Unique: "api_key", Text: "🔑 API key"}
+	btnIssueAPIKey             = telebot.InlineButton{Unique: "issue_api_key", Text: "➕ Issue API key"}
+	btnRegenerateAPIKey        = telebot.InlineButton{Unique: "regenerate_api_key", Text: "🔄 Regenerate"}
+	btnConfirmRegenerateAPIKey = telebot.InlineButton{Unique: "confirm_regenerate_api_key", Text: "✅ Yes, regenerate"}
+	btnRevokeAPIKey            = telebot.InlineButton{Unique: "revoke_api_key", Text: "🗑 Revoke"}
+	btnConfirmRevokeAPIKey     = telebot.InlineButton{Unique: "confirm_revoke_api_key", Text: "✅ Yes, revoke"}
+)
+
+type apiKeysHandler struct {
+	service *Service
+}
+
+func newAPIKeysHandler(s *Service) *apiKeysHandler {
+	return &apiKeysHandler{service: s}
+}
+
+func (h *apiKeysHandler) register() {
+	h.service.bot.Handle(&btnAPIKey, h.handleAPIKey)
+	h.service.bot.Handle(&btnIssueAPIKey, h.handleIssueAPIKey)
+	h.service.bot.Handle(&btnRegenerateAPIKey, h.handleRegenerateAPIKey)
+	h.service.bot.Handle(&btnConfirmRegenerateAPIKey, h.handleIssueAPIKey)
+	h.service.bot.Handle(&btnRevokeAPIKey, h.handleRevokeAPIKey)
+	h.service.bot.Handle(&btnConfirmRevokeAPIKey, h.handleConfirmRevokeAPIKey)
+}
+
+// apiKeyShownOnce warns that only the hash of a new API key is kept
+const apiKeyShownOnce = "⚠️ Copy the key now, it won't be shown again."
+
+// showAPIKey replaces the callback message with the publisher's API key.
+// The full key is only given right after it is issued, otherwise its prefix is shown.
+func (h *apiKeysHandler) showAPIKey(c *telebot.Callback, issued *domain.APIKey) {
+	l := h.service.userLocale(c.Sender)
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+
+	key := issued
+	if key == nil {
+		var err error
+		key, err = h.service.apiKeyService.Get(userID)
+		if err != nil && !errors.Is(err, domain.ErrAPIKeyNotFound) {
+			slog.Error("Failed to get API key", "error", err, "user_id", userID)
+			h.service.bot.Send(c.Sender, l.T("Sorry, failed to get your API key. Please try again."))
+			return
+		}
+	}
+
+	message := l.T("🔑 <b>API key</b>\n\n" +
+		"The API key lets scripts and CI manage your projects through the management API at <code>/api/v1/projects</code>. " +
+		"Send it in the <code>Authorization: Bearer</code> header.")
+
+	var keyboard [][]telebot.InlineButton
+	if key == nil {
+		message += "\n\n" + l.T("You don't have an API key yet.")
+		keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnIssueAPIKey)})
+	} else {
+		lastUsed := l.T("never")
+		if key.LastUsedAt != nil {
+			lastUsed = h.service.formatTime(*key.LastUsedAt, userID)
+		}
+		shown := key.Prefix + "…"
+		if key.Key != "" {
+			shown = key.Key
+		}
+		message += "\n\n" + l.T("<b>Key:</b> <code>%s</code>\n<b>Issued:</b> %s\n<b>Last used:</b> %s",
+			shown, h.service.formatTime(key.CreatedAt, userID), lastUsed)
+		if key.Key != "" {
+			message += "\n\n" + l.T(apiKeyShownOnce)
+		}
+		keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnRegenerateAPIKey), l.Button(btnRevokeAPIKey)})
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnProjectsList)})
+
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to update API key message", "error", err)
+	}
+}
+
+// handleAPIKey shows the publisher's API key
+func (h *apiKeysHandler) handleAPIKey(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showAPIKey(c, nil)
+}
+
+// handleIssueAPIKey issues a new API key, replacing the existing one
+func (h *apiKeysHandler) handleIssueAPIKey(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+
+	key, err := h.service.apiKeyService.Issue(userID)
+	if err != nil {
+		slog.Error("Failed to issue API key", "error", err, "user_id", userID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to issue API key. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("API key issued")})
+	h.showAPIKey(c, key)
+}
+
+// handleRegenerateAPIKey asks the publisher to confirm API key regeneration
+func (h *apiKeysHandler) handleRegenerateAPIKey(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	message := l.T("Regenerate your API key?\n\n" +
+		"The current key will stop working immediately, so you will have to update it everywhere it is used.")
+	h.confirm(c, message, btnConfirmRegenerateAPIKey)
+}
+
+// handleRevokeAPIKey asks the publisher to confirm API key revocation
+func (h *apiKeysHandler) handleRevokeAPIKey(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	message := l.T("Revoke your API key?\n\nThe management API will reject it immediately.")
+	h.confirm(c, message, btnConfirmRevokeAPIKey)
+}
+
+// confirm replaces the callback message with a question and buttons confirming or cancelling the action
+func (h *apiKeysHandler) confirm(c *telebot.Callback, message string, confirm telebot.InlineButton) {
+	l := h.service.userLocale(c.Sender)
+	backBtn := btnAPIKey
+	backBtn.Text = "↩️ Back"
+	markup := l.Markup(&telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{confirm, backBtn},
+		},
+	})
+	if _, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup); err != nil {
+		slog.Error("Failed to show API key confirmation", "error", err)
+	}
+}
+
+// handleConfirmRevokeAPIKey revokes the publisher's API key
+func (h *apiKeysHandler) handleConfirmRevokeAPIKey(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+
+	if err := h.service.apiKeyService.Revoke(userID); err != nil {
+		slog.Error("Failed to revoke API key", "error", err, "user_id", userID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to revoke API key. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("API key revoked")})
+	h.showAPIKey(c, nil)
+}
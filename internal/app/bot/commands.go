@@ -0,0 +1,487 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// botCommand is a command shown in the command menu of Telegram clients
+type botCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// privateCommands are offered in private chats with the bot, where all of them work
+var privateCommands = []botCommand{
+	{"projects", "Your projects"},
+	{"newproject", "Create a project: /newproject <name>"},
+	{"token", "Show the tokens of a project: /token <project>"},
+	{"subscriptions", "Your subscriptions"},
+	{"mute", "Mute a subscription: /mute <project> [duration]"},
+	{"pause", "Pause a subscription: /pause <project> <duration>"},
+	{"resume", "Unmute and resume a subscription: /resume <project>"},
+	{"cancel", "Cancel the current action"},
+	{"help", "How to use the bot"},
+}
+
+// groupCommands are offered in groups, where the bot only explains how to reach it
+var groupCommands = []botCommand{
+	{"help", "How to use the bot"},
+}
+
+// helpText describes the commands, it is sent as HTML
+const helpText = `<b>Noteo</b> delivers notifications from the projects you subscribe to and lets you publish your own.
+
+/projects — your projects
+/newproject &lt;name&gt; — create a project
+/token &lt;project&gt; — show the tokens of a project
+/subscriptions — your subscriptions
+/mute &lt;project&gt; [duration] — mute a subscription, until you resume it or for a while
+/pause &lt;project&gt; &lt;duration&gt; — pause a subscription
+/resume &lt;project&gt; — unmute and resume a subscription
+/cancel — cancel the current action
+
+Project names don't have to be exact, a part of the name is enough. Durations look like 45m, 3h, 2d, 18:30, tomorrow 9:00, friday or 2025-03-10.`
+
+type commandsHandler struct {
+	service *Service
+}
+
+func newCommandsHandler(s *Service) *commandsHandler {
+	return &commandsHandler{service: s}
+}
+
+func (h *commandsHandler) register() {
+	h.service.bot.Handle("/help", h.handleHelp)
+	h.service.bot.Handle("/projects", h.private(h.service.projects.handleMyProjects))
+	h.service.bot.Handle("/newproject", h.private(h.handleNewProject))
+	h.service.bot.Handle("/token", h.private(h.handleToken))
+	h.service.bot.Handle("/subscriptions", h.private(h.service.subscriptions.handleMySubscriptions))
+	h.service.bot.Handle("/mute", h.private(h.handleMute))
+	h.service.bot.Handle("/pause", h.private(h.handlePause))
+	h.service.bot.Handle("/resume", h.private(h.handleResume))
+}
+
+// setCommands registers the command menus of private chats and groups with Telegram,
+// in the default locale for all users and translated for the users of the other locales.
+// Telebot has no method for that, so the API is called directly.
+func (s *Service) setCommands() error {
+	scopes := []struct {
+		scope    string
+		commands []botCommand
+	}{
+		{`{"type":"all_private_chats"}`, privateCommands},
+		{`{"type":"all_group_chats"}`, groupCommands},
+	}
+
+	for i, l := range locales {
+		for _, sc := range scopes {
+			translated := make([]botCommand, len(sc.commands))
+			for j, command := range sc.commands {
+				translated[j] = botCommand{Command: command.Command, Description: l.T(command.Description)}
+			}
+			commands, err := json.Marshal(translated)
+			if err != nil {
+				return fmt.Errorf("failed to encode commands: %w", err)
+			}
+			params := map[string]string{
+				"commands": string(commands),
+				"scope":    sc.scope,
+			}
+			if i > 0 {
+				params["language_code"] = l.Language
+			}
+			if err := s.callAPI("setMyCommands", params); err != nil {
+				return fmt.Errorf("failed to set commands for language %s: %w", l.Language, err)
+			}
+		}
+	}
+	return nil
+}
+
+// private makes a command work in private chats only, in groups the user is asked to message the bot.
+// A command also cancels the wizard the user is going through.
+func (h *commandsHandler) private(handler func(m *telebot.Message)) func(m *telebot.Message) {
+	return func(m *telebot.Message) {
+		if !m.Private() {
+			h.replyInGroup(m)
+			return
+		}
+		h.service.stateManager.ClearState(m.Sender.ID)
+		handler(m)
+	}
+}
+
+// replyInGroup points the user of a group to the private chat with the bot
+func (h *commandsHandler) replyInGroup(m *telebot.Message) {
+	_, err := h.service.bot.Send(m.Chat, h.service.userLocale(m.Sender).T(
+		"I work in a private chat, please message me at https://t.me/%s", h.service.bot.Me.Username))
+	if err != nil {
+		slog.Error("Failed to reply in group", "error", err, "chat_id", m.Chat.ID)
+	}
+}
+
+func (h *commandsHandler) handleHelp(m *telebot.Message) {
+	if !m.Private() {
+		h.replyInGroup(m)
+		return
+	}
+	l := h.service.userLocale(m.Sender)
+	h.service.bot.Send(m.Sender, l.T(helpText), &telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+}
+
+// handleNewProject creates a project with the given name, or asks for one
+func (h *commandsHandler) handleNewProject(m *telebot.Message) {
+	var answers []string
+	if name := strings.TrimSpace(m.Payload); name != "" {
+		answers = append(answers, name)
+	}
+	h.service.wizards.start(m.Sender, nil, wizardCreateProject, StateData{}, answers...)
+}
+
+// handleToken shows the tokens of a project of the publisher
+func (h *commandsHandler) handleToken(m *telebot.Message) {
+	l := h.service.userLocale(m.Sender)
+	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
+	projects, err := h.service.projectService.GetByPublisher(userID)
+	if err != nil {
+		slog.Error("Failed to get projects", "error", err, "user_id", userID)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get your projects. Please try again."), l.Markup(mainMenu))
+		return
+	}
+	if len(projects) == 0 {
+		h.service.bot.Send(m.Sender, l.T("You don't have any projects yet. Create one with /newproject <name>."), l.Markup(projectsMenu))
+		return
+	}
+
+	names := make([]string, len(projects))
+	for i, project := range projects {
+		names[i] = project.Name
+	}
+
+	query := strings.TrimSpace(m.Payload)
+	matches := matchNames(names, query)
+	if len(matches) != 1 {
+		var candidates []*domain.Project
+		for _, i := range matches {
+			candidates = append(candidates, projects[i])
+		}
+		h.sendProjectCandidates(m, query, candidates, btnManageProject, projectsMenu)
+		return
+	}
+
+	project := projects[matches[0]]
+	message, markup, err := h.service.tokens.createTokensView(l, project)
+	if err != nil {
+		slog.Error("Failed to show project tokens", "error", err, "project_id", project.ID)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get the tokens. Please try again."), l.Markup(projectsMenu))
+		return
+	}
+	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+}
+
+// sendProjectCandidates tells the user that no project or several projects match the query,
+// offering the matching ones as buttons
+func (h *commandsHandler) sendProjectCandidates(
+	m *telebot.Message,
+	query string,
+	candidates []*domain.Project,
+	btn telebot.InlineButton,
+	menu *telebot.ReplyMarkup,
+) {
+	l := h.service.userLocale(m.Sender)
+	menu = l.Markup(menu)
+	if len(candidates) == 0 {
+		h.service.bot.Send(m.Sender, l.T("No project matches “%s”.", html.EscapeString(query)),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML}, menu)
+		return
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for _, project := range candidates {
+		projectBtn := btn
+		projectBtn.Text = project.Name
+		projectBtn.Data = project.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{projectBtn})
+	}
+	keyboard = append(keyboard, menu.InlineKeyboard...)
+
+	message := l.T("Which project do you mean?")
+	if query != "" {
+		message = l.T("Several projects match “%s”, which one do you mean?", html.EscapeString(query))
+	}
+	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+}
+
+// findSubscription finds the subscription named in the arguments of a command, followed by a duration.
+// If it isn't found the user is told so, and ok is false.
+func (h *commandsHandler) findSubscription(m *telebot.Message, durationOptional bool) (
+	sub *domain.Subscription, project *domain.Project, until *time.Time, ok bool,
+) {
+	l := h.service.userLocale(m.Sender)
+	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
+	subs, err := h.service.subscriptionService.GetUserSubscriptions(userID)
+	if err != nil {
+		slog.Error("Failed to get subscriptions", "error", err, "user_id", userID)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get your subscriptions. Please try again."), l.Markup(mainMenu))
+		return nil, nil, nil, false
+	}
+
+	var projects []*domain.Project
+	var names []string
+	for _, s := range subs {
+		p, err := h.service.projectService.GetByID(s.ProjectID)
+		if err != nil {
+			slog.Error("Failed to get project details", "error", err, "project_id", s.ProjectID)
+			continue
+		}
+		projects = append(projects, p)
+		names = append(names, p.Name)
+	}
+	if len(projects) == 0 {
+		h.service.bot.Send(m.Sender, l.T("You don't have any subscriptions yet."), l.Markup(mainMenu))
+		return nil, nil, nil, false
+	}
+
+	now := time.Now().In(h.service.userLocation(userID))
+	query, until := splitNameAndUntil(m.Payload, now, names, durationOptional)
+	if !durationOptional && until == nil {
+		h.service.bot.Send(m.Sender, l.T("Please add for how long, like /pause backend 2h.")+" "+l.T(untilFormats),
+			l.Markup(subscriptionsMenu))
+		return nil, nil, nil, false
+	}
+
+	matches := matchNames(names, query)
+	if len(matches) != 1 {
+		var candidates []*domain.Project
+		for _, i := range matches {
+			candidates = append(candidates, projects[i])
+		}
+		h.sendProjectCandidates(m, query, candidates, btnManageSubscription, subscriptionsMenu)
+		return nil, nil, nil, false
+	}
+
+	project = projects[matches[0]]
+	for _, s := range subs {
+		if s.ProjectID == project.ID {
+			return s, project, until, true
+		}
+	}
+	return nil, nil, nil, false
+}
+
+// untilFormats lists the accepted durations
+const untilFormats = "Durations look like 45m, 3h, 2d, 18:30, tomorrow 9:00, friday or 2025-03-10."
+
+// sendSubscriptionStatus sends the current status of a subscription after a command changed it
+func (h *commandsHandler) sendSubscriptionStatus(m *telebot.Message, header string, project *domain.Project) {
+	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
+	sub, project, err := h.service.subscriptionManagement.findSubscription(userID, project.ID)
+	if err != nil {
+		slog.Error("Failed to find subscription", "error", err)
+		h.service.bot.Send(m.Sender, header, h.service.userLocale(m.Sender).Markup(subscriptionsMenu))
+		return
+	}
+
+	h.service.bot.Send(m.Sender, header+"\n\n"+h.service.subscriptionManagement.createStatusMessage(sub, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		h.service.subscriptionManagement.createSubscriptionButtons(sub, project.ID))
+}
+
+// handleMute mutes a subscription, for the given duration if any
+func (h *commandsHandler) handleMute(m *telebot.Message) {
+	sub, project, until, ok := h.findSubscription(m, true)
+	if !ok {
+		return
+	}
+
+	l := h.service.userLocale(m.Sender)
+	userID := sub.UserID
+	var err error
+	header := l.T("✅ Muted.")
+	if until != nil {
+		err = h.service.subscriptionService.MuteNotificationsUntil(userID, project.ID, *until)
+		header = "✅ " + untilConfirmation(l, durationKindMute, *until) + "."
+	} else {
+		err = h.service.subscriptionService.MuteNotifications(userID, project.ID)
+	}
+	if err != nil {
+		slog.Error("Failed to mute subscription", "error", err, "project_id", project.ID)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to mute subscription. Please try again."), l.Markup(subscriptionsMenu))
+		return
+	}
+	h.sendSubscriptionStatus(m, header, project)
+}
+
+// handlePause pauses a subscription for the given duration
+func (h *commandsHandler) handlePause(m *telebot.Message) {
+	sub, project, until, ok := h.findSubscription(m, false)
+	if !ok {
+		return
+	}
+
+	l := h.service.userLocale(m.Sender)
+	if err := h.service.subscriptionService.PauseNotifications(sub.UserID, project.ID, *until); err != nil {
+		slog.Error("Failed to pause subscription", "error", err, "project_id", project.ID)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to pause subscription. Please try again."), l.Markup(subscriptionsMenu))
+		return
+	}
+	h.sendSubscriptionStatus(m, "✅ "+untilConfirmation(l, durationKindPause, *until)+".", project)
+}
+
+// handleResume unmutes and resumes a subscription, sending the notifications held during the pause
+func (h *commandsHandler) handleResume(m *telebot.Message) {
+	sub, project, _, ok := h.findSubscription(m, true)
+	if !ok {
+		return
+	}
+
+	l := h.service.userLocale(m.Sender)
+	if !sub.IsMuted() && !sub.Paused() {
+		h.sendSubscriptionStatus(m, l.T("Notifications are not muted or paused."), project)
+		return
+	}
+
+	if sub.IsMuted() {
+		if err := h.service.subscriptionService.UnmuteNotifications(sub.UserID, project.ID); err != nil {
+			slog.Error("Failed to unmute subscription", "error", err, "project_id", project.ID)
+			h.service.bot.Send(m.Sender, l.T("Sorry, failed to unmute subscription. Please try again."), l.Markup(subscriptionsMenu))
+			return
+		}
+	}
+	if sub.Paused() {
+		if err := h.service.subscriptionService.ResumeNotifications(sub.UserID, project.ID); err != nil {
+			slog.Error("Failed to resume subscription", "error", err, "project_id", project.ID)
+			h.service.bot.Send(m.Sender, l.T("Sorry, failed to resume subscription. Please try again."), l.Markup(subscriptionsMenu))
+			return
+		}
+	}
+
+	h.sendSubscriptionStatus(m, l.T("✅ Resumed."), project)
+	if sub.Paused() {
+		h.service.digests.sendResumeSummary(sub.UserID, project.ID)
+	}
+}
+
+// Quality of a project name match, better matches have higher values
+const (
+	matchNone = iota
+	matchTypo
+	matchSubstring
+	matchPrefix
+	matchExact
+)
+
+// matchQuality tells how well a name matches a query, ignoring case and extra spaces.
+// An empty query is a prefix of every name.
+func matchQuality(name, query string) int {
+	name, query = normalizeName(name), normalizeName(query)
+	switch {
+	case name == query:
+		return matchExact
+	case strings.HasPrefix(name, query):
+		return matchPrefix
+	case strings.Contains(name, query):
+		return matchSubstring
+	}
+
+	// Allow a typo per three characters in queries long enough to be told apart
+	length := utf8.RuneCountInString(query)
+	if length >= 3 && levenshtein(name, query) <= length/3 {
+		return matchTypo
+	}
+	return matchNone
+}
+
+// matchNames returns the indices of the names matching the query best
+func matchNames(names []string, query string) []int {
+	best := matchNone
+	var matches []int
+	for i, name := range names {
+		quality := matchQuality(name, query)
+		switch {
+		case quality == matchNone || quality < best:
+			continue
+		case quality > best:
+			best = quality
+			matches = matches[:0]
+		}
+		matches = append(matches, i)
+	}
+	return matches
+}
+
+// splitNameAndUntil splits the arguments of a command into a project name and the duration following it.
+// The duration may have several words, like "tomorrow 9:00", so the split whose name matches best is used.
+// If the duration is optional, the arguments may be the name only.
+func splitNameAndUntil(args string, now time.Time, names []string, durationOptional bool) (string, *time.Time) {
+	words := strings.Fields(args)
+
+	bestName, bestQuality := strings.Join(words, " "), matchNone
+	var bestUntil *time.Time
+	if !durationOptional {
+		bestQuality = -1
+	}
+
+	// Longer names are tried first, so they win over equally good shorter ones
+	for i := len(words); i >= 0; i-- {
+		name := strings.Join(words[:i], " ")
+		var until *time.Time
+		if i < len(words) {
+			t, err := domain.ParseUntil(strings.Join(words[i:], " "), now)
+			if err != nil {
+				continue
+			}
+			until = &t
+		} else if !durationOptional {
+			continue
+		}
+
+		quality := matchNone
+		for _, n := range names {
+			quality = max(quality, matchQuality(n, name))
+		}
+		if quality > bestQuality {
+			bestName, bestQuality, bestUntil = name, quality, until
+		}
+	}
+	return bestName, bestUntil
+}
+
+// normalizeName lowercases a name and collapses its spaces
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// levenshtein returns the number of single character edits turning a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
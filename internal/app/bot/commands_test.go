@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchNames(t *testing.T) {
+	names := []string{"Backend", "Backend staging", "Frontend", "Mobile app"}
+
+	tests := []struct {
+		name    string
+		query   string
+		matches []int
+	}{
+		{"exact match wins over prefix", "backend", []int{0}},
+		{"case and spaces ignored", "  BACKEND   Staging ", []int{1}},
+		{"prefix", "mob", []int{3}},
+		{"substring", "staging", []int{1}},
+		{"several substrings", "end", []int{0, 1, 2}},
+		{"typo", "frontnd", []int{2}},
+		{"too many typos", "frnt", nil},
+		{"empty query matches all", "", []int{0, 1, 2, 3}},
+		{"no match", "desktop", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, matchNames(names, tt.query))
+		})
+	}
+}
+
+func TestSplitNameAndUntil(t *testing.T) {
+	names := []string{"Backend", "Tomorrow digest"}
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		args     string
+		optional bool
+		query    string
+		until    *time.Time
+	}{
+		{"name and duration", "backend 2h", false, "backend", ptr(now.Add(2 * time.Hour))},
+		{"duration of several words", "backend tomorrow 9:00", false,
+			"backend", ptr(time.Date(2025, 3, 6, 9, 0, 0, 0, time.UTC))},
+		{"name looking like a duration", "tomorrow digest 30m", false, "tomorrow digest", ptr(now.Add(30 * time.Minute))},
+		{"missing duration", "backend", false, "backend", nil},
+		{"optional duration", "backend", true, "backend", nil},
+		{"duration only", "2h", true, "", ptr(now.Add(2 * time.Hour))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, until := splitNameAndUntil(tt.args, now, names, tt.optional)
+			assert.Equal(t, tt.query, query)
+			if tt.until == nil {
+				assert.Nil(t, until)
+				return
+			}
+			require.NotNil(t, until)
+			assert.Equal(t, *tt.until, *until)
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -1,5 +1,9 @@
 package bot
 
+import "time"
+
 type Config struct {
 	Token string
+	// StateTTL is how long a user has to finish a multi-step action, like naming a new project
+	StateTTL time.Duration
 }
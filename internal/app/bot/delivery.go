@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// apiError is an unsuccessful response of the Bot API
+type apiError struct {
+	Method      string
+	Code        int
+	Description string
+	// MigrateToChatID is the new ID of a group that was upgraded to a supergroup
+	MigrateToChatID int64
+	retryAfter      time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Description)
+}
+
+// Is makes server errors match domain.ErrMessengerUnavailable
+func (e *apiError) Is(target error) bool {
+	return target == domain.ErrMessengerUnavailable && e.Code >= 500
+}
+
+// RetryAfter implements queue.FloodError, it is how long to wait before
+// repeating a request that exceeded the flood limits
+func (e *apiError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// classifyUndeliverable tells whether an error of sending a message to the user is permanent.
+// It returns nil for errors that are worth retrying.
+func classifyUndeliverable(userID domain.TelegramUserID, err error) *domain.UndeliverableError {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	description := strings.ToLower(apiErr.Description)
+	var state domain.UserState
+	switch {
+	case apiErr.Code == 403 && strings.Contains(description, "blocked"):
+		state = domain.UserStateBlocked
+	case apiErr.Code == 403 && strings.Contains(description, "deactivated"):
+		state = domain.UserStateDeactivated
+	case apiErr.Code == 403:
+		// E.g. the user has never started the bot
+		state = domain.UserStateUnreachable
+	case apiErr.Code == 400 && strings.Contains(description, "chat not found"):
+		state = domain.UserStateUnreachable
+	case apiErr.MigrateToChatID != 0:
+		// Only groups are migrated, subscriptions belong to private chats
+		state = domain.UserStateUnreachable
+	default:
+		return nil
+	}
+
+	return &domain.UndeliverableError{UserID: userID, State: state, Reason: apiErr.Description}
+}
+
+// markUnreachable deactivates the subscriptions of a user who can't receive messages
+func (s *Service) markUnreachable(userID domain.TelegramUserID, state domain.UserState) {
+	if err := s.userService.Unreachable(userID, state); err != nil {
+		slog.Error("Failed to deactivate user", "error", err, "user_id", userID, "state", state)
+	}
+	// The next update from the user saves them as active again
+	s.users.Delete(userID)
+	slog.Info("User can't receive messages, subscriptions deactivated", "user_id", userID, "state", state)
+}
+
+// recordMemberStatus handles changes of the bot's membership in private chats:
+// users who block the bot are deactivated, users who unblock it are seen again
+func (s *Service) recordMemberStatus(profile *domain.User, status string) {
+	switch status {
+	case "kicked":
+		s.markUnreachable(profile.ID, domain.UserStateBlocked)
+	case "member":
+		// The cache may still hold the user as active, e.g. if another instance found them blocked
+		s.users.Delete(profile.ID)
+		s.recordUser(profile)
+	}
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestClassifyUndeliverable(t *testing.T) {
+	userID := domain.MustNewTelegramUserID(42)
+
+	tests := []struct {
+		name  string
+		err   error
+		state domain.UserState
+	}{
+		{"blocked", &apiError{Code: 403, Description: "Forbidden: bot was blocked by the user"}, domain.UserStateBlocked},
+		{"deactivated", &apiError{Code: 403, Description: "Forbidden: user is deactivated"}, domain.UserStateDeactivated},
+		{"never started", &apiError{Code: 403, Description: "Forbidden: bot can't initiate conversation with a user"}, domain.UserStateUnreachable},
+		{"chat not found", &apiError{Code: 400, Description: "Bad Request: chat not found"}, domain.UserStateUnreachable},
+		{"migrated", &apiError{Code: 400, Description: "Bad Request: group chat was upgraded to a supergroup chat", MigrateToChatID: -100123}, domain.UserStateUnreachable},
+		{"flood", &apiError{Code: 429, Description: "Too Many Requests: retry after 5"}, ""},
+		{"bad request", &apiError{Code: 400, Description: "Bad Request: message text is empty"}, ""},
+		{"network", errors.New("http.Post failed"), ""},
+		{"no error", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undeliverable := classifyUndeliverable(userID, tt.err)
+			if tt.state == "" {
+				assert.Nil(t, undeliverable)
+				return
+			}
+			if assert.NotNil(t, undeliverable) {
+				assert.Equal(t, tt.state, undeliverable.State)
+				assert.ErrorIs(t, undeliverable, domain.ErrUndeliverable)
+			}
+		})
+	}
+}
+
+func TestAPIError_Unavailable(t *testing.T) {
+	assert.ErrorIs(t, &apiError{Code: 502, Description: "Bad Gateway"}, domain.ErrMessengerUnavailable)
+	assert.NotErrorIs(t, &apiError{Code: 400, Description: "Bad Request: chat not found"}, domain.ErrMessengerUnavailable)
+}
@@ -0,0 +1,338 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// maxMessageLength is the maximum length of a Telegram message, in characters
+const maxMessageLength = 4096
+
+// Options of the digest schedule picker besides the digest modes
+const (
+	digestOptionOff    = "off"
+	digestOptionCustom = "custom"
+	digestOptionBack   = "back"
+)
+
+// Menu items for digests
+var (
+	btnDigestShowAll = telebot.InlineButton{Unique: "digest_all", Text: "📋 Show all"}
+	btnDigestMode    = telebot.InlineButton{Unique: "digest_mode"}
+	// Data is project ID|option|time of day in minutes
+	btnDigestOption = telebot.InlineButton{Unique: "digest_option"}
+)
+
+// digestTimePresets are the times of daily digests offered by the picker, in minutes since midnight
+var digestTimePresets = []int{9 * 60, 13 * 60, 18 * 60, 21 * 60}
+
+// wizardDigestTime asks the user for the time of daily digests
+const wizardDigestTime = "digest_time"
+
+type digestsHandler struct {
+	service *Service
+}
+
+func newDigestsHandler(s *Service) *digestsHandler {
+	return &digestsHandler{service: s}
+}
+
+func (h *digestsHandler) register() {
+	h.service.bot.Handle(&btnDigestShowAll, h.handleShowAll)
+	h.service.bot.Handle(&btnDigestMode, h.handleDigestMode)
+	h.service.bot.Handle(&btnDigestOption, h.handleDigestOption)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardDigestTime,
+		Steps:   []wizardStep{{Prompt: staticPrompt(digestTimePrompt)}},
+		Finish:  h.setDigestTime,
+		Cancel:  h.service.subscriptionManagement.showWizardSubscription,
+		Menu:    subscriptionsMenu,
+		Failure: "Sorry, failed to update subscription. Please try again.",
+	})
+}
+
+// createShowAllButton creates the button showing all notifications of a digest
+func (h *digestsHandler) createShowAllButton(l *Locale, digestID uuid.UUID) telebot.InlineButton {
+	btn := l.Button(btnDigestShowAll)
+	btn.Data = digestID.String()
+	return btn
+}
+
+// createModeButton creates the subscription management button opening the digest schedule picker
+func (h *digestsHandler) createModeButton(l *Locale, sub *domain.Subscription, projectID uuid.UUID) telebot.InlineButton {
+	btn := btnDigestMode
+	btn.Text = l.T("📬 Delivery: %s", l.DigestSchedule(sub.DigestMode, sub.DigestAt))
+	btn.Data = projectID.String()
+	return btn
+}
+
+// handleShowAll sends all notifications of a digest
+func (h *digestsHandler) handleShowAll(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	digestID, err := uuid.Parse(c.Data)
+	if err != nil {
+		slog.Error("Invalid digest ID in show all callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid digest. Please try again.")})
+		return
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	digest, err := h.service.notificationService.GetDigest(digestID)
+	if err != nil || digest.UserID != userID {
+		if err != nil && !errors.Is(err, domain.ErrDigestNotFound) {
+			slog.Error("Failed to get digest", "error", err, "digest_id", digestID)
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get the digest. Please try again.")})
+			return
+		}
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This digest has expired.")})
+		return
+	}
+
+	items, err := h.service.notificationService.GetDigestItems(digestID)
+	if err != nil {
+		slog.Error("Failed to get digest items", "error", err, "digest_id", digestID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get the digest. Please try again.")})
+		return
+	}
+
+	project, err := h.service.projectService.GetByID(digest.ProjectID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err, "project_id", digest.ProjectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get the digest. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	loc := h.service.userLocation(userID)
+	parts := []string{"📋 " + project.Name + ": " + l.N(len(items), "all %d notification", "all %d notifications")}
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("[%s] %s", l.ShortDateTime(item.CreatedAt.In(loc)), item.Text))
+	}
+
+	for _, text := range splitMessage(parts, maxMessageLength) {
+		if _, err := h.service.bot.Send(c.Sender, text); err != nil {
+			slog.Error("Failed to send digest items", "error", err, "digest_id", digestID)
+			return
+		}
+	}
+}
+
+// splitMessage joins the parts with blank lines into messages of at most limit characters,
+// parts longer than the limit are cut
+func splitMessage(parts []string, limit int) []string {
+	var messages []string
+	var current strings.Builder
+	for _, part := range parts {
+		for utf8.RuneCountInString(part) > limit {
+			runes := []rune(part)
+			if current.Len() > 0 {
+				messages = append(messages, current.String())
+				current.Reset()
+			}
+			messages = append(messages, string(runes[:limit]))
+			part = string(runes[limit:])
+		}
+
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+2+utf8.RuneCountInString(part) > limit {
+			messages = append(messages, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(part)
+	}
+	if current.Len() > 0 {
+		messages = append(messages, current.String())
+	}
+	return messages
+}
+
+// handleDigestMode replaces the subscription management message with the digest schedule picker
+func (h *digestsHandler) handleDigestMode(c *telebot.Callback) {
+	projectID, ok := h.service.subscriptionManagement.parseProjectID(c, "digest mode")
+	if !ok {
+		return
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	l := h.service.locale(userID)
+	sub, project, err := h.service.subscriptionManagement.findSubscription(userID, projectID)
+	if err != nil {
+		slog.Error("Failed to find subscription", "error", err)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get subscription details. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	button := func(option string, at int, label string) telebot.InlineButton {
+		mode := domain.DigestMode(option)
+		if option == digestOptionOff {
+			mode = domain.DigestOff
+		}
+		if mode == sub.DigestMode && (mode != domain.DigestDaily || at == sub.DigestAt) {
+			label = "✅ " + label
+		}
+
+		btn := btnDigestOption
+		btn.Text = label
+		btn.Data = joinCallbackData(projectID.String(), option, strconv.Itoa(at))
+		return btn
+	}
+
+	keyboard := [][]telebot.InlineButton{
+		{button(digestOptionOff, 0, l.T("Immediately"))},
+		{
+			button(string(domain.Digest15Min), 0, l.T("Every 15 minutes")),
+			button(string(domain.DigestHourly), 0, l.T("Hourly")),
+		},
+	}
+	var row []telebot.InlineButton
+	for _, at := range digestTimePresets {
+		row = append(row, button(string(domain.DigestDaily), at, l.T("Daily at %02d:%02d", at/60, at%60)))
+		if len(row) == 2 {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	keyboard = append(keyboard,
+		[]telebot.InlineButton{button(digestOptionCustom, 0, l.T("✏️ Daily at custom time"))},
+		[]telebot.InlineButton{button(digestOptionBack, 0, l.T("↩️ Back"))},
+	)
+
+	message := l.T("How should notifications from <b>%s</b> be delivered?\n\n"+
+		"Digests combine notifications into one message, showing the latest %d of them.",
+		project.Name, domain.DigestLatestItems)
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show digest options", "error", err)
+	}
+}
+
+// handleDigestOption applies the chosen digest schedule or asks for a custom time
+func (h *digestsHandler) handleDigestOption(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 3)
+	if !ok {
+		slog.Error("Invalid data in digest option callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	projectID, err := uuid.Parse(parts[0])
+	if err != nil {
+		slog.Error("Invalid project ID in digest option callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscription. Please try again.")})
+		return
+	}
+
+	at, err := strconv.Atoi(parts[2])
+	if err != nil {
+		slog.Error("Invalid time in digest option callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	var mode domain.DigestMode
+	switch parts[1] {
+	case digestOptionBack:
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		h.service.subscriptionManagement.updateSubscriptionMessage(c, projectID)
+		return
+
+	case digestOptionCustom:
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		h.service.wizards.start(c.Sender, c.Message, wizardDigestTime, StateData{ProjectID: projectID})
+		return
+
+	case digestOptionOff:
+		mode = domain.DigestOff
+
+	default:
+		mode = domain.DigestMode(parts[1])
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	now := time.Now().In(h.service.userLocation(userID))
+	if err := h.service.subscriptionService.SetDigest(userID, projectID, mode, at, now); err != nil {
+		slog.Error("Failed to set digest mode", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update subscription. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Notifications will be delivered %s", l.DigestSchedule(mode, at))})
+	h.service.subscriptionManagement.updateSubscriptionMessage(c, projectID)
+}
+
+// sendResumeSummary sends the notifications held while the subscription was paused, after the user resumed it
+func (h *digestsHandler) sendResumeSummary(userID domain.TelegramUserID, projectID uuid.UUID) {
+	sub, err := h.service.subscriptionService.GetSubscription(userID, projectID)
+	if err != nil {
+		slog.Error("Failed to get subscription", "error", err, "project_id", projectID)
+		return
+	}
+
+	pending, err := h.service.notificationService.PrepareResume(sub, time.Now())
+	if err != nil {
+		slog.Error("Failed to prepare held notifications", "error", err, "project_id", projectID)
+		return
+	}
+	if pending == nil {
+		return
+	}
+
+	if err := h.service.SendMessage(pending.Message); err != nil {
+		slog.Error("Failed to send held notifications", "error", err, "project_id", projectID)
+		return
+	}
+	if err := h.service.notificationService.CommitDigest(pending); err != nil {
+		slog.Error("Failed to save held notifications digest", "error", err, "project_id", projectID)
+	}
+}
+
+// digestTimePrompt explains which digest times are accepted
+const digestTimePrompt = "At what time should the daily digest be sent? Send a time like 8:30 or 19:00."
+
+// setDigestTime processes the time of daily digests entered by the user
+func (h *digestsHandler) setDigestTime(w *wizardContext) error {
+	projectID := w.Data.ProjectID
+	if projectID == uuid.Nil {
+		return fmt.Errorf("project ID is missing in state data")
+	}
+
+	at, err := domain.ParseDigestTime(w.Answer(0))
+	if err != nil {
+		return invalidAnswer(w.Locale.T("Sorry, I couldn't understand that.") + " " + w.Locale.T(digestTimePrompt))
+	}
+
+	userID := w.UserID()
+	now := time.Now().In(h.service.userLocation(userID))
+	if err := h.service.subscriptionService.SetDigest(userID, projectID, domain.DigestDaily, at, now); err != nil {
+		return fmt.Errorf("failed to set digest mode: %w", err)
+	}
+
+	sub, project, err := h.service.subscriptionManagement.findSubscription(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to find subscription: %w", err)
+	}
+
+	w.Reply("✅ "+w.Locale.T("Notifications will be delivered %s", w.Locale.DigestSchedule(domain.DigestDaily, at))+".\n\n"+
+		h.service.subscriptionManagement.createStatusMessage(sub, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		h.service.subscriptionManagement.createSubscriptionButtons(sub, projectID))
+	return nil
+}
@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Kinds of subscription actions that take a duration
+const (
+	durationKindMute  = "m"
+	durationKindPause = "p"
+)
+
+// Presets offered by the duration picker
+const (
+	durationPreset30m     = "30m"
+	durationPreset2h      = "2h"
+	durationPresetEOD     = "eod"
+	durationPresetMonday  = "mon"
+	durationPresetForever = "forever"
+	durationPresetCustom  = "custom"
+	durationPresetBack    = "back"
+)
+
+// wizardCustomDuration asks the user until when to mute or pause a subscription
+const wizardCustomDuration = "custom_duration"
+
+// Menu item of the duration picker, data is kind|project ID|preset
+var btnDuration = telebot.InlineButton{Unique: "duration"}
+
+// durationOption is a preset button of the duration picker
+type durationOption struct {
+	Preset string
+	// Label is translated when shown
+	Label string
+}
+
+// durationOptions are the presets offered for both muting and pausing
+var durationOptions = []durationOption{
+	{durationPreset30m, "30 minutes"},
+	{durationPreset2h, "2 hours"},
+	{durationPresetEOD, "Until end of day"},
+	{durationPresetMonday, "Until Monday"},
+}
+
+type durationsHandler struct {
+	service *Service
+}
+
+func newDurationsHandler(s *Service) *durationsHandler {
+	return &durationsHandler{service: s}
+}
+
+func (h *durationsHandler) register() {
+	h.service.bot.Handle(&btnDuration, h.handleDuration)
+
+	h.service.wizards.add(&wizard{
+		Name: wizardCustomDuration,
+		Steps: []wizardStep{{Prompt: func(w *wizardContext) (string, error) {
+			return customDurationPrompt(w.Locale, w.Data.DurationKind), nil
+		}}},
+		Finish:  h.applyCustomDuration,
+		Cancel:  h.service.subscriptionManagement.showWizardSubscription,
+		Menu:    subscriptionsMenu,
+		Failure: "Sorry, failed to update subscription. Please try again.",
+	})
+}
+
+// presetUntil returns the end time of a preset, ok is false for unknown presets
+func presetUntil(preset string, now time.Time) (time.Time, bool) {
+	switch preset {
+	case durationPreset30m:
+		return now.Add(30 * time.Minute), true
+	case durationPreset2h:
+		return now.Add(2 * time.Hour), true
+	case durationPresetEOD:
+		return domain.EndOfDay(now), true
+	case durationPresetMonday:
+		return domain.NextMonday(now), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// durationAction returns the name of an action kind, used in logs
+func durationAction(kind string) string {
+	if kind == durationKindMute {
+		return "mute"
+	}
+	return "pause"
+}
+
+// showPicker replaces the subscription management message with the duration presets
+func (h *durationsHandler) showPicker(c *telebot.Callback, kind string) {
+	projectID, ok := h.service.subscriptionManagement.parseProjectID(c, durationAction(kind)+" subscription")
+	if !ok {
+		return
+	}
+
+	l := h.service.userLocale(c.Sender)
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	button := func(preset, label string) telebot.InlineButton {
+		btn := btnDuration
+		btn.Text = label
+		btn.Data = joinCallbackData(kind, projectID.String(), preset)
+		return btn
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for i := 0; i < len(durationOptions); i += 2 {
+		keyboard = append(keyboard, []telebot.InlineButton{
+			button(durationOptions[i].Preset, l.T(durationOptions[i].Label)),
+			button(durationOptions[i+1].Preset, l.T(durationOptions[i+1].Label)),
+		})
+	}
+	if kind == durationKindMute {
+		keyboard = append(keyboard, []telebot.InlineButton{button(durationPresetForever, l.T("Until I unmute"))})
+	}
+	keyboard = append(keyboard,
+		[]telebot.InlineButton{button(durationPresetCustom, l.T("✏️ Custom"))},
+		[]telebot.InlineButton{button(durationPresetBack, l.T("↩️ Back"))},
+	)
+
+	message := l.T("For how long do you want to pause notifications from <b>%s</b>?", project.Name)
+	if kind == durationKindMute {
+		message = l.T("For how long do you want to mute notifications from <b>%s</b>?", project.Name)
+	}
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show duration options", "error", err)
+	}
+}
+
+// handleDuration applies the chosen preset or asks for a custom duration
+func (h *durationsHandler) handleDuration(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 3)
+	if !ok || (parts[0] != durationKindMute && parts[0] != durationKindPause) {
+		slog.Error("Invalid data in duration callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+	kind, preset := parts[0], parts[2]
+
+	projectID, err := uuid.Parse(parts[1])
+	if err != nil {
+		slog.Error("Invalid project ID in duration callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscription. Please try again.")})
+		return
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+
+	switch preset {
+	case durationPresetBack:
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		h.service.subscriptionManagement.updateSubscriptionMessage(c, projectID)
+		return
+
+	case durationPresetCustom:
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		h.service.wizards.start(c.Sender, c.Message, wizardCustomDuration, StateData{ProjectID: projectID, DurationKind: kind})
+		return
+
+	case durationPresetForever:
+		if kind != durationKindMute {
+			break
+		}
+		if err := h.service.subscriptionService.MuteNotifications(userID, projectID); err != nil {
+			slog.Error("Failed to mute subscription", "error", err)
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to mute subscription. Please try again.")})
+			return
+		}
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Subscription muted")})
+		h.service.subscriptionManagement.updateSubscriptionMessage(c, projectID)
+		return
+	}
+
+	until, ok := presetUntil(preset, time.Now().In(h.service.userLocation(userID)))
+	if !ok {
+		slog.Error("Unknown duration preset", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	if err := h.apply(kind, userID, projectID, until); err != nil {
+		slog.Error("Failed to "+durationAction(kind)+" subscription", "error", err)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update subscription. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: untilConfirmation(l, kind, until)})
+	h.service.subscriptionManagement.updateSubscriptionMessage(c, projectID)
+}
+
+// apply mutes or pauses the subscription until the given time
+func (h *durationsHandler) apply(kind string, userID domain.TelegramUserID, projectID uuid.UUID, until time.Time) error {
+	if kind == durationKindMute {
+		return h.service.subscriptionService.MuteNotificationsUntil(userID, projectID, until)
+	}
+	return h.service.subscriptionService.PauseNotifications(userID, projectID, until)
+}
+
+// applyCustomDuration processes a custom duration entered by the user
+func (h *durationsHandler) applyCustomDuration(w *wizardContext) error {
+	kind, projectID := w.Data.DurationKind, w.Data.ProjectID
+	if projectID == uuid.Nil {
+		return fmt.Errorf("project ID is missing in state data")
+	}
+
+	userID := w.UserID()
+	until, err := domain.ParseUntil(w.Answer(0), time.Now().In(h.service.userLocation(userID)))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidUntil) {
+			return invalidAnswer(w.Locale.T("Sorry, I couldn't understand that.") + " " + customDurationPrompt(w.Locale, kind))
+		}
+		return fmt.Errorf("failed to parse duration: %w", err)
+	}
+
+	if err := h.apply(kind, userID, projectID, until); err != nil {
+		return fmt.Errorf("failed to %s subscription: %w", durationAction(kind), err)
+	}
+
+	sub, project, err := h.service.subscriptionManagement.findSubscription(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to find subscription: %w", err)
+	}
+
+	w.Reply("✅ "+untilConfirmation(w.Locale, kind, until)+".\n\n"+h.service.subscriptionManagement.createStatusMessage(sub, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		h.service.subscriptionManagement.createSubscriptionButtons(sub, projectID))
+	return nil
+}
+
+// customDurationPrompt explains which custom durations are accepted
+func customDurationPrompt(l *Locale, kind string) string {
+	question := l.T("Until when do you want to pause notifications?")
+	if kind == durationKindMute {
+		question = l.T("Until when do you want to mute notifications?")
+	}
+	return question + " " + l.T("Send a duration like 45m, 3h or 2d, a time like 18:30 or tomorrow 9:00, "+
+		"a weekday like friday, or a date like 2025-03-10.")
+}
+
+// untilConfirmation describes the applied mute or pause
+func untilConfirmation(l *Locale, kind string, until time.Time) string {
+	if kind == durationKindMute {
+		return l.T("Muted until %s", l.DateTime(until))
+	}
+	return l.T("Paused until %s", l.DateTime(until))
+}
@@ -0,0 +1,368 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// historyPreviewLength is the maximum length of a notification shown in the history, in characters
+const historyPreviewLength = 300
+
+// Kinds of history views
+const (
+	// historyKindUser is the history of notifications a subscriber received from a project
+	historyKindUser = "u"
+	// historyKindProject is the log of notifications sent by a publisher's project
+	historyKindProject = "p"
+)
+
+// Menu items for the notification history. Data of the history page button
+// is kind|project ID|page|search, where search is 1 to apply the user's last search.
+var (
+	btnOpenHistory   = telebot.InlineButton{Unique: "open_history"}
+	btnHistory       = telebot.InlineButton{Unique: "history"}
+	btnHistorySearch = telebot.InlineButton{Unique: "history_search", Text: "🔍 Search"}
+)
+
+// historySearch is the last search of a user in a history view
+type historySearch struct {
+	Kind      string
+	ProjectID uuid.UUID
+	Query     string
+}
+
+// wizardSearchHistory asks the user what to search for in a history view
+const wizardSearchHistory = "search_history"
+
+type historyHandler struct {
+	service *Service
+
+	// Searches are kept in memory, as they don't fit in callback data
+	mu       sync.Mutex
+	searches map[int]historySearch
+}
+
+func newHistoryHandler(s *Service) *historyHandler {
+	return &historyHandler{
+		service:  s,
+		searches: make(map[int]historySearch),
+	}
+}
+
+func (h *historyHandler) register() {
+	h.service.bot.Handle(&btnOpenHistory, h.handleOpenHistory)
+	h.service.bot.Handle(&btnHistory, h.handleHistory)
+	h.service.bot.Handle(&btnHistorySearch, h.handleSearch)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardSearchHistory,
+		Steps:   []wizardStep{{Prompt: staticPrompt("What are you looking for? Send a word or phrase to search the notifications for:")}},
+		Finish:  h.search,
+		Cancel:  h.showWizardHistory,
+		Failure: "Sorry, failed to search notifications. Please try again.",
+	})
+}
+
+// createOpenButton creates a button showing the first page of a history view
+func (h *historyHandler) createOpenButton(l *Locale, kind string, projectID uuid.UUID) telebot.InlineButton {
+	btn := btnOpenHistory
+	if kind == historyKindProject {
+		btn.Text = l.T("📜 Sent log")
+	} else {
+		btn.Text = l.T("📜 History")
+	}
+	btn.Data = joinCallbackData(kind, projectID.String())
+	return btn
+}
+
+// getSearch returns the user's last search in the history view, if any
+func (h *historyHandler) getSearch(userID int, kind string, projectID uuid.UUID) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	search, ok := h.searches[userID]
+	if !ok || search.Kind != kind || search.ProjectID != projectID {
+		return "", false
+	}
+	return search.Query, true
+}
+
+// setSearch remembers the user's search in the history view
+func (h *historyHandler) setSearch(userID int, search historySearch) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.searches[userID] = search
+}
+
+// getPage loads a page of the history view, checking that the user may see it
+func (h *historyHandler) getPage(userID int, kind string, projectID uuid.UUID, query string, page int) (*domain.Project, *domain.HistoryPage, error) {
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	telegramUserID := domain.MustNewTelegramUserID(int64(userID))
+	var history *domain.HistoryPage
+	if kind == historyKindProject {
+		if project.PublisherID != telegramUserID {
+			return nil, nil, fmt.Errorf("user %d is not the publisher of project %s", userID, projectID)
+		}
+		history, err = h.service.historyService.GetProjectLog(projectID, query, page)
+	} else {
+		history, err = h.service.historyService.GetUserHistory(telegramUserID, projectID, query, page)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get history: %w", err)
+	}
+	return project, history, nil
+}
+
+// createHistoryMessage creates the text of a history page
+func (h *historyHandler) createHistoryMessage(l *Locale, userID int, kind string, project *domain.Project, history *domain.HistoryPage, query string) string {
+	var b strings.Builder
+	if kind == historyKindProject {
+		b.WriteString(l.T("📜 Notifications sent by <b>%s</b>", project.Name))
+	} else {
+		b.WriteString(l.T("📜 Notifications from <b>%s</b>", project.Name))
+	}
+	if query != "" {
+		b.WriteString("\n" + l.T("🔍 Search: <i>%s</i>", html.EscapeString(query)))
+	}
+
+	if history.Total == 0 {
+		if query != "" {
+			b.WriteString("\n\n" + l.T("Nothing found."))
+		} else {
+			b.WriteString("\n\n" + l.T("There are no notifications yet."))
+		}
+		return b.String()
+	}
+
+	telegramUserID := domain.MustNewTelegramUserID(int64(userID))
+	for _, n := range history.Notifications {
+		fmt.Fprintf(&b, "\n\n<i>%s</i>", h.service.formatTime(n.CreatedAt, telegramUserID))
+		if kind == historyKindProject {
+			b.WriteString(" · " + l.N(n.Recipients, "%d recipient", "%d recipients"))
+		}
+		b.WriteString("\n" + html.EscapeString(truncateText(n.Text, historyPreviewLength)))
+	}
+
+	b.WriteString("\n\n" + l.T("Page %d of %d", history.Page+1, history.Pages) + ", " +
+		l.N(history.Total, "%d notification", "%d notifications"))
+	return b.String()
+}
+
+// createHistoryButtons creates the pagination and search buttons of a history page
+func (h *historyHandler) createHistoryButtons(l *Locale, kind string, projectID uuid.UUID, history *domain.HistoryPage, searching bool) *telebot.ReplyMarkup {
+	search := "0"
+	if searching {
+		search = "1"
+	}
+	pageButton := func(text string, page int) telebot.InlineButton {
+		btn := btnHistory
+		btn.Text = text
+		btn.Data = joinCallbackData(kind, projectID.String(), strconv.Itoa(page), search)
+		return btn
+	}
+
+	var keyboard [][]telebot.InlineButton
+	var navigation []telebot.InlineButton
+	if history.Page > 0 {
+		navigation = append(navigation, pageButton(l.T("⬅️ Newer"), history.Page-1))
+	}
+	if history.Page < history.Pages-1 {
+		navigation = append(navigation, pageButton(l.T("Older ➡️"), history.Page+1))
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	searchBtn := l.Button(btnHistorySearch)
+	searchBtn.Data = joinCallbackData(kind, projectID.String())
+	row := []telebot.InlineButton{searchBtn}
+	if searching {
+		clearBtn := btnHistory
+		clearBtn.Text = l.T("✖️ Clear search")
+		clearBtn.Data = joinCallbackData(kind, projectID.String(), "0", "0")
+		row = append(row, clearBtn)
+	}
+	keyboard = append(keyboard, row)
+
+	// Go back to the project or subscription the history belongs to
+	backBtn := btnManageSubscription
+	if kind == historyKindProject {
+		backBtn = btnManageProject
+	}
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = projectID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	return &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// parseKindAndProject parses the history kind and project ID from callback data parts
+func (h *historyHandler) parseKindAndProject(c *telebot.Callback, parts []string) (string, uuid.UUID, bool) {
+	l := h.service.userLocale(c.Sender)
+	kind := parts[0]
+	if kind != historyKindUser && kind != historyKindProject {
+		slog.Error("Invalid kind in history callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return "", uuid.Nil, false
+	}
+
+	projectID, err := uuid.Parse(parts[1])
+	if err != nil {
+		slog.Error("Invalid project ID in history callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid project. Please try again.")})
+		return "", uuid.Nil, false
+	}
+	return kind, projectID, true
+}
+
+// handleOpenHistory replaces the callback message with the first page of a history view
+func (h *historyHandler) handleOpenHistory(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in open history callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+	kind, projectID, ok := h.parseKindAndProject(c, parts)
+	if !ok {
+		return
+	}
+
+	project, history, err := h.getPage(c.Sender.ID, kind, projectID, "", 0)
+	if err != nil {
+		slog.Error("Failed to get history", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get the history. Please try again.")})
+		return
+	}
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	_, err = h.service.bot.Edit(c.Message, h.createHistoryMessage(l, c.Sender.ID, kind, project, history, ""),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createHistoryButtons(l, kind, projectID, history, false))
+	if err != nil {
+		slog.Error("Failed to show history", "error", err)
+	}
+}
+
+// handleHistory replaces the callback message with a page of a history view
+func (h *historyHandler) handleHistory(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 4)
+	if !ok {
+		slog.Error("Invalid data in history callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+	kind, projectID, ok := h.parseKindAndProject(c, parts)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil {
+		slog.Error("Invalid page in history callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	var query string
+	if parts[3] == "1" {
+		query, ok = h.getSearch(c.Sender.ID, kind, projectID)
+		if !ok {
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This search has expired. Please search again.")})
+			return
+		}
+	}
+
+	project, history, err := h.getPage(c.Sender.ID, kind, projectID, query, page)
+	if err != nil {
+		slog.Error("Failed to get history", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get the history. Please try again.")})
+		return
+	}
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	_, err = h.service.bot.Edit(c.Message, h.createHistoryMessage(l, c.Sender.ID, kind, project, history, query),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createHistoryButtons(l, kind, projectID, history, query != ""))
+	if err != nil {
+		slog.Error("Failed to update history message", "error", err)
+	}
+}
+
+// handleSearch asks the user what to search for in the history view
+func (h *historyHandler) handleSearch(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in history search callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+	kind, projectID, ok := h.parseKindAndProject(c, parts)
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardSearchHistory, StateData{ProjectID: projectID, HistoryKind: kind})
+}
+
+// search shows the first page of the history view matching the query entered by the user
+func (h *historyHandler) search(w *wizardContext) error {
+	kind := w.Data.HistoryKind
+	if kind != historyKindUser && kind != historyKindProject {
+		return fmt.Errorf("history kind is missing in state data")
+	}
+	projectID := w.Data.ProjectID
+	if projectID == uuid.Nil {
+		return fmt.Errorf("project ID is missing in state data")
+	}
+
+	query := strings.TrimSpace(w.Answer(0))
+	if query == "" {
+		return invalidAnswer(w.Locale.T("Please send a word or phrase to search for:"))
+	}
+
+	project, history, err := h.getPage(w.User.ID, kind, projectID, query, 0)
+	if err != nil {
+		return err
+	}
+	h.setSearch(w.User.ID, historySearch{Kind: kind, ProjectID: projectID, Query: query})
+
+	w.Reply(h.createHistoryMessage(w.Locale, w.User.ID, kind, project, history, query),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createHistoryButtons(w.Locale, kind, projectID, history, true))
+	return nil
+}
+
+// showWizardHistory shows the first page of the history view again when the search is cancelled
+func (h *historyHandler) showWizardHistory(w *wizardContext) error {
+	kind, projectID := w.Data.HistoryKind, w.Data.ProjectID
+	project, history, err := h.getPage(w.User.ID, kind, projectID, "", 0)
+	if err != nil {
+		return err
+	}
+	return w.Show(h.createHistoryMessage(w.Locale, w.User.ID, kind, project, history, ""),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createHistoryButtons(w.Locale, kind, projectID, history, false))
+}
+
+// truncateText shortens a text to at most limit characters
+func truncateText(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Locale translates the texts of the bot into a language.
+// Texts are written in English in the code and used as keys of the message catalog,
+// texts missing from the catalog are shown in English.
+type Locale struct {
+	// Language is the code of the language, like "en"
+	Language string
+	// Name is the name of the language in the language itself
+	Name string
+
+	messages map[string]string
+	// plurals are the plural forms of texts counting something, keyed by the English singular form
+	plurals map[string][]string
+	// plural returns the index of the plural form used for n
+	plural func(n int) int
+
+	dateLayout          string
+	dateTimeLayout      string
+	shortDateTimeLayout string
+	// weekdays are the short names of the days of the week, starting with Sunday
+	weekdays [7]string
+}
+
+var localeEN = &Locale{
+	Language: "en",
+	Name:     "English",
+	plural: func(n int) int {
+		if n == 1 {
+			return 0
+		}
+		return 1
+	},
+	dateLayout:          "Jan 2, 2006",
+	dateTimeLayout:      "Jan 2, 2006 15:04",
+	shortDateTimeLayout: "Jan 2 15:04",
+	weekdays:            [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+// locales are the supported locales in the order they are offered to users, the first one is the default
+var locales = []*Locale{localeEN, localeRU}
+
+// findLocale returns the locale of a language code like "ru" or "pt-br", the default locale if it isn't supported
+func findLocale(languageCode string) *Locale {
+	language, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	for _, l := range locales {
+		if l.Language == language {
+			return l
+		}
+	}
+	return locales[0]
+}
+
+// T translates a text, formatting it with the arguments if there are any
+func (l *Locale) T(text string, args ...any) string {
+	if translated, ok := l.messages[text]; ok {
+		text = translated
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// N translates a text counting something, like "%d subscriber", choosing the plural form for n.
+// The text is formatted with n.
+func (l *Locale) N(n int, singular, plural string) string {
+	if forms, ok := l.plurals[singular]; ok {
+		return fmt.Sprintf(forms[min(l.plural(n), len(forms)-1)], n)
+	}
+	// Missing texts are shown in English
+	forms := []string{singular, plural}
+	return fmt.Sprintf(forms[localeEN.plural(n)], n)
+}
+
+// Button translates the text of a button, its handler doesn't depend on the text
+func (l *Locale) Button(btn telebot.InlineButton) telebot.InlineButton {
+	btn.Text = l.T(btn.Text)
+	return btn
+}
+
+// Markup translates the texts of the buttons of a menu
+func (l *Locale) Markup(markup *telebot.ReplyMarkup) *telebot.ReplyMarkup {
+	translated := *markup
+	translated.InlineKeyboard = make([][]telebot.InlineButton, len(markup.InlineKeyboard))
+	for i, row := range markup.InlineKeyboard {
+		translated.InlineKeyboard[i] = make([]telebot.InlineButton, len(row))
+		for j, btn := range row {
+			translated.InlineKeyboard[i][j] = l.Button(btn)
+		}
+	}
+	return &translated
+}
+
+// Date formats the date of t
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(l.dateLayout)
+}
+
+// DateTime formats the date and time of t
+func (l *Locale) DateTime(t time.Time) string {
+	return t.Format(l.dateTimeLayout)
+}
+
+// ShortDateTime formats the date and time of t without the year
+func (l *Locale) ShortDateTime(t time.Time) string {
+	return t.Format(l.shortDateTimeLayout)
+}
+
+// Weekdays describes a set of days of the week
+func (l *Locale) Weekdays(w domain.Weekdays) string {
+	switch w {
+	case domain.AllWeek:
+		return l.T("every day")
+	case domain.WorkWeek:
+		return l.T("weekdays")
+	case domain.Weekend:
+		return l.T("weekends")
+	}
+
+	var days []string
+	// Monday first
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		if w.Has(day) {
+			days = append(days, l.weekdays[day])
+		}
+	}
+	return strings.Join(days, ", ")
+}
+
+// QuietWindow describes a quiet hours window
+func (l *Locale) QuietWindow(w domain.QuietWindow) string {
+	if w.Start == w.End {
+		return l.T("all day, %s", l.Weekdays(w.Days))
+	}
+	return fmt.Sprintf("%02d:%02d–%02d:%02d, %s", w.Start/60, w.Start%60, w.End/60, w.End%60, l.Weekdays(w.Days))
+}
+
+// DigestSchedule describes when notifications are delivered, at is the time of daily digests
+func (l *Locale) DigestSchedule(mode domain.DigestMode, at int) string {
+	switch mode {
+	case domain.Digest15Min:
+		return l.T("every 15 minutes")
+	case domain.DigestHourly:
+		return l.T("hourly")
+	case domain.DigestDaily:
+		return l.T("daily at %02d:%02d", at/60, at%60)
+	default:
+		return l.T("immediately")
+	}
+}
@@ -0,0 +1,493 @@
+package bot
+
+var localeRU = &Locale{
+	Language: "ru",
+	Name:     "Русский",
+	plural: func(n int) int {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20):
+			return 1
+		default:
+			return 2
+		}
+	},
+	dateLayout:          "02.01.2006",
+	dateTimeLayout:      "02.01.2006 15:04",
+	shortDateTimeLayout: "02.01 15:04",
+	weekdays:            [7]string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"},
+	plurals: map[string][]string{
+		"%d notification":     {"%d уведомление", "%d уведомления", "%d уведомлений"},
+		"all %d notification": {"все %d уведомление", "все %d уведомления", "все %d уведомлений"},
+		"%d recipient":        {"%d получатель", "%d получателя", "%d получателей"},
+		"%d use":              {"%d использование", "%d использования", "%d использований"},
+	},
+	messages: map[string]string{
+		// Main menu
+		mainMenuText:                   "Выберите действие:",
+		"Welcome to Noteo!":            "Добро пожаловать в Noteo!",
+		"↩️ Main menu":                 "↩️ Главное меню",
+		"⬅️ Previous":                  "⬅️ Назад",
+		"Next ➡️":                      "Далее ➡️",
+		"↩️ Back":                      "↩️ Назад",
+		"✖️ Cancel":                    "✖️ Отмена",
+		"Manage":                       "Управление",
+		"(page %d of %d)":              "(страница %d из %d)",
+		"Operation cancelled.":         "Действие отменено.",
+		"There is nothing to cancel.":  "Отменять нечего.",
+		"Please use the menu buttons.": "Пожалуйста, пользуйтесь кнопками меню.",
+		"Something went wrong. Please try again.":                              "Что-то пошло не так. Попробуйте ещё раз.",
+		"⌛ Your last action has expired. Please start it again from the menu.": "⌛ Время на последнее действие истекло. Начните его заново из меню.",
+		"Invalid option. Please try again.":                                    "Неверный вариант. Попробуйте ещё раз.",
+		"Invalid project. Please try again.":                                   "Неверный проект. Попробуйте ещё раз.",
+		"Sorry, I couldn't understand that.":                                   "Извините, я не понял.",
+
+		// Commands
+		"Your projects":                                       "Ваши проекты",
+		"Create a project: /newproject <name>":                "Создать проект: /newproject <название>",
+		"Show the tokens of a project: /token <project>":      "Показать токены проекта: /token <проект>",
+		"Your subscriptions":                                  "Ваши подписки",
+		"Mute a subscription: /mute <project> [duration]":     "Выключить звук подписки: /mute <проект> [срок]",
+		"Pause a subscription: /pause <project> <duration>":   "Приостановить подписку: /pause <проект> <срок>",
+		"Unmute and resume a subscription: /resume <project>": "Включить звук и возобновить подписку: /resume <проект>",
+		"Cancel the current action":                           "Отменить текущее действие",
+		"How to use the bot":                                  "Как пользоваться ботом",
+		helpText: `<b>Noteo</b> доставляет уведомления от проектов, на которые вы подписаны, и позволяет публиковать свои.
+
+/projects — ваши проекты
+/newproject &lt;название&gt; — создать проект
+/token &lt;проект&gt; — показать токены проекта
+/subscriptions — ваши подписки
+/mute &lt;проект&gt; [срок] — выключить звук подписки, пока вы его не включите или на время
+/pause &lt;проект&gt; &lt;срок&gt; — приостановить подписку
+/resume &lt;проект&gt; — включить звук и возобновить подписку
+/cancel — отменить текущее действие
+
+Название проекта не обязательно писать точно, достаточно его части. Сроки выглядят так: 45m, 3h, 2d, 18:30, tomorrow 9:00, friday или 2025-03-10.`,
+		"I work in a private chat, please message me at https://t.me/%s":       "Я работаю в личном чате, напишите мне: https://t.me/%s",
+		"You don't have any projects yet. Create one with /newproject <name>.": "У вас пока нет проектов. Создайте проект командой /newproject <название>.",
+		"No project matches “%s”.":                                             "Нет проектов, подходящих под «%s».",
+		"Which project do you mean?":                                           "Какой проект вы имеете в виду?",
+		"Several projects match “%s”, which one do you mean?":                  "Под «%s» подходят несколько проектов, какой из них вы имеете в виду?",
+		"Please add for how long, like /pause backend 2h.":                     "Укажите срок, например /pause backend 2h.",
+		untilFormats:                             "Сроки выглядят так: 45m, 3h, 2d, 18:30, tomorrow 9:00, friday или 2025-03-10.",
+		"✅ Muted.":                               "✅ Звук выключен.",
+		"✅ Resumed.":                             "✅ Возобновлено.",
+		"Notifications are not muted or paused.": "Уведомления и так не выключены и не приостановлены.",
+		"Sorry, failed to mute subscription. Please try again.":   "Извините, не удалось выключить звук подписки. Попробуйте ещё раз.",
+		"Sorry, failed to pause subscription. Please try again.":  "Извините, не удалось приостановить подписку. Попробуйте ещё раз.",
+		"Sorry, failed to unmute subscription. Please try again.": "Извините, не удалось включить звук подписки. Попробуйте ещё раз.",
+		"Sorry, failed to resume subscription. Please try again.": "Извините, не удалось возобновить подписку. Попробуйте ещё раз.",
+
+		// Projects
+		"📁 My projects":                                                                                       "📁 Мои проекты",
+		"➕ Create new":                                                                                        "➕ Создать",
+		"📁 <b>My projects</b>":                                                                                "📁 <b>Мои проекты</b>",
+		"You don't have any projects yet.":                                                                    "У вас пока нет проектов.",
+		"Please enter the name for your new project:":                                                         "Введите название нового проекта:",
+		"Sorry, failed to create project. Please try again.":                                                  "Извините, не удалось создать проект. Попробуйте ещё раз.",
+		"Sorry, failed to get your projects. Please try again.":                                               "Извините, не удалось получить ваши проекты. Попробуйте ещё раз.",
+		"Failed to get your projects. Please try again.":                                                      "Не удалось получить ваши проекты. Попробуйте ещё раз.",
+		"Failed to get project details. Please try again.":                                                    "Не удалось получить данные проекта. Попробуйте ещё раз.",
+		"Project created successfully!\n\n<b>Name:</b> %s":                                                    "Проект создан!\n\n<b>Название:</b> %s",
+		"Use <b>Invite links</b> in project management to create a link for your subscribers.":                "Создайте ссылку для подписчиков в разделе <b>Ссылки-приглашения</b> управления проектом.",
+		"Share this link to let users subscribe to your project:\n%s":                                         "Поделитесь этой ссылкой, чтобы на проект можно было подписаться:\n%s",
+		"This name can't be used: it must be a single line of 1 to %d characters. Please enter another name:": "Это название не подходит: оно должно быть одной строкой длиной от 1 до %d символов. Введите другое название:",
+		"You already have a project with this name. Please enter another name:":                               "У вас уже есть проект с таким названием. Введите другое название:",
+
+		// Project management
+		"✏️ Rename":                      "✏️ Переименовать",
+		"📝 Edit description":             "📝 Изменить описание",
+		"🔑 Regenerate token":             "🔑 Сменить токен",
+		"✅ Yes, regenerate":              "✅ Да, сменить",
+		"🗑 Delete":                       "🗑 Удалить",
+		"✅ Yes, delete":                  "✅ Да, удалить",
+		"🔒 Require approval":             "🔒 Требовать одобрения",
+		"🔓 Don't require approval":       "🔓 Не требовать одобрения",
+		"🚫 Disable legacy share link":    "🚫 Отключить старую ссылку",
+		"✅ Yes, disable":                 "✅ Да, отключить",
+		"🙈 Hide notification buttons":    "🙈 Скрыть кнопки уведомлений",
+		"👀 Show notification buttons":    "👀 Показать кнопки уведомлений",
+		"unknown":                        "неизвестно",
+		"not required":                   "не требуется",
+		"required":                       "требуется",
+		"shown":                          "показаны",
+		"hidden":                         "скрыты",
+		"<b>Legacy share link:</b> %s":   "<b>Старая ссылка:</b> %s",
+		"<b>Description:</b>":            "<b>Описание:</b>",
+		"This project is not yours.":     "Это не ваш проект.",
+		"Approval is now required":       "Теперь нужно одобрение",
+		"Approval is no longer required": "Одобрение больше не нужно",
+		"Notification buttons hidden":    "Кнопки уведомлений скрыты",
+		"Notification buttons shown":     "Кнопки уведомлений показаны",
+		"Legacy share link disabled":     "Старая ссылка отключена",
+		"Project deleted":                "Проект удалён",
+		"✅ Project renamed.":             "✅ Проект переименован.",
+		"✅ Project description updated.": "✅ Описание проекта обновлено.",
+		"Managing project <b>%s</b>\n\n<b>Tokens:</b> %s\n<b>Subscribers:</b> %s\n<b>Active invite links:</b> %s\n" +
+			"<b>Approval of new subscribers:</b> %s\n<b>Snooze and unsubscribe buttons under notifications:</b> %s": "Управление проектом <b>%s</b>\n\n<b>Токены:</b> %s\n<b>Подписчики:</b> %s\n<b>Активные ссылки-приглашения:</b> %s\n" +
+			"<b>Одобрение новых подписчиков:</b> %s\n<b>Кнопки «Отложить» и «Отписаться» под уведомлениями:</b> %s",
+		"Failed to update project. Please try again.":                    "Не удалось обновить проект. Попробуйте ещё раз.",
+		"Failed to delete project. Please try again.":                    "Не удалось удалить проект. Попробуйте ещё раз.",
+		"Sorry, failed to rename project. Please try again.":             "Извините, не удалось переименовать проект. Попробуйте ещё раз.",
+		"Sorry, failed to update project description. Please try again.": "Извините, не удалось обновить описание проекта. Попробуйте ещё раз.",
+		"Please enter the new name for project <b>%s</b>:":               "Введите новое название проекта <b>%s</b>:",
+		"Please enter the new description for project <b>%s</b>. It is shown to users before they subscribe. " +
+			"Send a single dash (-) to remove the description.": "Введите новое описание проекта <b>%s</b>. Его видят пользователи перед подпиской. " +
+			"Отправьте один дефис (-), чтобы удалить описание.",
+		"The description must be at most %d characters. Please enter a shorter one:": "Описание должно быть не длиннее %d символов. Введите описание покороче:",
+		"Disable the legacy share link of project <b>%s</b>?\n\nNobody will be able to subscribe with it anymore, only with invite links. " +
+			"Existing subscriptions are not affected. This cannot be undone.": "Отключить старую ссылку проекта <b>%s</b>?\n\nПодписаться по ней больше будет нельзя, только по ссылкам-приглашениям. " +
+			"Существующие подписки останутся. Это действие нельзя отменить.",
+		"Regenerate the token of project <b>%s</b>?\n\nThe current token will stop working immediately, so you will have to update it everywhere it is used.": "Сменить токен проекта <b>%s</b>?\n\nТекущий токен сразу перестанет работать, и его придётся заменить везде, где он используется.",
+		"Delete project <b>%s</b>?\n\nAll its subscriptions will be removed and subscribers will be notified. This cannot be undone.":                         "Удалить проект <b>%s</b>?\n\nВсе его подписки будут удалены, а подписчики получат уведомление. Это действие нельзя отменить.",
+		"Project <b>%s</b> has been deleted.": "Проект <b>%s</b> удалён.",
+		"Project <b>%s</b> has been deleted by its publisher. You will no longer receive notifications from it.": "Проект <b>%s</b> удалён его издателем. Уведомления от него больше не будут приходить.",
+
+		// Invite links
+		"🔗 Invite links":                    "🔗 Ссылки-приглашения",
+		"➕ New link":                        "➕ Новая ссылка",
+		"%d of %d uses":                     "использовано %d из %d",
+		"never expires":                     "бессрочная",
+		"expires %s":                        "действует до %s",
+		"Invite links of <b>%s</b>":         "Ссылки-приглашения проекта <b>%s</b>",
+		"There are no active invite links.": "Активных ссылок-приглашений нет.",
+		"🗑 Revoke link %d":                  "🗑 Отозвать ссылку %d",
+		"No expiry":                         "Бессрочно",
+		"1 hour":                            "1 час",
+		"1 day":                             "1 день",
+		"7 days":                            "7 дней",
+		"30 days":                           "30 дней",
+		"Unlimited":                         "Без ограничений",
+		"Invite link created":               "Ссылка создана",
+		"Invite link revoked":               "Ссылка отозвана",
+		"✅ New invite link:":                "✅ Новая ссылка-приглашение:",
+		"New invite link for <b>%s</b>\n\nHow long should it be valid?":   "Новая ссылка-приглашение для <b>%s</b>\n\nСколько она будет действовать?",
+		"New invite link for <b>%s</b>\n\nHow many times can it be used?": "Новая ссылка-приглашение для <b>%s</b>\n\nСколько раз ей можно воспользоваться?",
+		"Sorry, failed to get invite links. Please try again.":            "Извините, не удалось получить ссылки-приглашения. Попробуйте ещё раз.",
+		"Failed to create invite link. Please try again.":                 "Не удалось создать ссылку. Попробуйте ещё раз.",
+		"Invalid invite link. Please try again.":                          "Неверная ссылка. Попробуйте ещё раз.",
+		"Failed to get invite link. Please try again.":                    "Не удалось получить ссылку. Попробуйте ещё раз.",
+		"Failed to revoke invite link. Please try again.":                 "Не удалось отозвать ссылку. Попробуйте ещё раз.",
+
+		// Subscribers
+		"👥 Subscribers":                      "👥 Подписчики",
+		"❌ Remove":                           "❌ Удалить",
+		"🚫 Ban":                              "🚫 Заблокировать",
+		"🚫 Banned users":                     "🚫 Заблокированные",
+		"Subscribers of <b>%s</b>":           "Подписчики проекта <b>%s</b>",
+		"There are no subscribers yet.":      "Подписчиков пока нет.",
+		" (page %d of %d):\n":                " (страница %d из %d):\n",
+		"%d. %s — since %s":                  "%d. %s — с %s",
+		"Subscriber removed":                 "Подписчик удалён",
+		"Subscriber banned":                  "Подписчик заблокирован",
+		"Banned users of <b>%s</b>":          "Заблокированные пользователи проекта <b>%s</b>",
+		"Nobody is banned.":                  "Заблокированных нет.",
+		"Tap a user to lift the ban:":        "Нажмите на пользователя, чтобы снять блокировку:",
+		"✅ Unban %s":                         "✅ Разблокировать %s",
+		"User unbanned":                      "Пользователь разблокирован",
+		"This user is no longer subscribed.": "Этот пользователь больше не подписан.",
+		"Subscriber of <b>%s</b>\n\n<b>Name:</b> %s\n<b>Subscribed since:</b> %s":                    "Подписчик проекта <b>%s</b>\n\n<b>Имя:</b> %s\n<b>Подписан с:</b> %s",
+		"🚫 Notifications can't be delivered: the user has blocked the bot or deleted their account.": "🚫 Уведомления не доставляются: пользователь заблокировал бота или удалил аккаунт.",
+		"You have been removed from project <b>%s</b> by its publisher.":                             "Издатель проекта <b>%s</b> удалил вас из подписчиков.",
+		"Invalid subscriber. Please try again.":                                                      "Неверный подписчик. Попробуйте ещё раз.",
+		"Sorry, failed to get project subscribers. Please try again.":                                "Извините, не удалось получить подписчиков проекта. Попробуйте ещё раз.",
+		"Failed to remove subscriber. Please try again.":                                             "Не удалось удалить подписчика. Попробуйте ещё раз.",
+		"Failed to ban subscriber. Please try again.":                                                "Не удалось заблокировать подписчика. Попробуйте ещё раз.",
+		"Sorry, failed to get banned users. Please try again.":                                       "Извините, не удалось получить заблокированных пользователей. Попробуйте ещё раз.",
+		"Failed to unban user. Please try again.":                                                    "Не удалось разблокировать пользователя. Попробуйте ещё раз.",
+
+		// Subscription requests
+		"✅ Approve":        "✅ Одобрить",
+		"❌ Reject":         "❌ Отклонить",
+		"Request approved": "Заявка одобрена",
+		"Request rejected": "Заявка отклонена",
+		"User is banned":   "Пользователь заблокирован",
+		"🚫 <b>%s</b> is banned from project <b>%s</b>, the request was discarded.":                                                 "🚫 <b>%s</b> заблокирован в проекте <b>%s</b>, заявка удалена.",
+		"Your request to subscribe to project <b>%s</b> is still waiting for approval.":                                            "Ваша заявка на подписку на проект <b>%s</b> всё ещё ждёт одобрения.",
+		"<b>%s</b> wants to subscribe to project <b>%s</b>.":                                                                       "<b>%s</b> хочет подписаться на проект <b>%s</b>.",
+		"Project <b>%s</b> requires approval. Your request has been sent to the publisher, you will be notified once they decide.": "Подписка на проект <b>%s</b> требует одобрения. Заявка отправлена издателю, вы получите уведомление, когда он примет решение.",
+		"✅ You approved the subscription of <b>%s</b> to project <b>%s</b>.":                                                       "✅ Вы одобрили подписку <b>%s</b> на проект <b>%s</b>.",
+		"❌ You rejected the subscription of <b>%s</b> to project <b>%s</b>.":                                                       "❌ Вы отклонили подписку <b>%s</b> на проект <b>%s</b>.",
+		"Your request to subscribe to project <b>%s</b> has been approved!":                                                        "Ваша заявка на подписку на проект <b>%s</b> одобрена!",
+		"Your request to subscribe to project <b>%s</b> has been rejected.":                                                        "Ваша заявка на подписку на проект <b>%s</b> отклонена.",
+		"Invalid request. Please try again.":                                                                                       "Неверная заявка. Попробуйте ещё раз.",
+		"This request has already been handled.":                                                                                   "Эта заявка уже обработана.",
+		"Failed to approve request. Please try again.":                                                                             "Не удалось одобрить заявку. Попробуйте ещё раз.",
+		"Failed to reject request. Please try again.":                                                                              "Не удалось отклонить заявку. Попробуйте ещё раз.",
+
+		// Subscriptions
+		"📬 My subscriptions":                                                                                 "📬 Мои подписки",
+		"✅ Subscribe":                                                                                        "✅ Подписаться",
+		"📬 <b>My subscriptions</b>":                                                                          "📬 <b>Мои подписки</b>",
+		"Subscription cancelled":                                                                             "Подписка отменена",
+		"Subscription cancelled.":                                                                            "Подписка отменена.",
+		"You don't have any subscriptions yet.":                                                              "У вас пока нет подписок.",
+		"Failed to get your subscriptions. Please try again.":                                                "Не удалось получить ваши подписки. Попробуйте ещё раз.",
+		"Sorry, failed to get your subscriptions. Please try again.":                                         "Извините, не удалось получить ваши подписки. Попробуйте ещё раз.",
+		"You are about to subscribe to project <b>%s</b>\n\n<b>Publisher:</b> %s":                            "Вы собираетесь подписаться на проект <b>%s</b>\n\n<b>Издатель:</b> %s",
+		"🔒 The publisher has to approve new subscribers.":                                                    "🔒 Новых подписчиков одобряет издатель.",
+		"You have successfully subscribed to project <b>%s</b>!":                                             "Вы подписались на проект <b>%s</b>!",
+		"You are already subscribed to project <b>%s</b>":                                                    "Вы уже подписаны на проект <b>%s</b>",
+		"Sorry, you can't subscribe to project <b>%s</b>.":                                                   "Извините, вы не можете подписаться на проект <b>%s</b>.",
+		"Sorry, failed to process your subscription. Please try again later.":                                "Извините, не удалось оформить подписку. Попробуйте позже.",
+		"Sorry, this subscription link has expired. Please ask the publisher for a new one.":                 "Извините, срок действия этой ссылки истёк. Попросите у издателя новую.",
+		"Sorry, this subscription link is no longer valid. Please ask the publisher for a new one.":          "Извините, эта ссылка больше не действует. Попросите у издателя новую.",
+		"Sorry, this subscription link has reached its usage limit. Please ask the publisher for a new one.": "Извините, по этой ссылке больше нельзя подписаться. Попросите у издателя новую.",
+		"Sorry, this subscription link is invalid.":                                                          "Извините, эта ссылка неверна.",
+
+		// Subscription management
+		"🔕 Mute":                                "🔕 Без звука",
+		"🔔 Unmute":                              "🔔 Со звуком",
+		"⏸️ Pause":                              "⏸️ Приостановить",
+		"▶️ Resume":                             "▶️ Возобновить",
+		"❌ Unsubscribe":                         "❌ Отписаться",
+		"↩️ Re-subscribe":                       "↩️ Подписаться снова",
+		"📥 While paused: hold notifications":    "📥 На паузе: откладывать уведомления",
+		"🚫 While paused: skip notifications":    "🚫 На паузе: пропускать уведомления",
+		"🌙 Quiet hours: %s":                     "🌙 Тихие часы: %s",
+		"Quiet hours: %s":                       "Тихие часы: %s",
+		"🔕 Notifications are muted until %s":    "🔕 Звук уведомлений выключен до %s",
+		"🔕 Notifications are currently muted":   "🔕 Звук уведомлений выключен",
+		"🔔 Notifications are currently enabled": "🔔 Уведомления включены",
+		"⏸️ Notifications are paused until %s, new ones are held until then":         "⏸️ Уведомления приостановлены до %s, новые будут отложены до этого времени",
+		"⏸️ Notifications are paused until %s":                                       "⏸️ Уведомления приостановлены до %s",
+		"▶️ Notifications are active":                                                "▶️ Уведомления активны",
+		"🌙 During quiet hours notifications are %s":                                  "🌙 В тихие часы уведомления %s",
+		"📬 Notifications are delivered as a digest %s, next at %s":                   "📬 Уведомления приходят сводкой %s, следующая в %s",
+		"📬 Notifications are delivered as a digest %s":                               "📬 Уведомления приходят сводкой %s",
+		"Managing subscription to <b>%s</b>\n\n%s":                                   "Управление подпиской на <b>%s</b>\n\n%s",
+		"You have unsubscribed from <b>%s</b>":                                       "Вы отписались от <b>%s</b>",
+		"Subscription unmuted":                                                       "Звук подписки включён",
+		"Subscription resumed":                                                       "Подписка возобновлена",
+		"Re-subscribed successfully":                                                 "Вы снова подписаны",
+		"Notifications will be skipped while paused":                                 "На паузе уведомления будут пропускаться",
+		"Notifications will be held while paused and summarized when the pause ends": "На паузе уведомления будут откладываться, а после паузы придёт сводка",
+		"Invalid subscription. Please try again.":                                    "Неверная подписка. Попробуйте ещё раз.",
+		"Failed to get subscription details. Please try again.":                      "Не удалось получить данные подписки. Попробуйте ещё раз.",
+		"Failed to update subscription. Please try again.":                           "Не удалось обновить подписку. Попробуйте ещё раз.",
+		"Sorry, failed to update subscription. Please try again.":                    "Извините, не удалось обновить подписку. Попробуйте ещё раз.",
+
+		// Quiet modes
+		"as in settings":              "как в настройках",
+		"ignored, delivered as usual": "приходят как обычно",
+		"delivered silently":          "приходят без звука",
+		"held until quiet hours end":  "откладываются до конца тихих часов",
+
+		// Notification buttons
+		"▶️ Snoozed until %s, resume":                       "▶️ Отложено до %s, возобновить",
+		"You are not subscribed to this project anymore.":   "Вы больше не подписаны на этот проект.",
+		"Failed to snooze notifications. Please try again.": "Не удалось отложить уведомления. Попробуйте ещё раз.",
+		"Failed to resume notifications. Please try again.": "Не удалось возобновить уведомления. Попробуйте ещё раз.",
+		"Notifications resumed":                             "Уведомления возобновлены",
+		"Failed to unsubscribe. Please try again.":          "Не удалось отписаться. Попробуйте ещё раз.",
+		"Unsubscribed successfully":                         "Вы отписались",
+		"This project doesn't exist anymore.":               "Этого проекта больше нет.",
+		"Failed to re-subscribe. Please try again.":         "Не удалось подписаться снова. Попробуйте ещё раз.",
+		"Re-subscribed to %s":                               "Вы снова подписаны на %s",
+
+		// Durations
+		"30 minutes":         "30 минут",
+		"2 hours":            "2 часа",
+		"Until end of day":   "До конца дня",
+		"Until Monday":       "До понедельника",
+		"Until I unmute":     "Пока не включу",
+		"✏️ Custom":          "✏️ Свой срок",
+		"Muted until %s":     "Звук выключен до %s",
+		"Paused until %s":    "Приостановлено до %s",
+		"Subscription muted": "Звук подписки выключен",
+		"For how long do you want to pause notifications from <b>%s</b>?":                                                           "На сколько приостановить уведомления от <b>%s</b>?",
+		"For how long do you want to mute notifications from <b>%s</b>?":                                                            "На сколько выключить звук уведомлений от <b>%s</b>?",
+		"Until when do you want to pause notifications?":                                                                            "До какого времени приостановить уведомления?",
+		"Until when do you want to mute notifications?":                                                                             "До какого времени выключить звук уведомлений?",
+		"Send a duration like 45m, 3h or 2d, a time like 18:30 or tomorrow 9:00, a weekday like friday, or a date like 2025-03-10.": "Отправьте срок, например 45m, 3h или 2d, время вроде 18:30 или tomorrow 9:00, день недели вроде friday или дату вроде 2025-03-10.",
+		"Failed to mute subscription. Please try again.":                                                                            "Не удалось выключить звук подписки. Попробуйте ещё раз.",
+
+		// Digests
+		"📋 Show all":                         "📋 Показать все",
+		"📬 Delivery: %s":                     "📬 Доставка: %s",
+		"Immediately":                        "Сразу",
+		"Every 15 minutes":                   "Каждые 15 минут",
+		"Hourly":                             "Каждый час",
+		"Daily at %02d:%02d":                 "Ежедневно в %02d:%02d",
+		"✏️ Daily at custom time":            "✏️ Ежедневно в своё время",
+		"every 15 minutes":                   "каждые 15 минут",
+		"hourly":                             "каждый час",
+		"daily at %02d:%02d":                 "ежедневно в %02d:%02d",
+		"immediately":                        "сразу",
+		"This digest has expired.":           "Эта сводка устарела.",
+		"Notifications will be delivered %s": "Уведомления будут приходить %s",
+		digestTimePrompt:                     "В какое время присылать ежедневную сводку? Отправьте время, например 8:30 или 19:00.",
+		"How should notifications from <b>%s</b> be delivered?\n\nDigests combine notifications into one message, showing the latest %d of them.": "Как доставлять уведомления от <b>%s</b>?\n\nСводка объединяет уведомления в одно сообщение и показывает последние %d из них.",
+		"Invalid digest. Please try again.":           "Неверная сводка. Попробуйте ещё раз.",
+		"Failed to get the digest. Please try again.": "Не удалось получить сводку. Попробуйте ещё раз.",
+
+		// History
+		"🔍 Search":                                    "🔍 Поиск",
+		"📜 Sent log":                                  "📜 Журнал отправки",
+		"📜 History":                                   "📜 История",
+		"📜 Notifications sent by <b>%s</b>":           "📜 Уведомления, отправленные проектом <b>%s</b>",
+		"📜 Notifications from <b>%s</b>":              "📜 Уведомления от <b>%s</b>",
+		"🔍 Search: <i>%s</i>":                         "🔍 Поиск: <i>%s</i>",
+		"Nothing found.":                              "Ничего не найдено.",
+		"There are no notifications yet.":             "Уведомлений пока нет.",
+		"Page %d of %d":                               "Страница %d из %d",
+		"⬅️ Newer":                                    "⬅️ Новее",
+		"Older ➡️":                                    "Старше ➡️",
+		"✖️ Clear search":                             "✖️ Сбросить поиск",
+		"Please send a word or phrase to search for:": "Отправьте слово или фразу для поиска:",
+		"What are you looking for? Send a word or phrase to search the notifications for:": "Что вы ищете? Отправьте слово или фразу для поиска по уведомлениям:",
+		"Sorry, failed to search notifications. Please try again.":                         "Извините, не удалось найти уведомления. Попробуйте ещё раз.",
+		"Failed to get the history. Please try again.":                                     "Не удалось получить историю. Попробуйте ещё раз.",
+		"This search has expired. Please search again.":                                    "Этот поиск устарел. Повторите поиск.",
+
+		// Settings
+		"⚙️ Settings":                 "⚙️ Настройки",
+		"🌍 Change timezone":           "🌍 Сменить часовой пояс",
+		"➕ Add quiet hours":           "➕ Добавить тихие часы",
+		"🌐 Language":                  "🌐 Язык",
+		"🔄 Automatic":                 "🔄 Автоматически",
+		"automatic (%s)":              "автоматически (%s)",
+		"Language updated":            "Язык изменён",
+		"every day":                   "каждый день",
+		"weekdays":                    "по будням",
+		"weekends":                    "по выходным",
+		"all day, %s":                 "весь день, %s",
+		"<b>Language:</b> %s\n":       "<b>Язык:</b> %s\n",
+		"<b>Quiet hours:</b> not set": "<b>Тихие часы:</b> не заданы",
+		"<b>Quiet hours:</b>":         "<b>Тихие часы:</b>",
+		"⚙️ <b>Settings</b>\n\n<b>Timezone:</b> %s (now %s)\n": "⚙️ <b>Настройки</b>\n\n<b>Часовой пояс:</b> %s (сейчас %s)\n",
+		"<b>During quiet hours notifications are</b> %s":       "<b>В тихие часы уведомления</b> %s",
+		"🗑 Remove quiet hours %d":                              "🗑 Удалить тихие часы %d",
+		"🔕 Deliver silently instead":                           "🔕 Лучше присылать без звука",
+		"📥 Hold until quiet hours end instead":                 "📥 Лучше откладывать до конца тихих часов",
+		"Notifications will be %s":                             "Уведомления в тихие часы %s",
+		"✅ Timezone updated.":                                  "✅ Часовой пояс изменён.",
+		"✅ Quiet hours added: %s":                              "✅ Тихие часы добавлены: %s",
+		"You can have at most %d quiet hours windows.":         "Можно задать не больше %d интервалов тихих часов.",
+		"These quiet hours have already been removed.":         "Эти тихие часы уже удалены.",
+		"Quiet hours removed":                                  "Тихие часы удалены",
+		"🌐 Choose the language of the bot. Automatic uses the language of your Telegram app.": "🌐 Выберите язык бота. «Автоматически» — язык вашего приложения Telegram.",
+		timezonePrompt: "Введите часовой пояс: название вроде Europe/Moscow или смещение от UTC вроде +3 или UTC-05:30:",
+		quietHoursPrompt: "Когда уведомления должны быть тихими? Отправьте один интервал времени, при желании с днями недели, например:\n\n" +
+			"23:00-08:00\nweekdays 23:00-08:00\nweekends all day\nmon-fri 22:30-07:00\nsat,sun 00:00-10:00\n\n" +
+			"Разные тихие часы для разных дней добавляются по одному.",
+		"Sorry, I don't know this timezone. Please enter a name like Europe/Berlin or an offset like +3:": "Извините, я не знаю такого часового пояса. Введите название вроде Europe/Moscow или смещение вроде +3:",
+		"Sorry, failed to update settings. Please try again.":                                             "Извините, не удалось обновить настройки. Попробуйте ещё раз.",
+		"Sorry, failed to get your settings. Please try again.":                                           "Извините, не удалось получить ваши настройки. Попробуйте ещё раз.",
+		"Failed to update settings. Please try again.":                                                    "Не удалось обновить настройки. Попробуйте ещё раз.",
+		"Failed to remove quiet hours. Please try again.":                                                 "Не удалось удалить тихие часы. Попробуйте ещё раз.",
+		"🔑 API key":                      "🔑 API-ключ",
+		"➕ Issue API key":                "➕ Выпустить API-ключ",
+		"🔄 Regenerate":                   "🔄 Перевыпустить",
+		"🗑 Revoke":                       "🗑 Отозвать",
+		"✅ Yes, revoke":                  "✅ Да, отозвать",
+		"never":                          "никогда",
+		"API key issued":                 "API-ключ выпущен",
+		"API key revoked":                "API-ключ отозван",
+		apiKeyShownOnce:                  "⚠️ Скопируйте ключ сейчас, больше он показан не будет.",
+		"You don't have an API key yet.": "У вас пока нет API-ключа.",
+		"🔑 <b>API key</b>\n\nThe API key lets scripts and CI manage your projects through the management API at <code>/api/v1/projects</code>. " +
+			"Send it in the <code>Authorization: Bearer</code> header.": "🔑 <b>API-ключ</b>\n\nAPI-ключ позволяет скриптам и CI управлять вашими проектами через API по адресу <code>/api/v1/projects</code>. " +
+			"Передавайте его в заголовке <code>Authorization: Bearer</code>.",
+		"<b>Key:</b> <code>%s</code>\n<b>Issued:</b> %s\n<b>Last used:</b> %s":                                                            "<b>Ключ:</b> <code>%s</code>\n<b>Выпущен:</b> %s\n<b>Последнее использование:</b> %s",
+		"Regenerate your API key?\n\nThe current key will stop working immediately, so you will have to update it everywhere it is used.": "Перевыпустить API-ключ?\n\nТекущий ключ сразу перестанет работать, и его придётся заменить везде, где он используется.",
+		"Revoke your API key?\n\nThe management API will reject it immediately.":                                                          "Отозвать API-ключ?\n\nAPI сразу перестанет его принимать.",
+		"Sorry, failed to get your API key. Please try again.":                                                                            "Извините, не удалось получить ваш API-ключ. Попробуйте ещё раз.",
+		"Failed to issue API key. Please try again.":                                                                                      "Не удалось выпустить API-ключ. Попробуйте ещё раз.",
+		"Failed to revoke API key. Please try again.":                                                                                     "Не удалось отозвать API-ключ. Попробуйте ещё раз.",
+
+		// Project tokens
+		"🔑 Tokens":                      "🔑 Токены",
+		"➕ New token":                   "➕ Новый токен",
+		"🌐 Allowed addresses":           "🌐 Разрешённые адреса",
+		"⏳ Expiry":                      "⏳ Срок действия",
+		"🔄 Rotate":                      "🔄 Заменить",
+		"send notifications":            "отправлять уведомления",
+		"report heartbeats":             "сообщать о работе",
+		"read status":                   "читать статус",
+		"90 days":                       "90 дней",
+		"1 year":                        "1 год",
+		"Stop the old token right away": "Сразу отключить старый токен",
+		"Keep the old token for 1 hour": "Оставить старый токен на 1 час",
+		"Keep the old token for 1 day":  "Оставить старый токен на 1 день",
+		"Keep the old token for 7 days": "Оставить старый токен на 7 дней",
+		"valid until revoked":           "действует до отзыва",
+		"⌛ expired %s":                  "⌛ истёк %s",
+		"any address":                   "любой адрес",
+		"<b>Token:</b> <code>%s</code>": "<b>Токен:</b> <code>%s</code>",
+		"Use <b>Tokens</b> in project management to create a token for sending notifications.": "Создайте токен для отправки уведомлений в разделе <b>Токены</b> управления проектом.",
+		"🔑 Tokens of <b>%s</b>\n\nTokens authenticate the requests of your services. " +
+			"Rotate a token with a grace period to replace it without breaking the services using it.": "🔑 Токены проекта <b>%s</b>\n\nТокены подтверждают запросы ваших сервисов. " +
+			"Заменяйте токен с переходным периодом, чтобы не сломать сервисы, которые его используют.",
+		"There are no tokens, so notifications can't be sent.": "Токенов нет, поэтому отправить уведомления нельзя.",
+		"🔑 Token <b>%s</b> of <b>%s</b>\n\n<code>%s</code>\n\n" +
+			"<b>Allows to:</b> %s\n<b>Allowed from:</b> %s\n<b>Expiry:</b> %s\n<b>Last used:</b> %s\n<b>Created:</b> %s": "🔑 Токен <b>%s</b> проекта <b>%s</b>\n\n<code>%s</code>\n\n" +
+			"<b>Разрешено:</b> %s\n<b>Разрешённые адреса:</b> %s\n<b>Срок действия:</b> %s\n<b>Последнее использование:</b> %s\n<b>Создан:</b> %s",
+		"Please enter a name for the new token, e.g. the service using it:": "Введите название нового токена, например имя сервиса, который будет его использовать:",
+		"Which addresses can token <b>%s</b> be used from?\n\n" +
+			"Send addresses or networks separated by commas, e.g. <code>203.0.113.7, 10.0.0.0/8</code>, " +
+			"or <code>-</code> to allow any address.": "С каких адресов можно использовать токен <b>%s</b>?\n\n" +
+			"Отправьте адреса или сети через запятую, например <code>203.0.113.7, 10.0.0.0/8</code>, " +
+			"или <code>-</code>, чтобы разрешить любой адрес.",
+		"How long should token <b>%s</b> be valid from now?": "Сколько должен действовать токен <b>%s</b>, начиная с этого момента?",
+		"Replace token <b>%s</b> with a new one?\n\nThe new token has the same settings. Keep the old one working for a while " +
+			"to update the services using it one by one.": "Заменить токен <b>%s</b> новым?\n\nУ нового токена будут те же настройки. Оставьте старый токен на время, " +
+			"чтобы обновить использующие его сервисы по очереди.",
+		"Revoke token <b>%s</b>?\n\nIt will stop working immediately.": "Отозвать токен <b>%s</b>?\n\nОн сразу перестанет работать.",
+		"A token must allow at least one action.":                      "Токен должен разрешать хотя бы одно действие.",
+		"These addresses can't be used: send at most %d addresses like 203.0.113.7 " +
+			"or networks like 10.0.0.0/8 separated by commas, or - to allow any address:": "Эти адреса не подходят: отправьте не более %d адресов вроде 203.0.113.7 " +
+			"или сетей вроде 10.0.0.0/8 через запятую или -, чтобы разрешить любой адрес:",
+		"✅ Token created.":                                   "✅ Токен создан.",
+		"✅ Allowed addresses updated.":                       "✅ Разрешённые адреса обновлены.",
+		"Expiry updated":                                     "Срок действия обновлён",
+		"Token rotated":                                      "Токен заменён",
+		"Token revoked":                                      "Токен отозван",
+		"Invalid token. Please try again.":                   "Неверный токен. Попробуйте ещё раз.",
+		"This token no longer exists.":                       "Этого токена больше нет.",
+		"Sorry, failed to get the tokens. Please try again.": "Извините, не удалось получить токены. Попробуйте ещё раз.",
+		"Sorry, failed to create token. Please try again.":   "Извините, не удалось создать токен. Попробуйте ещё раз.",
+		"Sorry, failed to update token. Please try again.":   "Извините, не удалось обновить токен. Попробуйте ещё раз.",
+		"Failed to update token. Please try again.":          "Не удалось обновить токен. Попробуйте ещё раз.",
+		"Failed to rotate token. Please try again.":          "Не удалось заменить токен. Попробуйте ещё раз.",
+		"Failed to revoke token. Please try again.":          "Не удалось отозвать токен. Попробуйте ещё раз.",
+		tokenShownOnce:                                       "⚠️ Скопируйте токен сейчас, больше он показан не будет.",
+
+		// Signed requests
+		"✍️ Signed requests":                               "✍️ Подписанные запросы",
+		"➕ Generate secret":                                "➕ Создать секрет",
+		"🔄 Regenerate secret":                              "🔄 Заменить секрет",
+		"🔒 Reject tokens":                                  "🔒 Отклонять токены",
+		"🔓 Accept tokens too":                              "🔓 Принимать и токены",
+		"🗑 Remove secret":                                  "🗑 Удалить секрет",
+		"✅ Yes, remove":                                    "✅ Да, удалить",
+		"accepted":                                         "принимаются",
+		"rejected":                                         "отклоняются",
+		"<b>Requests with tokens:</b> %s":                  "<b>Запросы с токенами:</b> %s",
+		"<b>Signed requests allow to:</b> %s":              "<b>Подписанные запросы разрешают:</b> %s",
+		"<b>Signing secret:</b> <code>%s</code>":           "<b>Секрет для подписи:</b> <code>%s</code>",
+		"⚠️ Copy the secret now, it won't be shown again.": "⚠️ Скопируйте секрет сейчас, больше он показан не будет.",
+		"The project has no signing secret yet.":           "У проекта пока нет секрета для подписи.",
+		"✍️ Signed requests of <b>%s</b>\n\n" +
+			"Instead of sending a token, your services can sign every request with the signing secret of the project. " +
+			"The secret itself is never sent, so proxies logging headers can't reveal it.": "✍️ Подписанные запросы проекта <b>%s</b>\n\n" +
+			"Вместо отправки токена ваши сервисы могут подписывать каждый запрос секретом проекта. " +
+			"Сам секрет не передаётся, поэтому прокси, записывающие заголовки, не смогут его раскрыть.",
+		"Generate a new signing secret for <b>%s</b>?\n\n" +
+			"Requests signed with the current secret will be rejected immediately.": "Создать новый секрет для подписи проекта <b>%s</b>?\n\n" +
+			"Запросы, подписанные текущим секретом, сразу начнут отклоняться.",
+		"Remove the signing secret of <b>%s</b>?\n\n" +
+			"Signed requests will be rejected and requests with tokens accepted again.": "Удалить секрет для подписи проекта <b>%s</b>?\n\n" +
+			"Подписанные запросы начнут отклоняться, а запросы с токенами снова будут приниматься.",
+		"Requests with tokens are now rejected":           "Запросы с токенами теперь отклоняются",
+		"Requests with tokens are accepted again":         "Запросы с токенами снова принимаются",
+		"Generate a signing secret first.":                "Сначала создайте секрет для подписи.",
+		"Signed requests must allow at least one action.": "Подписанные запросы должны разрешать хотя бы одно действие.",
+		"Secret generated":                                "Секрет создан",
+		"Secret removed":                                  "Секрет удалён",
+		"Failed to generate secret. Please try again.":    "Не удалось создать секрет. Попробуйте ещё раз.",
+		"Failed to remove secret. Please try again.":      "Не удалось удалить секрет. Попробуйте ещё раз.",
+	},
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestFindLocale(t *testing.T) {
+	tests := []struct {
+		code     string
+		language string
+	}{
+		{"ru", "ru"},
+		{"ru-RU", "ru"},
+		{"EN-us", "en"},
+		{"pt-br", "en"},
+		{"", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Equal(t, tt.language, findLocale(tt.code).Language)
+		})
+	}
+}
+
+func TestLocale_N(t *testing.T) {
+	tests := []struct {
+		n  int
+		en string
+		ru string
+	}{
+		{0, "0 notifications", "0 уведомлений"},
+		{1, "1 notification", "1 уведомление"},
+		{2, "2 notifications", "2 уведомления"},
+		{5, "5 notifications", "5 уведомлений"},
+		{11, "11 notifications", "11 уведомлений"},
+		{21, "21 notifications", "21 уведомление"},
+		{24, "24 notifications", "24 уведомления"},
+		{112, "112 notifications", "112 уведомлений"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			assert.Equal(t, tt.en, localeEN.N(tt.n, "%d notification", "%d notifications"))
+			assert.Equal(t, tt.ru, localeRU.N(tt.n, "%d notification", "%d notifications"))
+		})
+	}
+}
+
+func TestLocale_Formats(t *testing.T) {
+	at := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	window := domain.QuietWindow{Start: 23 * 60, End: 8 * 60, Days: domain.WorkWeek}
+
+	assert.Equal(t, "Mar 10, 2025 18:30", localeEN.DateTime(at))
+	assert.Equal(t, "10.03.2025 18:30", localeRU.DateTime(at))
+	assert.Equal(t, "23:00–08:00, weekdays", localeEN.QuietWindow(window))
+	assert.Equal(t, "23:00–08:00, по будням", localeRU.QuietWindow(window))
+	assert.Equal(t, "daily at 09:00", localeEN.DigestSchedule(domain.DigestDaily, 9*60))
+	assert.Equal(t, "ежедневно в 09:00", localeRU.DigestSchedule(domain.DigestDaily, 9*60))
+	assert.Equal(t, "Missing text 1", localeRU.T("Missing text %d", 1))
+}
+
+// formatVerbs finds the formatting verbs of a text
+var formatVerbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// TestLocale_Catalogs checks that every text of the bot is translated into every locale,
+// with the same formatting verbs as the English text
+func TestLocale_Catalogs(t *testing.T) {
+	texts, plurals := catalogTexts(t)
+	require.NotEmpty(t, texts)
+	require.NotEmpty(t, plurals)
+
+	// Texts kept in tables are translated when shown
+	for _, commands := range [][]botCommand{privateCommands, groupCommands} {
+		for _, command := range commands {
+			texts = append(texts, command.Description)
+		}
+	}
+	for _, option := range durationOptions {
+		texts = append(texts, option.Label)
+	}
+	for _, option := range inviteExpiryOptions {
+		texts = append(texts, option.Label)
+	}
+	for _, options := range [][]inviteExpiryOption{tokenExpiryOptions, tokenGraceOptions} {
+		for _, option := range options {
+			texts = append(texts, option.Label)
+		}
+	}
+	for _, label := range tokenScopeLabels {
+		texts = append(texts, label)
+	}
+	for _, description := range quietModeDescriptions {
+		texts = append(texts, description)
+	}
+
+	for _, l := range locales[1:] {
+		t.Run(l.Language, func(t *testing.T) {
+			for _, text := range texts {
+				translated, ok := l.messages[text]
+				if assert.True(t, ok, "missing translation of %q", text) {
+					assert.Equal(t, formatVerbs.FindAllString(text, -1), formatVerbs.FindAllString(translated, -1),
+						"formatting verbs of %q", text)
+				}
+			}
+			for _, singular := range plurals {
+				forms, ok := l.plurals[singular]
+				if assert.True(t, ok, "missing plural forms of %q", singular) {
+					for _, form := range forms {
+						assert.Equal(t, formatVerbs.FindAllString(singular, -1), formatVerbs.FindAllString(form, -1),
+							"formatting verbs of %q", singular)
+					}
+				}
+			}
+		})
+	}
+}
+
+// catalogTexts finds the constant texts translated in the code of the package:
+// the texts passed to T, N and staticPrompt, wizard failures and the texts of inline buttons
+func catalogTexts(t *testing.T) (texts, plurals []string) {
+	paths, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+
+	// Texts are often kept in constants
+	consts := make(map[string]ast.Expr)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.CONST {
+				for _, spec := range gen.Specs {
+					value := spec.(*ast.ValueSpec)
+					for i, name := range value.Names {
+						if i < len(value.Values) {
+							consts[name.Name] = value.Values[i]
+						}
+					}
+				}
+			}
+		}
+	}
+	var text func(expr ast.Expr) (string, bool)
+	text = func(expr ast.Expr) (string, bool) {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			if e.Kind != token.STRING {
+				return "", false
+			}
+			s, err := strconv.Unquote(e.Value)
+			return s, err == nil
+		case *ast.BinaryExpr:
+			x, okX := text(e.X)
+			y, okY := text(e.Y)
+			return x + y, okX && okY && e.Op == token.ADD
+		case *ast.Ident:
+			if value, ok := consts[e.Name]; ok {
+				return text(value)
+			}
+		}
+		return "", false
+	}
+	add := func(to *[]string, expr ast.Expr) {
+		if s, ok := text(expr); ok && s != "" {
+			*to = append(*to, s)
+		}
+	}
+
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.CallExpr:
+				switch fun := n.Fun.(type) {
+				case *ast.SelectorExpr:
+					if fun.Sel.Name == "T" && len(n.Args) > 0 {
+						add(&texts, n.Args[0])
+					}
+					if fun.Sel.Name == "N" && len(n.Args) == 3 {
+						add(&plurals, n.Args[1])
+					}
+				case *ast.Ident:
+					if fun.Name == "staticPrompt" {
+						add(&texts, n.Args[0])
+					}
+				}
+			case *ast.CompositeLit:
+				field := ""
+				switch typ := n.Type.(type) {
+				case *ast.SelectorExpr:
+					if typ.Sel.Name == "InlineButton" {
+						field = "Text"
+					}
+				case *ast.Ident:
+					if typ.Name == "wizard" {
+						field = "Failure"
+					}
+				}
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok && field != "" {
+						if key, ok := kv.Key.(*ast.Ident); ok && key.Name == field {
+							add(&texts, kv.Value)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	return texts, plurals
+}
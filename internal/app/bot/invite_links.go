@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for managing invite links
+var (
+	btnInviteLinks      = telebot.InlineButton{Unique: "invite_links", Text: "🔗 Invite links"}
+	btnNewInviteLink    = telebot.InlineButton{Unique: "new_invite", Text: "➕ New link"}
+	btnInviteExpiry     = telebot.InlineButton{Unique: "invite_expiry"}
+	btnInviteMaxUses    = telebot.InlineButton{Unique: "invite_uses"}
+	btnRevokeInviteLink = telebot.InlineButton{Unique: "revoke_invite"}
+)
+
+// inviteExpiryOption is a preset lifetime of a new invite link
+type inviteExpiryOption struct {
+	Label string
+	Hours int
+}
+
+// inviteExpiryOptions are the lifetimes offered for new invite links, zero means no expiry
+var inviteExpiryOptions = []inviteExpiryOption{
+	{"No expiry", 0},
+	{"1 hour", 1},
+	{"1 day", 24},
+	{"7 days", 7 * 24},
+	{"30 days", 30 * 24},
+}
+
+// inviteMaxUsesOptions are the usage limits offered for new invite links, zero means unlimited
+var inviteMaxUsesOptions = []int{0, 1, 5, 10, 50}
+
+type inviteLinksHandler struct {
+	service *Service
+}
+
+func newInviteLinksHandler(s *Service) *inviteLinksHandler {
+	return &inviteLinksHandler{service: s}
+}
+
+func (h *inviteLinksHandler) register() {
+	h.service.bot.Handle(&btnInviteLinks, h.handleInviteLinks)
+	h.service.bot.Handle(&btnNewInviteLink, h.handleNewInviteLink)
+	h.service.bot.Handle(&btnInviteExpiry, h.handleInviteExpiry)
+	h.service.bot.Handle(&btnInviteMaxUses, h.handleInviteMaxUses)
+	h.service.bot.Handle(&btnRevokeInviteLink, h.handleRevokeInviteLink)
+}
+
+// describeInviteLink creates a one-line description of an invite link
+func (h *inviteLinksHandler) describeInviteLink(l *Locale, link *domain.InviteLink, viewerID domain.TelegramUserID) string {
+	uses := l.N(link.Uses, "%d use", "%d uses")
+	if link.MaxUses > 0 {
+		uses = l.T("%d of %d uses", link.Uses, link.MaxUses)
+	}
+
+	expiry := l.T("never expires")
+	if link.ExpiresAt != nil {
+		expiry = l.T("expires %s", h.service.formatTime(*link.ExpiresAt, viewerID))
+	}
+
+	return fmt.Sprintf("%s\n   %s, %s", h.service.getSubscriptionURL(link.Code), uses, expiry)
+}
+
+// showInviteLinks replaces the callback message with the list of active invite links
+func (h *inviteLinksHandler) showInviteLinks(c *telebot.Callback, project *domain.Project, header string) {
+	l := h.service.userLocale(c.Sender)
+	links, err := h.service.inviteService.GetActiveByProject(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project invite links", "error", err, "project_id", project.ID)
+		h.service.bot.Send(c.Sender, l.T("Sorry, failed to get invite links. Please try again."))
+		return
+	}
+
+	message := header
+	if message != "" {
+		message += "\n\n"
+	}
+	message += l.T("Invite links of <b>%s</b>", project.Name)
+	if len(links) == 0 {
+		message += "\n\n" + l.T("There are no active invite links.")
+	} else {
+		message += ":\n"
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for i, link := range links {
+		message += fmt.Sprintf("\n%d. %s", i+1, h.describeInviteLink(l, link, project.PublisherID))
+
+		revokeBtn := btnRevokeInviteLink
+		revokeBtn.Text = l.T("🗑 Revoke link %d", i+1)
+		revokeBtn.Data = link.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{revokeBtn})
+	}
+
+	newBtn := l.Button(btnNewInviteLink)
+	newBtn.Data = project.ID.String()
+	backBtn := l.Button(btnBackToProject)
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{newBtn, backBtn})
+
+	_, err = h.service.bot.Edit(c.Message, message,
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML, DisableWebPagePreview: true},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to update invite links message", "error", err)
+	}
+}
+
+// handleInviteLinks shows the active invite links of the project
+func (h *inviteLinksHandler) handleInviteLinks(c *telebot.Callback) {
+	project, ok := h.service.projectManagement.getOwnedProject(c, "invite links")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showInviteLinks(c, project, "")
+}
+
+// handleNewInviteLink asks how long the new invite link should be valid
+func (h *inviteLinksHandler) handleNewInviteLink(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.service.projectManagement.getOwnedProject(c, "new invite link")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	var keyboard [][]telebot.InlineButton
+	for _, option := range inviteExpiryOptions {
+		btn := btnInviteExpiry
+		btn.Text = l.T(option.Label)
+		btn.Data = joinCallbackData(project.ID.String(), strconv.Itoa(option.Hours))
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+	backBtn := btnInviteLinks
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	_, err := h.service.bot.Edit(c.Message, l.T("New invite link for <b>%s</b>\n\nHow long should it be valid?", project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, &telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show invite link expiry options", "error", err)
+	}
+}
+
+// handleInviteExpiry asks how many times the new invite link can be used
+func (h *inviteLinksHandler) handleInviteExpiry(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in invite expiry callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, parts[0], "invite expiry")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	var keyboard [][]telebot.InlineButton
+	for _, maxUses := range inviteMaxUsesOptions {
+		btn := btnInviteMaxUses
+		btn.Text = l.T("Unlimited")
+		if maxUses > 0 {
+			btn.Text = fmt.Sprintf("%d", maxUses)
+		}
+		btn.Data = joinCallbackData(project.ID.String(), parts[1], strconv.Itoa(maxUses))
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+	backBtn := btnNewInviteLink
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	_, err := h.service.bot.Edit(c.Message, l.T("New invite link for <b>%s</b>\n\nHow many times can it be used?", project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, &telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show invite link usage options", "error", err)
+	}
+}
+
+// handleInviteMaxUses creates the new invite link with the chosen options
+func (h *inviteLinksHandler) handleInviteMaxUses(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 3)
+	if !ok {
+		slog.Error("Invalid data in invite max uses callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	hours, errHours := strconv.Atoi(parts[1])
+	maxUses, errUses := strconv.Atoi(parts[2])
+	if errHours != nil || errUses != nil || hours < 0 || maxUses < 0 {
+		slog.Error("Invalid options in invite max uses callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, parts[0], "invite max uses")
+	if !ok {
+		return
+	}
+
+	link, err := h.service.inviteService.Create(project.ID, time.Duration(hours)*time.Hour, maxUses)
+	if err != nil {
+		slog.Error("Failed to create invite link", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to create invite link. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invite link created")})
+	h.showInviteLinks(c, project, l.T("✅ New invite link:")+"\n"+h.describeInviteLink(l, link, project.PublisherID))
+}
+
+// handleRevokeInviteLink revokes an invite link so it can't be used anymore
+func (h *inviteLinksHandler) handleRevokeInviteLink(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	linkID, err := uuid.Parse(c.Data)
+	if err != nil {
+		slog.Error("Invalid invite link ID in revoke callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid invite link. Please try again.")})
+		return
+	}
+
+	link, err := h.service.inviteService.GetByID(linkID)
+	if err != nil {
+		slog.Error("Failed to get invite link", "error", err, "invite_id", linkID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get invite link. Please try again.")})
+		return
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, link.ProjectID.String(), "revoke invite link")
+	if !ok {
+		return
+	}
+
+	if err := h.service.inviteService.Revoke(link.ID); err != nil {
+		slog.Error("Failed to revoke invite link", "error", err, "invite_id", link.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to revoke invite link. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invite link revoked")})
+	h.showInviteLinks(c, project, "")
+}
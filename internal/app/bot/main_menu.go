@@ -1,20 +1,29 @@
 package bot
 
 import (
+	"errors"
 	"log/slog"
 
-	"github.com/google/uuid"
 	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
 )
 
+// mainMenuText is the text of the main menu message
+const mainMenuText = "Choose an option:"
+
 var (
+	btnMainMenu = telebot.InlineButton{Unique: "main_menu", Text: "↩️ Main menu"}
+
+	// btnBackToMenu is a button of the reply keyboard of earlier versions of the bot.
+	// Those keyboards were in English only, so their buttons are matched by the English text.
 	btnBackToMenu = telebot.ReplyButton{Text: "Back to main menu"}
 
 	mainMenu = &telebot.ReplyMarkup{
-		ReplyKeyboard: [][]telebot.ReplyButton{
-			{btnMyProjects, btnMySubscriptions},
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnProjectsList, btnSubscriptionsList},
+			{btnOpenSettings},
 		},
-		ResizeReplyKeyboard: true,
 	}
 )
 
@@ -28,59 +37,98 @@ func newMainMenuHandler(s *Service) *mainMenuHandler {
 
 func (h *mainMenuHandler) register() {
 	h.service.bot.Handle("/start", h.handleStart)
+	h.service.bot.Handle("/cancel", h.handleCancel)
+	h.service.bot.Handle(&btnMainMenu, h.handleMainMenu)
+	h.service.bot.Handle(&btnCancelWizard, h.handleCancelWizard)
 	h.service.bot.Handle(&btnBackToMenu, h.handleBackToMenu)
+	h.service.bot.Handle(&btnCancel, h.handleCancel)
 	h.service.bot.Handle(telebot.OnText, h.handleTextMessage)
 }
 
 func (h *mainMenuHandler) handleStart(m *telebot.Message) {
 	slog.Info("Received /start command", "user_id", m.Sender.ID, "payload", m.Payload)
 	h.service.stateManager.ClearState(m.Sender.ID)
+	l := h.service.userLocale(m.Sender)
 
 	if m.Payload == "" {
-		h.service.bot.Send(m.Sender, "Welcome to Noteo! Choose an option:", mainMenu)
+		if err := h.service.removeReplyKeyboard(m.Sender, l.T("Welcome to Noteo!")); err != nil {
+			slog.Error("Failed to send welcome message", "error", err, "user_id", m.Sender.ID)
+		}
+		h.service.bot.Send(m.Sender, l.T(mainMenuText), l.Markup(mainMenu))
 		return
 	}
 
-	projectID, err := uuid.Parse(m.Payload)
+	project, _, err := h.service.inviteService.Resolve(m.Payload)
 	if err != nil {
-		slog.Error("Invalid project ID in subscription link", "error", err, "payload", m.Payload)
-		h.service.bot.Send(m.Sender, "Sorry, this subscription link is invalid.", mainMenu)
+		slog.Warn("Failed to resolve subscription link", "error", err, "payload", m.Payload)
+		h.service.bot.Send(m.Sender, inviteErrorMessage(l, err), l.Markup(mainMenu))
 		return
 	}
 
-	err = h.service.subscriptions.handleSubscriptionLink(m, projectID)
+	err = h.service.subscriptions.confirmSubscription(m.Sender, project, m.Payload)
 	if err != nil {
 		slog.Error("Failed to handle subscription link", "error", err)
-		h.service.bot.Send(m.Sender, "Sorry, failed to process your subscription. Please try again later.", mainMenu)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to process your subscription. Please try again later."), l.Markup(mainMenu))
+	}
+}
+
+// inviteErrorMessage returns a user-facing message explaining why a subscription link can't be used
+func inviteErrorMessage(l *Locale, err error) string {
+	switch {
+	case errors.Is(err, domain.ErrInviteExpired):
+		return l.T("Sorry, this subscription link has expired. Please ask the publisher for a new one.")
+	case errors.Is(err, domain.ErrInviteRevoked), errors.Is(err, domain.ErrLegacyLinkDisabled):
+		return l.T("Sorry, this subscription link is no longer valid. Please ask the publisher for a new one.")
+	case errors.Is(err, domain.ErrInviteExhausted):
+		return l.T("Sorry, this subscription link has reached its usage limit. Please ask the publisher for a new one.")
+	default:
+		return l.T("Sorry, this subscription link is invalid.")
+	}
+}
+
+// handleMainMenu replaces the callback message with the main menu
+func (h *mainMenuHandler) handleMainMenu(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	l := h.service.userLocale(c.Sender)
+	if _, err := h.service.bot.Edit(c.Message, l.T(mainMenuText), l.Markup(mainMenu)); err != nil {
+		slog.Error("Failed to show main menu", "error", err)
 	}
 }
 
 func (h *mainMenuHandler) handleBackToMenu(m *telebot.Message) {
 	h.service.stateManager.ClearState(m.Sender.ID)
-	h.service.bot.Send(m.Sender, "Main menu:", mainMenu)
+	l := h.service.userLocale(m.Sender)
+	h.service.bot.Send(m.Sender, l.T(mainMenuText), l.Markup(mainMenu))
 }
 
-func (h *mainMenuHandler) handleTextMessage(m *telebot.Message) {
-	state, _, exists := h.service.stateManager.GetState(m.Sender.ID)
-	if !exists {
-		slog.Debug("Received unhandled text message",
-			"text", m.Text,
-			"user_id", m.Sender.ID)
-		h.service.bot.Send(m.Sender, "Please use the menu buttons.", mainMenu)
-		return
+// handleCancel cancels the wizard the user is going through
+func (h *mainMenuHandler) handleCancel(m *telebot.Message) {
+	if !h.service.wizards.cancel(m.Sender, nil) {
+		l := h.service.userLocale(m.Sender)
+		h.service.bot.Send(m.Sender, l.T("There is nothing to cancel.")+" "+l.T(mainMenuText), l.Markup(mainMenu))
 	}
+}
 
-	switch state {
-	case StateCreatingProject:
-		if err := h.service.projects.handleProjectCreation(m); err != nil {
-			slog.Error("Failed to create project", "error", err)
-			h.service.bot.Send(m.Sender, "Sorry, failed to create project. Please try again.", projectsMenu)
+// handleCancelWizard cancels the wizard the user is going through from its prompt
+func (h *mainMenuHandler) handleCancelWizard(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	if !h.service.wizards.cancel(c.Sender, c.Message) {
+		// The wizard has been finished or expired already
+		l := h.service.userLocale(c.Sender)
+		if _, err := h.service.bot.Edit(c.Message, l.T(mainMenuText), l.Markup(mainMenu)); err != nil {
+			slog.Error("Failed to show main menu", "error", err)
 		}
-		h.service.stateManager.ClearState(m.Sender.ID)
+	}
+}
 
-	default:
-		slog.Error("Unknown state", "state", state)
-		h.service.stateManager.ClearState(m.Sender.ID)
-		h.service.bot.Send(m.Sender, "Something went wrong. Please try again.", mainMenu)
+func (h *mainMenuHandler) handleTextMessage(m *telebot.Message) {
+	if h.service.wizards.handle(m) {
+		return
 	}
+
+	slog.Debug("Received unhandled text message",
+		"text", m.Text,
+		"user_id", m.Sender.ID)
+	l := h.service.userLocale(m.Sender)
+	h.service.bot.Send(m.Sender, l.T("Please use the menu buttons."), l.Markup(mainMenu))
 }
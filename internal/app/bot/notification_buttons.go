@@ -0,0 +1,300 @@
+package bot
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Buttons attached to delivered notifications
+var (
+	btnSnooze           = telebot.InlineButton{Unique: "snooze"}
+	btnNotifResume      = telebot.InlineButton{Unique: "notif_resume"}
+	btnNotifUnsub       = telebot.InlineButton{Unique: "notif_unsub", Text: "❌ Unsubscribe"}
+	btnNotifResubscribe = telebot.InlineButton{Unique: "notif_resub", Text: "↩️ Re-subscribe"}
+)
+
+// snoozeOption is a snooze button under a notification
+type snoozeOption struct {
+	Label   string
+	Minutes int
+}
+
+// snoozeOptions are the snooze durations offered under notifications
+var snoozeOptions = []snoozeOption{
+	{"😴 10m", 10},
+	{"😴 1h", 60},
+	{"😴 24h", 24 * 60},
+}
+
+type notificationButtonsHandler struct {
+	service *Service
+}
+
+func newNotificationButtonsHandler(s *Service) *notificationButtonsHandler {
+	return &notificationButtonsHandler{service: s}
+}
+
+func (h *notificationButtonsHandler) register() {
+	h.service.bot.Handle(&btnSnooze, h.handleSnooze)
+	h.service.bot.Handle(&btnNotifResume, h.handleResume)
+	h.service.bot.Handle(&btnNotifUnsub, h.handleUnsubscribe)
+	h.service.bot.Handle(&btnNotifResubscribe, h.handleResubscribe)
+}
+
+// notificationRef identifies the notification buttons refer to. It is the project ID,
+// or the digest ID prefixed with "d" for digests with a "show all" button,
+// so that the button can be kept when the other buttons change.
+func notificationRef(projectID, digestID uuid.UUID) string {
+	if digestID != uuid.Nil {
+		return "d" + digestID.String()
+	}
+	return projectID.String()
+}
+
+// withDigestButton adds the "show all" button of a digest on top of the keyboard,
+// it returns nil if there are no buttons at all
+func (h *notificationButtonsHandler) withDigestButton(l *Locale, digestID uuid.UUID, keyboard [][]telebot.InlineButton) *telebot.ReplyMarkup {
+	if digestID != uuid.Nil {
+		keyboard = append([][]telebot.InlineButton{{h.service.digests.createShowAllButton(l, digestID)}}, keyboard...)
+	}
+	if len(keyboard) == 0 {
+		return nil
+	}
+	return &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// createButtons creates the buttons of a notification from an active subscription
+func (h *notificationButtonsHandler) createButtons(l *Locale, projectID, digestID uuid.UUID) *telebot.ReplyMarkup {
+	ref := notificationRef(projectID, digestID)
+
+	var snoozeRow []telebot.InlineButton
+	for _, option := range snoozeOptions {
+		btn := btnSnooze
+		btn.Text = option.Label
+		btn.Data = joinCallbackData(ref, strconv.Itoa(option.Minutes))
+		snoozeRow = append(snoozeRow, btn)
+	}
+
+	unsubBtn := l.Button(btnNotifUnsub)
+	unsubBtn.Data = ref
+
+	return h.withDigestButton(l, digestID, [][]telebot.InlineButton{
+		snoozeRow,
+		{unsubBtn},
+	})
+}
+
+// createSnoozedButtons creates the buttons of a notification from a snoozed subscription
+func (h *notificationButtonsHandler) createSnoozedButtons(l *Locale, projectID, digestID uuid.UUID, until time.Time) *telebot.ReplyMarkup {
+	ref := notificationRef(projectID, digestID)
+
+	resumeBtn := btnNotifResume
+	resumeBtn.Text = l.T("▶️ Snoozed until %s, resume", formatClock(l, until, time.Now().In(until.Location())))
+	resumeBtn.Data = ref
+
+	unsubBtn := l.Button(btnNotifUnsub)
+	unsubBtn.Data = ref
+
+	return h.withDigestButton(l, digestID, [][]telebot.InlineButton{
+		{resumeBtn},
+		{unsubBtn},
+	})
+}
+
+// createUnsubscribedButtons creates the buttons of a notification from a project the user has left
+func (h *notificationButtonsHandler) createUnsubscribedButtons(l *Locale, projectID, digestID uuid.UUID) *telebot.ReplyMarkup {
+	resubBtn := l.Button(btnNotifResubscribe)
+	resubBtn.Data = notificationRef(projectID, digestID)
+
+	return h.withDigestButton(l, digestID, [][]telebot.InlineButton{
+		{resubBtn},
+	})
+}
+
+// formatClock formats a time briefly, omitting the date when it is today
+func formatClock(l *Locale, t time.Time, now time.Time) string {
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04")
+	}
+	return l.ShortDateTime(t)
+}
+
+// updateButtons replaces the buttons of the notification the callback came from
+func (h *notificationButtonsHandler) updateButtons(c *telebot.Callback, markup *telebot.ReplyMarkup) {
+	if err := h.service.editReplyMarkup(c.Message, markup); err != nil {
+		slog.Error("Failed to update notification buttons", "error", err)
+	}
+}
+
+// parseRef parses the notification reference of a button into the project and digest IDs
+func (h *notificationButtonsHandler) parseRef(c *telebot.Callback, ref string, action string) (uuid.UUID, uuid.UUID, bool) {
+	l := h.service.userLocale(c.Sender)
+	if !strings.HasPrefix(ref, "d") {
+		projectID, err := uuid.Parse(ref)
+		if err != nil {
+			slog.Error("Invalid project ID in "+action+" callback", "error", err, "data", c.Data)
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscription. Please try again.")})
+			return uuid.Nil, uuid.Nil, false
+		}
+		return projectID, uuid.Nil, true
+	}
+
+	digestID, err := uuid.Parse(strings.TrimPrefix(ref, "d"))
+	if err != nil {
+		slog.Error("Invalid digest ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscription. Please try again.")})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	digest, err := h.service.notificationService.GetDigest(digestID)
+	if err != nil {
+		slog.Warn("Digest for notification button not found", "error", err, "digest_id", digestID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This digest has expired.")})
+		h.updateButtons(c, nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return digest.ProjectID, digestID, true
+}
+
+// getSubscription finds the subscription a notification button refers to, along with the digest ID if any.
+// If the user is not subscribed anymore, the buttons are removed.
+func (h *notificationButtonsHandler) getSubscription(c *telebot.Callback, ref string, action string) (*domain.Subscription, uuid.UUID, bool) {
+	projectID, digestID, ok := h.parseRef(c, ref, action)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	sub, err := h.service.subscriptionService.GetSubscription(userID, projectID)
+	if err != nil {
+		slog.Warn("Subscription for notification button not found", "error", err, "user_id", userID, "project_id", projectID)
+		l := h.service.userLocale(c.Sender)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("You are not subscribed to this project anymore.")})
+		h.updateButtons(c, h.withDigestButton(l, digestID, nil))
+		return nil, uuid.Nil, false
+	}
+
+	return sub, digestID, true
+}
+
+// handleSnooze pauses the subscription for the chosen number of minutes
+func (h *notificationButtonsHandler) handleSnooze(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in snooze callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes <= 0 {
+		slog.Error("Invalid duration in snooze callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	sub, digestID, ok := h.getSubscription(c, parts[0], "snooze")
+	if !ok {
+		return
+	}
+
+	until := time.Now().In(h.service.userLocation(sub.UserID)).Add(time.Duration(minutes) * time.Minute)
+	if err := h.service.subscriptionService.PauseNotifications(sub.UserID, sub.ProjectID, until); err != nil {
+		slog.Error("Failed to snooze subscription", "error", err, "project_id", sub.ProjectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to snooze notifications. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: untilConfirmation(l, durationKindPause, until)})
+	h.updateButtons(c, h.createSnoozedButtons(l, sub.ProjectID, digestID, until))
+}
+
+// handleResume ends the snooze of the subscription
+func (h *notificationButtonsHandler) handleResume(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	sub, digestID, ok := h.getSubscription(c, c.Data, "notification resume")
+	if !ok {
+		return
+	}
+
+	if err := h.service.subscriptionService.ResumeNotifications(sub.UserID, sub.ProjectID); err != nil {
+		slog.Error("Failed to resume subscription", "error", err, "project_id", sub.ProjectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to resume notifications. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Notifications resumed")})
+	h.updateButtons(c, h.createButtons(l, sub.ProjectID, digestID))
+	h.service.digests.sendResumeSummary(sub.UserID, sub.ProjectID)
+}
+
+// handleUnsubscribe unsubscribes the user from the project of the notification
+func (h *notificationButtonsHandler) handleUnsubscribe(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	sub, digestID, ok := h.getSubscription(c, c.Data, "notification unsubscribe")
+	if !ok {
+		return
+	}
+
+	if err := h.service.subscriptionService.Unsubscribe(sub.UserID, sub.ProjectID); err != nil {
+		slog.Error("Failed to unsubscribe", "error", err, "project_id", sub.ProjectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to unsubscribe. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Unsubscribed successfully")})
+	h.updateButtons(c, h.createUnsubscribedButtons(l, sub.ProjectID, digestID))
+}
+
+// handleResubscribe subscribes the user back to the project of the notification
+func (h *notificationButtonsHandler) handleResubscribe(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	projectID, digestID, ok := h.parseRef(c, c.Data, "notification resubscribe")
+	if !ok {
+		return
+	}
+
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This project doesn't exist anymore.")})
+		h.updateButtons(c, nil)
+		return
+	}
+
+	// Projects requiring approval go through the publisher again
+	if project.RequiresApproval {
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		if _, err := h.service.subscriptionRequests.requestSubscription(c.Sender, project); err != nil {
+			slog.Error("Failed to request subscription", "error", err)
+			h.service.bot.Send(c.Sender, l.T("Sorry, failed to process your subscription. Please try again later."), l.Markup(mainMenu))
+			return
+		}
+		h.updateButtons(c, h.withDigestButton(l, digestID, nil))
+		return
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	if err := h.service.subscriptionService.Subscribe(userID, projectID); err != nil {
+		if message, ok := subscribeErrorMessage(l, err, project); ok {
+			h.service.bot.Respond(c, &telebot.CallbackResponse{})
+			h.service.bot.Send(c.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+			h.updateButtons(c, h.withDigestButton(l, digestID, nil))
+			return
+		}
+		slog.Error("Failed to resubscribe", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to re-subscribe. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Re-subscribed to %s", project.Name)})
+	h.updateButtons(c, h.createButtons(l, projectID, digestID))
+}
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// pollRetryDelay is the pause after a failed request for updates
+const pollRetryDelay = 5 * time.Second
+
+// telegramAPIURL is where updates are requested, replaced in tests
+var telegramAPIURL = "https://api.telegram.org"
+
+// senderPoller is a long poller that also reports the profiles of the users sending updates
+// and the users blocking or unblocking the bot. Telebot decodes neither the language_code field
+// of users nor my_chat_member updates, so updates are requested directly.
+type senderPoller struct {
+	timeout        time.Duration
+	lastUpdateID   int
+	onSender       func(profile *domain.User)
+	onMemberStatus func(profile *domain.User, status string)
+}
+
+// updateSender is the sender of an update, decoded alongside the update itself
+type updateSender struct {
+	ID           int    `json:"id"`
+	IsBot        bool   `json:"is_bot"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Username     string `json:"username"`
+	LanguageCode string `json:"language_code"`
+}
+
+// profile returns the profile of the sender, nil if the sender isn't a user
+func (s *updateSender) profile() *domain.User {
+	id, err := domain.NewTelegramUserID(int64(s.ID))
+	if err != nil || s.IsBot {
+		return nil
+	}
+	return &domain.User{
+		ID:           id,
+		FirstName:    s.FirstName,
+		LastName:     s.LastName,
+		Username:     s.Username,
+		LanguageCode: s.LanguageCode,
+	}
+}
+
+// senderOf is the part of an update identifying its sender
+type senderOf struct {
+	Message *struct {
+		From *updateSender `json:"from"`
+	} `json:"message"`
+	Callback *struct {
+		From *updateSender `json:"from"`
+	} `json:"callback_query"`
+	// MyChatMember is set when the bot's membership in a chat changes, e.g. when a user blocks the bot
+	MyChatMember *struct {
+		From *updateSender `json:"from"`
+		Chat struct {
+			Type string `json:"type"`
+		} `json:"chat"`
+		NewChatMember struct {
+			Status string `json:"status"`
+		} `json:"new_chat_member"`
+	} `json:"my_chat_member"`
+}
+
+// memberStatus returns the user who changed the bot's membership in their private chat
+// and the new status of the bot, like "kicked" when the bot is blocked
+func (s *senderOf) memberStatus() (*updateSender, string) {
+	if s.MyChatMember == nil || s.MyChatMember.Chat.Type != "private" {
+		return nil, ""
+	}
+	return s.MyChatMember.From, s.MyChatMember.NewChatMember.Status
+}
+
+// sender returns the sender of the update, nil if there is none
+func (s *senderOf) sender() *updateSender {
+	switch {
+	case s.Message != nil:
+		return s.Message.From
+	case s.Callback != nil:
+		return s.Callback.From
+	default:
+		return nil
+	}
+}
+
+// Poll implements telebot.Poller. A stop request cancels the long poll in flight and the pause after
+// a failed one, so that stopping the bot doesn't wait for them.
+func (p *senderPoller) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	// Telebot waits for the stop channel to be closed, which can only happen once the stop was received
+	defer close(stop)
+
+	for {
+		updates, senders, err := p.getUpdates(ctx, b)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Failed to get updates", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for i, update := range updates {
+			if sender := senders[i].sender(); sender != nil {
+				if profile := sender.profile(); profile != nil {
+					p.onSender(profile)
+				}
+			}
+			if sender, status := senders[i].memberStatus(); sender != nil {
+				if profile := sender.profile(); profile != nil {
+					p.onMemberStatus(profile, status)
+				}
+			}
+			// Updates not delivered yet are requested again after a restart
+			select {
+			case <-ctx.Done():
+				return
+			case dest <- update:
+				p.lastUpdateID = update.ID
+			}
+		}
+	}
+}
+
+// getUpdates requests new updates, decoding each of them both as a telebot update and for its sender.
+// Unlike telebot's Raw, the request is cancelled with the context.
+func (p *senderPoller) getUpdates(ctx context.Context, b *telebot.Bot) ([]telebot.Update, []senderOf, error) {
+	payload, err := json.Marshal(map[string]string{
+		"offset":  strconv.Itoa(p.lastUpdateID + 1),
+		"timeout": strconv.Itoa(int(p.timeout / time.Second)),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode getUpdates request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPIURL+"/bot"+b.Token+"/getUpdates", bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create getUpdates request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL contains the bot token, so only the cause is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, nil, fmt.Errorf("getUpdates request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+	respJSON, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read getUpdates response: %w", err)
+	}
+
+	var resp struct {
+		Ok          bool              `json:"ok"`
+		Result      []json.RawMessage `json:"result"`
+		Description string            `json:"description"`
+	}
+	if err := json.Unmarshal(respJSON, &resp); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode getUpdates response: %w", err)
+	}
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("getUpdates failed: %s", resp.Description)
+	}
+
+	updates := make([]telebot.Update, len(resp.Result))
+	senders := make([]senderOf, len(resp.Result))
+	for i, raw := range resp.Result {
+		if err := json.Unmarshal(raw, &updates[i]); err != nil {
+			// Skip the update instead of requesting it again and again
+			slog.Error("Failed to decode update", "error", err)
+			var id struct {
+				ID int `json:"update_id"`
+			}
+			_ = json.Unmarshal(raw, &id)
+			updates[i] = telebot.Update{ID: id.ID}
+		}
+		// The sender is optional, so an update that can't be decoded for it is still delivered
+		_ = json.Unmarshal(raw, &senders[i])
+	}
+	return updates, senders, nil
+}
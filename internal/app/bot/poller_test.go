@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tucnak/telebot"
+)
+
+func TestSenderPoller_Stop(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request)
+	}{
+		{"during a long poll", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}},
+		{"after a failed poll", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"ok":false,"description":"Bad Gateway"}`))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polled := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The server notices the client going away only once the body was read
+				io.ReadAll(r.Body)
+				select {
+				case polled <- struct{}{}:
+				default:
+				}
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+			defer func(url string) { telegramAPIURL = url }(telegramAPIURL)
+			telegramAPIURL = server.URL
+
+			poller := &senderPoller{timeout: time.Minute}
+			stop := make(chan struct{})
+			go poller.Poll(&telebot.Bot{Token: "token"}, make(chan telebot.Update), stop)
+			<-polled
+
+			// Telebot asks the poller to stop and waits for it to close the channel
+			select {
+			case stop <- struct{}{}:
+			case <-time.After(time.Second):
+				t.Fatal("poller didn't take the stop request")
+			}
+			select {
+			case _, ok := <-stop:
+				require.False(t, ok)
+			case <-time.After(time.Second):
+				t.Fatal("poller didn't stop")
+			}
+		})
+	}
+}
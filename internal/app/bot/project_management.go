@@ -0,0 +1,536 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for project management
+var (
+	btnManageProject        = telebot.InlineButton{Unique: "manage_project", Text: "Manage"}
+	btnRenameProject        = telebot.InlineButton{Unique: "rename_project", Text: "✏️ Rename"}
+	btnEditDescription      = telebot.InlineButton{Unique: "edit_description", Text: "📝 Edit description"}
+	btnDeleteProject        = telebot.InlineButton{Unique: "delete_project", Text: "🗑 Delete"}
+	btnConfirmDeleteProject = telebot.InlineButton{Unique: "confirm_delete_project", Text: "✅ Yes, delete"}
+	btnBackToProject        = telebot.InlineButton{Unique: "back_to_project", Text: "↩️ Back"}
+	btnRequireApproval      = telebot.InlineButton{Unique: "require_approval", Text: "🔒 Require approval"}
+	btnDisableApproval      = telebot.InlineButton{Unique: "disable_approval", Text: "🔓 Don't require approval"}
+	btnDisableLegacyLink    = telebot.InlineButton{Unique: "disable_legacy_link", Text: "🚫 Disable legacy share link"}
+	btnConfirmDisableLegacy = telebot.InlineButton{Unique: "confirm_disable_legacy", Text: "✅ Yes, disable"}
+	btnHideNotifButtons     = telebot.InlineButton{Unique: "hide_notif_buttons", Text: "🙈 Hide notification buttons"}
+	btnShowNotifButtons     = telebot.InlineButton{Unique: "show_notif_buttons", Text: "👀 Show notification buttons"}
+)
+
+// Wizards asking the publisher for new project details
+const (
+	wizardRenameProject   = "rename_project"
+	wizardEditDescription = "edit_project_description"
+)
+
+type projectManagementHandler struct {
+	service *Service
+}
+
+func newProjectManagementHandler(s *Service) *projectManagementHandler {
+	return &projectManagementHandler{service: s}
+}
+
+func (h *projectManagementHandler) register() {
+	h.service.bot.Handle(&btnManageProject, h.handleManageProject)
+	h.service.bot.Handle(&btnRenameProject, h.handleRenameProject)
+	h.service.bot.Handle(&btnEditDescription, h.handleEditDescription)
+	h.service.bot.Handle(&btnDeleteProject, h.handleDeleteProject)
+	h.service.bot.Handle(&btnConfirmDeleteProject, h.handleConfirmDeleteProject)
+	h.service.bot.Handle(&btnBackToProject, h.handleBackToProject)
+	h.service.bot.Handle(&btnRequireApproval, h.handleRequireApproval)
+	h.service.bot.Handle(&btnDisableApproval, h.handleDisableApproval)
+	h.service.bot.Handle(&btnDisableLegacyLink, h.handleDisableLegacyLink)
+	h.service.bot.Handle(&btnConfirmDisableLegacy, h.handleConfirmDisableLegacyLink)
+	h.service.bot.Handle(&btnHideNotifButtons, h.handleHideNotificationButtons)
+	h.service.bot.Handle(&btnShowNotifButtons, h.handleShowNotificationButtons)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardRenameProject,
+		Steps:   []wizardStep{{Prompt: h.renamePrompt}},
+		Finish:  h.renameProject,
+		Cancel:  h.showWizardProject,
+		Menu:    projectsMenu,
+		Failure: "Sorry, failed to rename project. Please try again.",
+	})
+	h.service.wizards.add(&wizard{
+		Name:    wizardEditDescription,
+		Steps:   []wizardStep{{Prompt: h.descriptionPrompt}},
+		Finish:  h.updateDescription,
+		Cancel:  h.showWizardProject,
+		Menu:    projectsMenu,
+		Failure: "Sorry, failed to update project description. Please try again.",
+	})
+}
+
+// getOwnedProject parses a project ID from callback data and makes sure
+// the project belongs to the user who pressed the button
+func (h *projectManagementHandler) getOwnedProject(c *telebot.Callback, action string) (*domain.Project, bool) {
+	return h.getOwnedProjectByID(c, c.Data, action)
+}
+
+// getOwnedProjectByID is like getOwnedProject, but takes the raw project ID explicitly
+func (h *projectManagementHandler) getOwnedProjectByID(c *telebot.Callback, rawID string, action string) (*domain.Project, bool) {
+	l := h.service.userLocale(c.Sender)
+	projectID, err := uuid.Parse(rawID)
+	if err != nil {
+		slog.Error("Invalid project ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid project. Please try again.")})
+		return nil, false
+	}
+
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
+		return nil, false
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	if !project.PublisherID.Equal(userID) {
+		slog.Warn("Attempt to manage a project of another publisher", "user_id", userID, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This project is not yours.")})
+		return nil, false
+	}
+
+	return project, true
+}
+
+// createProjectButtons creates the inline keyboard buttons for project management
+func (h *projectManagementHandler) createProjectButtons(l *Locale, project *domain.Project) *telebot.ReplyMarkup {
+	renameBtn := btnRenameProject
+	renameBtn.Data = project.ID.String()
+
+	descriptionBtn := btnEditDescription
+	descriptionBtn.Data = project.ID.String()
+
+	tokenBtn := btnProjectTokens
+	tokenBtn.Data = project.ID.String()
+
+	deleteBtn := btnDeleteProject
+	deleteBtn.Data = project.ID.String()
+
+	subscribersBtn := btnProjectSubscribers
+	subscribersBtn.Data = joinCallbackData(project.ID.String(), "0")
+
+	// Approval mode toggle
+	var approvalBtn telebot.InlineButton
+	if project.RequiresApproval {
+		approvalBtn = btnDisableApproval
+	} else {
+		approvalBtn = btnRequireApproval
+	}
+	approvalBtn.Data = project.ID.String()
+
+	// Notification buttons toggle
+	var notifButtonsBtn telebot.InlineButton
+	if project.NotificationButtonsDisabled {
+		notifButtonsBtn = btnShowNotifButtons
+	} else {
+		notifButtonsBtn = btnHideNotifButtons
+	}
+	notifButtonsBtn.Data = project.ID.String()
+
+	invitesBtn := btnInviteLinks
+	invitesBtn.Data = project.ID.String()
+
+	keyboard := [][]telebot.InlineButton{
+		{subscribersBtn},
+		{invitesBtn},
+		{approvalBtn},
+		{notifButtonsBtn},
+	}
+
+	// Legacy links can only be phased out, new projects don't have them at all
+	if !project.LegacyLinksDisabled {
+		legacyBtn := btnDisableLegacyLink
+		legacyBtn.Data = project.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{legacyBtn})
+	}
+
+	backBtn := btnProjectsList
+	backBtn.Text = "↩️ Back"
+
+	keyboard = append(keyboard,
+		[]telebot.InlineButton{renameBtn},
+		[]telebot.InlineButton{descriptionBtn},
+		[]telebot.InlineButton{tokenBtn},
+		[]telebot.InlineButton{deleteBtn},
+		[]telebot.InlineButton{h.service.history.createOpenButton(l, historyKindProject, project.ID), backBtn},
+	)
+
+	return l.Markup(&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+}
+
+// createConfirmationButtons creates an inline keyboard asking to confirm an action
+func (h *projectManagementHandler) createConfirmationButtons(l *Locale, confirm telebot.InlineButton, projectID uuid.UUID) *telebot.ReplyMarkup {
+	confirm.Data = projectID.String()
+	backBtn := btnBackToProject
+	backBtn.Data = projectID.String()
+
+	return l.Markup(&telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{confirm, backBtn},
+		},
+	})
+}
+
+// createProjectMessage creates a details message for a project
+func (h *projectManagementHandler) createProjectMessage(l *Locale, project *domain.Project) string {
+	subscribers := l.T("unknown")
+	subs, err := h.service.subscriptionService.GetProjectSubscriptions(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project subscriptions", "error", err, "project_id", project.ID)
+	} else {
+		subscribers = fmt.Sprintf("%d", len(subs))
+	}
+
+	invites := l.T("unknown")
+	links, err := h.service.inviteService.GetActiveByProject(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project invite links", "error", err, "project_id", project.ID)
+	} else {
+		invites = fmt.Sprintf("%d", len(links))
+	}
+
+	approval := l.T("not required")
+	if project.RequiresApproval {
+		approval = l.T("required")
+	}
+
+	notifButtons := l.T("shown")
+	if project.NotificationButtonsDisabled {
+		notifButtons = l.T("hidden")
+	}
+
+	tokens := l.T("unknown")
+	projectTokens, err := h.service.tokenService.GetByProject(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project tokens", "error", err, "project_id", project.ID)
+	} else {
+		tokens = fmt.Sprintf("%d", len(projectTokens))
+	}
+
+	message := l.T("Managing project <b>%s</b>\n\n<b>Tokens:</b> %s\n"+
+		"<b>Subscribers:</b> %s\n<b>Active invite links:</b> %s\n<b>Approval of new subscribers:</b> %s\n"+
+		"<b>Snooze and unsubscribe buttons under notifications:</b> %s",
+		project.Name, tokens, subscribers, invites, approval, notifButtons)
+
+	if !project.LegacyLinksDisabled {
+		message += "\n" + l.T("<b>Legacy share link:</b> %s", h.service.getSubscriptionURL(project.ID.String()))
+	}
+
+	if project.Description != "" {
+		message += "\n\n" + l.T("<b>Description:</b>") + "\n" + html.EscapeString(project.Description)
+	}
+
+	return message
+}
+
+// showProject replaces the callback message with the project details and management buttons
+func (h *projectManagementHandler) showProject(c *telebot.Callback, project *domain.Project) {
+	l := h.service.userLocale(c.Sender)
+	_, err := h.service.bot.Edit(c.Message, h.createProjectMessage(l, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createProjectButtons(l, project))
+	if err != nil {
+		slog.Error("Failed to update project management message", "error", err)
+	}
+}
+
+// handleManageProject handles the Manage button click for a project
+func (h *projectManagementHandler) handleManageProject(c *telebot.Callback) {
+	project, ok := h.getOwnedProject(c, "manage project")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showProject(c, project)
+}
+
+// handleBackToProject returns from a confirmation prompt to the project details
+func (h *projectManagementHandler) handleBackToProject(c *telebot.Callback) {
+	project, ok := h.getOwnedProject(c, "back to project")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showProject(c, project)
+}
+
+// handleRequireApproval makes new subscriptions to the project wait for approval
+func (h *projectManagementHandler) handleRequireApproval(c *telebot.Callback) {
+	h.setRequiresApproval(c, true, "Approval is now required")
+}
+
+// handleDisableApproval lets anyone with the link subscribe to the project right away
+func (h *projectManagementHandler) handleDisableApproval(c *telebot.Callback) {
+	h.setRequiresApproval(c, false, "Approval is no longer required")
+}
+
+// setRequiresApproval switches the approval mode of the project and refreshes its details
+func (h *projectManagementHandler) setRequiresApproval(c *telebot.Callback, requiresApproval bool, successMessage string) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "toggle approval")
+	if !ok {
+		return
+	}
+
+	if err := h.service.projectService.SetRequiresApproval(project.ID, requiresApproval); err != nil {
+		slog.Error("Failed to update project approval mode", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update project. Please try again.")})
+		return
+	}
+	project.RequiresApproval = requiresApproval
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T(successMessage)})
+	h.showProject(c, project)
+}
+
+// handleHideNotificationButtons stops attaching snooze and unsubscribe buttons to notifications
+func (h *projectManagementHandler) handleHideNotificationButtons(c *telebot.Callback) {
+	h.setNotificationButtonsDisabled(c, true, "Notification buttons hidden")
+}
+
+// handleShowNotificationButtons attaches snooze and unsubscribe buttons to notifications again
+func (h *projectManagementHandler) handleShowNotificationButtons(c *telebot.Callback) {
+	h.setNotificationButtonsDisabled(c, false, "Notification buttons shown")
+}
+
+// setNotificationButtonsDisabled switches the notification buttons of the project and refreshes its details
+func (h *projectManagementHandler) setNotificationButtonsDisabled(c *telebot.Callback, disabled bool, successMessage string) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "toggle notification buttons")
+	if !ok {
+		return
+	}
+
+	if err := h.service.projectService.SetNotificationButtonsDisabled(project.ID, disabled); err != nil {
+		slog.Error("Failed to update project notification buttons", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update project. Please try again.")})
+		return
+	}
+	project.NotificationButtonsDisabled = disabled
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T(successMessage)})
+	h.showProject(c, project)
+}
+
+// handleDisableLegacyLink asks the publisher to confirm turning off the legacy share link
+func (h *projectManagementHandler) handleDisableLegacyLink(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "disable legacy link")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	message := l.T("Disable the legacy share link of project <b>%s</b>?\n\n"+
+		"Nobody will be able to subscribe with it anymore, only with invite links. "+
+		"Existing subscriptions are not affected. This cannot be undone.", project.Name)
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		h.createConfirmationButtons(l, btnConfirmDisableLegacy, project.ID))
+	if err != nil {
+		slog.Error("Failed to show legacy link confirmation", "error", err)
+	}
+}
+
+// handleConfirmDisableLegacyLink turns off the share link containing the project ID
+func (h *projectManagementHandler) handleConfirmDisableLegacyLink(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "confirm disable legacy link")
+	if !ok {
+		return
+	}
+
+	if err := h.service.projectService.SetLegacyLinksDisabled(project.ID, true); err != nil {
+		slog.Error("Failed to disable legacy link", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update project. Please try again.")})
+		return
+	}
+	project.LegacyLinksDisabled = true
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Legacy share link disabled")})
+	h.showProject(c, project)
+}
+
+// handleRenameProject asks the publisher for a new project name
+func (h *projectManagementHandler) handleRenameProject(c *telebot.Callback) {
+	project, ok := h.getOwnedProject(c, "rename project")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardRenameProject, StateData{ProjectID: project.ID})
+}
+
+// wizardProject returns the project of a wizard, making sure it belongs to the user
+func (h *projectManagementHandler) wizardProject(w *wizardContext) (*domain.Project, error) {
+	projectID := w.Data.ProjectID
+	if projectID == uuid.Nil {
+		return nil, fmt.Errorf("project ID is missing in state data")
+	}
+
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	if !project.PublisherID.Equal(w.UserID()) {
+		return nil, fmt.Errorf("project %s does not belong to user %s", projectID, w.UserID())
+	}
+	return project, nil
+}
+
+func (h *projectManagementHandler) renamePrompt(w *wizardContext) (string, error) {
+	project, err := h.wizardProject(w)
+	if err != nil {
+		return "", err
+	}
+	return w.Locale.T("Please enter the new name for project <b>%s</b>:", project.Name), nil
+}
+
+// renameProject applies the new project name entered by the publisher
+func (h *projectManagementHandler) renameProject(w *wizardContext) error {
+	project, err := h.wizardProject(w)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.projectService.UpdateName(project.ID, w.Answer(0)); err != nil {
+		if message, ok := projectNameErrorMessage(w.Locale, err); ok {
+			return invalidAnswer(message)
+		}
+		return fmt.Errorf("failed to update project name: %w", err)
+	}
+
+	project, err = h.service.projectService.GetByID(project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get renamed project: %w", err)
+	}
+
+	w.Reply(w.Locale.T("✅ Project renamed.")+"\n\n"+h.createProjectMessage(w.Locale, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createProjectButtons(w.Locale, project))
+	return nil
+}
+
+// showWizardProject shows the details of the project again when a wizard about it is cancelled
+func (h *projectManagementHandler) showWizardProject(w *wizardContext) error {
+	project, err := h.wizardProject(w)
+	if err != nil {
+		return err
+	}
+	return w.Show(h.createProjectMessage(w.Locale, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createProjectButtons(w.Locale, project))
+}
+
+// handleEditDescription asks the publisher for a new project description
+func (h *projectManagementHandler) handleEditDescription(c *telebot.Callback) {
+	project, ok := h.getOwnedProject(c, "edit description")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardEditDescription, StateData{ProjectID: project.ID})
+}
+
+func (h *projectManagementHandler) descriptionPrompt(w *wizardContext) (string, error) {
+	project, err := h.wizardProject(w)
+	if err != nil {
+		return "", err
+	}
+	return w.Locale.T("Please enter the new description for project <b>%s</b>. "+
+		"It is shown to users before they subscribe. Send a single dash (-) to remove the description.", project.Name), nil
+}
+
+// updateDescription applies the new project description entered by the publisher
+func (h *projectManagementHandler) updateDescription(w *wizardContext) error {
+	project, err := h.wizardProject(w)
+	if err != nil {
+		return err
+	}
+
+	description := w.Answer(0)
+	if strings.TrimSpace(description) == "-" {
+		description = ""
+	}
+
+	if err := h.service.projectService.UpdateDescription(project.ID, description); err != nil {
+		if errors.Is(err, domain.ErrInvalidProjectDescription) {
+			return invalidAnswer(w.Locale.T("The description must be at most %d characters. Please enter a shorter one:",
+				domain.MaxProjectDescriptionLength))
+		}
+		return fmt.Errorf("failed to update project description: %w", err)
+	}
+
+	project, err = h.service.projectService.GetByID(project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get updated project: %w", err)
+	}
+
+	w.Reply(w.Locale.T("✅ Project description updated.")+"\n\n"+h.createProjectMessage(w.Locale, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createProjectButtons(w.Locale, project))
+	return nil
+}
+
+// handleDeleteProject asks the publisher to confirm project deletion
+func (h *projectManagementHandler) handleDeleteProject(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "delete project")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	message := l.T("Delete project <b>%s</b>?\n\n"+
+		"All its subscriptions will be removed and subscribers will be notified. This cannot be undone.",
+		project.Name)
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		h.createConfirmationButtons(l, btnConfirmDeleteProject, project.ID))
+	if err != nil {
+		slog.Error("Failed to show project deletion confirmation", "error", err)
+	}
+}
+
+// handleConfirmDeleteProject deletes the project and notifies its subscribers
+func (h *projectManagementHandler) handleConfirmDeleteProject(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.getOwnedProject(c, "confirm delete project")
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.service.projectService.Delete(project.ID)
+	if err != nil {
+		slog.Error("Failed to delete project", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to delete project. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Project deleted")})
+
+	message := l.T("Project <b>%s</b> has been deleted.", project.Name)
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(projectsMenu))
+	if err != nil {
+		slog.Error("Failed to update project message after deletion", "error", err)
+	}
+
+	h.service.NotifyProjectDeleted(project, subscriptions)
+}
@@ -1,34 +1,36 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 
 	"github.com/tucnak/telebot"
 
 	"github.com/sergeax/noteo/internal/domain"
 )
 
+// Menu items for projects, data of the projects list button is the page
 var (
+	btnProjectsList  = telebot.InlineButton{Unique: "projects", Text: "📁 My projects"}
+	btnCreateProject = telebot.InlineButton{Unique: "create_project", Text: "➕ Create new"}
+
+	// Buttons of the reply keyboard of earlier versions of the bot, matched by their English text
 	btnMyProjects = telebot.ReplyButton{Text: "My Projects"}
 	btnCreateNew  = telebot.ReplyButton{Text: "Create new"}
 	btnCancel     = telebot.ReplyButton{Text: "Cancel"}
 
 	projectsMenu = &telebot.ReplyMarkup{
-		ReplyKeyboard: [][]telebot.ReplyButton{
-			{btnCreateNew, btnBackToMenu},
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnProjectsList, btnMainMenu},
 		},
-		ResizeReplyKeyboard: true,
-	}
-
-	cancelMenu = &telebot.ReplyMarkup{
-		ReplyKeyboard: [][]telebot.ReplyButton{
-			{btnCancel},
-		},
-		ResizeReplyKeyboard: true,
 	}
 )
 
+// wizardCreateProject asks the publisher for the name of a new project
+const wizardCreateProject = "create_project"
+
 type projectsHandler struct {
 	service *Service
 }
@@ -38,55 +40,157 @@ func newProjectsHandler(s *Service) *projectsHandler {
 }
 
 func (h *projectsHandler) register() {
+	h.service.bot.Handle(&btnProjectsList, h.handleProjectsList)
+	h.service.bot.Handle(&btnCreateProject, h.handleCreateProject)
 	h.service.bot.Handle(&btnMyProjects, h.handleMyProjects)
-	h.service.bot.Handle(&btnCreateNew, h.handleCreateProject)
-	h.service.bot.Handle(&btnCancel, h.handleCancel)
+	h.service.bot.Handle(&btnCreateNew, h.handleCreateNew)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardCreateProject,
+		Steps:   []wizardStep{{Prompt: staticPrompt("Please enter the name for your new project:")}},
+		Finish:  h.createProject,
+		Cancel:  h.showProjectsList,
+		Menu:    projectsMenu,
+		Failure: "Sorry, failed to create project. Please try again.",
+	})
 }
 
-func (h *projectsHandler) handleMyProjects(m *telebot.Message) {
-	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
+// createProjectsList creates the menu message with a page of the user's projects
+func (h *projectsHandler) createProjectsList(userID domain.TelegramUserID, page int) (string, *telebot.ReplyMarkup, error) {
 	projects, err := h.service.projectService.GetByPublisher(userID)
 	if err != nil {
-		slog.Error("Failed to get projects", "error", err)
-		h.service.bot.Send(m.Sender, "Sorry, failed to get your projects. Please try again.", projectsMenu)
-		return
+		return "", nil, fmt.Errorf("failed to get projects: %w", err)
 	}
 
+	start, end, page, pages := paginate(len(projects), page, menuPageSize)
+
+	l := h.service.locale(userID)
+	message := l.T("📁 <b>My projects</b>")
 	if len(projects) == 0 {
-		h.service.bot.Send(m.Sender, "You don't have any projects yet.", projectsMenu)
+		message += "\n\n" + l.T("You don't have any projects yet.")
+	} else if pages > 1 {
+		message += " " + l.T("(page %d of %d)", page+1, pages)
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for _, project := range projects[start:end] {
+		btn := btnManageProject
+		btn.Text = project.Name
+		btn.Data = project.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	navigation := createPaginationRow(l, btnProjectsList, page, pages, strconv.Itoa)
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnCreateProject), l.Button(btnAPIKey)})
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnMainMenu)})
+
+	return message, &telebot.ReplyMarkup{InlineKeyboard: keyboard}, nil
+}
+
+// handleProjectsList replaces the callback message with a page of the user's projects
+func (h *projectsHandler) handleProjectsList(c *telebot.Callback) {
+	page, _ := strconv.Atoi(c.Data)
+	message, markup, err := h.createProjectsList(domain.MustNewTelegramUserID(int64(c.Sender.ID)), page)
+	if err != nil {
+		slog.Error("Failed to show projects", "error", err)
+		l := h.service.userLocale(c.Sender)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get your projects. Please try again.")})
 		return
 	}
 
-	var message string
-	for i, project := range projects {
-		message += fmt.Sprintf("%d. <b>%s</b>\n   Token: <code>%s</code>\n   Share link: %s\n\n",
-			i+1, project.Name, project.Token, h.service.getSubscriptionURL(project.ID))
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	if _, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup); err != nil {
+		slog.Error("Failed to update projects message", "error", err)
+	}
+}
+
+// handleMyProjects sends the list of the user's projects as a new message
+func (h *projectsHandler) handleMyProjects(m *telebot.Message) {
+	message, markup, err := h.createProjectsList(domain.MustNewTelegramUserID(int64(m.Sender.ID)), 0)
+	if err != nil {
+		slog.Error("Failed to show projects", "error", err)
+		l := h.service.userLocale(m.Sender)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get your projects. Please try again."), l.Markup(mainMenu))
+		return
 	}
+	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+}
 
-	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, projectsMenu)
+// showProjectsList shows the first page of the projects list when the project creation is cancelled
+func (h *projectsHandler) showProjectsList(w *wizardContext) error {
+	message, markup, err := h.createProjectsList(w.UserID(), 0)
+	if err != nil {
+		return err
+	}
+	return w.Show(message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
 }
 
-func (h *projectsHandler) handleCreateProject(m *telebot.Message) {
-	h.service.stateManager.SetState(m.Sender.ID, StateCreatingProject, nil)
-	h.service.bot.Send(m.Sender, "Please enter the name for your new project:", cancelMenu)
+func (h *projectsHandler) handleCreateProject(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardCreateProject, StateData{})
 }
 
-func (h *projectsHandler) handleCancel(m *telebot.Message) {
-	h.service.stateManager.ClearState(m.Sender.ID)
-	h.service.bot.Send(m.Sender, "Operation cancelled.", projectsMenu)
+func (h *projectsHandler) handleCreateNew(m *telebot.Message) {
+	h.service.wizards.start(m.Sender, nil, wizardCreateProject, StateData{})
 }
 
-func (h *projectsHandler) handleProjectCreation(m *telebot.Message) error {
-	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
-	project, err := h.service.projectService.Create(userID, m.Text)
+// createProject creates a project with the name entered by the publisher
+func (h *projectsHandler) createProject(w *wizardContext) error {
+	project, err := h.service.projectService.Create(w.UserID(), w.Answer(0))
 	if err != nil {
+		if message, ok := projectNameErrorMessage(w.Locale, err); ok {
+			return invalidAnswer(message)
+		}
 		return fmt.Errorf("failed to create project: %w", err)
 	}
 
-	message := fmt.Sprintf("Project created successfully!\n\n<b>Name:</b> %s\n<b>Token:</b> <code>%s</code>\n\n"+
-		"Share this link to let users subscribe to your project:\n%s",
-		project.Name, project.Token, h.service.getSubscriptionURL(project.ID))
+	l := w.Locale
+	message := l.T("Project created successfully!\n\n<b>Name:</b> %s", project.Name)
+
+	// Create a default token allowing everything, more can be added from project management
+	token, err := h.service.tokenService.Create(project.ID, domain.TokenOptions{Name: domain.DefaultTokenName})
+	if err != nil {
+		slog.Error("Failed to create default project token", "error", err, "project_id", project.ID)
+		message += "\n" + l.T("Use <b>Tokens</b> in project management to create a token for sending notifications.")
+	} else {
+		message += "\n" + l.T("<b>Token:</b> <code>%s</code>", token.Token) + "\n\n" + l.T(tokenShownOnce)
+	}
 
-	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, projectsMenu)
+	// Create a default invite link, more can be added from project management
+	link, err := h.service.inviteService.Create(project.ID, 0, 0)
+	if err != nil {
+		slog.Error("Failed to create default invite link", "error", err, "project_id", project.ID)
+		message += "\n\n" + l.T("Use <b>Invite links</b> in project management to create a link for your subscribers.")
+	} else {
+		message += "\n\n" + l.T("Share this link to let users subscribe to your project:\n%s",
+			h.service.getSubscriptionURL(link.Code))
+	}
+
+	manageBtn := l.Button(btnManageProject)
+	manageBtn.Data = project.ID.String()
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{manageBtn},
+			{l.Button(btnProjectsList), l.Button(btnMainMenu)},
+		},
+	}
+
+	w.Reply(message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
 	return nil
 }
+
+// projectNameErrorMessage returns a user-facing message for project name validation errors
+func projectNameErrorMessage(l *Locale, err error) (string, bool) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidProjectName):
+		return l.T("This name can't be used: it must be a single line of 1 to %d characters. "+
+			"Please enter another name:", domain.MaxProjectNameLength), true
+	case errors.Is(err, domain.ErrProjectNameTaken):
+		return l.T("You already have a project with this name. Please enter another name:"), true
+	default:
+		return "", false
+	}
+}
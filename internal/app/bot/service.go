@@ -1,11 +1,15 @@
 package bot
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/tucnak/telebot"
 
 	"github.com/sergeax/noteo/internal/domain"
@@ -14,24 +18,57 @@ import (
 type Service struct {
 	bot                 *telebot.Bot
 	projectService      *domain.ProjectService
+	tokenService        *domain.TokenService
+	signingService      *domain.SigningService
 	subscriptionService *domain.SubscriptionService
+	inviteService       *domain.InviteService
+	userSettingsService *domain.UserSettingsService
+	notificationService *domain.NotificationService
+	historyService      *domain.HistoryService
+	userService         *domain.UserService
+	apiKeyService       *domain.APIKeyService
 	stateManager        *StateManager
+	wizards             *wizardEngine
+	// users caches the saved users by their ID, so that updates don't hit the database
+	users sync.Map
 
 	mainMenu               *mainMenuHandler
 	projects               *projectsHandler
+	projectManagement      *projectManagementHandler
+	tokens                 *tokensHandler
+	signing                *signingHandler
+	inviteLinks            *inviteLinksHandler
+	apiKeys                *apiKeysHandler
+	subscribers            *subscribersHandler
 	subscriptions          *subscriptionsHandler
 	subscriptionManagement *subscriptionManagementHandler
+	subscriptionRequests   *subscriptionRequestsHandler
+	durations              *durationsHandler
+	settings               *settingsHandler
+	notificationButtons    *notificationButtonsHandler
+	digests                *digestsHandler
+	history                *historyHandler
+	commands               *commandsHandler
 }
 
 func NewService(
 	cfg *Config,
 	projectService *domain.ProjectService,
+	tokenService *domain.TokenService,
+	signingService *domain.SigningService,
 	subscriptionService *domain.SubscriptionService,
+	inviteService *domain.InviteService,
+	userSettingsService *domain.UserSettingsService,
+	notificationService *domain.NotificationService,
+	historyService *domain.HistoryService,
+	userService *domain.UserService,
+	apiKeyService *domain.APIKeyService,
 	stateManager *StateManager,
 ) (*Service, error) {
+	poller := &senderPoller{timeout: 10 * time.Second}
 	bot, err := telebot.NewBot(telebot.Settings{
 		Token:  cfg.Token,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		Poller: poller,
 	})
 	if err != nil {
 		return nil, err
@@ -40,15 +77,39 @@ func NewService(
 	service := &Service{
 		bot:                 bot,
 		projectService:      projectService,
+		tokenService:        tokenService,
+		signingService:      signingService,
 		subscriptionService: subscriptionService,
+		inviteService:       inviteService,
+		userSettingsService: userSettingsService,
+		notificationService: notificationService,
+		historyService:      historyService,
+		userService:         userService,
+		apiKeyService:       apiKeyService,
 		stateManager:        stateManager,
 	}
+	service.wizards = newWizardEngine(bot, stateManager, service.userLocale)
+	poller.onSender = service.recordUser
+	poller.onMemberStatus = service.recordMemberStatus
 
 	// Initialize handlers
 	service.mainMenu = newMainMenuHandler(service)
 	service.projects = newProjectsHandler(service)
+	service.projectManagement = newProjectManagementHandler(service)
+	service.tokens = newTokensHandler(service)
+	service.signing = newSigningHandler(service)
+	service.inviteLinks = newInviteLinksHandler(service)
+	service.apiKeys = newAPIKeysHandler(service)
+	service.subscribers = newSubscribersHandler(service)
 	service.subscriptions = newSubscriptionsHandler(service)
 	service.subscriptionManagement = newSubscriptionManagementHandler(service)
+	service.subscriptionRequests = newSubscriptionRequestsHandler(service)
+	service.durations = newDurationsHandler(service)
+	service.settings = newSettingsHandler(service)
+	service.notificationButtons = newNotificationButtonsHandler(service)
+	service.digests = newDigestsHandler(service)
+	service.history = newHistoryHandler(service)
+	service.commands = newCommandsHandler(service)
 
 	// Register handlers
 	service.registerHandlers()
@@ -56,34 +117,330 @@ func NewService(
 	return service, nil
 }
 
-// SendMessage implements the queue.MessageSender interface
+// SendMessage implements the queue.MessageSender interface.
+// The API is called directly, as telebot drops the error codes needed to tell permanent errors apart.
+// If the user can't receive messages anymore, their subscriptions are deactivated
+// and a *domain.UndeliverableError is returned.
 func (s *Service) SendMessage(msg domain.Message) error {
-	sendParams := &telebot.SendOptions{}
+	params := map[string]string{
+		"chat_id": msg.UserID.String(),
+		"text":    msg.Text,
+	}
 	if msg.Muted {
-		sendParams.DisableNotification = true
+		params["disable_notification"] = "true"
+	}
+
+	// The settings aren't looked up for every message, the language chosen by the user comes with it
+	// and the Telegram profiles of users are cached
+	l := findLocale(msg.Language)
+	if msg.Language == "" {
+		l = findLocale(s.telegramLanguage(msg.UserID))
+	}
+	var markup *telebot.ReplyMarkup
+	if msg.WithButtons {
+		markup = s.notificationButtons.createButtons(l, msg.ProjectID, msg.DigestID)
+	} else {
+		markup = s.notificationButtons.withDigestButton(l, msg.DigestID, nil)
+	}
+	if markup != nil {
+		replyMarkup, err := encodeReplyMarkup(markup)
+		if err != nil {
+			return err
+		}
+		params["reply_markup"] = replyMarkup
 	}
 
-	_, err := s.bot.Send(&telebot.Chat{ID: msg.UserID.Int64()}, msg.Text, sendParams)
+	err := s.callAPI("sendMessage", params)
+	if undeliverable := classifyUndeliverable(msg.UserID, err); undeliverable != nil {
+		s.markUnreachable(undeliverable.UserID, undeliverable.State)
+		return undeliverable
+	}
 	return err
 }
 
+// NotifyProjectDeleted lets the former subscribers of a deleted project know
+// they won't receive notifications from it anymore
+func (s *Service) NotifyProjectDeleted(project *domain.Project, subscriptions []*domain.Subscription) {
+	for _, sub := range subscriptions {
+		notice := s.locale(sub.UserID).T("Project <b>%s</b> has been deleted by its publisher. "+
+			"You will no longer receive notifications from it.", project.Name)
+		_, err := s.bot.Send(&telebot.Chat{ID: sub.UserID.Int64()}, notice,
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML})
+		if err != nil {
+			slog.Error("Failed to notify subscriber about project deletion",
+				"error", err, "chatId", sub.UserID, "project_id", project.ID)
+		}
+	}
+}
+
+// editReplyMarkup replaces the inline buttons of a message keeping its text,
+// nil markup removes the buttons. Telebot has no method for that, so the API is called directly.
+func (s *Service) editReplyMarkup(message telebot.Editable, markup *telebot.ReplyMarkup) error {
+	messageID, chatID := message.MessageSig()
+	params := map[string]string{
+		"chat_id":    strconv.FormatInt(chatID, 10),
+		"message_id": strconv.Itoa(messageID),
+	}
+
+	if markup != nil {
+		replyMarkup, err := encodeReplyMarkup(markup)
+		if err != nil {
+			return err
+		}
+		params["reply_markup"] = replyMarkup
+	}
+
+	if err := s.callAPI("editMessageReplyMarkup", params); err != nil {
+		return fmt.Errorf("failed to edit reply markup: %w", err)
+	}
+	return nil
+}
+
+// encodeReplyMarkup encodes inline buttons for a direct call of the API
+func encodeReplyMarkup(markup *telebot.ReplyMarkup) (string, error) {
+	var keyboard [][]telebot.InlineButton
+	for _, row := range markup.InlineKeyboard {
+		var buttons []telebot.InlineButton
+		for _, btn := range row {
+			// Callback data format expected by telebot handlers
+			if btn.Unique != "" && btn.Data != "" {
+				btn.Data = "\f" + joinCallbackData(btn.Unique, btn.Data)
+			} else if btn.Unique != "" {
+				btn.Data = "\f" + btn.Unique
+			}
+			buttons = append(buttons, btn)
+		}
+		keyboard = append(keyboard, buttons)
+	}
+
+	replyMarkup, err := json.Marshal(telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reply markup: %w", err)
+	}
+	return string(replyMarkup), nil
+}
+
+// callAPI calls a method of the Bot API that telebot doesn't support and checks the response,
+// unsuccessful responses are returned as *apiError, failures to reach it match domain.ErrMessengerUnavailable
+func (s *Service) callAPI(method string, params map[string]string) error {
+	respJSON, err := s.bot.Raw(method, params)
+	if err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrMessengerUnavailable, err)
+	}
+
+	var resp struct {
+		Ok          bool   `json:"ok"`
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
+		Parameters  struct {
+			MigrateToChatID int64 `json:"migrate_to_chat_id"`
+			RetryAfter      int   `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(respJSON, &resp); err != nil {
+		// Proxies in front of the API answer with HTML pages when it is down
+		return fmt.Errorf("%w: failed to decode %s response: %w", domain.ErrMessengerUnavailable, method, err)
+	}
+	if !resp.Ok {
+		return &apiError{
+			Method:          method,
+			Code:            resp.ErrorCode,
+			Description:     resp.Description,
+			MigrateToChatID: resp.Parameters.MigrateToChatID,
+			retryAfter:      time.Duration(resp.Parameters.RetryAfter) * time.Second,
+		}
+	}
+	return nil
+}
+
 func (s *Service) registerHandlers() {
 	s.mainMenu.register()
 	s.projects.register()
+	s.projectManagement.register()
+	s.tokens.register()
+	s.signing.register()
+	s.inviteLinks.register()
+	s.apiKeys.register()
+	s.subscribers.register()
 	s.subscriptions.register()
 	s.subscriptionManagement.register()
+	s.subscriptionRequests.register()
+	s.durations.register()
+	s.settings.register()
+	s.notificationButtons.register()
+	s.digests.register()
+	s.history.register()
+	s.commands.register()
+}
+
+// getSubscriptionURL returns a deep link to the bot with the given start payload,
+// which is an invite code or, for legacy links, a project ID
+func (s *Service) getSubscriptionURL(payload string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", s.bot.Me.Username, payload)
 }
 
-func (s *Service) getSubscriptionURL(projectID uuid.UUID) string {
-	return fmt.Sprintf("https://t.me/%s?start=%s", s.bot.Me.Username, projectID.String())
+// getDisplayName returns a human-readable name of a Telegram user
+func (s *Service) getDisplayName(userID domain.TelegramUserID) string {
+	if user, ok := s.getUser(userID); ok {
+		return user.DisplayName()
+	}
+
+	// Users who haven't interacted with the bot since users are saved are asked from Telegram
+	chat, err := s.bot.ChatByID(userID.String())
+	if err != nil {
+		slog.Warn("Failed to get user details", "error", err, "user_id", userID)
+		return fmt.Sprintf("User %s", userID)
+	}
+	user := &domain.User{ID: userID, FirstName: chat.FirstName, LastName: chat.LastName, Username: chat.Username}
+	return user.DisplayName()
+}
+
+// getUser returns the saved user, false if they haven't interacted with the bot yet
+func (s *Service) getUser(userID domain.TelegramUserID) (*domain.User, bool) {
+	if cached, ok := s.users.Load(userID); ok {
+		return cached.(*domain.User), true
+	}
+
+	user, err := s.userService.Get(userID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			slog.Error("Failed to get user", "error", err, "user_id", userID)
+		}
+		return nil, false
+	}
+	s.users.Store(userID, user)
+	return user, true
+}
+
+// recordUser saves the profile of the user who sent an update, it is called for every update
+func (s *Service) recordUser(profile *domain.User) {
+	if user, ok := s.getUser(profile.ID); ok && !user.Outdated(profile, time.Now()) {
+		return
+	}
+
+	user, err := s.userService.Seen(profile)
+	if err != nil {
+		slog.Error("Failed to save user", "error", err, "user_id", profile.ID)
+		return
+	}
+	s.users.Store(user.ID, user)
+}
+
+// telegramLanguage returns the language of the user's Telegram app, empty if it is unknown
+func (s *Service) telegramLanguage(userID domain.TelegramUserID) string {
+	if user, ok := s.getUser(userID); ok {
+		return user.LanguageCode
+	}
+	return ""
+}
+
+// userLocation returns the timezone of the user
+func (s *Service) userLocation(userID domain.TelegramUserID) *time.Location {
+	return s.userSettingsService.Location(userID)
+}
+
+// formatTime formats a time in the timezone and language of the user it is shown to
+func (s *Service) formatTime(t time.Time, userID domain.TelegramUserID) string {
+	return s.locale(userID).DateTime(t.In(s.userLocation(userID)))
+}
+
+// locale returns the locale of the user: the language chosen in the settings, or the language of their Telegram app
+func (s *Service) locale(userID domain.TelegramUserID) *Locale {
+	settings, err := s.userSettingsService.Get(userID)
+	if err != nil {
+		slog.Warn("Failed to get user language", "error", err, "user_id", userID)
+		return locales[0]
+	}
+	if settings.Language != "" {
+		return findLocale(settings.Language)
+	}
+	return findLocale(s.telegramLanguage(userID))
+}
+
+// userLocale returns the locale of a Telegram user
+func (s *Service) userLocale(user *telebot.User) *Locale {
+	return s.locale(domain.MustNewTelegramUserID(int64(user.ID)))
+}
+
+// menuPageSize is the number of items shown on one page of a menu list
+const menuPageSize = 8
+
+// paginate clamps the page to the available ones and returns the range of its items
+func paginate(total, page, size int) (start, end, clamped, pages int) {
+	pages = max((total+size-1)/size, 1)
+	clamped = min(max(page, 0), pages-1)
+	start = clamped * size
+	end = min(start+size, total)
+	return start, end, clamped, pages
+}
+
+// createPaginationRow creates the previous and next page buttons of a menu list, nil if there is one page only
+func createPaginationRow(l *Locale, btn telebot.InlineButton, page, pages int, data func(page int) string) []telebot.InlineButton {
+	var row []telebot.InlineButton
+	if page > 0 {
+		prevBtn := btn
+		prevBtn.Text = l.T("⬅️ Previous")
+		prevBtn.Data = data(page - 1)
+		row = append(row, prevBtn)
+	}
+	if page < pages-1 {
+		nextBtn := btn
+		nextBtn.Text = l.T("Next ➡️")
+		nextBtn.Data = data(page + 1)
+		row = append(row, nextBtn)
+	}
+	return row
+}
+
+// removeReplyKeyboard sends a message removing the reply keyboard of earlier versions of the bot.
+// Telebot has no option for that, so the API is called directly.
+func (s *Service) removeReplyKeyboard(to *telebot.User, text string) error {
+	params := map[string]string{
+		"chat_id":      strconv.Itoa(to.ID),
+		"text":         text,
+		"reply_markup": `{"remove_keyboard":true}`,
+	}
+	if err := s.callAPI("sendMessage", params); err != nil {
+		return fmt.Errorf("failed to remove reply keyboard: %w", err)
+	}
+	return nil
+}
+
+// joinCallbackData combines several values into inline button data
+func joinCallbackData(parts ...string) string {
+	return strings.Join(parts, "|")
+}
+
+// splitCallbackData splits inline button data into exactly n values
+func splitCallbackData(data string, n int) ([]string, bool) {
+	parts := strings.Split(data, "|")
+	if len(parts) != n {
+		return nil, false
+	}
+	return parts, true
 }
 
 func (s *Service) Start() {
 	slog.Info("Starting Telegram bot", "username", s.bot.Me.Username, "url", "https://t.me/"+s.bot.Me.Username)
+	if err := s.setCommands(); err != nil {
+		slog.Error("Failed to register bot commands", "error", err)
+	}
+	s.stateManager.Start(s.notifyStateExpired)
 	s.bot.Start()
 }
 
 // Stop gracefully stops the bot
 func (s *Service) Stop() {
 	s.bot.Stop()
+	s.stateManager.Stop()
+}
+
+// notifyStateExpired tells the user that the action they started has expired
+func (s *Service) notifyStateExpired(userID int, state UserState) {
+	l := s.locale(domain.MustNewTelegramUserID(int64(userID)))
+	_, err := s.bot.Send(&telebot.Chat{ID: int64(userID)},
+		l.T("⌛ Your last action has expired. Please start it again from the menu."), l.Markup(mainMenu))
+	if err != nil {
+		slog.Error("Failed to notify about expired action", "error", err, "user_id", userID, "state", state)
+	}
 }
@@ -0,0 +1,331 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for user settings
+var (
+	btnOpenSettings     = telebot.InlineButton{Unique: "settings", Text: "⚙️ Settings"}
+	btnSetTimezone      = telebot.InlineButton{Unique: "set_timezone", Text: "🌍 Change timezone"}
+	btnAddQuietHours    = telebot.InlineButton{Unique: "add_quiet_hours", Text: "➕ Add quiet hours"}
+	btnRemoveQuietHours = telebot.InlineButton{Unique: "remove_quiet_hours"}
+	btnQuietMode        = telebot.InlineButton{Unique: "quiet_mode"}
+	btnLanguage         = telebot.InlineButton{Unique: "language", Text: "🌐 Language"}
+	btnSetLanguage      = telebot.InlineButton{Unique: "set_language"}
+
+	// btnSettings is a button of the reply keyboard of earlier versions of the bot
+	btnSettings = telebot.ReplyButton{Text: "⚙️ Settings"}
+)
+
+// quietModeDescriptions describe what happens to notifications during quiet hours
+var quietModeDescriptions = map[domain.QuietMode]string{
+	domain.QuietModeDefault: "as in settings",
+	domain.QuietModeOff:     "ignored, delivered as usual",
+	domain.QuietModeSilent:  "delivered silently",
+	domain.QuietModeHold:    "held until quiet hours end",
+}
+
+// Wizards asking the user for new settings
+const (
+	wizardSetTimezone   = "set_timezone"
+	wizardAddQuietHours = "add_quiet_hours"
+)
+
+type settingsHandler struct {
+	service *Service
+}
+
+func newSettingsHandler(s *Service) *settingsHandler {
+	return &settingsHandler{service: s}
+}
+
+func (h *settingsHandler) register() {
+	h.service.bot.Handle(&btnOpenSettings, h.handleOpenSettings)
+	h.service.bot.Handle(&btnSettings, h.handleSettings)
+	h.service.bot.Handle(&btnSetTimezone, h.handleSetTimezone)
+	h.service.bot.Handle(&btnAddQuietHours, h.handleAddQuietHours)
+	h.service.bot.Handle(&btnRemoveQuietHours, h.handleRemoveQuietHours)
+	h.service.bot.Handle(&btnQuietMode, h.handleQuietMode)
+	h.service.bot.Handle(&btnLanguage, h.handleLanguage)
+	h.service.bot.Handle(&btnSetLanguage, h.handleSetLanguage)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardSetTimezone,
+		Steps:   []wizardStep{{Prompt: staticPrompt(timezonePrompt)}},
+		Finish:  h.setTimezone,
+		Cancel:  h.showWizardSettings,
+		Failure: "Sorry, failed to update settings. Please try again.",
+	})
+	h.service.wizards.add(&wizard{
+		Name:    wizardAddQuietHours,
+		Steps:   []wizardStep{{Prompt: staticPrompt(quietHoursPrompt)}},
+		Finish:  h.addQuietHours,
+		Cancel:  h.showWizardSettings,
+		Failure: "Sorry, failed to update settings. Please try again.",
+	})
+}
+
+// createSettingsMessage creates the message describing the user's settings
+func (h *settingsHandler) createSettingsMessage(l *Locale, settings *domain.UserSettings) string {
+	timezone := settings.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	message := l.T("⚙️ <b>Settings</b>\n\n<b>Timezone:</b> %s (now %s)\n",
+		timezone, time.Now().In(settings.Location()).Format("15:04"))
+
+	language := l.T("automatic (%s)", findLocale(h.service.telegramLanguage(settings.UserID)).Name)
+	if settings.Language != "" {
+		language = findLocale(settings.Language).Name
+	}
+	message += l.T("<b>Language:</b> %s\n", language)
+
+	if len(settings.QuietWindows) == 0 {
+		message += l.T("<b>Quiet hours:</b> not set")
+	} else {
+		message += l.T("<b>Quiet hours:</b>")
+		for i, window := range settings.QuietWindows {
+			message += fmt.Sprintf("\n%d. %s", i+1, l.QuietWindow(window))
+		}
+		message += "\n\n" + l.T("<b>During quiet hours notifications are</b> %s", l.T(quietModeDescriptions[settings.QuietMode]))
+	}
+
+	return message
+}
+
+// createSettingsButtons creates the inline keyboard for changing the user's settings
+func (h *settingsHandler) createSettingsButtons(l *Locale, settings *domain.UserSettings) *telebot.ReplyMarkup {
+	keyboard := [][]telebot.InlineButton{
+		{l.Button(btnSetTimezone), l.Button(btnLanguage)},
+	}
+
+	for i := range settings.QuietWindows {
+		btn := btnRemoveQuietHours
+		btn.Text = l.T("🗑 Remove quiet hours %d", i+1)
+		btn.Data = strconv.Itoa(i)
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+	if len(settings.QuietWindows) < domain.MaxQuietWindows {
+		keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnAddQuietHours)})
+	}
+
+	if len(settings.QuietWindows) > 0 {
+		modeBtn := btnQuietMode
+		if settings.QuietMode == domain.QuietModeHold {
+			modeBtn.Text = l.T("🔕 Deliver silently instead")
+			modeBtn.Data = string(domain.QuietModeSilent)
+		} else {
+			modeBtn.Text = l.T("📥 Hold until quiet hours end instead")
+			modeBtn.Data = string(domain.QuietModeHold)
+		}
+		keyboard = append(keyboard, []telebot.InlineButton{modeBtn})
+	}
+
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnMainMenu)})
+	return &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// sendSettings sends the user's settings as a new message, the header is shown above them
+func (h *settingsHandler) sendSettings(to *telebot.User, header string) error {
+	settings, err := h.service.userSettingsService.Get(domain.MustNewTelegramUserID(int64(to.ID)))
+	if err != nil {
+		return fmt.Errorf("failed to get user settings: %w", err)
+	}
+
+	l := h.service.userLocale(to)
+	message := h.createSettingsMessage(l, settings)
+	if header != "" {
+		message = header + "\n\n" + message
+	}
+
+	_, err = h.service.bot.Send(to, message,
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createSettingsButtons(l, settings))
+	if err != nil {
+		return fmt.Errorf("failed to send settings: %w", err)
+	}
+	return nil
+}
+
+// showWizardSettings shows the settings again when a wizard changing them is cancelled
+func (h *settingsHandler) showWizardSettings(w *wizardContext) error {
+	settings, err := h.service.userSettingsService.Get(w.UserID())
+	if err != nil {
+		return fmt.Errorf("failed to get user settings: %w", err)
+	}
+	return w.Show(h.createSettingsMessage(w.Locale, settings),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createSettingsButtons(w.Locale, settings))
+}
+
+// showSettings replaces the callback message with the user's settings
+func (h *settingsHandler) showSettings(c *telebot.Callback) {
+	settings, err := h.service.userSettingsService.Get(domain.MustNewTelegramUserID(int64(c.Sender.ID)))
+	if err != nil {
+		slog.Error("Failed to get user settings", "error", err, "user_id", c.Sender.ID)
+		return
+	}
+
+	l := h.service.userLocale(c.Sender)
+	_, err = h.service.bot.Edit(c.Message, h.createSettingsMessage(l, settings),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createSettingsButtons(l, settings))
+	if err != nil {
+		slog.Error("Failed to update settings message", "error", err)
+	}
+}
+
+// handleOpenSettings replaces the callback message with the user's settings
+func (h *settingsHandler) handleOpenSettings(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showSettings(c)
+}
+
+// handleSettings sends the user's settings as a new message
+func (h *settingsHandler) handleSettings(m *telebot.Message) {
+	h.service.stateManager.ClearState(m.Sender.ID)
+	if err := h.sendSettings(m.Sender, ""); err != nil {
+		slog.Error("Failed to show settings", "error", err)
+		l := h.service.userLocale(m.Sender)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get your settings. Please try again."), l.Markup(mainMenu))
+	}
+}
+
+// handleSetTimezone asks the user for their timezone
+func (h *settingsHandler) handleSetTimezone(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardSetTimezone, StateData{})
+}
+
+// timezonePrompt explains which timezones are accepted
+const timezonePrompt = "Please enter your timezone, either a name like Europe/Berlin " +
+	"or an offset from UTC like +3 or UTC-05:30:"
+
+// setTimezone processes the timezone entered by the user
+func (h *settingsHandler) setTimezone(w *wizardContext) error {
+	if err := h.service.userSettingsService.SetTimezone(w.UserID(), w.Answer(0)); err != nil {
+		if errors.Is(err, domain.ErrInvalidTimezone) {
+			return invalidAnswer(w.Locale.T("Sorry, I don't know this timezone. " +
+				"Please enter a name like Europe/Berlin or an offset like +3:"))
+		}
+		return fmt.Errorf("failed to set timezone: %w", err)
+	}
+
+	return h.sendSettings(w.User, w.Locale.T("✅ Timezone updated."))
+}
+
+// handleAddQuietHours asks the user for a new quiet hours window
+func (h *settingsHandler) handleAddQuietHours(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardAddQuietHours, StateData{})
+}
+
+// quietHoursPrompt explains which quiet hours are accepted
+const quietHoursPrompt = "When should notifications be quiet? Send one time range with optional days, for example:\n\n" +
+	"23:00-08:00\nweekdays 23:00-08:00\nweekends all day\nmon-fri 22:30-07:00\nsat,sun 00:00-10:00\n\n" +
+	"Different quiet hours on different days are added one at a time."
+
+// addQuietHours processes the quiet hours window entered by the user
+func (h *settingsHandler) addQuietHours(w *wizardContext) error {
+	window, err := domain.ParseQuietWindow(w.Answer(0))
+	if err != nil {
+		return invalidAnswer(w.Locale.T("Sorry, I couldn't understand that.") + " " + w.Locale.T(quietHoursPrompt))
+	}
+
+	if err := h.service.userSettingsService.AddQuietWindow(w.UserID(), window); err != nil {
+		if errors.Is(err, domain.ErrTooManyQuietWindows) {
+			return h.sendSettings(w.User, w.Locale.T("You can have at most %d quiet hours windows.", domain.MaxQuietWindows))
+		}
+		return fmt.Errorf("failed to add quiet hours: %w", err)
+	}
+
+	return h.sendSettings(w.User, w.Locale.T("✅ Quiet hours added: %s", w.Locale.QuietWindow(window)))
+}
+
+// handleRemoveQuietHours removes a quiet hours window
+func (h *settingsHandler) handleRemoveQuietHours(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	index, err := strconv.Atoi(c.Data)
+	if err != nil {
+		slog.Error("Invalid index in remove quiet hours callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	if err := h.service.userSettingsService.RemoveQuietWindow(userID, index); err != nil {
+		if errors.Is(err, domain.ErrQuietWindowNotFound) {
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("These quiet hours have already been removed.")})
+			h.showSettings(c)
+			return
+		}
+		slog.Error("Failed to remove quiet hours", "error", err, "user_id", userID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to remove quiet hours. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Quiet hours removed")})
+	h.showSettings(c)
+}
+
+// handleQuietMode switches between delivering silently and holding notifications during quiet hours
+func (h *settingsHandler) handleQuietMode(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	mode := domain.QuietMode(c.Data)
+	if err := h.service.userSettingsService.SetQuietMode(userID, mode); err != nil {
+		slog.Error("Failed to set quiet mode", "error", err, "user_id", userID, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update settings. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Notifications will be %s", l.T(quietModeDescriptions[mode]))})
+	h.showSettings(c)
+}
+
+// handleLanguage shows the languages the bot speaks
+func (h *settingsHandler) handleLanguage(c *telebot.Callback) {
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	l := h.service.userLocale(c.Sender)
+
+	automaticBtn := btnSetLanguage
+	automaticBtn.Text = l.T("🔄 Automatic")
+	keyboard := [][]telebot.InlineButton{{automaticBtn}}
+	for _, locale := range locales {
+		btn := btnSetLanguage
+		btn.Text = locale.Name
+		btn.Data = locale.Language
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnOpenSettings)})
+
+	_, err := h.service.bot.Edit(c.Message,
+		l.T("🌐 Choose the language of the bot. Automatic uses the language of your Telegram app."),
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show languages", "error", err)
+	}
+}
+
+// handleSetLanguage sets the language chosen by the user, an empty one means the language of their Telegram app
+func (h *settingsHandler) handleSetLanguage(c *telebot.Callback) {
+	userID := domain.MustNewTelegramUserID(int64(c.Sender.ID))
+	if err := h.service.userSettingsService.SetLanguage(userID, c.Data); err != nil {
+		slog.Error("Failed to set language", "error", err, "user_id", userID, "data", c.Data)
+		l := h.service.userLocale(c.Sender)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update settings. Please try again.")})
+		return
+	}
+
+	// The response is already in the new language
+	l := h.service.userLocale(c.Sender)
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Language updated")})
+	h.showSettings(c)
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for signed requests of a project, the data of the buttons is the project ID
+var (
+	btnSignedRequests          = telebot.InlineButton{Unique: "signed_requests", Text: "✍️ Signed requests"}
+	btnGenerateSecret          = telebot.InlineButton{Unique: "generate_secret", Text: "➕ Generate secret"}
+	btnRegenerateSecret        = telebot.InlineButton{Unique: "regenerate_secret", Text: "🔄 Regenerate secret"}
+	btnConfirmRegenerateSecret = telebot.InlineButton{Unique: "confirm_regenerate_secret", Text: "✅ Yes, regenerate"}
+	btnRequireSigned           = telebot.InlineButton{Unique: "require_signed", Text: "🔒 Reject tokens"}
+	btnAllowTokens             = telebot.InlineButton{Unique: "allow_tokens", Text: "🔓 Accept tokens too"}
+	btnRemoveSecret            = telebot.InlineButton{Unique: "remove_secret", Text: "🗑 Remove secret"}
+	btnConfirmRemoveSecret     = telebot.InlineButton{Unique: "confirm_remove_secret", Text: "✅ Yes, remove"}
+	// btnSigningScope allows or forbids an action to signed requests, its data is the project ID and the scope
+	btnSigningScope = telebot.InlineButton{Unique: "signing_scope"}
+)
+
+type signingHandler struct {
+	service *Service
+}
+
+func newSigningHandler(s *Service) *signingHandler {
+	return &signingHandler{service: s}
+}
+
+func (h *signingHandler) register() {
+	h.service.bot.Handle(&btnSignedRequests, h.handleSignedRequests)
+	h.service.bot.Handle(&btnGenerateSecret, h.handleGenerateSecret)
+	h.service.bot.Handle(&btnRegenerateSecret, h.handleRegenerateSecret)
+	h.service.bot.Handle(&btnConfirmRegenerateSecret, h.handleGenerateSecret)
+	h.service.bot.Handle(&btnRequireSigned, h.handleRequireSigned)
+	h.service.bot.Handle(&btnAllowTokens, h.handleAllowTokens)
+	h.service.bot.Handle(&btnRemoveSecret, h.handleRemoveSecret)
+	h.service.bot.Handle(&btnConfirmRemoveSecret, h.handleConfirmRemoveSecret)
+	h.service.bot.Handle(&btnSigningScope, h.handleSigningScope)
+}
+
+// showSigning replaces the callback message with the signed requests settings of the project.
+// The secret is only given right after it is generated.
+func (h *signingHandler) showSigning(c *telebot.Callback, project *domain.Project, secret string) {
+	l := h.service.userLocale(c.Sender)
+
+	message := l.T("✍️ Signed requests of <b>%s</b>\n\n"+
+		"Instead of sending a token, your services can sign every request with the signing secret of the project. "+
+		"The secret itself is never sent, so proxies logging headers can't reveal it.", project.Name)
+
+	var keyboard [][]telebot.InlineButton
+	switch {
+	case secret != "":
+		message += "\n\n" + l.T("<b>Signing secret:</b> <code>%s</code>", secret) + "\n" +
+			l.T("⚠️ Copy the secret now, it won't be shown again.")
+	case !project.HasSigningSecret():
+		message += "\n\n" + l.T("The project has no signing secret yet.")
+	}
+
+	if project.HasSigningSecret() {
+		tokens := l.T("accepted")
+		toggleBtn := btnRequireSigned
+		if project.RequireSignedRequests {
+			tokens = l.T("rejected")
+			toggleBtn = btnAllowTokens
+		}
+		message += "\n\n" + l.T("<b>Signed requests allow to:</b> %s", describeScopes(l, project.SigningScopes)) +
+			"\n" + l.T("<b>Requests with tokens:</b> %s", tokens)
+
+		for _, scope := range domain.TokenScopes {
+			btn := btnSigningScope
+			btn.Text = "⬜ " + l.T(tokenScopeLabels[scope])
+			if project.SigningAllows(scope) {
+				btn.Text = "✅ " + l.T(tokenScopeLabels[scope])
+			}
+			btn.Data = joinCallbackData(project.ID.String(), string(scope))
+			keyboard = append(keyboard, []telebot.InlineButton{btn})
+		}
+		toggleBtn.Data = project.ID.String()
+		regenerateBtn := btnRegenerateSecret
+		regenerateBtn.Data = project.ID.String()
+		removeBtn := btnRemoveSecret
+		removeBtn.Data = project.ID.String()
+		keyboard = append(keyboard,
+			[]telebot.InlineButton{toggleBtn},
+			[]telebot.InlineButton{regenerateBtn, removeBtn},
+		)
+	} else {
+		generateBtn := btnGenerateSecret
+		generateBtn.Data = project.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{generateBtn})
+	}
+
+	backBtn := btnProjectTokens
+	backBtn.Text = "↩️ Back"
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		l.Markup(&telebot.ReplyMarkup{InlineKeyboard: keyboard}))
+	if err != nil {
+		slog.Error("Failed to update signed requests message", "error", err)
+	}
+}
+
+// handleSignedRequests shows the signed requests settings of the project
+func (h *signingHandler) handleSignedRequests(c *telebot.Callback) {
+	project, ok := h.service.projectManagement.getOwnedProject(c, "signed requests")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showSigning(c, project, "")
+}
+
+// handleGenerateSecret generates a signing secret for the project, replacing the current one
+func (h *signingHandler) handleGenerateSecret(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.service.projectManagement.getOwnedProject(c, "generate signing secret")
+	if !ok {
+		return
+	}
+
+	secret, err := h.service.signingService.Generate(project.ID)
+	if err != nil {
+		slog.Error("Failed to generate signing secret", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to generate secret. Please try again.")})
+		return
+	}
+
+	project, err = h.service.projectService.GetByID(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Secret generated")})
+	h.showSigning(c, project, secret)
+}
+
+// handleRegenerateSecret asks the publisher to confirm replacing the signing secret
+func (h *signingHandler) handleRegenerateSecret(c *telebot.Callback) {
+	h.confirm(c, "regenerate signing secret", btnConfirmRegenerateSecret,
+		"Generate a new signing secret for <b>%s</b>?\n\n"+
+			"Requests signed with the current secret will be rejected immediately.")
+}
+
+// handleRemoveSecret asks the publisher to confirm removing the signing secret
+func (h *signingHandler) handleRemoveSecret(c *telebot.Callback) {
+	h.confirm(c, "remove signing secret", btnConfirmRemoveSecret,
+		"Remove the signing secret of <b>%s</b>?\n\n"+
+			"Signed requests will be rejected and requests with tokens accepted again.")
+}
+
+// confirm replaces the callback message with a question about the project, the text is translated when shown
+func (h *signingHandler) confirm(c *telebot.Callback, action string, confirmBtn telebot.InlineButton, question string) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.service.projectManagement.getOwnedProject(c, action)
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	confirmBtn.Data = project.ID.String()
+	backBtn := btnSignedRequests
+	backBtn.Text = "↩️ Back"
+	backBtn.Data = project.ID.String()
+	_, err := h.service.bot.Edit(c.Message, l.T(question, project.Name), &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		l.Markup(&telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{confirmBtn, backBtn}}}))
+	if err != nil {
+		slog.Error("Failed to show signing secret confirmation", "error", err)
+	}
+}
+
+// handleConfirmRemoveSecret removes the signing secret of the project
+func (h *signingHandler) handleConfirmRemoveSecret(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.service.projectManagement.getOwnedProject(c, "confirm remove signing secret")
+	if !ok {
+		return
+	}
+
+	if err := h.service.signingService.Remove(project.ID); err != nil {
+		slog.Error("Failed to remove signing secret", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to remove secret. Please try again.")})
+		return
+	}
+	project.SigningSecret = ""
+	project.RequireSignedRequests = false
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Secret removed")})
+	h.showSigning(c, project, "")
+}
+
+// handleSigningScope allows or forbids an action to requests signed with the secret of the project
+func (h *signingHandler) handleSigningScope(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in signing scope callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, parts[0], "signing scope")
+	if !ok {
+		return
+	}
+
+	scope := domain.TokenScope(parts[1])
+	scopes := slices.DeleteFunc(slices.Clone(project.SigningScopes), func(s domain.TokenScope) bool { return s == scope })
+	if !project.SigningAllows(scope) {
+		scopes = append(scopes, scope)
+	}
+
+	if err := h.service.signingService.SetScopes(project, scopes); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNoSigningSecret):
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Generate a signing secret first.")})
+		case errors.Is(err, domain.ErrInvalidTokenScopes):
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Signed requests must allow at least one action.")})
+		default:
+			slog.Error("Failed to update signing scopes", "error", err, "project_id", project.ID)
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update project. Please try again.")})
+		}
+		return
+	}
+
+	project, err := h.service.projectService.GetByID(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showSigning(c, project, "")
+}
+
+// handleRequireSigned makes the project reject requests with tokens
+func (h *signingHandler) handleRequireSigned(c *telebot.Callback) {
+	h.setRequired(c, true, "Requests with tokens are now rejected")
+}
+
+// handleAllowTokens makes the project accept requests with tokens along with signed ones
+func (h *signingHandler) handleAllowTokens(c *telebot.Callback) {
+	h.setRequired(c, false, "Requests with tokens are accepted again")
+}
+
+// setRequired switches whether the project requires signed requests and refreshes the settings
+func (h *signingHandler) setRequired(c *telebot.Callback, required bool, successMessage string) {
+	l := h.service.userLocale(c.Sender)
+	project, ok := h.service.projectManagement.getOwnedProject(c, "toggle signed requests")
+	if !ok {
+		return
+	}
+
+	if err := h.service.signingService.SetRequired(project, required); err != nil {
+		if errors.Is(err, domain.ErrNoSigningSecret) {
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Generate a signing secret first.")})
+			return
+		}
+		slog.Error("Failed to update signed requests requirement", "error", err, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update project. Please try again.")})
+		return
+	}
+	project.RequireSignedRequests = required
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T(successMessage)})
+	h.showSigning(c, project, "")
+}
@@ -1,99 +1,158 @@
 package bot
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sergeax/noteo/internal/domain"
 )
 
-// UserState represents the current state of user interaction
+// UserState represents the current state of user interaction.
+// The values are stored in the database, so new states must be added at the end.
 type UserState int
 
 const (
 	StateNone UserState = iota
-	StateCreatingProject
-	StateMutingSubscription
-	StateSuspendingSubscription
-	StateUnsubscribing
-	StateCustomMuteDuration
-	StateCustomSuspendDuration
+	// StateWizard means the user is going through the wizard named in the state data
+	StateWizard
 )
 
-// UserContext stores the current state and data for a user
-type UserContext struct {
-	State     UserState
-	CreatedAt time.Time
-	Data      map[string]interface{} // For storing additional data like project ID
+// stateDataVersion is the version of the StateData format. It must be increased
+// when the meaning of existing fields changes, states saved with another version are discarded.
+const stateDataVersion = 2
+
+// StateData stores additional data of a user state, like the project being edited
+type StateData struct {
+	Wizard    string    `json:"wizard,omitempty"`
+	Step      int       `json:"step,omitempty"`
+	Answers   []string  `json:"answers,omitempty"`
+	ProjectID uuid.UUID `json:"project_id,omitempty"`
+	// TokenID is the project token being changed
+	TokenID     uuid.UUID `json:"token_id,omitempty"`
+	HistoryKind string    `json:"history_kind,omitempty"`
+	// DurationKind is the kind of the custom duration being entered
+	DurationKind string `json:"duration_kind,omitempty"`
 }
 
-// StateManager handles all user state-related operations
+// stateSweepInterval is how often expired states are looked for
+const stateSweepInterval = time.Minute
+
+// stateSweepBatchSize limits the number of expired states handled per sweep
+const stateSweepBatchSize = 100
+
+// StateManager handles all user state-related operations.
+// States are stored in the database, so that conversations survive restarts.
 type StateManager struct {
-	userStates  map[int]UserContext
-	stateMutex  sync.RWMutex
-	cleanupDone chan struct{}
+	ttl           time.Duration
+	conversations *domain.ConversationService
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
 }
 
 // NewStateManager creates a new instance of StateManager
-func NewStateManager() *StateManager {
-	sm := &StateManager{
-		userStates:  make(map[int]UserContext),
-		cleanupDone: make(chan struct{}),
+func NewStateManager(cfg *Config, conversations *domain.ConversationService) *StateManager {
+	return &StateManager{
+		ttl:           cfg.StateTTL,
+		conversations: conversations,
+		stopCh:        make(chan struct{}),
 	}
-	go sm.cleanupStaleStates()
-	return sm
 }
 
 // GetState retrieves the current state for a user
-func (sm *StateManager) GetState(userID int) (UserState, map[string]interface{}, bool) {
-	sm.stateMutex.RLock()
-	defer sm.stateMutex.RUnlock()
-	ctx, exists := sm.userStates[userID]
-	if !exists {
-		return StateNone, nil, false
+func (sm *StateManager) GetState(userID int) (UserState, StateData, bool) {
+	conversation, err := sm.conversations.Get(domain.MustNewTelegramUserID(int64(userID)))
+	if err != nil {
+		if !errors.Is(err, domain.ErrConversationNotFound) {
+			slog.Error("Failed to get user state", "error", err, "user_id", userID)
+		}
+		return StateNone, StateData{}, false
+	}
+
+	if conversation.Version != stateDataVersion {
+		slog.Warn("Discarding user state of an old version", "user_id", userID, "version", conversation.Version)
+		sm.ClearState(userID)
+		return StateNone, StateData{}, false
+	}
+
+	var data StateData
+	if len(conversation.Data) > 0 {
+		if err := json.Unmarshal(conversation.Data, &data); err != nil {
+			slog.Error("Failed to decode user state data", "error", err, "user_id", userID)
+			sm.ClearState(userID)
+			return StateNone, StateData{}, false
+		}
 	}
-	return ctx.State, ctx.Data, true
+	return UserState(conversation.State), data, true
 }
 
 // SetState sets the state for a user
-func (sm *StateManager) SetState(userID int, state UserState, data map[string]interface{}) {
-	sm.stateMutex.Lock()
-	defer sm.stateMutex.Unlock()
-	sm.userStates[userID] = UserContext{
-		State:     state,
-		CreatedAt: time.Now(),
-		Data:      data,
+func (sm *StateManager) SetState(userID int, state UserState, data StateData) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode user state data", "error", err, "user_id", userID)
+		return
+	}
+
+	err = sm.conversations.Start(domain.MustNewTelegramUserID(int64(userID)), int(state), stateDataVersion, encoded, sm.ttl)
+	if err != nil {
+		slog.Error("Failed to save user state", "error", err, "user_id", userID, "state", state)
 	}
 }
 
 // ClearState removes the state for a user
 func (sm *StateManager) ClearState(userID int) {
-	sm.stateMutex.Lock()
-	defer sm.stateMutex.Unlock()
-	delete(sm.userStates, userID)
+	if err := sm.conversations.End(domain.MustNewTelegramUserID(int64(userID))); err != nil {
+		slog.Error("Failed to clear user state", "error", err, "user_id", userID)
+	}
 }
 
-// cleanupStaleStates removes user states that are older than 5 minutes
-func (sm *StateManager) cleanupStaleStates() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			sm.stateMutex.Lock()
-			now := time.Now()
-			for userID, state := range sm.userStates {
-				if now.Sub(state.CreatedAt) > 5*time.Minute {
-					delete(sm.userStates, userID)
-				}
+// Start begins sweeping expired states, calling onExpired for each of them
+func (sm *StateManager) Start(onExpired func(userID int, state UserState)) {
+	sm.wg.Add(1)
+	go func() {
+		defer sm.wg.Done()
+		ticker := time.NewTicker(stateSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sm.sweep(onExpired)
+			case <-sm.stopCh:
+				return
 			}
-			sm.stateMutex.Unlock()
-		case <-sm.cleanupDone:
-			return
+		}
+	}()
+}
+
+// sweep removes expired states
+func (sm *StateManager) sweep(onExpired func(userID int, state UserState)) {
+	now := time.Now()
+	conversations, err := sm.conversations.GetExpired(now, stateSweepBatchSize)
+	if err != nil {
+		slog.Error("Failed to get expired user states", "error", err)
+		return
+	}
+
+	for _, conversation := range conversations {
+		expired, err := sm.conversations.Expire(conversation, now)
+		if err != nil {
+			slog.Error("Failed to remove expired user state", "error", err, "user_id", conversation.UserID)
+			continue
+		}
+		if expired {
+			onExpired(int(conversation.UserID.Int64()), UserState(conversation.State))
 		}
 	}
 }
 
 // Stop gracefully stops the state manager's background processes
 func (sm *StateManager) Stop() {
-	close(sm.cleanupDone)
+	close(sm.stopCh)
+	sm.wg.Wait()
 }
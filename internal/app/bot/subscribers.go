@@ -0,0 +1,315 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for managing project subscribers.
+// Button uniques are kept short, since callback data is limited to 64 bytes.
+var (
+	btnProjectSubscribers = telebot.InlineButton{Unique: "subscribers", Text: "👥 Subscribers"}
+	btnSubscriber         = telebot.InlineButton{Unique: "subscriber"}
+	btnRemoveSubscriber   = telebot.InlineButton{Unique: "remove_sub", Text: "❌ Remove"}
+	btnBanSubscriber      = telebot.InlineButton{Unique: "ban_sub", Text: "🚫 Ban"}
+	btnBannedUsers        = telebot.InlineButton{Unique: "banned_users", Text: "🚫 Banned users"}
+	btnUnbanUser          = telebot.InlineButton{Unique: "unban_user"}
+)
+
+type subscribersHandler struct {
+	service *Service
+}
+
+func newSubscribersHandler(s *Service) *subscribersHandler {
+	return &subscribersHandler{service: s}
+}
+
+func (h *subscribersHandler) register() {
+	h.service.bot.Handle(&btnProjectSubscribers, h.handleProjectSubscribers)
+	h.service.bot.Handle(&btnSubscriber, h.handleSubscriber)
+	h.service.bot.Handle(&btnRemoveSubscriber, h.handleRemoveSubscriber)
+	h.service.bot.Handle(&btnBanSubscriber, h.handleBanSubscriber)
+	h.service.bot.Handle(&btnBannedUsers, h.handleBannedUsers)
+	h.service.bot.Handle(&btnUnbanUser, h.handleUnbanUser)
+}
+
+// parseProjectAndUser parses "<project ID>|<user ID>" callback data and checks project ownership
+func (h *subscribersHandler) parseProjectAndUser(c *telebot.Callback, action string) (*domain.Project, domain.TelegramUserID, bool) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in "+action+" callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscriber. Please try again.")})
+		return nil, 0, false
+	}
+
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		slog.Error("Invalid user ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscriber. Please try again.")})
+		return nil, 0, false
+	}
+	userID, err := domain.NewTelegramUserID(id)
+	if err != nil {
+		slog.Error("Invalid user ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscriber. Please try again.")})
+		return nil, 0, false
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, parts[0], action)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return project, userID, true
+}
+
+// showSubscribers replaces the callback message with a page of project subscribers
+func (h *subscribersHandler) showSubscribers(c *telebot.Callback, project *domain.Project, page int) {
+	l := h.service.userLocale(c.Sender)
+	subscribers, err := h.service.subscriptionService.GetSubscribersPage(project.ID, page)
+	if err != nil {
+		slog.Error("Failed to get project subscriptions", "error", err, "project_id", project.ID)
+		h.service.bot.Send(c.Sender, l.T("Sorry, failed to get project subscribers. Please try again."))
+		return
+	}
+
+	page, pages, start := subscribers.Page, subscribers.Pages, subscribers.Offset
+
+	message := l.T("Subscribers of <b>%s</b>", project.Name)
+	if subscribers.Total == 0 {
+		message += "\n\n" + l.T("There are no subscribers yet.")
+	} else {
+		message += l.T(" (page %d of %d):\n", page+1, pages)
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for i, sub := range subscribers.Subscriptions {
+		name := h.service.getDisplayName(sub.UserID)
+		since := l.Date(sub.CreatedAt.In(h.service.userLocation(project.PublisherID)))
+		message += "\n" + l.T("%d. %s — since %s", start+i+1, html.EscapeString(name), since)
+
+		btn := btnSubscriber
+		btn.Text = fmt.Sprintf("%d. %s", start+i+1, name)
+		btn.Data = joinCallbackData(project.ID.String(), sub.UserID.String())
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	// Pagination buttons
+	var navigation []telebot.InlineButton
+	if page > 0 {
+		prevBtn := btnProjectSubscribers
+		prevBtn.Text = l.T("⬅️ Previous")
+		prevBtn.Data = joinCallbackData(project.ID.String(), strconv.Itoa(page-1))
+		navigation = append(navigation, prevBtn)
+	}
+	if page < pages-1 {
+		nextBtn := btnProjectSubscribers
+		nextBtn.Text = l.T("Next ➡️")
+		nextBtn.Data = joinCallbackData(project.ID.String(), strconv.Itoa(page+1))
+		navigation = append(navigation, nextBtn)
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	bannedBtn := l.Button(btnBannedUsers)
+	bannedBtn.Data = project.ID.String()
+	backBtn := l.Button(btnBackToProject)
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{bannedBtn, backBtn})
+
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to update project subscribers message", "error", err)
+	}
+}
+
+// handleProjectSubscribers shows a page of project subscribers
+func (h *subscribersHandler) handleProjectSubscribers(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in project subscribers callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid project. Please try again.")})
+		return
+	}
+
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		page = 0
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, parts[0], "project subscribers")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showSubscribers(c, project, page)
+}
+
+// handleSubscriber shows the details of a single subscriber with removal and ban options
+func (h *subscribersHandler) handleSubscriber(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, userID, ok := h.parseProjectAndUser(c, "subscriber")
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.subscriptionService.GetSubscription(userID, project.ID)
+	if err != nil {
+		slog.Error("Failed to get subscription", "error", err, "user_id", userID, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This user is no longer subscribed.")})
+		h.showSubscribers(c, project, 0)
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	message := l.T("Subscriber of <b>%s</b>\n\n<b>Name:</b> %s\n<b>Subscribed since:</b> %s",
+		project.Name, html.EscapeString(h.service.getDisplayName(userID)), h.service.formatTime(sub.CreatedAt, project.PublisherID))
+	if sub.Inactive {
+		message += "\n\n" + l.T("🚫 Notifications can't be delivered: the user has blocked the bot or deleted their account.")
+	}
+
+	removeBtn := l.Button(btnRemoveSubscriber)
+	removeBtn.Data = c.Data
+	banBtn := l.Button(btnBanSubscriber)
+	banBtn.Data = c.Data
+	backBtn := btnProjectSubscribers
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = joinCallbackData(project.ID.String(), "0")
+
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{removeBtn, banBtn},
+			{backBtn},
+		},
+	}
+
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	if err != nil {
+		slog.Error("Failed to update subscriber message", "error", err)
+	}
+}
+
+// handleRemoveSubscriber removes a subscriber from the project
+func (h *subscribersHandler) handleRemoveSubscriber(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, userID, ok := h.parseProjectAndUser(c, "remove subscriber")
+	if !ok {
+		return
+	}
+
+	if err := h.service.subscriptionService.Unsubscribe(userID, project.ID); err != nil {
+		slog.Error("Failed to remove subscriber", "error", err, "user_id", userID, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to remove subscriber. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Subscriber removed")})
+	h.notifyRemoved(userID, project)
+	h.showSubscribers(c, project, 0)
+}
+
+// handleBanSubscriber removes a subscriber from the project and bans them
+func (h *subscribersHandler) handleBanSubscriber(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, userID, ok := h.parseProjectAndUser(c, "ban subscriber")
+	if !ok {
+		return
+	}
+
+	if err := h.service.subscriptionService.BanSubscriber(project.ID, userID); err != nil {
+		slog.Error("Failed to ban subscriber", "error", err, "user_id", userID, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to ban subscriber. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Subscriber banned")})
+	h.notifyRemoved(userID, project)
+	h.showSubscribers(c, project, 0)
+}
+
+// notifyRemoved tells a user that the publisher removed them from the project
+func (h *subscribersHandler) notifyRemoved(userID domain.TelegramUserID, project *domain.Project) {
+	message := h.service.locale(userID).T("You have been removed from project <b>%s</b> by its publisher.", project.Name)
+	_, err := h.service.bot.Send(&telebot.Chat{ID: userID.Int64()}, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+	if err != nil {
+		slog.Error("Failed to notify removed subscriber", "error", err, "chatId", userID, "project_id", project.ID)
+	}
+}
+
+// showBannedUsers replaces the callback message with the list of banned users
+func (h *subscribersHandler) showBannedUsers(c *telebot.Callback, project *domain.Project) {
+	l := h.service.userLocale(c.Sender)
+	bans, err := h.service.subscriptionService.GetProjectBans(project.ID)
+	if err != nil {
+		slog.Error("Failed to get project bans", "error", err, "project_id", project.ID)
+		h.service.bot.Send(c.Sender, l.T("Sorry, failed to get banned users. Please try again."))
+		return
+	}
+
+	message := l.T("Banned users of <b>%s</b>", project.Name)
+	if len(bans) == 0 {
+		message += "\n\n" + l.T("Nobody is banned.")
+	} else {
+		message += "\n\n" + l.T("Tap a user to lift the ban:")
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for _, ban := range bans {
+		btn := btnUnbanUser
+		btn.Text = l.T("✅ Unban %s", h.service.getDisplayName(ban.UserID))
+		btn.Data = joinCallbackData(project.ID.String(), ban.UserID.String())
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	backBtn := btnProjectSubscribers
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = joinCallbackData(project.ID.String(), "0")
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to update banned users message", "error", err)
+	}
+}
+
+// handleBannedUsers shows the users banned from the project
+func (h *subscribersHandler) handleBannedUsers(c *telebot.Callback) {
+	project, ok := h.service.projectManagement.getOwnedProject(c, "banned users")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showBannedUsers(c, project)
+}
+
+// handleUnbanUser lifts a ban so the user can subscribe again
+func (h *subscribersHandler) handleUnbanUser(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	project, userID, ok := h.parseProjectAndUser(c, "unban user")
+	if !ok {
+		return
+	}
+
+	if err := h.service.subscriptionService.UnbanSubscriber(project.ID, userID); err != nil {
+		slog.Error("Failed to unban user", "error", err, "user_id", userID, "project_id", project.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to unban user. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("User unbanned")})
+	h.showBannedUsers(c, project)
+}
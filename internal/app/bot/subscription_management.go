@@ -3,7 +3,6 @@ package bot
 import (
 	"fmt"
 	"log/slog"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/tucnak/telebot"
@@ -13,21 +12,18 @@ import (
 
 // Menu items for subscription management
 var (
-	btnManageSubscription  = telebot.InlineButton{Unique: "manage_subscription", Text: "Manage"}
-	btnMuteSubscription    = telebot.InlineButton{Unique: "mute_subscription", Text: "🔕 Mute"}
-	btnUnmuteSubscription  = telebot.InlineButton{Unique: "unmute_subscription", Text: "🔔 Unmute"}
-	btnPauseSubscription   = telebot.InlineButton{Unique: "pause_subscription", Text: "⏸️ Pause for 24 hours"}
-	btnResumeSubscription  = telebot.InlineButton{Unique: "resume_subscription", Text: "▶️ Resume"}
-	btnUnsubscribe         = telebot.InlineButton{Unique: "unsubscribe", Text: "❌ Unsubscribe"}
-	btnResubscribe         = telebot.InlineButton{Unique: "resubscribe", Text: "↩️ Re-subscribe"}
+	btnManageSubscription = telebot.InlineButton{Unique: "manage_subscription", Text: "Manage"}
+	btnMuteSubscription   = telebot.InlineButton{Unique: "mute_subscription", Text: "🔕 Mute"}
+	btnUnmuteSubscription = telebot.InlineButton{Unique: "unmute_subscription", Text: "🔔 Unmute"}
+	btnPauseSubscription  = telebot.InlineButton{Unique: "pause_subscription", Text: "⏸️ Pause"}
+	btnResumeSubscription = telebot.InlineButton{Unique: "resume_subscription", Text: "▶️ Resume"}
+	btnUnsubscribe        = telebot.InlineButton{Unique: "unsubscribe", Text: "❌ Unsubscribe"}
+	btnResubscribe        = telebot.InlineButton{Unique: "resubscribe", Text: "↩️ Re-subscribe"}
+	btnQuietOverride      = telebot.InlineButton{Unique: "quiet_override"}
+	btnHoldWhilePaused    = telebot.InlineButton{Unique: "hold_paused"}
+
+	// btnBackToSubscriptions is a button of the reply keyboard of earlier versions of the bot, matched by its English text
 	btnBackToSubscriptions = telebot.ReplyButton{Text: "Back to subscriptions"}
-
-	subscriptionManagementMenu = &telebot.ReplyMarkup{
-		ReplyKeyboard: [][]telebot.ReplyButton{
-			{btnBackToSubscriptions, btnBackToMenu},
-		},
-		ResizeReplyKeyboard: true,
-	}
 )
 
 type subscriptionManagementHandler struct {
@@ -47,6 +43,8 @@ func (h *subscriptionManagementHandler) register() {
 	h.service.bot.Handle(&btnResumeSubscription, h.handleResumeSubscription)
 	h.service.bot.Handle(&btnUnsubscribe, h.handleUnsubscribe)
 	h.service.bot.Handle(&btnResubscribe, h.handleResubscribe)
+	h.service.bot.Handle(&btnQuietOverride, h.handleQuietOverride)
+	h.service.bot.Handle(&btnHoldWhilePaused, h.handleHoldWhilePaused)
 }
 
 // parseProjectID parses a project ID from callback data and handles errors
@@ -54,7 +52,8 @@ func (h *subscriptionManagementHandler) parseProjectID(c *telebot.Callback, acti
 	projectID, err := uuid.Parse(c.Data)
 	if err != nil {
 		slog.Error("Invalid project ID in "+action+" callback", "error", err, "data", c.Data)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Invalid subscription. Please try again."})
+		l := h.service.userLocale(c.Sender)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid subscription. Please try again.")})
 		return uuid.Nil, false
 	}
 	return projectID, true
@@ -88,66 +87,115 @@ func (h *subscriptionManagementHandler) findSubscription(userID domain.TelegramU
 // createSubscriptionButtons creates the inline keyboard buttons for subscription management
 func (h *subscriptionManagementHandler) createSubscriptionButtons(sub *domain.Subscription, projectID uuid.UUID) *telebot.ReplyMarkup {
 	inlineMarkup := &telebot.ReplyMarkup{}
+	l := h.service.locale(sub.UserID)
 
 	// Mute/Unmute button
 	var muteBtn telebot.InlineButton
-	if sub.Muted {
-		muteBtn = btnUnmuteSubscription
+	if sub.IsMuted() {
+		muteBtn = l.Button(btnUnmuteSubscription)
 	} else {
-		muteBtn = btnMuteSubscription
+		muteBtn = l.Button(btnMuteSubscription)
 	}
 	muteBtn.Data = projectID.String()
 
 	// Pause/Resume button
 	var pauseBtn telebot.InlineButton
 	if sub.Paused() {
-		pauseBtn = btnResumeSubscription
+		pauseBtn = l.Button(btnResumeSubscription)
 	} else {
-		pauseBtn = btnPauseSubscription
+		pauseBtn = l.Button(btnPauseSubscription)
 	}
 	pauseBtn.Data = projectID.String()
 
+	// Hold while paused button
+	holdBtn := btnHoldWhilePaused
+	if sub.HoldWhilePaused {
+		holdBtn.Text = l.T("📥 While paused: hold notifications")
+	} else {
+		holdBtn.Text = l.T("🚫 While paused: skip notifications")
+	}
+	holdBtn.Data = projectID.String()
+
+	// Quiet hours override button
+	quietBtn := btnQuietOverride
+	quietBtn.Text = l.T("🌙 Quiet hours: %s", l.T(quietModeDescriptions[sub.QuietMode]))
+	quietBtn.Data = projectID.String()
+
+	// Digest schedule button
+	digestBtn := h.service.digests.createModeButton(l, sub, projectID)
+
 	// Unsubscribe button
-	unsubBtn := btnUnsubscribe
+	unsubBtn := l.Button(btnUnsubscribe)
 	unsubBtn.Data = projectID.String()
 
+	backBtn := btnSubscriptionsList
+	backBtn.Text = l.T("↩️ Back")
+
 	inlineMarkup.InlineKeyboard = [][]telebot.InlineButton{
 		{muteBtn},
 		{pauseBtn},
+		{holdBtn},
+		{quietBtn},
+		{digestBtn},
 		{unsubBtn},
+		{h.service.history.createOpenButton(l, historyKindUser, projectID), backBtn},
 	}
 
 	return inlineMarkup
 }
 
-// createResubscribeButton creates an inline keyboard with just the resubscribe button
-func (h *subscriptionManagementHandler) createResubscribeButton(projectID uuid.UUID) *telebot.ReplyMarkup {
+// createResubscribeButton creates an inline keyboard with the resubscribe button and a way back to the subscriptions
+func (h *subscriptionManagementHandler) createResubscribeButton(l *Locale, projectID uuid.UUID) *telebot.ReplyMarkup {
 	inlineMarkup := &telebot.ReplyMarkup{}
-	resubBtn := btnResubscribe
+	resubBtn := l.Button(btnResubscribe)
 	resubBtn.Data = projectID.String()
 	inlineMarkup.InlineKeyboard = [][]telebot.InlineButton{
 		{resubBtn},
+		{l.Button(btnSubscriptionsList), l.Button(btnMainMenu)},
 	}
 	return inlineMarkup
 }
 
 // createStatusMessage creates a status message for a subscription
 func (h *subscriptionManagementHandler) createStatusMessage(sub *domain.Subscription, project *domain.Project) string {
+	l := h.service.locale(sub.UserID)
+
 	// Status message
 	statusMsg := ""
-	if sub.Muted {
-		statusMsg += "🔕 Notifications are currently muted\n"
+	if sub.IsMuted() && sub.MutedUntil != nil {
+		statusMsg += l.T("🔕 Notifications are muted until %s", h.service.formatTime(*sub.MutedUntil, sub.UserID)) + "\n"
+	} else if sub.IsMuted() {
+		statusMsg += l.T("🔕 Notifications are currently muted") + "\n"
 	} else {
-		statusMsg += "🔔 Notifications are currently enabled\n"
+		statusMsg += l.T("🔔 Notifications are currently enabled") + "\n"
 	}
 
 	if sub.Paused() {
-		statusMsg += "⏸️ Notifications are paused until " + sub.PausedUntil.Format("Jan 2, 2006 15:04")
+		if sub.HoldWhilePaused {
+			statusMsg += l.T("⏸️ Notifications are paused until %s, new ones are held until then",
+				h.service.formatTime(*sub.PausedUntil, sub.UserID))
+		} else {
+			statusMsg += l.T("⏸️ Notifications are paused until %s", h.service.formatTime(*sub.PausedUntil, sub.UserID))
+		}
 	} else {
-		statusMsg += "▶️ Notifications are active"
+		statusMsg += l.T("▶️ Notifications are active")
+	}
+
+	if sub.QuietMode != domain.QuietModeDefault {
+		statusMsg += "\n" + l.T("🌙 During quiet hours notifications are %s", l.T(quietModeDescriptions[sub.QuietMode]))
 	}
 
-	return fmt.Sprintf("Managing subscription to <b>%s</b>\n\n%s", project.Name, statusMsg)
+	if sub.DigestMode != domain.DigestOff {
+		schedule := l.DigestSchedule(sub.DigestMode, sub.DigestAt)
+		if sub.NextDigestAt != nil {
+			statusMsg += "\n" + l.T("📬 Notifications are delivered as a digest %s, next at %s",
+				schedule, h.service.formatTime(*sub.NextDigestAt, sub.UserID))
+		} else {
+			statusMsg += "\n" + l.T("📬 Notifications are delivered as a digest %s", schedule)
+		}
+	}
+
+	return l.T("Managing subscription to <b>%s</b>\n\n%s", project.Name, statusMsg)
 }
 
 // handleSubscriptionAction performs a subscription action and handles common response patterns
@@ -156,7 +204,6 @@ func (h *subscriptionManagementHandler) handleSubscriptionAction(
 	action string,
 	actionFunc func(userID domain.TelegramUserID, projectID uuid.UUID) error,
 	successMessage string,
-	confirmationMessage string,
 ) {
 	projectID, ok := h.parseProjectID(c, action)
 	if !ok {
@@ -164,20 +211,18 @@ func (h *subscriptionManagementHandler) handleSubscriptionAction(
 	}
 
 	userID := h.getUserID(c)
+	l := h.service.locale(userID)
 	if err := actionFunc(userID, projectID); err != nil {
 		slog.Error("Failed to "+action+" subscription", "error", err)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Failed to " + action + " subscription. Please try again."})
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update subscription. Please try again.")})
 		return
 	}
 
 	// Respond to the callback to remove the loading indicator
-	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: successMessage})
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T(successMessage)})
 
 	// Update the message with new status and inline buttons
 	h.updateSubscriptionMessage(c, projectID)
-
-	// Send a confirmation with the subscription management menu
-	h.service.bot.Send(c.Sender, confirmationMessage, subscriptionManagementMenu)
 }
 
 // handleManageSubscription handles the Manage button click for a subscription
@@ -192,29 +237,19 @@ func (h *subscriptionManagementHandler) handleManageSubscription(c *telebot.Call
 	sub, project, err := h.findSubscription(userID, projectID)
 	if err != nil {
 		slog.Error("Failed to find subscription", "error", err)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Failed to get subscription details. Please try again."})
+		l := h.service.locale(userID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get subscription details. Please try again.")})
 		return
 	}
 
 	// Respond to the callback to remove the loading indicator
 	h.service.bot.Respond(c, &telebot.CallbackResponse{})
 
-	// Create inline keyboard with management options
-	inlineMarkup := h.createSubscriptionButtons(sub, projectID)
-
-	// Create status message
-	message := h.createStatusMessage(sub, project)
-
-	// Send message with both inline buttons and reply keyboard
-	_, err = h.service.bot.Send(c.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, inlineMarkup)
+	// Replace the list with the status and management options
+	_, err = h.service.bot.Edit(c.Message, h.createStatusMessage(sub, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createSubscriptionButtons(sub, projectID))
 	if err != nil {
-		slog.Error("Failed to send subscription management message", "error", err)
-	}
-
-	// Send the reply keyboard separately
-	_, err = h.service.bot.Send(c.Sender, "Use the buttons below to manage your subscription:", subscriptionManagementMenu)
-	if err != nil {
-		slog.Error("Failed to send subscription management menu", "error", err)
+		slog.Error("Failed to show subscription management message", "error", err)
 	}
 }
 
@@ -240,15 +275,19 @@ func (h *subscriptionManagementHandler) updateSubscriptionMessage(c *telebot.Cal
 	}
 }
 
-// handleMuteSubscription handles muting a subscription
+// showWizardSubscription shows the subscription again when a wizard about it is cancelled
+func (h *subscriptionManagementHandler) showWizardSubscription(w *wizardContext) error {
+	sub, project, err := h.findSubscription(w.UserID(), w.Data.ProjectID)
+	if err != nil {
+		return err
+	}
+	return w.Show(h.createStatusMessage(sub, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, h.createSubscriptionButtons(sub, project.ID))
+}
+
+// handleMuteSubscription asks for how long to mute a subscription
 func (h *subscriptionManagementHandler) handleMuteSubscription(c *telebot.Callback) {
-	h.handleSubscriptionAction(
-		c,
-		"mute",
-		h.service.subscriptionService.MuteNotifications,
-		"Subscription muted",
-		"Notifications have been muted.",
-	)
+	h.service.durations.showPicker(c, durationKindMute)
 }
 
 // handleUnmuteSubscription handles unmuting a subscription
@@ -258,47 +297,92 @@ func (h *subscriptionManagementHandler) handleUnmuteSubscription(c *telebot.Call
 		"unmute",
 		h.service.subscriptionService.UnmuteNotifications,
 		"Subscription unmuted",
-		"Notifications have been unmuted.",
 	)
 }
 
-// handlePauseSubscription handles pausing a subscription
+// handlePauseSubscription asks for how long to pause a subscription
 func (h *subscriptionManagementHandler) handlePauseSubscription(c *telebot.Callback) {
-	projectID, ok := h.parseProjectID(c, "pause subscription")
+	h.service.durations.showPicker(c, durationKindPause)
+}
+
+// handleResumeSubscription handles resuming a subscription, sending the notifications held during the pause
+func (h *subscriptionManagementHandler) handleResumeSubscription(c *telebot.Callback) {
+	h.handleSubscriptionAction(
+		c,
+		"resume",
+		h.service.subscriptionService.ResumeNotifications,
+		"Subscription resumed",
+	)
+
+	if projectID, err := uuid.Parse(c.Data); err == nil {
+		h.service.digests.sendResumeSummary(h.getUserID(c), projectID)
+	}
+}
+
+// nextQuietOverride is the order in which the quiet hours override button cycles through the modes
+var nextQuietOverride = map[domain.QuietMode]domain.QuietMode{
+	domain.QuietModeDefault: domain.QuietModeOff,
+	domain.QuietModeOff:     domain.QuietModeSilent,
+	domain.QuietModeSilent:  domain.QuietModeHold,
+	domain.QuietModeHold:    domain.QuietModeDefault,
+}
+
+// handleQuietOverride switches how quiet hours apply to the subscription
+func (h *subscriptionManagementHandler) handleQuietOverride(c *telebot.Callback) {
+	projectID, ok := h.parseProjectID(c, "quiet override")
 	if !ok {
 		return
 	}
 
-	// Pause the subscription for 24 hours
 	userID := h.getUserID(c)
-	pauseUntil := time.Now().Add(24 * time.Hour)
-
-	// We need a custom function to handle the additional pauseUntil parameter
-	if err := h.service.subscriptionService.PauseNotifications(userID, projectID, pauseUntil); err != nil {
-		slog.Error("Failed to pause subscription", "error", err)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Failed to pause subscription. Please try again."})
+	l := h.service.locale(userID)
+	sub, err := h.service.subscriptionService.GetSubscription(userID, projectID)
+	if err != nil {
+		slog.Error("Failed to get subscription", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get subscription details. Please try again.")})
 		return
 	}
 
-	// Respond to the callback to remove the loading indicator
-	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Subscription paused for 24 hours"})
+	mode := nextQuietOverride[sub.QuietMode]
+	if err := h.service.subscriptionService.SetQuietMode(userID, projectID, mode); err != nil {
+		slog.Error("Failed to set subscription quiet mode", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update subscription. Please try again.")})
+		return
+	}
 
-	// Update the message with new status and inline buttons
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Quiet hours: %s", l.T(quietModeDescriptions[mode]))})
 	h.updateSubscriptionMessage(c, projectID)
-
-	// Send a confirmation with the subscription management menu
-	h.service.bot.Send(c.Sender, "Notifications have been paused for 24 hours.", subscriptionManagementMenu)
 }
 
-// handleResumeSubscription handles resuming a subscription
-func (h *subscriptionManagementHandler) handleResumeSubscription(c *telebot.Callback) {
-	h.handleSubscriptionAction(
-		c,
-		"resume",
-		h.service.subscriptionService.ResumeNotifications,
-		"Subscription resumed",
-		"Notifications have been resumed.",
-	)
+// handleHoldWhilePaused switches between skipping and holding notifications during pauses
+func (h *subscriptionManagementHandler) handleHoldWhilePaused(c *telebot.Callback) {
+	projectID, ok := h.parseProjectID(c, "hold while paused")
+	if !ok {
+		return
+	}
+
+	userID := h.getUserID(c)
+	l := h.service.locale(userID)
+	sub, err := h.service.subscriptionService.GetSubscription(userID, projectID)
+	if err != nil {
+		slog.Error("Failed to get subscription", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get subscription details. Please try again.")})
+		return
+	}
+
+	hold := !sub.HoldWhilePaused
+	if err := h.service.subscriptionService.SetHoldWhilePaused(userID, projectID, hold); err != nil {
+		slog.Error("Failed to set hold while paused", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update subscription. Please try again.")})
+		return
+	}
+
+	response := "Notifications will be skipped while paused"
+	if hold {
+		response = "Notifications will be held while paused and summarized when the pause ends"
+	}
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T(response)})
+	h.updateSubscriptionMessage(c, projectID)
 }
 
 // handleUnsubscribe handles unsubscribing from a project
@@ -309,46 +393,66 @@ func (h *subscriptionManagementHandler) handleUnsubscribe(c *telebot.Callback) {
 	}
 
 	userID := h.getUserID(c)
+	l := h.service.locale(userID)
 
 	// Get project details before unsubscribing
 	project, err := h.service.projectService.GetByID(projectID)
 	if err != nil {
 		slog.Error("Failed to get project details", "error", err, "project_id", projectID)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Failed to get project details. Please try again."})
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
 		return
 	}
 
 	// Unsubscribe the user
 	if err := h.service.subscriptionService.Unsubscribe(userID, projectID); err != nil {
 		slog.Error("Failed to unsubscribe", "error", err)
-		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Failed to unsubscribe. Please try again."})
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to unsubscribe. Please try again.")})
 		return
 	}
 
 	// Respond to the callback to remove the loading indicator
-	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: "Unsubscribed successfully"})
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Unsubscribed successfully")})
 
 	// Create inline keyboard with re-subscribe button
-	inlineMarkup := h.createResubscribeButton(projectID)
+	inlineMarkup := h.createResubscribeButton(l, projectID)
 
 	// Update the message
-	message := fmt.Sprintf("You have unsubscribed from <b>%s</b>", project.Name)
+	message := l.T("You have unsubscribed from <b>%s</b>", project.Name)
 	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, inlineMarkup)
 	if err != nil {
 		slog.Error("Failed to update subscription message after unsubscribe", "error", err)
 	}
-
-	// Send a confirmation with the subscription management menu
-	h.service.bot.Send(c.Sender, "You have been unsubscribed from the project.", subscriptionManagementMenu)
 }
 
 // handleResubscribe handles re-subscribing to a project
 func (h *subscriptionManagementHandler) handleResubscribe(c *telebot.Callback) {
+	projectID, ok := h.parseProjectID(c, "resubscribe")
+	if !ok {
+		return
+	}
+
+	l := h.service.userLocale(c.Sender)
+	project, err := h.service.projectService.GetByID(projectID)
+	if err != nil {
+		slog.Error("Failed to get project details", "error", err, "project_id", projectID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get project details. Please try again.")})
+		return
+	}
+
+	// Projects requiring approval go through the publisher again
+	if project.RequiresApproval {
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		if _, err := h.service.subscriptionRequests.requestSubscription(c.Sender, project); err != nil {
+			slog.Error("Failed to request subscription", "error", err)
+			h.service.bot.Send(c.Sender, l.T("Sorry, failed to process your subscription. Please try again later."), l.Markup(subscriptionsMenu))
+		}
+		return
+	}
+
 	h.handleSubscriptionAction(
 		c,
 		"resubscribe",
 		h.service.subscriptionService.Subscribe,
 		"Re-subscribed successfully",
-		"You have been re-subscribed to the project.",
 	)
 }
@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for approving subscription requests
+var (
+	btnApproveRequest = telebot.InlineButton{Unique: "approve_request", Text: "✅ Approve"}
+	btnRejectRequest  = telebot.InlineButton{Unique: "reject_request", Text: "❌ Reject"}
+)
+
+type subscriptionRequestsHandler struct {
+	service *Service
+}
+
+func newSubscriptionRequestsHandler(s *Service) *subscriptionRequestsHandler {
+	return &subscriptionRequestsHandler{service: s}
+}
+
+func (h *subscriptionRequestsHandler) register() {
+	h.service.bot.Handle(&btnApproveRequest, h.handleApproveRequest)
+	h.service.bot.Handle(&btnRejectRequest, h.handleRejectRequest)
+}
+
+// requestSubscription creates a pending subscription request and asks the publisher to approve it.
+// It returns nil request if no new request was created, e.g. because one is already pending.
+func (h *subscriptionRequestsHandler) requestSubscription(sender *telebot.User, project *domain.Project) (*domain.SubscriptionRequest, error) {
+	l := h.service.userLocale(sender)
+	userID := domain.MustNewTelegramUserID(int64(sender.ID))
+	request, err := h.service.subscriptionService.RequestSubscription(userID, project.ID)
+	if err != nil {
+		if errors.Is(err, domain.ErrRequestPending) {
+			h.service.bot.Send(sender, l.T("Your request to subscribe to project <b>%s</b> is still waiting for approval.", project.Name),
+				&telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+			return nil, nil
+		}
+		if message, ok := subscribeErrorMessage(l, err, project); ok {
+			h.service.bot.Send(sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to request subscription: %w", err)
+	}
+
+	// The publisher gets the request in their own language
+	publisherLocale := h.service.locale(project.PublisherID)
+	approveBtn := publisherLocale.Button(btnApproveRequest)
+	approveBtn.Data = request.ID.String()
+	rejectBtn := publisherLocale.Button(btnRejectRequest)
+	rejectBtn.Data = request.ID.String()
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{approveBtn, rejectBtn},
+		},
+	}
+
+	message := publisherLocale.T("<b>%s</b> wants to subscribe to project <b>%s</b>.",
+		html.EscapeString(h.service.getDisplayName(userID)), project.Name)
+	_, err = h.service.bot.Send(&telebot.Chat{ID: project.PublisherID.Int64()}, message,
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	if err != nil {
+		// The request was created even though the publisher was not told about it
+		return request, fmt.Errorf("failed to send subscription request to publisher: %w", err)
+	}
+
+	h.service.bot.Send(sender, l.T("Project <b>%s</b> requires approval. "+
+		"Your request has been sent to the publisher, you will be notified once they decide.", project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+	return request, nil
+}
+
+// getOwnedRequest parses a request ID from callback data and makes sure
+// the requested project belongs to the user who pressed the button
+func (h *subscriptionRequestsHandler) getOwnedRequest(c *telebot.Callback, action string) (*domain.SubscriptionRequest, *domain.Project, bool) {
+	l := h.service.userLocale(c.Sender)
+	requestID, err := uuid.Parse(c.Data)
+	if err != nil {
+		slog.Error("Invalid request ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid request. Please try again.")})
+		return nil, nil, false
+	}
+
+	request, err := h.service.subscriptionService.GetRequest(requestID)
+	if err != nil {
+		slog.Warn("Subscription request not found", "error", err, "request_id", requestID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This request has already been handled.")})
+		if _, err := h.service.bot.Edit(c.Message, c.Message.Text); err != nil {
+			slog.Error("Failed to remove buttons from handled request", "error", err)
+		}
+		return nil, nil, false
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, request.ProjectID.String(), action)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return request, project, true
+}
+
+// handleApproveRequest approves a pending subscription request
+func (h *subscriptionRequestsHandler) handleApproveRequest(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	request, project, ok := h.getOwnedRequest(c, "approve request")
+	if !ok {
+		return
+	}
+
+	_, err := h.service.subscriptionService.ApproveRequest(request.ID)
+	if errors.Is(err, domain.ErrSubscriberBanned) {
+		// The request is discarded without telling the banned user
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("User is banned")})
+		_, err := h.service.bot.Edit(c.Message, l.T("🚫 <b>%s</b> is banned from project <b>%s</b>, the request was discarded.",
+			html.EscapeString(h.service.getDisplayName(request.UserID)), project.Name),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML})
+		if err != nil {
+			slog.Error("Failed to update subscription request message", "error", err)
+		}
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to approve subscription request", "error", err, "request_id", request.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to approve request. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Request approved")})
+	h.finishRequest(c, request, project,
+		"✅ You approved the subscription of <b>%s</b> to project <b>%s</b>.",
+		"Your request to subscribe to project <b>%s</b> has been approved!")
+}
+
+// handleRejectRequest rejects a pending subscription request
+func (h *subscriptionRequestsHandler) handleRejectRequest(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	request, project, ok := h.getOwnedRequest(c, "reject request")
+	if !ok {
+		return
+	}
+
+	if _, err := h.service.subscriptionService.RejectRequest(request.ID); err != nil {
+		slog.Error("Failed to reject subscription request", "error", err, "request_id", request.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to reject request. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Request rejected")})
+	h.finishRequest(c, request, project,
+		"❌ You rejected the subscription of <b>%s</b> to project <b>%s</b>.",
+		"Your request to subscribe to project <b>%s</b> has been rejected.")
+}
+
+// finishRequest updates the publisher's prompt and tells the requester about the outcome
+func (h *subscriptionRequestsHandler) finishRequest(
+	c *telebot.Callback,
+	request *domain.SubscriptionRequest,
+	project *domain.Project,
+	publisherMessage string,
+	requesterMessage string,
+) {
+	name := html.EscapeString(h.service.getDisplayName(request.UserID))
+	_, err := h.service.bot.Edit(c.Message, h.service.userLocale(c.Sender).T(publisherMessage, name, project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML})
+	if err != nil {
+		slog.Error("Failed to update subscription request message", "error", err)
+	}
+
+	_, err = h.service.bot.Send(&telebot.Chat{ID: request.UserID.Int64()}, h.service.locale(request.UserID).T(requesterMessage, project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML})
+	if err != nil {
+		slog.Error("Failed to notify requester", "error", err, "chatId", request.UserID)
+	}
+}
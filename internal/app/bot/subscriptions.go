@@ -1,25 +1,30 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
-	"strings"
+	"strconv"
 
-	"github.com/google/uuid"
 	"github.com/tucnak/telebot"
 
 	"github.com/sergeax/noteo/internal/domain"
 )
 
-// Menu items
+// Menu items, data of the subscriptions list button is the page
 var (
+	btnSubscriptionsList = telebot.InlineButton{Unique: "subscriptions", Text: "📬 My subscriptions"}
+	btnConfirmSubscribe  = telebot.InlineButton{Unique: "confirm_subscribe", Text: "✅ Subscribe"}
+	btnCancelSubscribe   = telebot.InlineButton{Unique: "cancel_subscribe", Text: "✖️ Cancel"}
+
+	// btnMySubscriptions is a button of the reply keyboard of earlier versions of the bot, matched by its English text
 	btnMySubscriptions = telebot.ReplyButton{Text: "My Subscriptions"}
 
 	subscriptionsMenu = &telebot.ReplyMarkup{
-		ReplyKeyboard: [][]telebot.ReplyButton{
-			{btnBackToMenu},
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnSubscriptionsList, btnMainMenu},
 		},
-		ResizeReplyKeyboard: true,
 	}
 )
 
@@ -32,81 +37,230 @@ func newSubscriptionsHandler(s *Service) *subscriptionsHandler {
 }
 
 func (h *subscriptionsHandler) register() {
+	h.service.bot.Handle(&btnSubscriptionsList, h.handleSubscriptionsList)
 	h.service.bot.Handle(&btnMySubscriptions, h.handleMySubscriptions)
+	h.service.bot.Handle(&btnConfirmSubscribe, h.handleConfirmSubscribe)
+	h.service.bot.Handle(&btnCancelSubscribe, h.handleCancelSubscribe)
 }
 
-func (h *subscriptionsHandler) handleMySubscriptions(m *telebot.Message) {
-	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
-
+// createSubscriptionsList creates the menu message with a page of the user's subscriptions
+func (h *subscriptionsHandler) createSubscriptionsList(userID domain.TelegramUserID, page int) (string, *telebot.ReplyMarkup, error) {
 	subs, err := h.service.subscriptionService.GetUserSubscriptions(userID)
 	if err != nil {
-		slog.Error("Failed to get subscriptions", "error", err)
-		h.service.bot.Send(m.Sender, "Sorry, failed to get your subscriptions. Please try again.", subscriptionsMenu)
-		return
+		return "", nil, fmt.Errorf("failed to get subscriptions: %w", err)
 	}
 
+	start, end, page, pages := paginate(len(subs), page, menuPageSize)
+
+	l := h.service.locale(userID)
+	message := l.T("📬 <b>My subscriptions</b>")
 	if len(subs) == 0 {
-		h.service.bot.Send(m.Sender, "You don't have any subscriptions yet.", subscriptionsMenu)
-		return
+		message += "\n\n" + l.T("You don't have any subscriptions yet.")
+	} else if pages > 1 {
+		message += " " + l.T("(page %d of %d)", page+1, pages)
 	}
 
-	// Send each subscription as a separate message with a Manage button
-	for i, sub := range subs {
+	var keyboard [][]telebot.InlineButton
+	for _, sub := range subs[start:end] {
 		project, err := h.service.projectService.GetByID(sub.ProjectID)
 		if err != nil {
 			slog.Error("Failed to get project details", "error", err, "project_id", sub.ProjectID)
 			continue
 		}
 
-		// Create inline keyboard with Manage button
-		markup := &telebot.ReplyMarkup{}
-		btn := btnManageSubscription
-		btn.Data = sub.ProjectID.String() // Store project ID in button data
-		markup.InlineKeyboard = [][]telebot.InlineButton{
-			{btn},
-		}
-
-		message := fmt.Sprintf("%d. <b>%s</b>", i+1, project.Name)
-
 		// Add status indicators
-		statusFlags := []string{}
-		if sub.Muted {
-			statusFlags = append(statusFlags, "🔕 Muted")
+		text := project.Name
+		if sub.IsMuted() {
+			text += " 🔕"
 		}
-
 		if sub.Paused() {
-			statusFlags = append(statusFlags, "⏸️ Paused")
+			text += " ⏸️"
 		}
 
-		if len(statusFlags) > 0 {
-			message += " [" + strings.Join(statusFlags, ", ") + "]"
-		}
+		btn := btnManageSubscription
+		btn.Text = text
+		btn.Data = sub.ProjectID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	navigation := createPaginationRow(l, btnSubscriptionsList, page, pages, strconv.Itoa)
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	keyboard = append(keyboard, []telebot.InlineButton{l.Button(btnMainMenu)})
+
+	return message, &telebot.ReplyMarkup{InlineKeyboard: keyboard}, nil
+}
 
-		h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+// handleSubscriptionsList replaces the callback message with a page of the user's subscriptions
+func (h *subscriptionsHandler) handleSubscriptionsList(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	page, _ := strconv.Atoi(c.Data)
+	message, markup, err := h.createSubscriptionsList(domain.MustNewTelegramUserID(int64(c.Sender.ID)), page)
+	if err != nil {
+		slog.Error("Failed to show subscriptions", "error", err)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to get your subscriptions. Please try again.")})
+		return
 	}
 
-	// Send the main menu after all subscriptions
-	h.service.bot.Send(m.Sender, "Use the buttons below to manage your subscriptions.", subscriptionsMenu)
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	if _, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup); err != nil {
+		slog.Error("Failed to update subscriptions message", "error", err)
+	}
 }
 
-func (h *subscriptionsHandler) handleSubscriptionLink(m *telebot.Message, projectID uuid.UUID) error {
-	project, err := h.service.projectService.GetByID(projectID)
+// handleMySubscriptions sends the list of the user's subscriptions as a new message
+func (h *subscriptionsHandler) handleMySubscriptions(m *telebot.Message) {
+	message, markup, err := h.createSubscriptionsList(domain.MustNewTelegramUserID(int64(m.Sender.ID)), 0)
 	if err != nil {
-		return fmt.Errorf("failed to get project by ID: %w", err)
+		slog.Error("Failed to show subscriptions", "error", err)
+		l := h.service.userLocale(m.Sender)
+		h.service.bot.Send(m.Sender, l.T("Sorry, failed to get your subscriptions. Please try again."), l.Markup(mainMenu))
+		return
 	}
+	h.service.bot.Send(m.Sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+}
 
-	userID := domain.MustNewTelegramUserID(int64(m.Sender.ID))
-	err = h.service.subscriptionService.Subscribe(userID, project.ID)
+// createConfirmationMessage describes the project the user is about to subscribe to
+func (h *subscriptionsHandler) createConfirmationMessage(l *Locale, project *domain.Project) string {
+	message := l.T("You are about to subscribe to project <b>%s</b>\n\n<b>Publisher:</b> %s",
+		project.Name, html.EscapeString(h.service.getDisplayName(project.PublisherID)))
+	if project.Description != "" {
+		message += "\n\n" + html.EscapeString(project.Description)
+	}
+	if project.RequiresApproval {
+		message += "\n\n" + l.T("🔒 The publisher has to approve new subscribers.")
+	}
+	return message
+}
+
+// confirmSubscription shows the project details and asks the user to confirm the subscription.
+// The payload is the start parameter of the link, which is resolved again on confirmation.
+func (h *subscriptionsHandler) confirmSubscription(sender *telebot.User, project *domain.Project, payload string) error {
+	userID := domain.MustNewTelegramUserID(int64(sender.ID))
+	l := h.service.locale(userID)
+	subscribed, err := h.service.subscriptionService.IsSubscribed(userID, project.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
-			h.service.bot.Send(m.Sender, fmt.Sprintf("You are already subscribed to project <b>%s</b>", project.Name),
-				&telebot.SendOptions{ParseMode: telebot.ModeHTML}, mainMenu)
-			return nil
-		}
-		return fmt.Errorf("failed to subscribe: %w", err)
+		return fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if subscribed {
+		message, _ := subscribeErrorMessage(l, domain.ErrAlreadySubscribed, project)
+		h.service.bot.Send(sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+		return nil
 	}
 
-	h.service.bot.Send(m.Sender, fmt.Sprintf("You have successfully subscribed to project <b>%s</b>!", project.Name),
-		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, mainMenu)
+	subscribeBtn := l.Button(btnConfirmSubscribe)
+	subscribeBtn.Data = payload
+	cancelBtn := l.Button(btnCancelSubscribe)
+	cancelBtn.Data = payload
+	markup := &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{subscribeBtn, cancelBtn},
+		},
+	}
+
+	_, err = h.service.bot.Send(sender, h.createConfirmationMessage(l, project),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	if err != nil {
+		return fmt.Errorf("failed to send subscription confirmation: %w", err)
+	}
 	return nil
 }
+
+// handleConfirmSubscribe subscribes the user after they confirmed the subscription
+func (h *subscriptionsHandler) handleConfirmSubscribe(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	// The link may have expired or been revoked since the dialog was shown
+	project, link, err := h.service.inviteService.Resolve(c.Data)
+	if err != nil {
+		slog.Warn("Failed to resolve subscription link", "error", err, "payload", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{})
+		if _, err := h.service.bot.Edit(c.Message, inviteErrorMessage(l, err)); err != nil {
+			slog.Error("Failed to update subscription confirmation message", "error", err)
+		}
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	// Keep the project details, but remove the buttons
+	_, err = h.service.bot.Edit(c.Message, h.createConfirmationMessage(l, project), &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+	if err != nil {
+		slog.Error("Failed to update subscription confirmation message", "error", err)
+	}
+
+	if err := h.handleSubscriptionLink(c.Sender, project, link); err != nil {
+		slog.Error("Failed to handle subscription link", "error", err)
+		h.service.bot.Send(c.Sender, l.T("Sorry, failed to process your subscription. Please try again later."), l.Markup(mainMenu))
+	}
+}
+
+// handleCancelSubscribe dismisses the subscription confirmation
+func (h *subscriptionsHandler) handleCancelSubscribe(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Subscription cancelled")})
+	if _, err := h.service.bot.Edit(c.Message, l.T("Subscription cancelled.")+" "+l.T(mainMenuText), l.Markup(mainMenu)); err != nil {
+		slog.Error("Failed to update subscription confirmation message", "error", err)
+	}
+}
+
+// handleSubscriptionLink subscribes the user to the project a link points to.
+// The invite link is nil for legacy links containing the project ID.
+// Its use is counted first, so that a link can't be used more than allowed,
+// and given back if the user doesn't subscribe after all.
+func (h *subscriptionsHandler) handleSubscriptionLink(sender *telebot.User, project *domain.Project, link *domain.InviteLink) error {
+	l := h.service.userLocale(sender)
+	if link != nil {
+		if err := h.service.inviteService.Redeem(link); err != nil {
+			if errors.Is(err, domain.ErrInviteExhausted) {
+				h.service.bot.Send(sender, inviteErrorMessage(l, err), l.Markup(mainMenu))
+				return nil
+			}
+			return fmt.Errorf("failed to redeem invite link: %w", err)
+		}
+	}
+
+	subscribed, err := h.subscribe(sender, project)
+	if !subscribed && link != nil {
+		if err := h.service.inviteService.Unredeem(link); err != nil {
+			slog.Error("Failed to unredeem invite link", "error", err, "invite_id", link.ID)
+		}
+	}
+	return err
+}
+
+// subscribe subscribes the user to the project, or asks the publisher for approval if the project requires it.
+// It returns false if neither happened, the user is told why unless an error is returned.
+func (h *subscriptionsHandler) subscribe(sender *telebot.User, project *domain.Project) (bool, error) {
+	if project.RequiresApproval {
+		request, err := h.service.subscriptionRequests.requestSubscription(sender, project)
+		return request != nil, err
+	}
+
+	userID := domain.MustNewTelegramUserID(int64(sender.ID))
+	l := h.service.locale(userID)
+	err := h.service.subscriptionService.Subscribe(userID, project.ID)
+	if err != nil {
+		if message, ok := subscribeErrorMessage(l, err, project); ok {
+			h.service.bot.Send(sender, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	h.service.bot.Send(sender, l.T("You have successfully subscribed to project <b>%s</b>!", project.Name),
+		&telebot.SendOptions{ParseMode: telebot.ModeHTML}, l.Markup(mainMenu))
+	return true, nil
+}
+
+// subscribeErrorMessage returns a user-facing message for expected subscription errors
+func subscribeErrorMessage(l *Locale, err error, project *domain.Project) (string, bool) {
+	switch {
+	case errors.Is(err, domain.ErrAlreadySubscribed):
+		return l.T("You are already subscribed to project <b>%s</b>", project.Name), true
+	case errors.Is(err, domain.ErrSubscriberBanned):
+		return l.T("Sorry, you can't subscribe to project <b>%s</b>.", project.Name), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,629 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Menu items for managing project tokens, the data of the token buttons is the token ID
+var (
+	btnProjectTokens      = telebot.InlineButton{Unique: "project_tokens", Text: "🔑 Tokens"}
+	btnProjectToken       = telebot.InlineButton{Unique: "project_token"}
+	btnNewToken           = telebot.InlineButton{Unique: "new_token", Text: "➕ New token"}
+	btnTokenScope         = telebot.InlineButton{Unique: "token_scope"}
+	btnTokenAllowedIPs    = telebot.InlineButton{Unique: "token_ips", Text: "🌐 Allowed addresses"}
+	btnTokenExpiry        = telebot.InlineButton{Unique: "token_expiry", Text: "⏳ Expiry"}
+	btnSetTokenExpiry     = telebot.InlineButton{Unique: "set_token_expiry"}
+	btnRotateToken        = telebot.InlineButton{Unique: "rotate_token", Text: "🔄 Rotate"}
+	btnConfirmRotateToken = telebot.InlineButton{Unique: "confirm_rotate_token"}
+	btnRevokeToken        = telebot.InlineButton{Unique: "revoke_token", Text: "🗑 Revoke"}
+	btnConfirmRevokeToken = telebot.InlineButton{Unique: "confirm_revoke_token", Text: "✅ Yes, revoke"}
+)
+
+// tokenScopeLabels describe what the scopes of a token allow
+var tokenScopeLabels = map[domain.TokenScope]string{
+	domain.TokenScopeNotify:    "send notifications",
+	domain.TokenScopeHeartbeat: "report heartbeats",
+	domain.TokenScopeStatus:    "read status",
+}
+
+// tokenExpiryOptions are the lifetimes offered for tokens, zero means no expiry
+var tokenExpiryOptions = []inviteExpiryOption{
+	{"No expiry", 0},
+	{"7 days", 7 * 24},
+	{"30 days", 30 * 24},
+	{"90 days", 90 * 24},
+	{"1 year", 365 * 24},
+}
+
+// tokenGraceOptions are the periods a rotated token keeps working, zero means it stops right away
+var tokenGraceOptions = []inviteExpiryOption{
+	{"Stop the old token right away", 0},
+	{"Keep the old token for 1 hour", 1},
+	{"Keep the old token for 1 day", 24},
+	{"Keep the old token for 7 days", 7 * 24},
+}
+
+// tokenShownOnce warns that only the hash of a new token is kept
+const tokenShownOnce = "⚠️ Copy the token now, it won't be shown again."
+
+// Wizards asking the publisher about tokens
+const (
+	wizardNewToken        = "new_token"
+	wizardTokenAllowedIPs = "token_allowed_ips"
+)
+
+type tokensHandler struct {
+	service *Service
+}
+
+func newTokensHandler(s *Service) *tokensHandler {
+	return &tokensHandler{service: s}
+}
+
+func (h *tokensHandler) register() {
+	h.service.bot.Handle(&btnProjectTokens, h.handleProjectTokens)
+	h.service.bot.Handle(&btnProjectToken, h.handleProjectToken)
+	h.service.bot.Handle(&btnNewToken, h.handleNewToken)
+	h.service.bot.Handle(&btnTokenScope, h.handleTokenScope)
+	h.service.bot.Handle(&btnTokenAllowedIPs, h.handleTokenAllowedIPs)
+	h.service.bot.Handle(&btnTokenExpiry, h.handleTokenExpiry)
+	h.service.bot.Handle(&btnSetTokenExpiry, h.handleSetTokenExpiry)
+	h.service.bot.Handle(&btnRotateToken, h.handleRotateToken)
+	h.service.bot.Handle(&btnConfirmRotateToken, h.handleConfirmRotateToken)
+	h.service.bot.Handle(&btnRevokeToken, h.handleRevokeToken)
+	h.service.bot.Handle(&btnConfirmRevokeToken, h.handleConfirmRevokeToken)
+
+	h.service.wizards.add(&wizard{
+		Name:    wizardNewToken,
+		Steps:   []wizardStep{{Prompt: staticPrompt("Please enter a name for the new token, e.g. the service using it:")}},
+		Finish:  h.createToken,
+		Cancel:  h.showWizardTokens,
+		Menu:    projectsMenu,
+		Failure: "Sorry, failed to create token. Please try again.",
+	})
+	h.service.wizards.add(&wizard{
+		Name:    wizardTokenAllowedIPs,
+		Steps:   []wizardStep{{Prompt: h.allowedIPsPrompt}},
+		Finish:  h.setAllowedIPs,
+		Cancel:  h.showWizardToken,
+		Menu:    projectsMenu,
+		Failure: "Sorry, failed to update token. Please try again.",
+	})
+}
+
+// describeScopes lists what a token or signing secret allows
+func describeScopes(l *Locale, scopes []domain.TokenScope) string {
+	labels := make([]string, len(scopes))
+	for i, scope := range scopes {
+		labels[i] = l.T(tokenScopeLabels[scope])
+	}
+	return strings.Join(labels, ", ")
+}
+
+// describeExpiry tells when the token expires or expired
+func (h *tokensHandler) describeExpiry(l *Locale, token *domain.ProjectToken, viewerID domain.TelegramUserID) string {
+	switch {
+	case token.ExpiresAt == nil:
+		return l.T("valid until revoked")
+	case token.Expired(time.Now()):
+		return l.T("⌛ expired %s", h.service.formatTime(*token.ExpiresAt, viewerID))
+	default:
+		return l.T("expires %s", h.service.formatTime(*token.ExpiresAt, viewerID))
+	}
+}
+
+// createTokensView creates the message listing the tokens of the project and its keyboard
+func (h *tokensHandler) createTokensView(l *Locale, project *domain.Project) (string, *telebot.ReplyMarkup, error) {
+	tokens, err := h.service.tokenService.GetByProject(project.ID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get project tokens: %w", err)
+	}
+
+	message := l.T("🔑 Tokens of <b>%s</b>\n\n"+
+		"Tokens authenticate the requests of your services. "+
+		"Rotate a token with a grace period to replace it without breaking the services using it.", project.Name)
+	if len(tokens) == 0 {
+		message += "\n\n" + l.T("There are no tokens, so notifications can't be sent.")
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for i, token := range tokens {
+		message += fmt.Sprintf("\n\n%d. <b>%s</b>\n<code>%s…</code>\n%s, %s", i+1, html.EscapeString(token.Name),
+			token.Prefix, describeScopes(l, token.Scopes), h.describeExpiry(l, token, project.PublisherID))
+
+		btn := btnProjectToken
+		btn.Text = fmt.Sprintf("%d. %s", i+1, token.Name)
+		btn.Data = token.ID.String()
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	newBtn := l.Button(btnNewToken)
+	newBtn.Data = project.ID.String()
+	signingBtn := l.Button(btnSignedRequests)
+	signingBtn.Data = project.ID.String()
+	backBtn := l.Button(btnBackToProject)
+	backBtn.Data = project.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{newBtn, signingBtn}, []telebot.InlineButton{backBtn})
+
+	return message, &telebot.ReplyMarkup{InlineKeyboard: keyboard}, nil
+}
+
+// createTokenView creates the message describing a token and the keyboard for changing it.
+// Only the prefix of the token is shown, unless it has just been created and the full token is known.
+func (h *tokensHandler) createTokenView(l *Locale, project *domain.Project, token *domain.ProjectToken) (string, *telebot.ReplyMarkup) {
+	value := token.Prefix + "…"
+	if token.Token != "" {
+		value = token.Token
+	}
+
+	allowedFrom := l.T("any address")
+	if len(token.AllowedIPs) > 0 {
+		prefixes := make([]string, len(token.AllowedIPs))
+		for i, prefix := range token.AllowedIPs {
+			prefixes[i] = prefix.String()
+		}
+		allowedFrom = strings.Join(prefixes, ", ")
+	}
+
+	lastUsed := l.T("never")
+	if token.LastUsedAt != nil {
+		lastUsed = h.service.formatTime(*token.LastUsedAt, project.PublisherID)
+	}
+
+	message := l.T("🔑 Token <b>%s</b> of <b>%s</b>\n\n<code>%s</code>\n\n"+
+		"<b>Allows to:</b> %s\n<b>Allowed from:</b> %s\n<b>Expiry:</b> %s\n<b>Last used:</b> %s\n<b>Created:</b> %s",
+		html.EscapeString(token.Name), project.Name, value,
+		describeScopes(l, token.Scopes), allowedFrom, h.describeExpiry(l, token, project.PublisherID),
+		lastUsed, h.service.formatTime(token.CreatedAt, project.PublisherID))
+	if token.Token != "" {
+		message += "\n\n" + l.T(tokenShownOnce)
+	}
+
+	var keyboard [][]telebot.InlineButton
+	for _, scope := range domain.TokenScopes {
+		btn := btnTokenScope
+		btn.Text = "⬜ " + l.T(tokenScopeLabels[scope])
+		if token.HasScope(scope) {
+			btn.Text = "✅ " + l.T(tokenScopeLabels[scope])
+		}
+		btn.Data = joinCallbackData(token.ID.String(), string(scope))
+		keyboard = append(keyboard, []telebot.InlineButton{btn})
+	}
+
+	ipsBtn := l.Button(btnTokenAllowedIPs)
+	ipsBtn.Data = token.ID.String()
+	expiryBtn := l.Button(btnTokenExpiry)
+	expiryBtn.Data = token.ID.String()
+	rotateBtn := l.Button(btnRotateToken)
+	rotateBtn.Data = token.ID.String()
+	revokeBtn := l.Button(btnRevokeToken)
+	revokeBtn.Data = token.ID.String()
+	backBtn := btnProjectTokens
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = project.ID.String()
+
+	keyboard = append(keyboard,
+		[]telebot.InlineButton{ipsBtn, expiryBtn},
+		[]telebot.InlineButton{rotateBtn, revokeBtn},
+		[]telebot.InlineButton{backBtn},
+	)
+	return message, &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// showTokens replaces the callback message with the tokens of the project
+func (h *tokensHandler) showTokens(c *telebot.Callback, project *domain.Project) {
+	l := h.service.userLocale(c.Sender)
+	message, markup, err := h.createTokensView(l, project)
+	if err != nil {
+		slog.Error("Failed to show project tokens", "error", err, "project_id", project.ID)
+		h.service.bot.Send(c.Sender, l.T("Sorry, failed to get the tokens. Please try again."))
+		return
+	}
+
+	_, err = h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	if err != nil {
+		slog.Error("Failed to update project tokens message", "error", err)
+	}
+}
+
+// showToken replaces the callback message with the details of the token
+func (h *tokensHandler) showToken(c *telebot.Callback, project *domain.Project, token *domain.ProjectToken) {
+	message, markup := h.createTokenView(h.service.userLocale(c.Sender), project, token)
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	if err != nil {
+		slog.Error("Failed to update project token message", "error", err)
+	}
+}
+
+// getOwnedToken parses a token ID and makes sure the token belongs to a project of the user who pressed the button
+func (h *tokensHandler) getOwnedToken(c *telebot.Callback, rawID string, action string) (*domain.ProjectToken, *domain.Project, bool) {
+	l := h.service.userLocale(c.Sender)
+	tokenID, err := uuid.Parse(rawID)
+	if err != nil {
+		slog.Error("Invalid token ID in "+action+" callback", "error", err, "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid token. Please try again.")})
+		return nil, nil, false
+	}
+
+	token, err := h.service.tokenService.GetByID(tokenID)
+	if err != nil {
+		slog.Error("Failed to get project token", "error", err, "token_id", tokenID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("This token no longer exists.")})
+		return nil, nil, false
+	}
+
+	project, ok := h.service.projectManagement.getOwnedProjectByID(c, token.ProjectID.String(), action)
+	if !ok {
+		return nil, nil, false
+	}
+	return token, project, true
+}
+
+// handleProjectTokens shows the tokens of the project
+func (h *tokensHandler) handleProjectTokens(c *telebot.Callback) {
+	project, ok := h.service.projectManagement.getOwnedProject(c, "project tokens")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showTokens(c, project)
+}
+
+// handleProjectToken shows the details of a token
+func (h *tokensHandler) handleProjectToken(c *telebot.Callback) {
+	token, project, ok := h.getOwnedToken(c, c.Data, "project token")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showToken(c, project, token)
+}
+
+// handleNewToken asks the publisher for the name of a new token
+func (h *tokensHandler) handleNewToken(c *telebot.Callback) {
+	project, ok := h.service.projectManagement.getOwnedProject(c, "new token")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardNewToken, StateData{ProjectID: project.ID})
+}
+
+// createToken creates a token allowing everything with the name entered by the publisher
+func (h *tokensHandler) createToken(w *wizardContext) error {
+	project, err := h.service.projectManagement.wizardProject(w)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.service.tokenService.Create(project.ID, domain.TokenOptions{Name: w.Answer(0)})
+	if err != nil {
+		if message, ok := tokenErrorMessage(w.Locale, err); ok {
+			return invalidAnswer(message)
+		}
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+
+	message, markup := h.createTokenView(w.Locale, project, token)
+	w.Reply(w.Locale.T("✅ Token created.")+"\n\n"+message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	return nil
+}
+
+// showWizardTokens shows the tokens of the project again when creating a token is cancelled
+func (h *tokensHandler) showWizardTokens(w *wizardContext) error {
+	project, err := h.service.projectManagement.wizardProject(w)
+	if err != nil {
+		return err
+	}
+
+	message, markup, err := h.createTokensView(w.Locale, project)
+	if err != nil {
+		return err
+	}
+	return w.Show(message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+}
+
+// handleTokenScope allows or forbids an action to the token
+func (h *tokensHandler) handleTokenScope(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	if !ok {
+		slog.Error("Invalid data in token scope callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return
+	}
+
+	token, project, ok := h.getOwnedToken(c, parts[0], "token scope")
+	if !ok {
+		return
+	}
+
+	scope := domain.TokenScope(parts[1])
+	if token.HasScope(scope) {
+		var scopes []domain.TokenScope
+		for _, s := range token.Scopes {
+			if s != scope {
+				scopes = append(scopes, s)
+			}
+		}
+		token.Scopes = scopes
+	} else {
+		token.Scopes = append(token.Scopes, scope)
+	}
+
+	if err := h.service.tokenService.Update(token); err != nil {
+		if message, ok := tokenErrorMessage(l, err); ok {
+			h.service.bot.Respond(c, &telebot.CallbackResponse{Text: message})
+			return
+		}
+		slog.Error("Failed to update token scopes", "error", err, "token_id", token.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update token. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showToken(c, project, token)
+}
+
+// handleTokenAllowedIPs asks the publisher for the addresses the token can be used from
+func (h *tokensHandler) handleTokenAllowedIPs(c *telebot.Callback) {
+	token, project, ok := h.getOwnedToken(c, c.Data, "token allowed addresses")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.service.wizards.start(c.Sender, c.Message, wizardTokenAllowedIPs, StateData{ProjectID: project.ID, TokenID: token.ID})
+}
+
+// wizardToken returns the token of a wizard, making sure it belongs to the user
+func (h *tokensHandler) wizardToken(w *wizardContext) (*domain.ProjectToken, *domain.Project, error) {
+	project, err := h.service.projectManagement.wizardProject(w)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := h.service.tokenService.GetByID(w.Data.TokenID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token.ProjectID != project.ID {
+		return nil, nil, fmt.Errorf("token %s doesn't belong to project %s", token.ID, project.ID)
+	}
+	return token, project, nil
+}
+
+// allowedIPsPrompt asks for the addresses the token can be used from
+func (h *tokensHandler) allowedIPsPrompt(w *wizardContext) (string, error) {
+	token, _, err := h.wizardToken(w)
+	if err != nil {
+		return "", err
+	}
+	return w.Locale.T("Which addresses can token <b>%s</b> be used from?\n\n"+
+		"Send addresses or networks separated by commas, e.g. <code>203.0.113.7, 10.0.0.0/8</code>, "+
+		"or <code>-</code> to allow any address.", html.EscapeString(token.Name)), nil
+}
+
+// setAllowedIPs limits the token to the addresses entered by the publisher
+func (h *tokensHandler) setAllowedIPs(w *wizardContext) error {
+	token, project, err := h.wizardToken(w)
+	if err != nil {
+		return err
+	}
+
+	answer := strings.TrimSpace(w.Answer(0))
+	if answer == "-" {
+		answer = ""
+	}
+	token.AllowedIPs, err = domain.ParseAllowedIPs(answer)
+	if err == nil {
+		err = h.service.tokenService.Update(token)
+	}
+	if err != nil {
+		if message, ok := tokenErrorMessage(w.Locale, err); ok {
+			return invalidAnswer(message)
+		}
+		return fmt.Errorf("failed to update token allowed addresses: %w", err)
+	}
+
+	message, markup := h.createTokenView(w.Locale, project, token)
+	w.Reply(w.Locale.T("✅ Allowed addresses updated.")+"\n\n"+message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+	return nil
+}
+
+// showWizardToken shows the token again when changing it is cancelled
+func (h *tokensHandler) showWizardToken(w *wizardContext) error {
+	token, project, err := h.wizardToken(w)
+	if err != nil {
+		return err
+	}
+
+	message, markup := h.createTokenView(w.Locale, project, token)
+	return w.Show(message, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, markup)
+}
+
+// showOptions replaces the callback message with a question and a button for every option
+func (h *tokensHandler) showOptions(c *telebot.Callback, token *domain.ProjectToken, question string,
+	btn telebot.InlineButton, options []inviteExpiryOption) {
+	l := h.service.userLocale(c.Sender)
+
+	var keyboard [][]telebot.InlineButton
+	for _, option := range options {
+		optionBtn := btn
+		optionBtn.Text = l.T(option.Label)
+		optionBtn.Data = joinCallbackData(token.ID.String(), strconv.Itoa(option.Hours))
+		keyboard = append(keyboard, []telebot.InlineButton{optionBtn})
+	}
+	backBtn := btnProjectToken
+	backBtn.Text = l.T("↩️ Back")
+	backBtn.Data = token.ID.String()
+	keyboard = append(keyboard, []telebot.InlineButton{backBtn})
+
+	_, err := h.service.bot.Edit(c.Message, question, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		&telebot.ReplyMarkup{InlineKeyboard: keyboard})
+	if err != nil {
+		slog.Error("Failed to show token options", "error", err)
+	}
+}
+
+// parseOption parses the token ID and the number of hours of an option button
+func (h *tokensHandler) parseOption(c *telebot.Callback, action string) (*domain.ProjectToken, *domain.Project, time.Duration, bool) {
+	l := h.service.userLocale(c.Sender)
+	parts, ok := splitCallbackData(c.Data, 2)
+	hours, err := 0, error(nil)
+	if ok {
+		hours, err = strconv.Atoi(parts[1])
+	}
+	if !ok || err != nil || hours < 0 {
+		slog.Error("Invalid data in "+action+" callback", "data", c.Data)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Invalid option. Please try again.")})
+		return nil, nil, 0, false
+	}
+
+	token, project, ok := h.getOwnedToken(c, parts[0], action)
+	if !ok {
+		return nil, nil, 0, false
+	}
+	return token, project, time.Duration(hours) * time.Hour, true
+}
+
+// handleTokenExpiry asks how long the token should be valid
+func (h *tokensHandler) handleTokenExpiry(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, _, ok := h.getOwnedToken(c, c.Data, "token expiry")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showOptions(c, token, l.T("How long should token <b>%s</b> be valid from now?", html.EscapeString(token.Name)),
+		btnSetTokenExpiry, tokenExpiryOptions)
+}
+
+// handleSetTokenExpiry changes when the token expires
+func (h *tokensHandler) handleSetTokenExpiry(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, project, ttl, ok := h.parseOption(c, "set token expiry")
+	if !ok {
+		return
+	}
+
+	token.ExpiresAt = nil
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := h.service.tokenService.Update(token); err != nil {
+		slog.Error("Failed to update token expiry", "error", err, "token_id", token.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to update token. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Expiry updated")})
+	h.showToken(c, project, token)
+}
+
+// handleRotateToken asks how long the token should keep working after it is replaced
+func (h *tokensHandler) handleRotateToken(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, _, ok := h.getOwnedToken(c, c.Data, "rotate token")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+	h.showOptions(c, token, l.T("Replace token <b>%s</b> with a new one?\n\n"+
+		"The new token has the same settings. Keep the old one working for a while "+
+		"to update the services using it one by one.", html.EscapeString(token.Name)),
+		btnConfirmRotateToken, tokenGraceOptions)
+}
+
+// handleConfirmRotateToken replaces the token with a new one
+func (h *tokensHandler) handleConfirmRotateToken(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, project, grace, ok := h.parseOption(c, "confirm rotate token")
+	if !ok {
+		return
+	}
+
+	rotated, err := h.service.tokenService.Rotate(token.ID, grace, time.Now())
+	if err != nil {
+		slog.Error("Failed to rotate token", "error", err, "token_id", token.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to rotate token. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Token rotated")})
+	h.showToken(c, project, rotated)
+}
+
+// handleRevokeToken asks the publisher to confirm token revocation
+func (h *tokensHandler) handleRevokeToken(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, _, ok := h.getOwnedToken(c, c.Data, "revoke token")
+	if !ok {
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{})
+
+	confirmBtn := btnConfirmRevokeToken
+	confirmBtn.Data = token.ID.String()
+	backBtn := btnProjectToken
+	backBtn.Text = "↩️ Back"
+	backBtn.Data = token.ID.String()
+	message := l.T("Revoke token <b>%s</b>?\n\nIt will stop working immediately.", html.EscapeString(token.Name))
+	_, err := h.service.bot.Edit(c.Message, message, &telebot.SendOptions{ParseMode: telebot.ModeHTML},
+		l.Markup(&telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{confirmBtn, backBtn}}}))
+	if err != nil {
+		slog.Error("Failed to show token revocation confirmation", "error", err)
+	}
+}
+
+// handleConfirmRevokeToken revokes the token
+func (h *tokensHandler) handleConfirmRevokeToken(c *telebot.Callback) {
+	l := h.service.userLocale(c.Sender)
+	token, project, ok := h.getOwnedToken(c, c.Data, "confirm revoke token")
+	if !ok {
+		return
+	}
+
+	if err := h.service.tokenService.Revoke(token.ID); err != nil {
+		slog.Error("Failed to revoke token", "error", err, "token_id", token.ID)
+		h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Failed to revoke token. Please try again.")})
+		return
+	}
+
+	h.service.bot.Respond(c, &telebot.CallbackResponse{Text: l.T("Token revoked")})
+	h.showTokens(c, project)
+}
+
+// tokenErrorMessage explains why the token settings were rejected, returning false for unexpected errors
+func tokenErrorMessage(l *Locale, err error) (string, bool) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidTokenName):
+		return l.T("This name can't be used: it must be a single line of 1 to %d characters. "+
+			"Please enter another name:", domain.MaxTokenNameLength), true
+	case errors.Is(err, domain.ErrInvalidTokenScopes):
+		return l.T("A token must allow at least one action."), true
+	case errors.Is(err, domain.ErrInvalidAllowedIPs):
+		return l.T("These addresses can't be used: send at most %d addresses like 203.0.113.7 "+
+			"or networks like 10.0.0.0/8 separated by commas, or - to allow any address:", domain.MaxTokenAllowedIPs), true
+	default:
+		return "", false
+	}
+}
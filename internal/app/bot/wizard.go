@@ -0,0 +1,306 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/tucnak/telebot"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Prompts of wizards have a button to cancel them
+var (
+	btnCancelWizard = telebot.InlineButton{Unique: "cancel_wizard", Text: "✖️ Cancel"}
+
+	cancelMenu = &telebot.ReplyMarkup{
+		InlineKeyboard: [][]telebot.InlineButton{
+			{btnCancelWizard},
+		},
+	}
+)
+
+// wizardStep is a single question of a wizard
+type wizardStep struct {
+	// Prompt returns the translated question asked to the user, it is sent as HTML
+	Prompt func(w *wizardContext) (string, error)
+	// Validate checks the answer before moving on to the next step, it is optional.
+	// Errors created by invalidAnswer are shown to the user, who is asked to answer again.
+	Validate func(w *wizardContext, answer string) error
+}
+
+// wizard is a multi-step conversation with the user, like naming a new project.
+// Its progress is kept in the user state, so it survives restarts.
+type wizard struct {
+	// Name identifies the wizard in stored user states, so it must not change
+	Name  string
+	Steps []wizardStep
+	// Finish performs the action once all steps are answered.
+	// Errors created by invalidAnswer make the user answer the last step again.
+	Finish func(w *wizardContext) error
+	// Cancel shows where the user came from when they cancel the wizard, by default the Menu is shown
+	Cancel func(w *wizardContext) error
+	// Menu is the keyboard shown when the wizard is cancelled or fails, the main menu by default
+	Menu *telebot.ReplyMarkup
+	// Failure is the message shown when the wizard fails, it is translated when shown
+	Failure string
+}
+
+// staticPrompt returns a prompt that doesn't depend on the state of the wizard, the text is translated when asked
+func staticPrompt(text string) func(w *wizardContext) (string, error) {
+	return func(w *wizardContext) (string, error) {
+		return w.Locale.T(text), nil
+	}
+}
+
+// invalidAnswerError is an answer rejected by a wizard, the message is shown to the user
+type invalidAnswerError struct {
+	message string
+}
+
+func (e *invalidAnswerError) Error() string {
+	return e.message
+}
+
+// invalidAnswer rejects the answer of the user, explaining what is wrong with it in a translated message
+func invalidAnswer(message string) error {
+	return &invalidAnswerError{message: message}
+}
+
+// wizardContext is the state of a wizard passed to its steps and actions
+type wizardContext struct {
+	User   *telebot.User
+	Data   StateData
+	Locale *Locale
+	// Message is the menu message the wizard was started or cancelled from, nil when the user sent an answer
+	Message *telebot.Message
+
+	sender wizardSender
+}
+
+// UserID returns the ID of the user going through the wizard
+func (w *wizardContext) UserID() domain.TelegramUserID {
+	return domain.MustNewTelegramUserID(int64(w.User.ID))
+}
+
+// Answer returns the answer to the given step
+func (w *wizardContext) Answer(step int) string {
+	if step < 0 || step >= len(w.Data.Answers) {
+		return ""
+	}
+	return w.Data.Answers[step]
+}
+
+// Reply sends a message to the user
+func (w *wizardContext) Reply(what interface{}, options ...interface{}) error {
+	_, err := w.sender.Send(w.User, what, options...)
+	return err
+}
+
+// Show replaces the menu message with the given one, or sends it if there is no menu message
+func (w *wizardContext) Show(what interface{}, options ...interface{}) error {
+	if w.Message == nil {
+		return w.Reply(what, options...)
+	}
+	_, err := w.sender.Edit(w.Message, what, options...)
+	return err
+}
+
+// wizardSender sends the messages of wizards, it is implemented by telebot.Bot
+type wizardSender interface {
+	Send(to telebot.Recipient, what interface{}, options ...interface{}) (*telebot.Message, error)
+	Edit(message telebot.Editable, what interface{}, options ...interface{}) (*telebot.Message, error)
+}
+
+// wizardStates keeps the progress of wizards, it is implemented by StateManager
+type wizardStates interface {
+	GetState(userID int) (UserState, StateData, bool)
+	SetState(userID int, state UserState, data StateData)
+	ClearState(userID int)
+}
+
+// wizardEngine runs the wizards of the bot
+type wizardEngine struct {
+	sender  wizardSender
+	states  wizardStates
+	locale  func(user *telebot.User) *Locale
+	wizards map[string]*wizard
+}
+
+func newWizardEngine(sender wizardSender, states wizardStates, locale func(user *telebot.User) *Locale) *wizardEngine {
+	return &wizardEngine{
+		sender:  sender,
+		states:  states,
+		locale:  locale,
+		wizards: make(map[string]*wizard),
+	}
+}
+
+// add makes a wizard available to start
+func (e *wizardEngine) add(w *wizard) {
+	if _, exists := e.wizards[w.Name]; exists {
+		panic(fmt.Sprintf("wizard %q is added twice", w.Name))
+	}
+	if len(w.Steps) == 0 {
+		panic(fmt.Sprintf("wizard %q has no steps", w.Name))
+	}
+	e.wizards[w.Name] = w
+}
+
+// start begins a wizard for the user, data is available to all its steps.
+// The first prompt replaces the menu message, if any. Answers given in advance,
+// like the arguments of a command, are processed as if the user sent them.
+func (e *wizardEngine) start(user *telebot.User, message *telebot.Message, name string, data StateData, answers ...string) {
+	w, ok := e.wizards[name]
+	if !ok {
+		slog.Error("Unknown wizard", "wizard", name)
+		l := e.locale(user)
+		e.send(user, l.T("Something went wrong. Please try again."), l.Markup(mainMenu))
+		return
+	}
+
+	data.Wizard = name
+	data.Step = 0
+	data.Answers = nil
+	ctx := e.newContext(user, data)
+	ctx.Message = message
+
+	for _, answer := range answers {
+		// Save the progress first, so that the user can correct a rejected answer
+		e.states.SetState(user.ID, StateWizard, ctx.Data)
+		if !e.answer(w, ctx, answer) || e.finished(w, ctx) {
+			return
+		}
+	}
+	e.ask(w, ctx)
+}
+
+// handle processes an answer of the user, it returns false if the user isn't going through a wizard
+func (e *wizardEngine) handle(m *telebot.Message) bool {
+	state, data, ok := e.states.GetState(m.Sender.ID)
+	if !ok || state != StateWizard {
+		return false
+	}
+
+	w, ok := e.wizards[data.Wizard]
+	if !ok || data.Step < 0 || data.Step >= len(w.Steps) {
+		slog.Error("Unknown wizard step", "wizard", data.Wizard, "step", data.Step, "user_id", m.Sender.ID)
+		e.states.ClearState(m.Sender.ID)
+		l := e.locale(m.Sender)
+		e.send(m.Sender, l.T("Something went wrong. Please try again."), l.Markup(mainMenu))
+		return true
+	}
+
+	ctx := e.newContext(m.Sender, data)
+	if e.answer(w, ctx, m.Text) && !e.finished(w, ctx) {
+		e.ask(w, ctx)
+	}
+	return true
+}
+
+// answer records the answer to the current step and finishes the wizard after the last one.
+// It returns false if the answer was rejected or the wizard failed.
+func (e *wizardEngine) answer(w *wizard, ctx *wizardContext, text string) bool {
+	if validate := w.Steps[ctx.Data.Step].Validate; validate != nil {
+		if err := validate(ctx, text); err != nil {
+			e.fail(w, ctx, err)
+			return false
+		}
+	}
+
+	ctx.Data.Answers = append(ctx.Data.Answers, text)
+	if !e.finished(w, ctx) {
+		ctx.Data.Step++
+		return true
+	}
+
+	if err := w.Finish(ctx); err != nil {
+		e.fail(w, ctx, err)
+		return false
+	}
+	e.states.ClearState(ctx.User.ID)
+	return true
+}
+
+// finished reports whether all steps of the wizard are answered
+func (e *wizardEngine) finished(w *wizard, ctx *wizardContext) bool {
+	return len(ctx.Data.Answers) >= len(w.Steps)
+}
+
+// cancel stops the wizard of the user, it returns false if the user isn't going through one.
+// The message is the prompt the user cancelled the wizard from, if any.
+func (e *wizardEngine) cancel(user *telebot.User, message *telebot.Message) bool {
+	state, data, ok := e.states.GetState(user.ID)
+	if !ok {
+		return false
+	}
+	e.states.ClearState(user.ID)
+
+	w, ok := e.wizards[data.Wizard]
+	if state != StateWizard || !ok {
+		return false
+	}
+
+	ctx := e.newContext(user, data)
+	ctx.Message = message
+	if w.Cancel != nil {
+		err := w.Cancel(ctx)
+		if err == nil {
+			return true
+		}
+		slog.Error("Failed to cancel wizard", "error", err, "wizard", w.Name, "user_id", user.ID)
+	}
+
+	if err := ctx.Show(ctx.Locale.T("Operation cancelled."), e.menu(ctx, w)); err != nil {
+		slog.Error("Failed to send wizard message", "error", err, "user_id", user.ID)
+	}
+	return true
+}
+
+// ask saves the progress of the wizard and sends the prompt of its current step
+func (e *wizardEngine) ask(w *wizard, ctx *wizardContext) {
+	prompt, err := w.Steps[ctx.Data.Step].Prompt(ctx)
+	if err != nil {
+		e.fail(w, ctx, err)
+		return
+	}
+
+	e.states.SetState(ctx.User.ID, StateWizard, ctx.Data)
+	if err := ctx.Show(prompt, &telebot.SendOptions{ParseMode: telebot.ModeHTML}, ctx.Locale.Markup(cancelMenu)); err != nil {
+		slog.Error("Failed to send wizard message", "error", err, "user_id", ctx.User.ID)
+	}
+}
+
+// fail asks the user to answer again if the answer was invalid, otherwise it stops the wizard
+func (e *wizardEngine) fail(w *wizard, ctx *wizardContext, err error) {
+	var invalid *invalidAnswerError
+	if errors.As(err, &invalid) {
+		e.send(ctx.User, invalid.message, ctx.Locale.Markup(cancelMenu))
+		return
+	}
+
+	slog.Error("Wizard failed", "error", err, "wizard", w.Name, "step", ctx.Data.Step, "user_id", ctx.User.ID)
+	e.states.ClearState(ctx.User.ID)
+	if err := ctx.Show(ctx.Locale.T(w.Failure), e.menu(ctx, w)); err != nil {
+		slog.Error("Failed to send wizard message", "error", err, "user_id", ctx.User.ID)
+	}
+}
+
+// menu returns the keyboard shown when the wizard is cancelled or fails
+func (e *wizardEngine) menu(ctx *wizardContext, w *wizard) *telebot.ReplyMarkup {
+	if w.Menu == nil {
+		return ctx.Locale.Markup(mainMenu)
+	}
+	return ctx.Locale.Markup(w.Menu)
+}
+
+func (e *wizardEngine) newContext(user *telebot.User, data StateData) *wizardContext {
+	return &wizardContext{User: user, Data: data, Locale: e.locale(user), sender: e.sender}
+}
+
+func (e *wizardEngine) send(user *telebot.User, what interface{}, options ...interface{}) {
+	if _, err := e.sender.Send(user, what, options...); err != nil {
+		slog.Error("Failed to send wizard message", "error", err, "user_id", user.ID)
+	}
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tucnak/telebot"
+)
+
+// sentMessages records the texts sent by wizards, edited messages are marked with a pencil
+type sentMessages []string
+
+func (s *sentMessages) Send(_ telebot.Recipient, what interface{}, _ ...interface{}) (*telebot.Message, error) {
+	*s = append(*s, what.(string))
+	return &telebot.Message{}, nil
+}
+
+func (s *sentMessages) Edit(_ telebot.Editable, what interface{}, _ ...interface{}) (*telebot.Message, error) {
+	*s = append(*s, "✏️ "+what.(string))
+	return &telebot.Message{}, nil
+}
+
+// memoryStates keeps user states in memory
+type memoryStates map[int]StateData
+
+func (s memoryStates) GetState(userID int) (UserState, StateData, bool) {
+	data, ok := s[userID]
+	if !ok {
+		return StateNone, StateData{}, false
+	}
+	return StateWizard, data, true
+}
+
+func (s memoryStates) SetState(userID int, _ UserState, data StateData) {
+	s[userID] = data
+}
+
+func (s memoryStates) ClearState(userID int) {
+	delete(s, userID)
+}
+
+// englishLocale shows the wizards in English
+func englishLocale(*telebot.User) *Locale {
+	return localeEN
+}
+
+// newTestWizard creates a wizard asking for a name and a color, which must not be "black"
+func newTestWizard(finished *[]string) *wizard {
+	return &wizard{
+		Name: "test",
+		Steps: []wizardStep{
+			{Prompt: staticPrompt("Name?")},
+			{
+				Prompt: func(w *wizardContext) (string, error) {
+					return "Color for " + w.Answer(0) + "?", nil
+				},
+				Validate: func(_ *wizardContext, answer string) error {
+					if answer == "black" {
+						return invalidAnswer("Not black")
+					}
+					return nil
+				},
+			},
+		},
+		Finish: func(w *wizardContext) error {
+			if w.Answer(1) == "fail" {
+				return errors.New("failed")
+			}
+			if w.Answer(1) == "white" {
+				return invalidAnswer("Not white either")
+			}
+			*finished = append(*finished, w.Answer(0)+" "+w.Answer(1))
+			return nil
+		},
+		Menu:    mainMenu,
+		Failure: "Sorry",
+	}
+}
+
+func TestWizardEngine(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []string
+		sent     []string
+		finished []string
+		active   bool
+	}{
+		{"not started", nil, []string{"Name?"}, nil, true},
+		{"next step", []string{"box"}, []string{"Name?", "Color for box?"}, nil, true},
+		{"finished", []string{"box", "red"}, []string{"Name?", "Color for box?"}, []string{"box red"}, false},
+		{"invalid answer", []string{"box", "black", "red"},
+			[]string{"Name?", "Color for box?", "Not black"}, []string{"box red"}, false},
+		{"rejected by action", []string{"box", "white"},
+			[]string{"Name?", "Color for box?", "Not white either"}, nil, true},
+		{"failed", []string{"box", "fail"}, []string{"Name?", "Color for box?", "Sorry"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent sentMessages
+			var finished []string
+			states := memoryStates{}
+			engine := newWizardEngine(&sent, states, englishLocale)
+			engine.add(newTestWizard(&finished))
+
+			user := &telebot.User{ID: 42}
+			engine.start(user, nil, "test", StateData{})
+			for _, answer := range tt.answers {
+				require.True(t, engine.handle(&telebot.Message{Sender: user, Text: answer}))
+			}
+
+			assert.Equal(t, tt.sent, []string(sent))
+			assert.Equal(t, tt.finished, finished)
+			_, active := states[user.ID]
+			assert.Equal(t, tt.active, active)
+		})
+	}
+}
+
+func TestWizardEngine_Cancel(t *testing.T) {
+	var sent sentMessages
+	states := memoryStates{}
+	engine := newWizardEngine(&sent, states, englishLocale)
+	engine.add(newTestWizard(new([]string)))
+
+	user := &telebot.User{ID: 42}
+	assert.False(t, engine.cancel(user, nil))
+
+	engine.start(user, nil, "test", StateData{})
+	require.True(t, engine.handle(&telebot.Message{Sender: user, Text: "box"}))
+	assert.True(t, engine.cancel(user, nil))
+	assert.Empty(t, states)
+	assert.Equal(t, "Operation cancelled.", sent[len(sent)-1])
+
+	assert.False(t, engine.handle(&telebot.Message{Sender: user, Text: "red"}))
+}
+
+func TestWizardEngine_MenuMessage(t *testing.T) {
+	var sent sentMessages
+	states := memoryStates{}
+	engine := newWizardEngine(&sent, states, englishLocale)
+	w := newTestWizard(new([]string))
+	w.Cancel = func(w *wizardContext) error {
+		return w.Show("Back to " + w.Data.HistoryKind)
+	}
+	engine.add(w)
+
+	// The first prompt replaces the menu message and cancelling returns to it
+	user := &telebot.User{ID: 42}
+	menu := &telebot.Message{ID: 1, Chat: &telebot.Chat{ID: 42}}
+	engine.start(user, menu, "test", StateData{HistoryKind: "menu"})
+	require.True(t, engine.handle(&telebot.Message{Sender: user, Text: "box"}))
+	assert.True(t, engine.cancel(user, menu))
+
+	assert.Equal(t, []string{"✏️ Name?", "Color for box?", "✏️ Back to menu"}, []string(sent))
+}
+
+func TestWizardEngine_KeepsData(t *testing.T) {
+	var sent sentMessages
+	states := memoryStates{}
+	engine := newWizardEngine(&sent, states, englishLocale)
+
+	var kinds []string
+	engine.add(&wizard{
+		Name: "kind",
+		Steps: []wizardStep{{Prompt: func(w *wizardContext) (string, error) {
+			return "Search " + w.Data.HistoryKind + "?", nil
+		}}},
+		Finish: func(w *wizardContext) error {
+			kinds = append(kinds, w.Data.HistoryKind+":"+strings.ToUpper(w.Answer(0)))
+			return nil
+		},
+	})
+
+	user := &telebot.User{ID: 42}
+	engine.start(user, nil, "kind", StateData{HistoryKind: historyKindUser})
+	require.True(t, engine.handle(&telebot.Message{Sender: user, Text: "deploy"}))
+
+	assert.Equal(t, []string{"Search u?"}, []string(sent))
+	assert.Equal(t, []string{"u:DEPLOY"}, kinds)
+}
+
+func TestWizardEngine_AnswersInAdvance(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []string
+		sent     []string
+		finished []string
+		active   bool
+	}{
+		{"first step", []string{"box"}, []string{"Color for box?"}, nil, true},
+		{"all steps", []string{"box", "red"}, nil, []string{"box red"}, false},
+		{"invalid answer", []string{"box", "black"}, []string{"Not black"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent sentMessages
+			var finished []string
+			states := memoryStates{}
+			engine := newWizardEngine(&sent, states, englishLocale)
+			engine.add(newTestWizard(&finished))
+
+			user := &telebot.User{ID: 42}
+			engine.start(user, nil, "test", StateData{}, tt.answers...)
+
+			assert.Equal(t, tt.sent, []string(sent))
+			assert.Equal(t, tt.finished, finished)
+			_, active := states[user.ID]
+			assert.Equal(t, tt.active, active)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/sergeax/noteo/internal/app/bot"
 	"github.com/sergeax/noteo/internal/app/db"
 	"github.com/sergeax/noteo/internal/app/queue"
+	"github.com/sergeax/noteo/internal/app/scheduler"
+	"github.com/sergeax/noteo/internal/domain"
 )
 
 type Config struct {
@@ -19,6 +22,18 @@ type Config struct {
 	LogFormat string
 	LogLevel  string
 	DBDSN     string
+	// HistoryRetention is how long delivered notifications are kept in the history
+	HistoryRetention time.Duration
+	// StateTTL is how long users have to finish multi-step actions in the bot
+	StateTTL time.Duration
+	// QueueWorkers is the number of notifications sent in parallel
+	QueueWorkers int
+	// QueueRate is the maximum number of notifications sent per second
+	QueueRate int
+	// TrustProxy takes client addresses from the X-Forwarded-For header of a reverse proxy
+	TrustProxy bool
+	// TokenSecret is the key protecting stored project tokens, API keys and signing secrets, the bot token is used if it is empty
+	TokenSecret string
 }
 
 // LoadConfig initializes and returns the application configuration
@@ -27,6 +42,11 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("PORT", 8080)
 	viper.SetDefault("LOG_FORMAT", "json")
 	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("HISTORY_RETENTION", "720h")
+	viper.SetDefault("STATE_TTL", "15m")
+	viper.SetDefault("QUEUE_WORKERS", 8)
+	viper.SetDefault("QUEUE_RATE", 30)
+	viper.SetDefault("TRUST_PROXY", false)
 
 	// Setup environment variables
 	viper.SetEnvPrefix("NOTEO")
@@ -65,19 +85,51 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid log level: %s (must be one of: debug, info, warn, error)", logLevel)
 	}
 
+	// Get history retention and validate
+	historyRetention, err := time.ParseDuration(strings.TrimSpace(viper.GetString("HISTORY_RETENTION")))
+	if err != nil || historyRetention <= 0 {
+		return nil, fmt.Errorf("invalid history retention: %s (must be a positive duration like 720h)",
+			viper.GetString("HISTORY_RETENTION"))
+	}
+
+	// Get state TTL and validate
+	stateTTL, err := time.ParseDuration(strings.TrimSpace(viper.GetString("STATE_TTL")))
+	if err != nil || stateTTL <= 0 {
+		return nil, fmt.Errorf("invalid state TTL: %s (must be a positive duration like 15m)",
+			viper.GetString("STATE_TTL"))
+	}
+
+	// Get queue workers and rate and validate
+	queueWorkers := viper.GetInt("QUEUE_WORKERS")
+	if queueWorkers <= 0 {
+		return nil, fmt.Errorf("invalid queue workers: %s (must be a positive number)", viper.GetString("QUEUE_WORKERS"))
+	}
+	queueRate := viper.GetInt("QUEUE_RATE")
+	if queueRate <= 0 {
+		return nil, fmt.Errorf("invalid queue rate: %s (must be a positive number of messages per second)",
+			viper.GetString("QUEUE_RATE"))
+	}
+
 	return &Config{
-		BotToken:  strings.TrimSpace(viper.GetString("BOT_TOKEN")),
-		Port:      port,
-		LogFormat: logFormat,
-		LogLevel:  logLevel,
-		DBDSN:     strings.TrimSpace(viper.GetString("DB_DSN")),
+		BotToken:         strings.TrimSpace(viper.GetString("BOT_TOKEN")),
+		Port:             port,
+		LogFormat:        logFormat,
+		LogLevel:         logLevel,
+		DBDSN:            strings.TrimSpace(viper.GetString("DB_DSN")),
+		HistoryRetention: historyRetention,
+		StateTTL:         stateTTL,
+		QueueWorkers:     queueWorkers,
+		QueueRate:        queueRate,
+		TrustProxy:       viper.GetBool("TRUST_PROXY"),
+		TokenSecret:      strings.TrimSpace(viper.GetString("TOKEN_SECRET")),
 	}, nil
 }
 
 // NewBotConfig creates bot-specific configuration
 func NewBotConfig(cfg *Config) *bot.Config {
 	return &bot.Config{
-		Token: cfg.BotToken,
+		Token:    cfg.BotToken,
+		StateTTL: cfg.StateTTL,
 	}
 }
 
@@ -88,6 +140,7 @@ func NewAPIConfig(cfg *Config) *api.Config {
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		TrustProxy:   cfg.TrustProxy,
 	}
 }
 
@@ -98,12 +151,52 @@ func NewDBConfig(cfg *Config) *db.Config {
 	}
 }
 
+// secretKey returns the key protecting project tokens, API keys and signing secrets. Changing it invalidates them,
+// so without a dedicated secret they stop working when the bot token changes.
+func (c *Config) secretKey() []byte {
+	if c.TokenSecret == "" {
+		return []byte(c.BotToken)
+	}
+	return []byte(c.TokenSecret)
+}
+
+// NewTokenHasher creates the hasher of project tokens and API keys
+func NewTokenHasher(cfg *Config) *domain.TokenHasher {
+	if cfg.TokenSecret == "" {
+		slog.Warn("NOTEO_TOKEN_SECRET is not set, the bot token protects project tokens, API keys and signing secrets " +
+			"and they will stop working if the bot token changes")
+	}
+	return domain.NewTokenHasher(cfg.secretKey())
+}
+
+// NewSecretBox creates the box encrypting the signing secrets of projects
+func NewSecretBox(cfg *Config) *domain.SecretBox {
+	return domain.NewSecretBox(cfg.secretKey())
+}
+
 // NewQueueConfig creates a new queue configuration
 func NewQueueConfig(cfg *Config) *queue.Config {
 	return &queue.Config{
-		Capacity:          1000,
+		Capacity:        50000,
+		ProjectCapacity: 10000,
+		Workers:         cfg.QueueWorkers,
+		Rate:            cfg.QueueRate,
+		// Telegram allows about one message per second to a private chat and 20 per minute to a group
+		ChatInterval:      1 * time.Second,
+		GroupInterval:     3 * time.Second,
+		BreakerThreshold:  5,
+		BreakerCooldown:   30 * time.Second,
 		InitialRetryDelay: 1 * time.Second,
 		MaxRetryDelay:     1 * time.Minute,
 		MaxRetries:        10,
 	}
 }
+
+// NewSchedulerConfig creates scheduler-specific configuration
+func NewSchedulerConfig(cfg *Config) *scheduler.Config {
+	return &scheduler.Config{
+		Interval:         30 * time.Second,
+		BatchSize:        100,
+		HistoryRetention: cfg.HistoryRetention,
+	}
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -11,7 +12,7 @@ import (
 
 func TestLoadConfig(t *testing.T) {
 	// Save original environment variables
-	envVars := []string{"NOTEO_BOT_TOKEN", "NOTEO_PORT", "NOTEO_LOG_FORMAT", "NOTEO_LOG_LEVEL"}
+	envVars := []string{"NOTEO_BOT_TOKEN", "NOTEO_PORT", "NOTEO_LOG_FORMAT", "NOTEO_LOG_LEVEL", "NOTEO_DB_DSN", "NOTEO_HISTORY_RETENTION", "NOTEO_STATE_TTL", "NOTEO_QUEUE_WORKERS", "NOTEO_QUEUE_RATE", "NOTEO_TRUST_PROXY", "NOTEO_TOKEN_SECRET"}
 	oldEnvVars := make(map[string]string)
 	for _, env := range envVars {
 		oldEnvVars[env] = os.Getenv(env)
@@ -36,9 +37,16 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set environment variables for this test
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_PORT", "9090")
 		os.Setenv("NOTEO_LOG_FORMAT", "text")
 		os.Setenv("NOTEO_LOG_LEVEL", "debug")
+		os.Setenv("NOTEO_HISTORY_RETENTION", "48h")
+		os.Setenv("NOTEO_STATE_TTL", "1h")
+		os.Setenv("NOTEO_QUEUE_WORKERS", "4")
+		os.Setenv("NOTEO_QUEUE_RATE", "20")
+		os.Setenv("NOTEO_TRUST_PROXY", "true")
+		os.Setenv("NOTEO_TOKEN_SECRET", "secret")
 
 		// Reset Viper to ensure a clean state
 		viper.Reset()
@@ -52,6 +60,13 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, 9090, config.Port)
 		assert.Equal(t, "text", config.LogFormat)
 		assert.Equal(t, "debug", config.LogLevel)
+		assert.Equal(t, ":memory:", config.DBDSN)
+		assert.Equal(t, 48*time.Hour, config.HistoryRetention)
+		assert.Equal(t, time.Hour, config.StateTTL)
+		assert.Equal(t, 4, config.QueueWorkers)
+		assert.Equal(t, 20, config.QueueRate)
+		assert.True(t, config.TrustProxy)
+		assert.Equal(t, "secret", config.TokenSecret)
 	})
 
 	t.Run("Test with missing required BOT_TOKEN", func(t *testing.T) {
@@ -80,6 +95,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set only the required BOT_TOKEN
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 
 		// Reset Viper to ensure a clean state
 		viper.Reset()
@@ -93,6 +109,12 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, 8080, config.Port)        // Default value
 		assert.Equal(t, "json", config.LogFormat) // Default value
 		assert.Equal(t, "info", config.LogLevel)  // Default value
+		assert.Equal(t, 30*24*time.Hour, config.HistoryRetention)
+		assert.Equal(t, 15*time.Minute, config.StateTTL)
+		assert.Equal(t, 8, config.QueueWorkers)
+		assert.Equal(t, 30, config.QueueRate)
+		assert.False(t, config.TrustProxy)
+		assert.Empty(t, config.TokenSecret)
 	})
 
 	t.Run("Test with invalid PORT value", func(t *testing.T) {
@@ -103,6 +125,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set required BOT_TOKEN and invalid PORT (0 is invalid)
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_PORT", "0")
 
 		// Reset Viper to ensure a clean state
@@ -125,6 +148,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set required BOT_TOKEN and non-numeric PORT
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_PORT", "invalid-port")
 
 		// Reset Viper to ensure a clean state
@@ -148,6 +172,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set required BOT_TOKEN and invalid LOG_FORMAT
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_LOG_FORMAT", "invalid-format")
 
 		// Reset Viper to ensure a clean state
@@ -170,6 +195,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set required BOT_TOKEN and invalid LOG_LEVEL
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_LOG_LEVEL", "invalid-level")
 
 		// Reset Viper to ensure a clean state
@@ -184,6 +210,52 @@ func TestLoadConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "invalid log level")
 	})
 
+	t.Run("Test with invalid HISTORY_RETENTION value", func(t *testing.T) {
+		// Clear all environment variables for testing
+		for _, env := range envVars {
+			os.Unsetenv(env)
+		}
+
+		// Set required variables and invalid HISTORY_RETENTION
+		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
+		os.Setenv("NOTEO_HISTORY_RETENTION", "forever")
+
+		// Reset Viper to ensure a clean state
+		viper.Reset()
+
+		// Load config
+		config, err := LoadConfig()
+
+		// Verify
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "invalid history retention")
+	})
+
+	t.Run("Test with invalid QUEUE_RATE value", func(t *testing.T) {
+		// Clear all environment variables for testing
+		for _, env := range envVars {
+			os.Unsetenv(env)
+		}
+
+		// Set required BOT_TOKEN and invalid QUEUE_RATE
+		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
+		os.Setenv("NOTEO_QUEUE_RATE", "0")
+
+		// Reset Viper to ensure a clean state
+		viper.Reset()
+
+		// Load config
+		config, err := LoadConfig()
+
+		// Verify
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "invalid queue rate")
+	})
+
 	t.Run("Test case insensitivity for LOG_FORMAT and LOG_LEVEL", func(t *testing.T) {
 		// Clear all environment variables for testing
 		for _, env := range envVars {
@@ -192,6 +264,7 @@ func TestLoadConfig(t *testing.T) {
 
 		// Set environment variables with mixed case
 		os.Setenv("NOTEO_BOT_TOKEN", "test-token")
+		os.Setenv("NOTEO_DB_DSN", ":memory:")
 		os.Setenv("NOTEO_LOG_FORMAT", "JSON")
 		os.Setenv("NOTEO_LOG_LEVEL", "DEBUG")
 
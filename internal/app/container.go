@@ -9,6 +9,7 @@ import (
 	"github.com/sergeax/noteo/internal/app/bot"
 	"github.com/sergeax/noteo/internal/app/db"
 	"github.com/sergeax/noteo/internal/app/queue"
+	"github.com/sergeax/noteo/internal/app/scheduler"
 	"github.com/sergeax/noteo/internal/domain"
 )
 
@@ -54,6 +55,9 @@ func NewContainer(cfg *Config) (*Container, error) {
 	c.provide(NewAPIConfig, "api config")
 	c.provide(NewDBConfig, "db config")
 	c.provide(NewQueueConfig, "queue config")
+	c.provide(NewSchedulerConfig, "scheduler config")
+	c.provide(NewTokenHasher, "token hasher")
+	c.provide(NewSecretBox, "secret box")
 
 	// Database
 	c.provide(db.NewDB, "database")
@@ -61,19 +65,43 @@ func NewContainer(cfg *Config) (*Container, error) {
 	// Repositories
 	c.provide(db.NewProjectRepository, "project repository", new(domain.ProjectRepository))
 	c.provide(db.NewSubscriptionRepository, "subscription repository", new(domain.SubscriptionRepository))
+	c.provide(db.NewBanRepository, "ban repository", new(domain.BanRepository))
+	c.provide(db.NewSubscriptionRequestRepository, "subscription request repository", new(domain.SubscriptionRequestRepository))
+	c.provide(db.NewInviteLinkRepository, "invite link repository", new(domain.InviteLinkRepository))
+	c.provide(db.NewUserSettingsRepository, "user settings repository", new(domain.UserSettingsRepository))
+	c.provide(db.NewHeldMessageRepository, "held message repository", new(domain.HeldMessageRepository))
+	c.provide(db.NewDigestRepository, "digest repository", new(domain.DigestRepository))
+	c.provide(db.NewHistoryRepository, "history repository", new(domain.HistoryRepository))
+	c.provide(db.NewConversationRepository, "conversation repository", new(domain.ConversationRepository))
+	c.provide(db.NewUserRepository, "user repository", new(domain.UserRepository))
+	c.provide(db.NewAPIKeyRepository, "api key repository", new(domain.APIKeyRepository))
+	c.provide(db.NewProjectTokenRepository, "project token repository", new(domain.ProjectTokenRepository))
+	c.provide(db.NewNonceRepository, "nonce repository", new(domain.NonceRepository))
 
 	// Domain services
 	c.provide(domain.NewProjectService, "project service")
 	c.provide(domain.NewSubscriptionService, "subscription service")
+	c.provide(domain.NewInviteService, "invite service")
+	c.provide(domain.NewTokenService, "token service")
+	c.provide(domain.NewSigningService, "signing service")
+	c.provide(domain.NewUserSettingsService, "user settings service")
+	c.provide(domain.NewNotificationService, "notification service")
+	c.provide(domain.NewHistoryService, "history service")
+	c.provide(domain.NewConversationService, "conversation service")
+	c.provide(domain.NewUserService, "user service")
+	c.provide(domain.NewAPIKeyService, "api key service")
 
 	// Create message queue
+	c.provide(func(s *domain.NotificationService) queue.MessagePostponer { return s }, "message postponer")
 	c.provide(queue.NewQueue, "message queue")
 
 	// App services
 	c.provide(bot.NewStateManager, "state manager")
 	c.provide(bot.NewService, "bot service")
 	c.provide(bot.NewService, "message sender", new(queue.MessageSender))
+	c.provide(func(s *bot.Service) api.ProjectDeletionNotifier { return s }, "project deletion notifier")
 	c.provide(api.NewService, "api service")
+	c.provide(scheduler.NewService, "scheduler")
 
 	if c.err != nil {
 		return nil, c.err
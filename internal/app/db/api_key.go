@@ -0,0 +1,132 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type apiKey struct {
+	PublisherID domain.TelegramUserID `gorm:"primaryKey;autoIncrement:false"`
+	Prefix      string
+	Hash        string `gorm:"uniqueIndex:idx_api_keys_hash"`
+	CreatedAt   time.Time
+	LastUsedAt  *time.Time
+}
+
+func (k *apiKey) toDomain() *domain.APIKey {
+	return &domain.APIKey{
+		PublisherID: k.PublisherID,
+		Prefix:      k.Prefix,
+		Hash:        k.Hash,
+		CreatedAt:   k.CreatedAt,
+		LastUsedAt:  k.LastUsedAt,
+	}
+}
+
+func apiKeyFromDomain(k *domain.APIKey) *apiKey {
+	return &apiKey{
+		PublisherID: k.PublisherID,
+		Prefix:      k.Prefix,
+		Hash:        k.Hash,
+		CreatedAt:   k.CreatedAt,
+		LastUsedAt:  k.LastUsedAt,
+	}
+}
+
+// hashAPIKeys replaces the API keys that used to be stored in plaintext with their hashes
+// and drops the column they were stored in
+func hashAPIKeys(db *gorm.DB, hasher *domain.TokenHasher) error {
+	if ok, err := hasColumn(db, "api_keys", "key"); err != nil || !ok {
+		return err
+	}
+
+	var plaintext []struct {
+		PublisherID domain.TelegramUserID
+		Key         string
+	}
+	if err := db.Table("api_keys").Select("publisher_id, key").
+		Where("key IS NOT NULL AND key <> ''").Scan(&plaintext).Error; err != nil {
+		return fmt.Errorf("getting plaintext api keys: %w", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, k := range plaintext {
+			err := tx.Table("api_keys").Where("publisher_id = ?", k.PublisherID).UpdateColumns(map[string]interface{}{
+				"prefix": domain.APIKeyVisiblePrefix(k.Key),
+				"hash":   hasher.Hash(k.Key),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("hashing api key of publisher %s: %w", k.PublisherID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dropColumn(db, &apiKey{}, "key"); err != nil {
+		return err
+	}
+	slog.Info("Replaced plaintext API keys with their hashes", "keys", len(plaintext))
+	return nil
+}
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Get(publisherID domain.TelegramUserID) (*domain.APIKey, error) {
+	var k apiKey
+	if err := r.db.First(&k, "publisher_id = ?", publisherID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("getting api key from db: %w", err)
+	}
+	return k.toDomain(), nil
+}
+
+func (r *APIKeyRepository) GetByHash(hash string) (*domain.APIKey, error) {
+	var k apiKey
+	if err := r.db.First(&k, "hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("getting api key by hash from db: %w", err)
+	}
+	return k.toDomain(), nil
+}
+
+func (r *APIKeyRepository) Save(key *domain.APIKey) error {
+	err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(apiKeyFromDomain(key)).Error
+	if err != nil {
+		return fmt.Errorf("saving api key in db: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) Delete(publisherID domain.TelegramUserID) error {
+	if err := r.db.Where("publisher_id = ?", publisherID).Delete(&apiKey{}).Error; err != nil {
+		return fmt.Errorf("deleting api key from db: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(publisherID domain.TelegramUserID, at time.Time) error {
+	if err := r.db.Model(&apiKey{}).Where("publisher_id = ?", publisherID).Update("last_used_at", at).Error; err != nil {
+		return fmt.Errorf("updating api key last use in db: %w", err)
+	}
+	return nil
+}
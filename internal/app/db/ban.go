@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type ban struct {
+	ProjectID uuid.UUID             `gorm:"primaryKey;type:uuid"`
+	UserID    domain.TelegramUserID `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+func (b *ban) toDomain() *domain.Ban {
+	return &domain.Ban{
+		ProjectID: b.ProjectID,
+		UserID:    b.UserID,
+		CreatedAt: b.CreatedAt,
+	}
+}
+
+func banFromDomain(b *domain.Ban) *ban {
+	return &ban{
+		ProjectID: b.ProjectID,
+		UserID:    b.UserID,
+		CreatedAt: b.CreatedAt,
+	}
+}
+
+type BanRepository struct {
+	db *gorm.DB
+}
+
+func NewBanRepository(db *gorm.DB) *BanRepository {
+	return &BanRepository{db: db}
+}
+
+func (r *BanRepository) Create(b *domain.Ban) error {
+	if err := r.db.Save(banFromDomain(b)).Error; err != nil {
+		return fmt.Errorf("creating ban in db: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepository) Delete(projectID uuid.UUID, userID domain.TelegramUserID) error {
+	if err := r.db.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&ban{}).Error; err != nil {
+		return fmt.Errorf("deleting ban from db: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepository) DeleteByProject(projectID uuid.UUID) error {
+	if err := r.db.Where("project_id = ?", projectID).Delete(&ban{}).Error; err != nil {
+		return fmt.Errorf("deleting project bans from db: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepository) Exists(projectID uuid.UUID, userID domain.TelegramUserID) (bool, error) {
+	var count int64
+	if err := r.db.Model(&ban{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("checking ban in db: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *BanRepository) GetByProject(projectID uuid.UUID) ([]*domain.Ban, error) {
+	var bans []ban
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at").Find(&bans).Error; err != nil {
+		return nil, fmt.Errorf("getting project bans from db: %w", err)
+	}
+
+	result := make([]*domain.Ban, len(bans))
+	for i := range bans {
+		result[i] = bans[i].toDomain()
+	}
+	return result, nil
+}
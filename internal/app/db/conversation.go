@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type conversation struct {
+	UserID    domain.TelegramUserID `gorm:"primaryKey;autoIncrement:false"`
+	State     int
+	Version   int
+	Data      []byte
+	ExpiresAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+func (c *conversation) toDomain() *domain.Conversation {
+	return &domain.Conversation{
+		UserID:    c.UserID,
+		State:     c.State,
+		Version:   c.Version,
+		Data:      c.Data,
+		ExpiresAt: c.ExpiresAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func conversationFromDomain(c *domain.Conversation) *conversation {
+	return &conversation{
+		UserID:    c.UserID,
+		State:     c.State,
+		Version:   c.Version,
+		Data:      c.Data,
+		ExpiresAt: c.ExpiresAt.UTC(),
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+type ConversationRepository struct {
+	db *gorm.DB
+}
+
+func NewConversationRepository(db *gorm.DB) *ConversationRepository {
+	return &ConversationRepository{db: db}
+}
+
+func (r *ConversationRepository) Get(userID domain.TelegramUserID) (*domain.Conversation, error) {
+	var c conversation
+	if err := r.db.First(&c, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrConversationNotFound
+		}
+		return nil, fmt.Errorf("getting conversation from db: %w", err)
+	}
+	return c.toDomain(), nil
+}
+
+func (r *ConversationRepository) Save(c *domain.Conversation) error {
+	err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(conversationFromDomain(c)).Error
+	if err != nil {
+		return fmt.Errorf("saving conversation in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ConversationRepository) Delete(userID domain.TelegramUserID) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&conversation{}).Error; err != nil {
+		return fmt.Errorf("deleting conversation from db: %w", err)
+	}
+	return nil
+}
+
+func (r *ConversationRepository) GetExpired(now time.Time, limit int) ([]*domain.Conversation, error) {
+	var conversations []conversation
+	err := r.db.Where("expires_at <= ?", now.UTC()).Order("expires_at").Limit(limit).Find(&conversations).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting expired conversations from db: %w", err)
+	}
+
+	result := make([]*domain.Conversation, len(conversations))
+	for i := range conversations {
+		result[i] = conversations[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *ConversationRepository) DeleteExpired(userID domain.TelegramUserID, now time.Time) (bool, error) {
+	result := r.db.Where("user_id = ? AND expires_at <= ?", userID, now.UTC()).Delete(&conversation{})
+	if result.Error != nil {
+		return false, fmt.Errorf("deleting expired conversation from db: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -1,11 +1,14 @@
 package db
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
 )
 
 // Config holds database configuration
@@ -13,26 +16,123 @@ type Config struct {
 	DSN string
 }
 
-// NewDB creates a new database connection using the provided configuration
-func NewDB(cfg *Config) (*gorm.DB, error) {
+// NewDB creates a new database connection using the provided configuration.
+// The hasher is needed to migrate project tokens that used to be stored in plaintext.
+func NewDB(cfg *Config, hasher *domain.TokenHasher) (*gorm.DB, error) {
 	slog.Info("Using database", "dsn", cfg.DSN)
 	if cfg.DSN == ":memory:" {
 		slog.Warn("In-memory database: all data will be lost when the application stops or restarts")
 	}
 
-	db, err := gorm.Open(sqlite.Open(cfg.DSN), &gorm.Config{})
+	// Translated errors tell unique constraint violations apart
+	db, err := gorm.Open(sqlite.Open(cfg.DSN), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrateProjectNameKeys(db); err != nil {
+		slog.Error("Failed to migrate project name keys", "error", err)
+		os.Exit(1)
+	}
+
 	// Auto-migrate the schemas using db package models
 	if err := db.AutoMigrate(
 		&project{},
 		&subscription{},
+		&ban{},
+		&subscriptionRequest{},
+		&inviteLink{},
+		&userSettings{},
+		&heldMessage{},
+		&digest{},
+		&digestItem{},
+		&sentNotification{},
+		&notificationRecipient{},
+		&conversation{},
+		&user{},
+		&apiKey{},
+		&projectToken{},
+		&requestNonce{},
 	); err != nil {
 		slog.Error("Failed to auto-migrate database", "error", err)
 		os.Exit(1)
 	}
+	if err := migrateSigningScopes(db); err != nil {
+		slog.Error("Failed to migrate signing scopes", "error", err)
+		os.Exit(1)
+	}
+	plaintext, err := hasPlaintextSecrets(db)
+	if err != nil {
+		slog.Error("Failed to check for plaintext secrets", "error", err)
+		os.Exit(1)
+	}
+	if err := migrateProjectTokens(db, hasher); err != nil {
+		slog.Error("Failed to migrate project tokens", "error", err)
+		os.Exit(1)
+	}
+	if err := hashProjectTokens(db, hasher); err != nil {
+		slog.Error("Failed to hash project tokens", "error", err)
+		os.Exit(1)
+	}
+	if err := hashAPIKeys(db, hasher); err != nil {
+		slog.Error("Failed to hash API keys", "error", err)
+		os.Exit(1)
+	}
+	// Dropped columns stay readable in the free pages of the file until it is rebuilt
+	if plaintext {
+		if err := db.Exec("VACUUM").Error; err != nil {
+			slog.Error("Failed to vacuum database", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Vacuumed database to remove plaintext secrets")
+	}
 
 	return db, nil
 }
+
+// hasPlaintextSecrets returns true if the database still has a column of tokens or keys stored in plaintext
+func hasPlaintextSecrets(db *gorm.DB) (bool, error) {
+	for _, c := range []struct{ table, column string }{
+		{"projects", "token"},
+		{"project_tokens", "token"},
+		{"api_keys", "key"},
+	} {
+		if ok, err := hasColumn(db, c.table, c.column); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// hasColumn returns true if the table has the column. Migrator().HasColumn searches the table's SQL
+// and mistakes a "key" column for the PRIMARY KEY clause.
+func hasColumn(db *gorm.DB, table, column string) (bool, error) {
+	var count int64
+	if err := db.Raw("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count).Error; err != nil {
+		return false, fmt.Errorf("checking column %s.%s: %w", table, column, err)
+	}
+	return count > 0, nil
+}
+
+// dropColumn removes a column that is no longer used by the model.
+// SQLite recreates the table for that, which drops its indexes, so they are migrated again.
+func dropColumn(db *gorm.DB, model interface{}, column string) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("parsing model: %w", err)
+	}
+	// A unique column also has a constraint that would refer to the dropped column
+	constraint := db.NamingStrategy.UniqueName(stmt.Schema.Table, column)
+	if db.Migrator().HasConstraint(model, constraint) {
+		if err := db.Migrator().DropConstraint(model, constraint); err != nil {
+			return fmt.Errorf("dropping constraint %s: %w", constraint, err)
+		}
+	}
+	if err := db.Migrator().DropColumn(model, column); err != nil {
+		return fmt.Errorf("dropping column %s: %w", column, err)
+	}
+	if err := db.AutoMigrate(model); err != nil {
+		return fmt.Errorf("recreating indexes after dropping column %s: %w", column, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+const (
+	legacyProjectID = "6f1c1a52-8c52-4a8e-9d4e-3f0a7c1f2b10"
+	legacyToken     = "0b7e8a51-1c7d-4d0e-8f5a-2f9c3b6d4e21"
+	legacyAPIKey    = "noteo_9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d"
+)
+
+// baselineSchema is the database of the first release, projects had a single token
+var baselineSchema = []string{
+	"CREATE TABLE `projects` (`id` uuid,`name` text,`token` text,`publisher_id` integer,`created_at` datetime," +
+		"`updated_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_projects_token` UNIQUE (`token`))",
+	"CREATE UNIQUE INDEX `idx_publisher_project_name` ON `projects`(`name`)",
+	"CREATE TABLE `subscriptions` (`id` uuid,`user_id` integer,`project_id` uuid,`created_at` datetime," +
+		"`updated_at` datetime,`muted` numeric,`paused_until` datetime,PRIMARY KEY (`id`))",
+	"INSERT INTO projects VALUES ('" + legacyProjectID + "', 'Deployments', '" + legacyToken + "', 1, " +
+		"'2024-01-01 00:00:00', '2024-01-01 00:00:00')",
+	"INSERT INTO subscriptions VALUES ('" + uuid.NewString() + "', 100, '" + legacyProjectID + "', " +
+		"'2024-01-01 00:00:00', '2024-01-01 00:00:00', 0, NULL)",
+}
+
+// plaintextSchema is the database of the releases storing project tokens and API keys in plaintext
+var plaintextSchema = []string{
+	"CREATE TABLE `projects` (`id` uuid,`name` text,`description` text,`publisher_id` integer,`created_at` datetime," +
+		"`updated_at` datetime,`requires_approval` numeric,`legacy_links_disabled` numeric," +
+		"`notification_buttons_disabled` numeric,`last_heartbeat_at` datetime,PRIMARY KEY (`id`))",
+	"CREATE UNIQUE INDEX `idx_publisher_project_name` ON `projects`(`name`)",
+	"CREATE TABLE `project_tokens` (`id` uuid,`project_id` uuid,`name` text,`token` text,`scopes` text," +
+		"`allowed_ips` text,`expires_at` datetime,`last_used_at` datetime,`created_at` datetime," +
+		"PRIMARY KEY (`id`),CONSTRAINT `uni_project_tokens_token` UNIQUE (`token`))",
+	"CREATE INDEX `idx_project_tokens_project_id` ON `project_tokens`(`project_id`)",
+	"CREATE TABLE `api_keys` (`publisher_id` integer,`key` text,`created_at` datetime,`last_used_at` datetime," +
+		"PRIMARY KEY (`publisher_id`),CONSTRAINT `uni_api_keys_key` UNIQUE (`key`))",
+	"INSERT INTO projects VALUES ('" + legacyProjectID + "', 'Deployments', '', 1, '2024-01-01 00:00:00', " +
+		"'2024-01-01 00:00:00', 0, 0, 0, NULL)",
+	"INSERT INTO project_tokens VALUES ('" + uuid.NewString() + "', '" + legacyProjectID + "', 'Default', '" +
+		legacyToken + "', '[\"notify\"]', 'null', NULL, NULL, '2024-01-01 00:00:00')",
+	"INSERT INTO api_keys VALUES (1, '" + legacyAPIKey + "', '2024-01-01 00:00:00', NULL)",
+}
+
+func TestNewDB_Upgrade(t *testing.T) {
+	hasher := domain.NewTokenHasher([]byte("secret"))
+
+	tests := []struct {
+		name       string
+		schema     []string
+		wantScopes []domain.TokenScope
+		wantAPIKey bool
+	}{
+		{"from the single project token", baselineSchema, domain.TokenScopes, false},
+		{"from plaintext tokens and keys", plaintextSchema, []domain.TokenScope{domain.TokenScopeNotify}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "noteo.db")
+			old, err := gorm.Open(sqlite.Open(path))
+			require.NoError(t, err)
+			for _, stmt := range tt.schema {
+				require.NoError(t, old.Exec(stmt).Error, stmt)
+			}
+			closeDB(t, old)
+
+			// Upgrading an upgraded database changes nothing
+			for range 2 {
+				db, err := NewDB(&Config{DSN: path}, hasher)
+				require.NoError(t, err)
+
+				for _, c := range []struct{ table, column string }{
+					{"projects", "token"},
+					{"project_tokens", "token"},
+					{"api_keys", "key"},
+				} {
+					ok, err := hasColumn(db, c.table, c.column)
+					require.NoError(t, err)
+					assert.False(t, ok, "%s.%s", c.table, c.column)
+				}
+
+				token, err := NewProjectTokenRepository(db).GetByHash(hasher.Hash(legacyToken))
+				require.NoError(t, err)
+				assert.Equal(t, uuid.MustParse(legacyProjectID), token.ProjectID)
+				assert.Equal(t, domain.TokenPrefix(legacyToken), token.Prefix)
+				assert.Equal(t, tt.wantScopes, token.Scopes)
+				tokens, err := NewProjectTokenRepository(db).GetByProject(token.ProjectID)
+				require.NoError(t, err)
+				assert.Len(t, tokens, 1)
+
+				key, err := NewAPIKeyRepository(db).GetByHash(hasher.Hash(legacyAPIKey))
+				if tt.wantAPIKey {
+					require.NoError(t, err)
+					assert.Equal(t, domain.TelegramUserID(1), key.PublisherID)
+					assert.Equal(t, domain.APIKeyVisiblePrefix(legacyAPIKey), key.Prefix)
+				} else {
+					assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+				}
+
+				project, err := NewProjectRepository(db).GetByID(uuid.MustParse(legacyProjectID))
+				require.NoError(t, err)
+				assert.Equal(t, "Deployments", project.Name)
+				closeDB(t, db)
+			}
+
+			// The plaintext is gone from the file, not only from the tables
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), legacyToken)
+			assert.NotContains(t, string(data), legacyAPIKey)
+		})
+	}
+}
+
+func closeDB(t *testing.T, db *gorm.DB) {
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+}
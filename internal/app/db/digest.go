@@ -0,0 +1,158 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type digest struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserID    domain.TelegramUserID
+	ProjectID uuid.UUID
+	Count     int
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (d *digest) toDomain() *domain.Digest {
+	return &domain.Digest{
+		ID:        d.ID,
+		UserID:    d.UserID,
+		ProjectID: d.ProjectID,
+		Count:     d.Count,
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+func digestFromDomain(d *domain.Digest) *digest {
+	return &digest{
+		ID:        d.ID,
+		UserID:    d.UserID,
+		ProjectID: d.ProjectID,
+		Count:     d.Count,
+		CreatedAt: d.CreatedAt.UTC(),
+	}
+}
+
+type digestItem struct {
+	ID        uuid.UUID             `gorm:"primaryKey;type:uuid"`
+	UserID    domain.TelegramUserID `gorm:"index:idx_digest_item_subscription"`
+	ProjectID uuid.UUID             `gorm:"index:idx_digest_item_subscription"`
+	DigestID  *uuid.UUID            `gorm:"type:uuid;index"`
+	Text      string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (i *digestItem) toDomain() *domain.DigestItem {
+	return &domain.DigestItem{
+		ID:        i.ID,
+		UserID:    i.UserID,
+		ProjectID: i.ProjectID,
+		DigestID:  i.DigestID,
+		Text:      i.Text,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+func digestItemFromDomain(i *domain.DigestItem) *digestItem {
+	return &digestItem{
+		ID:        i.ID,
+		UserID:    i.UserID,
+		ProjectID: i.ProjectID,
+		DigestID:  i.DigestID,
+		Text:      i.Text,
+		CreatedAt: i.CreatedAt.UTC(),
+	}
+}
+
+type DigestRepository struct {
+	db *gorm.DB
+}
+
+func NewDigestRepository(db *gorm.DB) *DigestRepository {
+	return &DigestRepository{db: db}
+}
+
+func (r *DigestRepository) AddItem(item *domain.DigestItem) error {
+	if err := r.db.Create(digestItemFromDomain(item)).Error; err != nil {
+		return fmt.Errorf("creating digest item in db: %w", err)
+	}
+	return nil
+}
+
+func (r *DigestRepository) GetPendingItems(userID domain.TelegramUserID, projectID uuid.UUID) ([]*domain.DigestItem, error) {
+	var items []digestItem
+	err := r.db.Where("user_id = ? AND project_id = ? AND digest_id IS NULL", userID, projectID).
+		Order("created_at").Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting pending digest items from db: %w", err)
+	}
+	return digestItemsToDomain(items), nil
+}
+
+func (r *DigestRepository) Create(d *domain.Digest, itemIDs []uuid.UUID) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(digestFromDomain(d)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&digestItem{}).Where("id IN ?", itemIDs).Update("digest_id", d.ID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("creating digest in db: %w", err)
+	}
+	return nil
+}
+
+func (r *DigestRepository) GetByID(id uuid.UUID) (*domain.Digest, error) {
+	var d digest
+	if err := r.db.First(&d, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrDigestNotFound
+		}
+		return nil, fmt.Errorf("getting digest by id from db: %w", err)
+	}
+	return d.toDomain(), nil
+}
+
+func (r *DigestRepository) GetItems(digestID uuid.UUID) ([]*domain.DigestItem, error) {
+	var items []digestItem
+	if err := r.db.Where("digest_id = ?", digestID).Order("created_at").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("getting digest items from db: %w", err)
+	}
+	return digestItemsToDomain(items), nil
+}
+
+func (r *DigestRepository) DeleteOlderThan(t time.Time) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		sent := tx.Model(&digest{}).Select("id").Where("created_at < ?", t.UTC())
+		if err := tx.Where("digest_id IN (?)", sent).Delete(&digestItem{}).Error; err != nil {
+			return err
+		}
+		// Pending items are kept as long as the subscription exists, however long it is paused
+		subscribed := tx.Model(&subscription{}).Select("1").
+			Where("subscriptions.user_id = digest_items.user_id AND subscriptions.project_id = digest_items.project_id")
+		err := tx.Where("digest_id IS NULL AND created_at < ? AND NOT EXISTS (?)", t.UTC(), subscribed).
+			Delete(&digestItem{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("created_at < ?", t.UTC()).Delete(&digest{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("deleting old digests from db: %w", err)
+	}
+	return nil
+}
+
+func digestItemsToDomain(items []digestItem) []*domain.DigestItem {
+	result := make([]*domain.DigestItem, len(items))
+	for i := range items {
+		result[i] = items[i].toDomain()
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type heldMessage struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserID    domain.TelegramUserID
+	ProjectID uuid.UUID
+	Text      string
+	Silent    bool
+	ReleaseAt time.Time `gorm:"index"`
+	Attempts  int
+	CreatedAt time.Time
+}
+
+func (m *heldMessage) toDomain() *domain.HeldMessage {
+	return &domain.HeldMessage{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		ProjectID: m.ProjectID,
+		Text:      m.Text,
+		Silent:    m.Silent,
+		ReleaseAt: m.ReleaseAt,
+		Attempts:  m.Attempts,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func heldMessageFromDomain(m *domain.HeldMessage) *heldMessage {
+	return &heldMessage{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		ProjectID: m.ProjectID,
+		Text:      m.Text,
+		Silent:    m.Silent,
+		ReleaseAt: m.ReleaseAt.UTC(),
+		Attempts:  m.Attempts,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+type HeldMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewHeldMessageRepository(db *gorm.DB) *HeldMessageRepository {
+	return &HeldMessageRepository{db: db}
+}
+
+func (r *HeldMessageRepository) Create(message *domain.HeldMessage) error {
+	if err := r.db.Create(heldMessageFromDomain(message)).Error; err != nil {
+		return fmt.Errorf("creating held message in db: %w", err)
+	}
+	return nil
+}
+
+func (r *HeldMessageRepository) GetDue(now time.Time, limit int) ([]*domain.HeldMessage, error) {
+	var messages []heldMessage
+	if err := r.db.Where("release_at <= ?", now.UTC()).Order("created_at").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("getting due held messages from db: %w", err)
+	}
+
+	result := make([]*domain.HeldMessage, len(messages))
+	for i := range messages {
+		result[i] = messages[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *HeldMessageRepository) Retry(id uuid.UUID, releaseAt time.Time) error {
+	if err := r.db.Model(&heldMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"release_at": releaseAt.UTC(),
+		"attempts":   gorm.Expr("attempts + 1"),
+	}).Error; err != nil {
+		return fmt.Errorf("retrying held message in db: %w", err)
+	}
+	return nil
+}
+
+func (r *HeldMessageRepository) Delete(id uuid.UUID) error {
+	if err := r.db.Where("id = ?", id).Delete(&heldMessage{}).Error; err != nil {
+		return fmt.Errorf("deleting held message from db: %w", err)
+	}
+	return nil
+}
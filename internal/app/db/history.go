@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type sentNotification struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:uuid"`
+	ProjectID  uuid.UUID `gorm:"index:idx_sent_notification_project"`
+	Text       string
+	Recipients int
+	CreatedAt  time.Time `gorm:"index:idx_sent_notification_project;index"`
+}
+
+func (n *sentNotification) toDomain() *domain.SentNotification {
+	return &domain.SentNotification{
+		ID:         n.ID,
+		ProjectID:  n.ProjectID,
+		Text:       n.Text,
+		Recipients: n.Recipients,
+		CreatedAt:  n.CreatedAt,
+	}
+}
+
+func sentNotificationFromDomain(n *domain.SentNotification) *sentNotification {
+	return &sentNotification{
+		ID:         n.ID,
+		ProjectID:  n.ProjectID,
+		Text:       n.Text,
+		Recipients: n.Recipients,
+		CreatedAt:  n.CreatedAt.UTC(),
+	}
+}
+
+type notificationRecipient struct {
+	NotificationID uuid.UUID             `gorm:"primaryKey;type:uuid"`
+	UserID         domain.TelegramUserID `gorm:"primaryKey;index:idx_notification_recipient_user"`
+	ProjectID      uuid.UUID             `gorm:"index:idx_notification_recipient_user"`
+	CreatedAt      time.Time             `gorm:"index:idx_notification_recipient_user;index"`
+}
+
+type HistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewHistoryRepository(db *gorm.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+func (r *HistoryRepository) Create(n *domain.SentNotification, recipients []domain.TelegramUserID) error {
+	dbNotification := sentNotificationFromDomain(n)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(dbNotification).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		rows := make([]notificationRecipient, len(recipients))
+		for i, userID := range recipients {
+			rows[i] = notificationRecipient{
+				NotificationID: dbNotification.ID,
+				UserID:         userID,
+				ProjectID:      dbNotification.ProjectID,
+				CreatedAt:      dbNotification.CreatedAt,
+			}
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+	if err != nil {
+		return fmt.Errorf("creating notification history in db: %w", err)
+	}
+	return nil
+}
+
+func (r *HistoryRepository) GetByRecipient(
+	userID domain.TelegramUserID,
+	projectID uuid.UUID,
+	query string,
+	offset, limit int,
+) ([]*domain.SentNotification, int, error) {
+	q := r.db.Model(&sentNotification{}).
+		Joins("JOIN notification_recipients ON notification_recipients.notification_id = sent_notifications.id").
+		Where("notification_recipients.user_id = ? AND notification_recipients.project_id = ?", userID, projectID)
+
+	notifications, total, err := r.getPage(q, query, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting user history from db: %w", err)
+	}
+	return notifications, total, nil
+}
+
+func (r *HistoryRepository) GetByProject(projectID uuid.UUID, query string, offset, limit int) ([]*domain.SentNotification, int, error) {
+	q := r.db.Model(&sentNotification{}).Where("sent_notifications.project_id = ?", projectID)
+
+	notifications, total, err := r.getPage(q, query, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting project history from db: %w", err)
+	}
+	return notifications, total, nil
+}
+
+// getPage filters the notifications by the query and returns a page of them, newest first, with their total number
+func (r *HistoryRepository) getPage(q *gorm.DB, query string, offset, limit int) ([]*domain.SentNotification, int, error) {
+	if query != "" {
+		q = q.Where("sent_notifications.text LIKE ? ESCAPE '\\'", "%"+escapeLike(query)+"%")
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var notifications []sentNotification
+	err := q.Session(&gorm.Session{}).Select("sent_notifications.*").
+		Order("sent_notifications.created_at DESC").Offset(offset).Limit(limit).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*domain.SentNotification, len(notifications))
+	for i := range notifications {
+		result[i] = notifications[i].toDomain()
+	}
+	return result, int(total), nil
+}
+
+func (r *HistoryRepository) DeleteOlderThan(t time.Time) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("created_at < ?", t.UTC()).Delete(&notificationRecipient{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("created_at < ?", t.UTC()).Delete(&sentNotification{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("deleting old notification history from db: %w", err)
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
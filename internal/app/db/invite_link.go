@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type inviteLink struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid"`
+	ProjectID uuid.UUID `gorm:"index"`
+	Code      string    `gorm:"unique"`
+	ExpiresAt *time.Time
+	MaxUses   int
+	Uses      int
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (l *inviteLink) toDomain() *domain.InviteLink {
+	return &domain.InviteLink{
+		ID:        l.ID,
+		ProjectID: l.ProjectID,
+		Code:      l.Code,
+		ExpiresAt: l.ExpiresAt,
+		MaxUses:   l.MaxUses,
+		Uses:      l.Uses,
+		RevokedAt: l.RevokedAt,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+func inviteLinkFromDomain(l *domain.InviteLink) *inviteLink {
+	return &inviteLink{
+		ID:        l.ID,
+		ProjectID: l.ProjectID,
+		Code:      l.Code,
+		ExpiresAt: l.ExpiresAt,
+		MaxUses:   l.MaxUses,
+		Uses:      l.Uses,
+		RevokedAt: l.RevokedAt,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+type InviteLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteLinkRepository(db *gorm.DB) *InviteLinkRepository {
+	return &InviteLinkRepository{db: db}
+}
+
+func (r *InviteLinkRepository) Create(link *domain.InviteLink) error {
+	if err := r.db.Create(inviteLinkFromDomain(link)).Error; err != nil {
+		return fmt.Errorf("creating invite link in db: %w", err)
+	}
+	return nil
+}
+
+func (r *InviteLinkRepository) GetByID(id uuid.UUID) (*domain.InviteLink, error) {
+	var link inviteLink
+	if err := r.db.First(&link, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("getting invite link by id from db: %w", err)
+	}
+	return link.toDomain(), nil
+}
+
+func (r *InviteLinkRepository) GetByCode(code string) (*domain.InviteLink, error) {
+	var link inviteLink
+	if err := r.db.First(&link, "code = ?", code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInviteNotFound
+		}
+		return nil, fmt.Errorf("getting invite link by code from db: %w", err)
+	}
+	return link.toDomain(), nil
+}
+
+func (r *InviteLinkRepository) GetByProject(projectID uuid.UUID) ([]*domain.InviteLink, error) {
+	var links []inviteLink
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("getting project invite links from db: %w", err)
+	}
+
+	result := make([]*domain.InviteLink, len(links))
+	for i := range links {
+		result[i] = links[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *InviteLinkRepository) IncrementUses(id uuid.UUID) error {
+	// The limit is checked by the update itself, so concurrent redemptions can't exceed it
+	result := r.db.Model(&inviteLink{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", id).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("incrementing invite link uses in db: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrInviteExhausted
+	}
+	return nil
+}
+
+func (r *InviteLinkRepository) DecrementUses(id uuid.UUID) error {
+	result := r.db.Model(&inviteLink{}).
+		Where("id = ? AND uses > 0", id).
+		Update("uses", gorm.Expr("uses - 1"))
+	if result.Error != nil {
+		return fmt.Errorf("decrementing invite link uses in db: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *InviteLinkRepository) Revoke(id uuid.UUID, at time.Time) error {
+	if err := r.db.Model(&inviteLink{}).Where("id = ?", id).Update("revoked_at", at).Error; err != nil {
+		return fmt.Errorf("revoking invite link in db: %w", err)
+	}
+	return nil
+}
+
+func (r *InviteLinkRepository) DeleteByProject(projectID uuid.UUID) error {
+	if err := r.db.Where("project_id = ?", projectID).Delete(&inviteLink{}).Error; err != nil {
+		return fmt.Errorf("deleting project invite links from db: %w", err)
+	}
+	return nil
+}
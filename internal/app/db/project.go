@@ -1,7 +1,10 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,36 +14,126 @@ import (
 )
 
 type project struct {
-	ID          uuid.UUID `gorm:"primaryKey;type:uuid"`
-	Name        string    `gorm:"uniqueIndex:idx_publisher_project_name"`
-	Token       string    `gorm:"unique"`
-	PublisherID domain.TelegramUserID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID   uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Name string
+	// NameKey is the lowercased name, the names of the projects of a publisher must differ in more than case
+	NameKey                     string `gorm:"uniqueIndex:idx_publisher_project_name_key,priority:2"`
+	Description                 string
+	PublisherID                 domain.TelegramUserID `gorm:"uniqueIndex:idx_publisher_project_name_key,priority:1"`
+	CreatedAt                   time.Time
+	UpdatedAt                   time.Time
+	RequiresApproval            bool
+	LegacyLinksDisabled         bool
+	NotificationButtonsDisabled bool
+	LastHeartbeatAt             *time.Time
+	SigningSecret               string
+	SigningScopes               []domain.TokenScope `gorm:"serializer:json"`
+	RequireSignedRequests       bool
 }
 
 func (p *project) toDomain() *domain.Project {
 	return &domain.Project{
-		ID:          p.ID,
-		Name:        p.Name,
-		Token:       p.Token,
-		PublisherID: p.PublisherID,
-		CreatedAt:   p.CreatedAt,
-		UpdatedAt:   p.UpdatedAt,
+		ID:                          p.ID,
+		Name:                        p.Name,
+		Description:                 p.Description,
+		PublisherID:                 p.PublisherID,
+		CreatedAt:                   p.CreatedAt,
+		UpdatedAt:                   p.UpdatedAt,
+		RequiresApproval:            p.RequiresApproval,
+		LegacyLinksDisabled:         p.LegacyLinksDisabled,
+		NotificationButtonsDisabled: p.NotificationButtonsDisabled,
+		LastHeartbeatAt:             p.LastHeartbeatAt,
+		SigningSecret:               p.SigningSecret,
+		SigningScopes:               p.SigningScopes,
+		RequireSignedRequests:       p.RequireSignedRequests,
 	}
 }
 
 func projectFromDomain(p *domain.Project) *project {
 	return &project{
-		ID:          p.ID,
-		Name:        p.Name,
-		Token:       p.Token,
-		PublisherID: p.PublisherID,
-		CreatedAt:   p.CreatedAt,
-		UpdatedAt:   p.UpdatedAt,
+		ID:                          p.ID,
+		Name:                        p.Name,
+		NameKey:                     projectNameKey(p.Name),
+		Description:                 p.Description,
+		PublisherID:                 p.PublisherID,
+		CreatedAt:                   p.CreatedAt,
+		UpdatedAt:                   p.UpdatedAt,
+		RequiresApproval:            p.RequiresApproval,
+		LegacyLinksDisabled:         p.LegacyLinksDisabled,
+		NotificationButtonsDisabled: p.NotificationButtonsDisabled,
+		LastHeartbeatAt:             p.LastHeartbeatAt,
+		SigningSecret:               p.SigningSecret,
+		SigningScopes:               p.SigningScopes,
+		RequireSignedRequests:       p.RequireSignedRequests,
 	}
 }
 
+// projectNameKey returns the key making project names unique regardless of case
+func projectNameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// migrateProjectNameKeys replaces the case-sensitive unique index on project names created by older versions
+// with the one on their lowercased names. It fills the keys before AutoMigrate creates the index,
+// and numbers the names of a publisher's projects that differ only in case, so that the index can be created.
+func migrateProjectNameKeys(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&project{}) {
+		return nil
+	}
+	if db.Migrator().HasIndex(&project{}, "idx_publisher_project_name") {
+		if err := db.Migrator().DropIndex(&project{}, "idx_publisher_project_name"); err != nil {
+			return fmt.Errorf("dropping project name index: %w", err)
+		}
+	}
+	if ok, err := hasColumn(db, "projects", "name_key"); err != nil || ok {
+		return err
+	}
+	if err := db.Migrator().AddColumn(&project{}, "NameKey"); err != nil {
+		return fmt.Errorf("adding project name key: %w", err)
+	}
+
+	var projects []project
+	if err := db.Order("created_at").Find(&projects).Error; err != nil {
+		return fmt.Errorf("getting projects: %w", err)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		taken := make(map[domain.TelegramUserID]map[string]bool)
+		for _, p := range projects {
+			if taken[p.PublisherID] == nil {
+				taken[p.PublisherID] = make(map[string]bool)
+			}
+			name := p.Name
+			for i := 2; taken[p.PublisherID][projectNameKey(name)]; i++ {
+				name = fmt.Sprintf("%s (%d)", p.Name, i)
+			}
+			taken[p.PublisherID][projectNameKey(name)] = true
+			if name != p.Name {
+				slog.Warn("Renamed project whose name differs from another one only in case", "projectId", p.ID, "name", name)
+			}
+
+			err := tx.Model(&project{}).Where("id = ?", p.ID).UpdateColumns(map[string]interface{}{
+				"name":     name,
+				"name_key": projectNameKey(name),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("setting name key of project %s: %w", p.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// migrateSigningScopes lets the signing secrets created before they had scopes do every action, as they used to
+func migrateSigningScopes(db *gorm.DB) error {
+	err := db.Model(&project{}).
+		Where("signing_secret <> '' AND (signing_scopes IS NULL OR signing_scopes IN ('', 'null'))").
+		Select("signing_scopes").UpdateColumns(&project{SigningScopes: domain.TokenScopes}).Error
+	if err != nil {
+		return fmt.Errorf("setting signing scopes: %w", err)
+	}
+	return nil
+}
+
 type ProjectRepository struct {
 	db *gorm.DB
 }
@@ -54,6 +147,9 @@ func NewProjectRepository(db *gorm.DB) *ProjectRepository {
 func (r *ProjectRepository) Create(project *domain.Project) error {
 	dbProject := projectFromDomain(project)
 	if err := r.db.Create(dbProject).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.ErrProjectNameTaken
+		}
 		return fmt.Errorf("creating project in db: %w", err)
 	}
 	return nil
@@ -62,19 +158,14 @@ func (r *ProjectRepository) Create(project *domain.Project) error {
 func (r *ProjectRepository) GetByID(id uuid.UUID) (*domain.Project, error) {
 	var project project
 	if err := r.db.First(&project, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrProjectNotFound
+		}
 		return nil, fmt.Errorf("getting project by id from db: %w", err)
 	}
 	return project.toDomain(), nil
 }
 
-func (r *ProjectRepository) GetByToken(token string) (*domain.Project, error) {
-	var project project
-	if err := r.db.First(&project, "token = ?", token).Error; err != nil {
-		return nil, fmt.Errorf("getting project by token from db: %w", err)
-	}
-	return project.toDomain(), nil
-}
-
 func (r *ProjectRepository) GetByPublisher(publisherID domain.TelegramUserID) ([]*domain.Project, error) {
 	var projects []project
 	if err := r.db.Where("publisher_id = ?", publisherID).Find(&projects).Error; err != nil {
@@ -89,15 +180,100 @@ func (r *ProjectRepository) GetByPublisher(publisherID domain.TelegramUserID) ([
 }
 
 func (r *ProjectRepository) UpdateName(id uuid.UUID, name string) error {
-	if err := r.db.Model(&project{}).Where("id = ?", id).Update("name", name).Error; err != nil {
+	err := r.db.Model(&project{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":     name,
+		"name_key": projectNameKey(name),
+	}).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.ErrProjectNameTaken
+		}
 		return fmt.Errorf("updating project name in db: %w", err)
 	}
 	return nil
 }
 
-func (r *ProjectRepository) UpdateToken(id uuid.UUID, token string) error {
-	if err := r.db.Model(&project{}).Where("id = ?", id).Update("token", token).Error; err != nil {
-		return fmt.Errorf("updating project token in db: %w", err)
+func (r *ProjectRepository) UpdateDescription(id uuid.UUID, description string) error {
+	if err := r.db.Model(&project{}).Where("id = ?", id).Update("description", description).Error; err != nil {
+		return fmt.Errorf("updating project description in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateRequiresApproval(id uuid.UUID, requiresApproval bool) error {
+	if err := r.db.Model(&project{}).Where("id = ?", id).Update("requires_approval", requiresApproval).Error; err != nil {
+		return fmt.Errorf("updating project approval mode in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateLegacyLinksDisabled(id uuid.UUID, disabled bool) error {
+	if err := r.db.Model(&project{}).Where("id = ?", id).Update("legacy_links_disabled", disabled).Error; err != nil {
+		return fmt.Errorf("updating project legacy links in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateNotificationButtonsDisabled(id uuid.UUID, disabled bool) error {
+	if err := r.db.Model(&project{}).Where("id = ?", id).Update("notification_buttons_disabled", disabled).Error; err != nil {
+		return fmt.Errorf("updating project notification buttons in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateLastHeartbeat(id uuid.UUID, at time.Time) error {
+	// Heartbeats don't change the project, so its update time is kept
+	if err := r.db.Model(&project{}).Where("id = ?", id).UpdateColumn("last_heartbeat_at", at).Error; err != nil {
+		return fmt.Errorf("updating project heartbeat in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateSigningSecret(id uuid.UUID, sealed string, scopes []domain.TokenScope) error {
+	err := r.db.Model(&project{}).Where("id = ?", id).
+		Select("signing_secret", "signing_scopes").
+		Updates(&project{SigningSecret: sealed, SigningScopes: scopes}).Error
+	if err != nil {
+		return fmt.Errorf("updating project signing secret in db: %w", err)
 	}
 	return nil
 }
+
+func (r *ProjectRepository) UpdateSigningScopes(id uuid.UUID, scopes []domain.TokenScope) error {
+	err := r.db.Model(&project{}).Where("id = ?", id).
+		Select("signing_scopes").Updates(&project{SigningScopes: scopes}).Error
+	if err != nil {
+		return fmt.Errorf("updating project signing scopes in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) UpdateRequireSignedRequests(id uuid.UUID, required bool) error {
+	if err := r.db.Model(&project{}).Where("id = ?", id).Update("require_signed_requests", required).Error; err != nil {
+		return fmt.Errorf("updating project signed requests requirement in db: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the project with everything belonging to it in one transaction,
+// so that a failure leaves neither orphaned rows nor a project without its subscribers
+func (r *ProjectRepository) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, deleteByProject := range []func(uuid.UUID) error{
+			NewSubscriptionRepository(tx).DeleteByProject,
+			NewBanRepository(tx).DeleteByProject,
+			NewSubscriptionRequestRepository(tx).DeleteByProject,
+			NewInviteLinkRepository(tx).DeleteByProject,
+			NewProjectTokenRepository(tx).DeleteByProject,
+		} {
+			if err := deleteByProject(id); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&project{}).Error; err != nil {
+			return fmt.Errorf("deleting project from db: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// newTestDB opens a migrated database in a temporary file
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := NewDB(&Config{DSN: filepath.Join(t.TempDir(), "noteo.db")}, domain.NewTokenHasher([]byte("secret")))
+	require.NoError(t, err)
+	return db
+}
+
+func TestProjectRepository_NameUniqueness(t *testing.T) {
+	repo := NewProjectRepository(newTestDB(t))
+	newProject := func(publisherID domain.TelegramUserID, name string) *domain.Project {
+		return &domain.Project{ID: uuid.New(), Name: name, PublisherID: publisherID}
+	}
+
+	api := newProject(1, "API")
+	require.NoError(t, repo.Create(api))
+	backups := newProject(1, "Backups")
+	require.NoError(t, repo.Create(backups))
+
+	tests := []struct {
+		name string
+		err  error
+		run  func() error
+	}{
+		{"same name", domain.ErrProjectNameTaken, func() error { return repo.Create(newProject(1, "API")) }},
+		{"different case", domain.ErrProjectNameTaken, func() error { return repo.Create(newProject(1, "api")) }},
+		{"different case in unicode", nil, func() error { return repo.Create(newProject(1, "Проект")) }},
+		{"same unicode name in another case", domain.ErrProjectNameTaken, func() error { return repo.Create(newProject(1, "ПРОЕКТ")) }},
+		{"name of another publisher", nil, func() error { return repo.Create(newProject(2, "api")) }},
+		{"rename to a taken name", domain.ErrProjectNameTaken, func() error { return repo.UpdateName(backups.ID, "Api") }},
+		{"rename changing the case", nil, func() error { return repo.UpdateName(api.ID, "api") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMigrateProjectNameKeys(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "noteo.db")
+	old, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	// Projects of older versions had a case-sensitive unique index on the name
+	require.NoError(t, old.Exec("CREATE TABLE `projects` (`id` uuid,`name` text,`publisher_id` integer,"+
+		"`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`))").Error)
+	require.NoError(t, old.Exec("CREATE UNIQUE INDEX `idx_publisher_project_name` ON `projects`(`publisher_id`,`name`)").Error)
+	now := time.Now()
+	first, second, other := uuid.New(), uuid.New(), uuid.New()
+	for _, p := range []struct {
+		id          uuid.UUID
+		name        string
+		publisherID int
+		createdAt   time.Time
+	}{
+		{first, "API", 1, now},
+		{second, "api", 1, now.Add(time.Minute)},
+		{other, "api", 2, now},
+	} {
+		require.NoError(t, old.Exec("INSERT INTO projects (id, name, publisher_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			p.id, p.name, p.publisherID, p.createdAt, p.createdAt).Error)
+	}
+
+	db, err := NewDB(&Config{DSN: dsn}, domain.NewTokenHasher([]byte("secret")))
+	require.NoError(t, err)
+	repo := NewProjectRepository(db)
+
+	// The newer of the names differing only in case is numbered
+	for id, name := range map[uuid.UUID]string{first: "API", second: "api (2)", other: "api"} {
+		project, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, name, project.Name)
+	}
+	assert.False(t, db.Migrator().HasIndex(&project{}, "idx_publisher_project_name"))
+	assert.ErrorIs(t, repo.Create(&domain.Project{ID: uuid.New(), Name: "Api", PublisherID: 2}), domain.ErrProjectNameTaken)
+}
+
+func TestProjectRepository_Delete(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail makes deleting the project tokens fail after the subscriptions and bans were deleted
+		fail bool
+	}{
+		{"deletes everything", false},
+		{"keeps everything on failure", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			repo := NewProjectRepository(db)
+			subscriptions := NewSubscriptionRepository(db)
+			bans := NewBanRepository(db)
+
+			project := &domain.Project{ID: uuid.New(), Name: "Deployments", PublisherID: 1}
+			require.NoError(t, repo.Create(project))
+			require.NoError(t, subscriptions.Create(&domain.Subscription{ID: uuid.New(), UserID: 2, ProjectID: project.ID}))
+			require.NoError(t, bans.Create(&domain.Ban{ProjectID: project.ID, UserID: 3}))
+			if tt.fail {
+				require.NoError(t, db.Migrator().DropTable(&projectToken{}))
+			}
+
+			err := repo.Delete(project.ID)
+			if tt.fail {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, err = repo.GetByID(project.ID)
+			assert.Equal(t, tt.fail, err == nil)
+			subscribed, err := subscriptions.Exists(2, project.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.fail, subscribed)
+			banned, err := bans.Exists(project.ID, 3)
+			require.NoError(t, err)
+			assert.Equal(t, tt.fail, banned)
+		})
+	}
+}
+
+func TestMigrateSigningScopes(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewProjectRepository(db)
+	signed, limited, unsigned := uuid.New(), uuid.New(), uuid.New()
+	for i, id := range []uuid.UUID{signed, limited, unsigned} {
+		require.NoError(t, repo.Create(&domain.Project{ID: id, Name: fmt.Sprintf("Project %d", i), PublisherID: 1}))
+	}
+	// Secrets of older versions had no scopes
+	require.NoError(t, db.Exec("UPDATE projects SET signing_secret = 'sealed', signing_scopes = NULL WHERE id = ?", signed).Error)
+	require.NoError(t, repo.UpdateSigningSecret(limited, "sealed", []domain.TokenScope{domain.TokenScopeHeartbeat}))
+
+	require.NoError(t, migrateSigningScopes(db))
+
+	for id, scopes := range map[uuid.UUID][]domain.TokenScope{
+		signed:   domain.TokenScopes,
+		limited:  {domain.TokenScopeHeartbeat},
+		unsigned: nil,
+	} {
+		project, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, scopes, project.SigningScopes)
+	}
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type projectToken struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:uuid"`
+	ProjectID  uuid.UUID `gorm:"index"`
+	Name       string
+	Prefix     string
+	Hash       string              `gorm:"uniqueIndex:idx_project_tokens_hash"`
+	Scopes     []domain.TokenScope `gorm:"serializer:json"`
+	AllowedIPs []netip.Prefix      `gorm:"serializer:json"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (t *projectToken) toDomain() *domain.ProjectToken {
+	return &domain.ProjectToken{
+		ID:         t.ID,
+		ProjectID:  t.ProjectID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Hash:       t.Hash,
+		Scopes:     t.Scopes,
+		AllowedIPs: t.AllowedIPs,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func projectTokenFromDomain(t *domain.ProjectToken) *projectToken {
+	return &projectToken{
+		ID:         t.ID,
+		ProjectID:  t.ProjectID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Hash:       t.Hash,
+		Scopes:     t.Scopes,
+		AllowedIPs: t.AllowedIPs,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// migrateProjectTokens moves the single token projects used to have into the tokens table,
+// where it becomes the default token allowing everything, and drops the column it was stored in
+func migrateProjectTokens(db *gorm.DB, hasher *domain.TokenHasher) error {
+	if ok, err := hasColumn(db, "projects", "token"); err != nil || !ok {
+		return err
+	}
+
+	var legacy []struct {
+		ID        uuid.UUID
+		Token     string
+		CreatedAt time.Time
+	}
+	if err := db.Table("projects").Select("id, token, created_at").
+		Where("token IS NOT NULL AND token <> ''").Scan(&legacy).Error; err != nil {
+		return fmt.Errorf("getting legacy project tokens: %w", err)
+	}
+
+	// Moved tokens are cleared, so that they aren't moved again if dropping the column fails
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range legacy {
+			token := &projectToken{
+				ID:        uuid.New(),
+				ProjectID: p.ID,
+				Name:      domain.DefaultTokenName,
+				Prefix:    domain.TokenPrefix(p.Token),
+				Hash:      hasher.Hash(p.Token),
+				Scopes:    domain.TokenScopes,
+				CreatedAt: p.CreatedAt,
+			}
+			if err := tx.Create(token).Error; err != nil {
+				return fmt.Errorf("moving token of project %s: %w", p.ID, err)
+			}
+			if err := tx.Table("projects").Where("id = ?", p.ID).UpdateColumn("token", nil).Error; err != nil {
+				return fmt.Errorf("clearing token of project %s: %w", p.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dropColumn(db, &project{}, "token"); err != nil {
+		return err
+	}
+	slog.Info("Moved project tokens to the tokens table", "projects", len(legacy))
+	return nil
+}
+
+// hashProjectTokens replaces the tokens that used to be stored in plaintext with their hashes
+// and drops the column they were stored in
+func hashProjectTokens(db *gorm.DB, hasher *domain.TokenHasher) error {
+	if ok, err := hasColumn(db, "project_tokens", "token"); err != nil || !ok {
+		return err
+	}
+
+	var plaintext []struct {
+		ID    uuid.UUID
+		Token string
+	}
+	if err := db.Table("project_tokens").Select("id, token").
+		Where("token IS NOT NULL AND token <> ''").Scan(&plaintext).Error; err != nil {
+		return fmt.Errorf("getting plaintext project tokens: %w", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range plaintext {
+			err := tx.Table("project_tokens").Where("id = ?", t.ID).UpdateColumns(map[string]interface{}{
+				"prefix": domain.TokenPrefix(t.Token),
+				"hash":   hasher.Hash(t.Token),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("hashing project token %s: %w", t.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dropColumn(db, &projectToken{}, "token"); err != nil {
+		return err
+	}
+	slog.Info("Replaced plaintext project tokens with their hashes", "tokens", len(plaintext))
+	return nil
+}
+
+type ProjectTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectTokenRepository(db *gorm.DB) *ProjectTokenRepository {
+	return &ProjectTokenRepository{db: db}
+}
+
+func (r *ProjectTokenRepository) Create(token *domain.ProjectToken) error {
+	if err := r.db.Create(projectTokenFromDomain(token)).Error; err != nil {
+		return fmt.Errorf("creating project token in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectTokenRepository) GetByID(id uuid.UUID) (*domain.ProjectToken, error) {
+	var token projectToken
+	if err := r.db.First(&token, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("getting project token by id from db: %w", err)
+	}
+	return token.toDomain(), nil
+}
+
+func (r *ProjectTokenRepository) GetByHash(hash string) (*domain.ProjectToken, error) {
+	var token projectToken
+	if err := r.db.First(&token, "hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("getting project token from db: %w", err)
+	}
+	return token.toDomain(), nil
+}
+
+func (r *ProjectTokenRepository) GetByProject(projectID uuid.UUID) ([]*domain.ProjectToken, error) {
+	var tokens []projectToken
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("getting project tokens from db: %w", err)
+	}
+
+	result := make([]*domain.ProjectToken, len(tokens))
+	for i := range tokens {
+		result[i] = tokens[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *ProjectTokenRepository) Update(token *domain.ProjectToken) error {
+	err := r.db.Model(&projectToken{ID: token.ID}).
+		Select("name", "scopes", "allowed_ips", "expires_at").
+		Updates(projectTokenFromDomain(token)).Error
+	if err != nil {
+		return fmt.Errorf("updating project token in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectTokenRepository) UpdateLastUsed(id uuid.UUID, at time.Time) error {
+	if err := r.db.Model(&projectToken{}).Where("id = ?", id).Update("last_used_at", at).Error; err != nil {
+		return fmt.Errorf("updating project token last use in db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectTokenRepository) Delete(id uuid.UUID) error {
+	if err := r.db.Where("id = ?", id).Delete(&projectToken{}).Error; err != nil {
+		return fmt.Errorf("deleting project token from db: %w", err)
+	}
+	return nil
+}
+
+func (r *ProjectTokenRepository) DeleteByProject(projectID uuid.UUID) error {
+	if err := r.db.Where("project_id = ?", projectID).Delete(&projectToken{}).Error; err != nil {
+		return fmt.Errorf("deleting project tokens from db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type requestNonce struct {
+	ProjectID uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Nonce     string    `gorm:"primaryKey"`
+	UsedAt    time.Time `gorm:"index"`
+}
+
+type NonceRepository struct {
+	db *gorm.DB
+}
+
+func NewNonceRepository(db *gorm.DB) *NonceRepository {
+	return &NonceRepository{db: db}
+}
+
+func (r *NonceRepository) Use(projectID uuid.UUID, nonce string, at time.Time) (bool, error) {
+	err := r.db.Create(&requestNonce{ProjectID: projectID, Nonce: nonce, UsedAt: at.UTC()}).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return false, nil
+		}
+		return false, fmt.Errorf("recording request nonce in db: %w", err)
+	}
+	return true, nil
+}
+
+func (r *NonceRepository) DeleteOlderThan(t time.Time) error {
+	if err := r.db.Where("used_at < ?", t.UTC()).Delete(&requestNonce{}).Error; err != nil {
+		return fmt.Errorf("deleting old request nonces from db: %w", err)
+	}
+	return nil
+}
@@ -11,36 +11,57 @@ import (
 )
 
 type subscription struct {
-	ID          uuid.UUID `gorm:"primaryKey;type:uuid"`
-	UserID      domain.TelegramUserID
-	ProjectID   uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Muted       bool
-	PausedUntil *time.Time
+	ID              uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserID          domain.TelegramUserID
+	ProjectID       uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Muted           bool
+	MutedUntil      *time.Time
+	PausedUntil     *time.Time `gorm:"index"`
+	HoldWhilePaused bool
+	QuietMode       domain.QuietMode
+	DigestMode      domain.DigestMode
+	DigestAt        int
+	NextDigestAt    *time.Time `gorm:"index"`
+	Inactive        bool
 }
 
 func (s *subscription) toDomain() *domain.Subscription {
 	return &domain.Subscription{
-		ID:          s.ID,
-		UserID:      s.UserID,
-		ProjectID:   s.ProjectID,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
-		Muted:       s.Muted,
-		PausedUntil: s.PausedUntil,
+		ID:              s.ID,
+		UserID:          s.UserID,
+		ProjectID:       s.ProjectID,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+		Muted:           s.Muted,
+		MutedUntil:      s.MutedUntil,
+		PausedUntil:     s.PausedUntil,
+		HoldWhilePaused: s.HoldWhilePaused,
+		QuietMode:       s.QuietMode,
+		DigestMode:      s.DigestMode,
+		DigestAt:        s.DigestAt,
+		NextDigestAt:    s.NextDigestAt,
+		Inactive:        s.Inactive,
 	}
 }
 
 func subscriptionFromDomain(s *domain.Subscription) *subscription {
 	return &subscription{
-		ID:          s.ID,
-		UserID:      s.UserID,
-		ProjectID:   s.ProjectID,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
-		Muted:       s.Muted,
-		PausedUntil: s.PausedUntil,
+		ID:              s.ID,
+		UserID:          s.UserID,
+		ProjectID:       s.ProjectID,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+		Muted:           s.Muted,
+		MutedUntil:      s.MutedUntil,
+		PausedUntil:     utc(s.PausedUntil),
+		HoldWhilePaused: s.HoldWhilePaused,
+		QuietMode:       s.QuietMode,
+		DigestMode:      s.DigestMode,
+		DigestAt:        s.DigestAt,
+		NextDigestAt:    utc(s.NextDigestAt),
+		Inactive:        s.Inactive,
 	}
 }
 
@@ -67,9 +88,16 @@ func (r *SubscriptionRepository) Delete(userID domain.TelegramUserID, projectID
 	return nil
 }
 
+func (r *SubscriptionRepository) DeleteByProject(projectID uuid.UUID) error {
+	if err := r.db.Where("project_id = ?", projectID).Delete(&subscription{}).Error; err != nil {
+		return fmt.Errorf("deleting project subscriptions from db: %w", err)
+	}
+	return nil
+}
+
 func (r *SubscriptionRepository) GetByProject(projectID uuid.UUID) ([]*domain.Subscription, error) {
 	var subscriptions []subscription
-	if err := r.db.Where("project_id = ?", projectID).Find(&subscriptions).Error; err != nil {
+	if err := r.db.Where("project_id = ?", projectID).Order("created_at").Find(&subscriptions).Error; err != nil {
 		return nil, fmt.Errorf("getting project subscriptions from db: %w", err)
 	}
 
@@ -108,3 +136,54 @@ func (r *SubscriptionRepository) GetByUserAndProject(userID domain.TelegramUserI
 	}
 	return sub.toDomain(), nil
 }
+
+func (r *SubscriptionRepository) Exists(userID domain.TelegramUserID, projectID uuid.UUID) (bool, error) {
+	var count int64
+	if err := r.db.Model(&subscription{}).Where("user_id = ? AND project_id = ?", userID, projectID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("checking subscription in db: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *SubscriptionRepository) GetDueDigests(now time.Time) ([]*domain.Subscription, error) {
+	var subscriptions []subscription
+	if err := r.db.Where("next_digest_at <= ?", now.UTC()).Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("getting due digests from db: %w", err)
+	}
+
+	result := make([]*domain.Subscription, len(subscriptions))
+	for i := range subscriptions {
+		result[i] = subscriptions[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *SubscriptionRepository) GetEndedPauses(now time.Time) ([]*domain.Subscription, error) {
+	var subscriptions []subscription
+	if err := r.db.Where("paused_until <= ?", now.UTC()).Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("getting ended pauses from db: %w", err)
+	}
+
+	result := make([]*domain.Subscription, len(subscriptions))
+	for i := range subscriptions {
+		result[i] = subscriptions[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *SubscriptionRepository) SetInactive(userID domain.TelegramUserID, inactive bool) error {
+	err := r.db.Model(&subscription{}).Where("user_id = ?", userID).Update("inactive", inactive).Error
+	if err != nil {
+		return fmt.Errorf("updating user subscriptions in db: %w", err)
+	}
+	return nil
+}
+
+// utc converts an optional time to UTC, as sqlite compares times as strings
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
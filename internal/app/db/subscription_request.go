@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type subscriptionRequest struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserID    domain.TelegramUserID
+	ProjectID uuid.UUID
+	CreatedAt time.Time
+}
+
+func (r *subscriptionRequest) toDomain() *domain.SubscriptionRequest {
+	return &domain.SubscriptionRequest{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		ProjectID: r.ProjectID,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+func subscriptionRequestFromDomain(r *domain.SubscriptionRequest) *subscriptionRequest {
+	return &subscriptionRequest{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		ProjectID: r.ProjectID,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+type SubscriptionRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRequestRepository(db *gorm.DB) *SubscriptionRequestRepository {
+	return &SubscriptionRequestRepository{db: db}
+}
+
+func (r *SubscriptionRequestRepository) Create(request *domain.SubscriptionRequest) error {
+	if err := r.db.Create(subscriptionRequestFromDomain(request)).Error; err != nil {
+		return fmt.Errorf("creating subscription request in db: %w", err)
+	}
+	return nil
+}
+
+func (r *SubscriptionRequestRepository) GetByID(id uuid.UUID) (*domain.SubscriptionRequest, error) {
+	var request subscriptionRequest
+	if err := r.db.First(&request, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("getting subscription request by id from db: %w", err)
+	}
+	return request.toDomain(), nil
+}
+
+func (r *SubscriptionRequestRepository) GetByUserAndProject(userID domain.TelegramUserID, projectID uuid.UUID) (*domain.SubscriptionRequest, error) {
+	var request subscriptionRequest
+	if err := r.db.Where("user_id = ? AND project_id = ?", userID, projectID).First(&request).Error; err != nil {
+		return nil, fmt.Errorf("getting subscription request from db: %w", err)
+	}
+	return request.toDomain(), nil
+}
+
+func (r *SubscriptionRequestRepository) Delete(id uuid.UUID) error {
+	if err := r.db.Where("id = ?", id).Delete(&subscriptionRequest{}).Error; err != nil {
+		return fmt.Errorf("deleting subscription request from db: %w", err)
+	}
+	return nil
+}
+
+func (r *SubscriptionRequestRepository) DeleteByProject(projectID uuid.UUID) error {
+	if err := r.db.Where("project_id = ?", projectID).Delete(&subscriptionRequest{}).Error; err != nil {
+		return fmt.Errorf("deleting project subscription requests from db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type user struct {
+	ID           domain.TelegramUserID `gorm:"primaryKey;autoIncrement:false"`
+	FirstName    string
+	LastName     string
+	Username     string
+	LanguageCode string
+	State        domain.UserState
+	FirstSeenAt  time.Time
+	LastSeenAt   time.Time
+	UpdatedAt    time.Time
+}
+
+func (u *user) toDomain() *domain.User {
+	return &domain.User{
+		ID:           u.ID,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		Username:     u.Username,
+		LanguageCode: u.LanguageCode,
+		State:        u.State,
+		FirstSeenAt:  u.FirstSeenAt,
+		LastSeenAt:   u.LastSeenAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+func userFromDomain(u *domain.User) *user {
+	return &user{
+		ID:           u.ID,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		Username:     u.Username,
+		LanguageCode: u.LanguageCode,
+		State:        u.State,
+		FirstSeenAt:  u.FirstSeenAt,
+		LastSeenAt:   u.LastSeenAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Get(id domain.TelegramUserID) (*domain.User, error) {
+	var u user
+	if err := r.db.First(&u, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("getting user from db: %w", err)
+	}
+	return u.toDomain(), nil
+}
+
+func (r *UserRepository) Save(u *domain.User) error {
+	err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(userFromDomain(u)).Error
+	if err != nil {
+		return fmt.Errorf("saving user in db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+type userSettings struct {
+	UserID       domain.TelegramUserID `gorm:"primaryKey;autoIncrement:false"`
+	Timezone     string
+	QuietMode    domain.QuietMode
+	QuietWindows []domain.QuietWindow `gorm:"serializer:json"`
+	Language     string
+	UpdatedAt    time.Time
+}
+
+func (s *userSettings) toDomain() *domain.UserSettings {
+	return &domain.UserSettings{
+		UserID:       s.UserID,
+		Timezone:     s.Timezone,
+		QuietMode:    s.QuietMode,
+		QuietWindows: s.QuietWindows,
+		Language:     s.Language,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+func userSettingsFromDomain(s *domain.UserSettings) *userSettings {
+	return &userSettings{
+		UserID:       s.UserID,
+		Timezone:     s.Timezone,
+		QuietMode:    s.QuietMode,
+		QuietWindows: s.QuietWindows,
+		Language:     s.Language,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+type UserSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewUserSettingsRepository(db *gorm.DB) *UserSettingsRepository {
+	return &UserSettingsRepository{db: db}
+}
+
+func (r *UserSettingsRepository) Get(userID domain.TelegramUserID) (*domain.UserSettings, error) {
+	var settings userSettings
+	if err := r.db.First(&settings, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrUserSettingsNotFound
+		}
+		return nil, fmt.Errorf("getting user settings from db: %w", err)
+	}
+	return settings.toDomain(), nil
+}
+
+func (r *UserSettingsRepository) GetByUsers(userIDs []domain.TelegramUserID) ([]*domain.UserSettings, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var settings []userSettings
+	if err := r.db.Where("user_id IN ?", userIDs).Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("getting users settings from db: %w", err)
+	}
+
+	result := make([]*domain.UserSettings, len(settings))
+	for i := range settings {
+		result[i] = settings[i].toDomain()
+	}
+	return result, nil
+}
+
+func (r *UserSettingsRepository) Save(settings *domain.UserSettings) error {
+	err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(userSettingsFromDomain(settings)).Error
+	if err != nil {
+		return fmt.Errorf("saving user settings in db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// probeWait is how often senders waiting for a probe check whether it is over
+const probeWait = time.Second
+
+// BreakerState is the state of the circuit breaker in front of the message sender
+type BreakerState string
+
+const (
+	// BreakerClosed lets messages through
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen pauses sending after consecutive failures to reach Telegram
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single message through to probe whether Telegram is back
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// breaker pauses sending while Telegram is unavailable, so that messages don't waste their retries.
+// It opens after a number of consecutive failures and probes Telegram with a single message
+// once the cooldown is over, closing again as soon as a message gets through.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	// pausedAt is when the breaker opened last, openedAt is when the current cooldown started
+	pausedAt time.Time
+	openedAt time.Time
+	opens    int
+}
+
+func newBreaker(cfg *Config) *breaker {
+	return &breaker{
+		threshold: cfg.BreakerThreshold,
+		cooldown:  cfg.BreakerCooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// acquire returns zero if a message can be sent now, or how long to wait before asking again.
+// Once the cooldown is over, only the first caller gets to send, as a probe.
+func (b *breaker) acquire(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return 0
+	case BreakerOpen:
+		if wait := b.openedAt.Add(b.cooldown).Sub(now); wait > 0 {
+			return wait
+		}
+		b.state = BreakerHalfOpen
+		slog.Info("Probing whether Telegram is available")
+		return 0
+	default:
+		return probeWait
+	}
+}
+
+// record updates the state with the result of sending a message. Any error other
+// than domain.ErrMessengerUnavailable means Telegram is reachable.
+// It returns true if sending was paused by this result.
+func (b *breaker) record(err error, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !errors.Is(err, domain.ErrMessengerUnavailable) {
+		if b.state != BreakerClosed {
+			slog.Info("Telegram is available again, resuming sending", "pausedFor", now.Sub(b.pausedAt))
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		paused := b.state == BreakerClosed
+		if paused {
+			b.opens++
+			b.pausedAt = now
+			slog.Warn("Telegram is unavailable, pausing sending", "error", err, "failures", b.failures)
+		}
+		b.state = BreakerOpen
+		b.openedAt = now
+		return paused
+	}
+	return false
+}
+
+// current returns the state of the breaker, when it was last opened and how many times it was opened
+func (b *breaker) current() (BreakerState, time.Time, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.pausedAt, b.opens
+}
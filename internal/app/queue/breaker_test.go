@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(&Config{BreakerThreshold: 3, BreakerCooldown: 30 * time.Second})
+	now := time.Unix(1000, 0)
+	outage := fmt.Errorf("%w: connection refused", domain.ErrMessengerUnavailable)
+
+	// Other errors mean Telegram is reachable
+	b.record(outage, now)
+	b.record(outage, now)
+	b.record(errors.New("bad request"), now)
+	b.record(outage, now)
+	assert.Zero(t, b.acquire(now))
+
+	b.record(outage, now)
+	assert.True(t, b.record(outage, now))
+	state, pausedAt, opens := b.current()
+	assert.Equal(t, BreakerOpen, state)
+	assert.Equal(t, now, pausedAt)
+	assert.Equal(t, 1, opens)
+	assert.Equal(t, 30*time.Second, b.acquire(now))
+
+	// A single probe goes through after the cooldown, and a failed one restarts it
+	later := now.Add(30 * time.Second)
+	assert.Zero(t, b.acquire(later))
+	assert.Equal(t, probeWait, b.acquire(later))
+	// Sending is already paused
+	assert.False(t, b.record(outage, later))
+	assert.Equal(t, 30*time.Second, b.acquire(later))
+
+	// A successful probe resumes sending
+	muchLater := later.Add(30 * time.Second)
+	assert.Zero(t, b.acquire(muchLater))
+	b.record(nil, muchLater)
+	state, _, opens = b.current()
+	assert.Equal(t, BreakerClosed, state)
+	assert.Equal(t, 1, opens)
+	assert.Zero(t, b.acquire(muchLater))
+}
@@ -4,7 +4,22 @@ import "time"
 
 // Config holds configuration for the message queue
 type Config struct {
-	Capacity          int
+	// Capacity is the maximum number of messages waiting in the queue
+	Capacity int
+	// ProjectCapacity is the maximum number of messages of a single project waiting in the queue
+	ProjectCapacity int
+	// Workers is the number of messages sent in parallel
+	Workers int
+	// Rate is the maximum number of messages sent per second
+	Rate int
+	// ChatInterval is the minimum time between two messages to a private chat
+	ChatInterval time.Duration
+	// GroupInterval is the minimum time between two messages to a group chat
+	GroupInterval time.Duration
+	// BreakerThreshold is the number of consecutive failures to reach Telegram that pause sending
+	BreakerThreshold int
+	// BreakerCooldown is how long sending is paused before Telegram is probed
+	BreakerCooldown   time.Duration
 	InitialRetryDelay time.Duration
 	MaxRetryDelay     time.Duration
 	MaxRetries        int
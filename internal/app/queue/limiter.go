@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// chatsPruneInterval is how often the send times of idle chats are forgotten
+const chatsPruneInterval = time.Minute
+
+// limiter spaces messages out to stay within Telegram's flood limits. Time is divided into
+// slots of one message each, a message to a chat takes the first free slot that is far enough
+// from the previous message to the same chat.
+type limiter struct {
+	mu            sync.Mutex
+	slot          time.Duration
+	chatInterval  time.Duration
+	groupInterval time.Duration
+	// next is the first slot that may be free, every slot from now to next is taken
+	next int64
+	// taken holds the slots after next that are taken by messages delayed for their chat
+	taken map[int64]bool
+	// chats holds the send time of the last message to each chat
+	chats    map[int64]time.Time
+	prunedAt time.Time
+}
+
+func newLimiter(cfg *Config) *limiter {
+	return &limiter{
+		slot:          time.Second / time.Duration(cfg.Rate),
+		chatInterval:  cfg.ChatInterval,
+		groupInterval: cfg.GroupInterval,
+		taken:         make(map[int64]bool),
+		chats:         make(map[int64]time.Time),
+	}
+}
+
+// interval returns the minimum time between two messages to the chat, group chats have negative IDs
+func (l *limiter) interval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return l.groupInterval
+	}
+	return l.chatInterval
+}
+
+// reserve returns when a message to the chat can be sent, the time is reserved for the message
+func (l *limiter) reserve(chatID int64, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	at := now
+	if last, ok := l.chats[chatID]; ok && last.Add(l.interval(chatID)).After(at) {
+		at = last.Add(l.interval(chatID))
+	}
+
+	slot := l.takeSlot(l.slotOf(at))
+	sendAt := time.Unix(0, slot*int64(l.slot))
+	l.chats[chatID] = sendAt
+	return sendAt
+}
+
+// pause delays the messages not reserved yet, Telegram asks for it when the limits are exceeded
+func (l *limiter) pause(chatID int64, d time.Duration, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := now.Add(d)
+	if slot := l.slotOf(until); slot > l.next {
+		l.skipTo(slot)
+	}
+	// The next message to the chat is due one interval after the last one
+	if last := until.Add(-l.interval(chatID)); last.After(l.chats[chatID]) {
+		l.chats[chatID] = last
+	}
+}
+
+// slotOf returns the first slot starting at or after the given time
+func (l *limiter) slotOf(t time.Time) int64 {
+	ns := t.UnixNano()
+	slot := ns / int64(l.slot)
+	if ns%int64(l.slot) != 0 {
+		slot++
+	}
+	return slot
+}
+
+// takeSlot takes the first free slot starting from the given one
+func (l *limiter) takeSlot(from int64) int64 {
+	if from < l.next {
+		from = l.next
+	}
+	for l.taken[from] {
+		from++
+	}
+
+	if from != l.next {
+		l.taken[from] = true
+		return from
+	}
+	l.next++
+	for l.taken[l.next] {
+		delete(l.taken, l.next)
+		l.next++
+	}
+	return from
+}
+
+// skipTo moves next forward to the given slot, leaving the slots before it unused
+func (l *limiter) skipTo(slot int64) {
+	for taken := range l.taken {
+		if taken < slot {
+			delete(l.taken, taken)
+		}
+	}
+	l.next = slot
+	for l.taken[l.next] {
+		delete(l.taken, l.next)
+		l.next++
+	}
+}
+
+// prune forgets the slots in the past and, from time to time, the chats that can be messaged right away
+func (l *limiter) prune(now time.Time) {
+	if slot := l.slotOf(now); slot > l.next {
+		l.skipTo(slot)
+	}
+
+	if now.Sub(l.prunedAt) < chatsPruneInterval {
+		return
+	}
+	for chatID, last := range l.chats {
+		if !last.Add(l.interval(chatID)).After(now) {
+			delete(l.chats, chatID)
+		}
+	}
+	l.prunedAt = now
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Reserve(t *testing.T) {
+	l := newLimiter(&Config{Rate: 10, ChatInterval: time.Second, GroupInterval: 3 * time.Second})
+	now := time.Unix(1000, 0)
+
+	// Different chats share the global rate
+	assert.Equal(t, now, l.reserve(1, now))
+	assert.Equal(t, now.Add(100*time.Millisecond), l.reserve(2, now))
+	assert.Equal(t, now.Add(200*time.Millisecond), l.reserve(3, now))
+
+	// A chat waits for its interval, without holding up other chats
+	assert.Equal(t, now.Add(time.Second), l.reserve(1, now))
+	assert.Equal(t, now.Add(300*time.Millisecond), l.reserve(4, now))
+	assert.Equal(t, now.Add(2*time.Second), l.reserve(1, now))
+
+	// Groups have a longer interval
+	assert.Equal(t, now.Add(400*time.Millisecond), l.reserve(-1, now))
+	assert.Equal(t, now.Add(3400*time.Millisecond), l.reserve(-1, now))
+
+	// Slots taken by delayed messages are skipped
+	later := now.Add(900 * time.Millisecond)
+	assert.Equal(t, later, l.reserve(5, later))
+	assert.Equal(t, later.Add(200*time.Millisecond), l.reserve(6, later))
+
+	// Slots in the past aren't used
+	muchLater := now.Add(time.Minute)
+	assert.Equal(t, muchLater, l.reserve(7, muchLater))
+}
+
+func TestLimiter_Pause(t *testing.T) {
+	l := newLimiter(&Config{Rate: 10, ChatInterval: time.Second, GroupInterval: 3 * time.Second})
+	now := time.Unix(1000, 0)
+
+	assert.Equal(t, now, l.reserve(1, now))
+	l.pause(1, 5*time.Second, now)
+
+	assert.Equal(t, now.Add(5*time.Second), l.reserve(2, now))
+	assert.Equal(t, now.Add(5100*time.Millisecond), l.reserve(1, now))
+	assert.Equal(t, now.Add(6100*time.Millisecond), l.reserve(1, now))
+}
@@ -3,16 +3,22 @@ package queue
 import (
 	"errors"
 	"log/slog"
-	"os"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/sergeax/noteo/internal/domain"
 )
 
 var (
-	// ErrQueueFull is returned when the queue is at capacity
+	// ErrQueueFull is returned when the queue or the project's lane is at capacity
 	ErrQueueFull = errors.New("message queue is full")
+	// ErrQueueStopped is returned when a message is put after the queue is stopped
+	ErrQueueStopped = errors.New("message queue is stopped")
+	// ErrQueuePaused is returned while sending is paused because Telegram is unavailable,
+	// messages should be kept elsewhere and put again later
+	ErrQueuePaused = errors.New("message queue is paused while telegram is unavailable")
 )
 
 // MessageSender is an interface for sending messages
@@ -21,84 +27,254 @@ type MessageSender interface {
 	SendMessage(msg domain.Message) error
 }
 
-// Queue is an in-memory message queue
+// MessagePostponer keeps messages the queue can't send while Telegram is unavailable,
+// so that they aren't lost if the application stops meanwhile
+type MessagePostponer interface {
+	// Postpone keeps the messages until they can be queued again
+	Postpone(messages []domain.Message, now time.Time) error
+}
+
+// FloodError is implemented by errors of a sender that exceeded the flood limits of Telegram
+type FloodError interface {
+	error
+	// RetryAfter is how long to wait before sending again, zero if the error isn't caused by the limits
+	RetryAfter() time.Duration
+}
+
+// Queue is an in-memory message queue sending messages in parallel within Telegram's limits.
+// Each project has its own lane, and the lanes take turns, so that a project sending
+// a lot of messages doesn't delay the messages of the others.
 type Queue struct {
 	config        *Config
 	messageSender MessageSender
-	messages      chan domain.Message
+	postponer     MessagePostponer
+	limiter       *limiter
+	breaker       *breaker
 	wg            sync.WaitGroup
 	stopCh        chan struct{}
+
+	mu       sync.Mutex
+	nonEmpty *sync.Cond
+	lanes    map[uuid.UUID][]domain.Message
+	// turns holds the projects with waiting messages in the order they are served
+	turns   []uuid.UUID
+	size    int
+	stopped bool
 }
 
 // NewQueue creates a new message queue with the specified configuration
-func NewQueue(cfg *Config, sender MessageSender) *Queue {
-	return &Queue{
-		messages:      make(chan domain.Message, cfg.Capacity),
+func NewQueue(cfg *Config, sender MessageSender, postponer MessagePostponer) *Queue {
+	q := &Queue{
 		stopCh:        make(chan struct{}),
 		config:        cfg,
 		messageSender: sender,
+		postponer:     postponer,
+		limiter:       newLimiter(cfg),
+		breaker:       newBreaker(cfg),
+		lanes:         make(map[uuid.UUID][]domain.Message),
 	}
+	q.nonEmpty = sync.NewCond(&q.mu)
+	return q
 }
 
-// Put adds a message to the queue, returning immediately
-// Returns ErrQueueFull if the queue is at capacity
+// Put adds a message to the lane of its project, returning immediately
+// Returns ErrQueueFull if the queue or the lane is at capacity, and ErrQueuePaused
+// while Telegram is unavailable
 func (q *Queue) Put(msg domain.Message) error {
-	select {
-	case q.messages <- msg:
-		return nil
-	default:
+	return q.PutAll([]domain.Message{msg})
+}
+
+// PutAll adds either all the messages to the lanes of their projects or none of them,
+// so that messages the queue can't take are kept elsewhere without sending any of them twice.
+// It returns the errors of Put.
+func (q *Queue) PutAll(messages []domain.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// The state is checked under the lock, so that no message is put after the waiting ones are postponed
+	if state, _, _ := q.breaker.current(); state != BreakerClosed {
+		return ErrQueuePaused
+	}
+	if q.stopped {
+		return ErrQueueStopped
+	}
+	if q.size+len(messages) > q.config.Capacity {
 		return ErrQueueFull
 	}
+	added := make(map[uuid.UUID]int)
+	for _, msg := range messages {
+		added[msg.ProjectID]++
+	}
+	for projectID, n := range added {
+		if len(q.lanes[projectID])+n > q.config.ProjectCapacity {
+			return ErrQueueFull
+		}
+	}
+
+	q.add(messages)
+	return nil
+}
+
+// add appends the messages to the lanes of their projects, q.mu must be held
+func (q *Queue) add(messages []domain.Message) {
+	for _, msg := range messages {
+		lane, ok := q.lanes[msg.ProjectID]
+		if !ok {
+			q.turns = append(q.turns, msg.ProjectID)
+		}
+		q.lanes[msg.ProjectID] = append(lane, msg)
+	}
+	q.size += len(messages)
+	q.nonEmpty.Broadcast()
+}
+
+// Stats describes the queue for health checks and metrics
+type Stats struct {
+	// Depth is the number of messages waiting to be sent
+	Depth   int
+	Breaker BreakerState
+	// PausedAt is when sending was last paused because Telegram was unavailable, zero if it never was
+	PausedAt time.Time
+	// Pauses is the number of times sending was paused
+	Pauses int
+}
+
+// Stats returns the current state of the queue
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	depth := q.size
+	q.mu.Unlock()
+
+	state, pausedAt, pauses := q.breaker.current()
+	return Stats{Depth: depth, Breaker: state, PausedAt: pausedAt, Pauses: pauses}
+}
+
+// next takes the first message of the project whose turn it is, waiting for one if the queue is empty.
+// It returns false once the queue is stopped and empty.
+func (q *Queue) next() (domain.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size == 0 {
+		if q.stopped {
+			return domain.Message{}, false
+		}
+		q.nonEmpty.Wait()
+	}
+
+	projectID := q.turns[0]
+	lane := q.lanes[projectID]
+	msg := lane[0]
+	q.turns = q.turns[1:]
+	if len(lane) > 1 {
+		q.lanes[projectID] = lane[1:]
+		q.turns = append(q.turns, projectID)
+	} else {
+		delete(q.lanes, projectID)
+	}
+	q.size--
+	return msg, true
 }
 
 // Start begins processing messages from the queue
 func (q *Queue) Start() {
-	slog.Info("Starting message queue", "capacity", q.config.Capacity)
+	slog.Info("Starting message queue",
+		"capacity", q.config.Capacity,
+		"projectCapacity", q.config.ProjectCapacity,
+		"workers", q.config.Workers,
+		"rate", q.config.Rate)
 	if q.messageSender == nil {
 		slog.Error("Message sender not set")
 		panic("message sender not set")
 	}
+	if q.postponer == nil {
+		slog.Error("Message postponer not set")
+		panic("message postponer not set")
+	}
 
-	q.wg.Add(1)
-	go func() {
-		defer q.wg.Done()
-		for msg := range q.messages {
-			if err := q.sendWithRetry(msg); err != nil {
-				slog.Error("Failed to send message after all retries, exiting application",
-					"error", err,
-					"chatId", msg.UserID)
-				os.Exit(1)
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for {
+				msg, ok := q.next()
+				if !ok {
+					return
+				}
+				q.send(msg)
 			}
-		}
-	}()
+		}()
+	}
 }
 
-// sendWithRetry attempts to send a message with exponential backoff retries
-func (q *Queue) sendWithRetry(msg domain.Message) error {
-	var err error
+// send delivers a message, retrying failures with exponential backoff and flood errors
+// after the time asked by Telegram. Messages that fail after all retries are dropped,
+// failures to reach Telegram don't count as they pause sending altogether.
+// The messages waiting in the lanes are postponed when sending is paused, and so is
+// the message itself if the queue stops before Telegram is back.
+func (q *Queue) send(msg domain.Message) {
 	delay := q.config.InitialRetryDelay
 
-	for attempt := 0; attempt < q.config.MaxRetries; attempt++ {
-		// Try to send the message
-		err = q.messageSender.SendMessage(msg)
-		if err == nil {
-			return nil // Success!
+	for attempts, retry := 0, false; ; retry = true {
+		if !q.waitBreaker() {
+			slog.Warn("Queue stopped while Telegram is unavailable, postponing message", "chatId", msg.UserID)
+			q.postpone([]domain.Message{msg})
+			return
+		}
+		// Retries are abandoned when the queue stops, new messages are still sent
+		if !q.wait(msg.UserID.Int64(), retry) {
+			slog.Warn("Queue stopped during retry, dropping message", "chatId", msg.UserID)
+			return
+		}
+
+		err := q.messageSender.SendMessage(msg)
+		if q.breaker.record(err, time.Now()) {
+			q.postponeWaiting()
+		}
+		switch {
+		case err == nil:
+			return
+		case errors.Is(err, domain.ErrUndeliverable):
+			slog.Warn("Dropping message to unreachable user", "error", err, "chatId", msg.UserID)
+			return
+		case !errors.Is(err, domain.ErrMessengerUnavailable):
+			// Failures to reach Telegram aren't counted, the breaker decides when to try again
+			attempts++
+			if attempts >= q.config.MaxRetries {
+				slog.Error("Failed to send message after all retries, dropping it",
+					"error", err,
+					"chatId", msg.UserID)
+				return
+			}
+		}
+
+		var flood FloodError
+		if errors.As(err, &flood) && flood.RetryAfter() > 0 {
+			slog.Warn("Flood limits exceeded, pausing sending",
+				"error", err,
+				"retryAfter", flood.RetryAfter(),
+				"chatId", msg.UserID)
+			q.limiter.pause(msg.UserID.Int64(), flood.RetryAfter(), time.Now())
+			continue
 		}
 
-		// Log the error and prepare for retry
 		slog.Warn("Failed to send message, will retry",
 			"error", err,
-			"attempt", attempt+1,
+			"attempt", attempts,
 			"maxRetries", q.config.MaxRetries,
 			"nextRetryDelay", delay,
 			"chatId", msg.UserID)
 
-		// Wait for the delay or until the queue is stopped
 		select {
 		case <-time.After(delay):
-			// Continue with retry
 		case <-q.stopCh:
-			return errors.New("queue stopped during retry")
+			if errors.Is(err, domain.ErrMessengerUnavailable) {
+				slog.Warn("Queue stopped while Telegram is unavailable, postponing message", "chatId", msg.UserID)
+				q.postpone([]domain.Message{msg})
+				return
+			}
+			slog.Warn("Queue stopped during retry, dropping message", "chatId", msg.UserID)
+			return
 		}
 
 		// Exponential backoff: double the delay for next attempt
@@ -107,14 +283,85 @@ func (q *Queue) sendWithRetry(msg domain.Message) error {
 			delay = q.config.MaxRetryDelay
 		}
 	}
+}
 
-	return err // Return the last error after all retries
+// postponeWaiting hands the messages waiting in the lanes over to the postponer once sending is paused.
+// The lock is held meanwhile, so that they aren't sent in the meantime, and the messages are kept
+// in the lanes if they can't be postponed. Messages being sent stay with their workers.
+func (q *Queue) postponeWaiting() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size == 0 {
+		return
+	}
+	messages := make([]domain.Message, 0, q.size)
+	for _, projectID := range q.turns {
+		messages = append(messages, q.lanes[projectID]...)
+	}
+	if !q.postpone(messages) {
+		return
+	}
+
+	q.lanes = make(map[uuid.UUID][]domain.Message)
+	q.turns = nil
+	q.size = 0
+	slog.Warn("Postponed waiting messages until Telegram is available", "messages", len(messages))
+}
+
+// postpone hands the messages over to the postponer, returning false if it fails
+func (q *Queue) postpone(messages []domain.Message) bool {
+	if err := q.postponer.Postpone(messages, time.Now()); err != nil {
+		slog.Error("Failed to postpone messages", "error", err, "messages", len(messages))
+		return false
+	}
+	return true
+}
+
+// waitBreaker blocks while sending is paused because Telegram is unavailable.
+// It returns false if the queue is stopped meanwhile.
+func (q *Queue) waitBreaker() bool {
+	for {
+		wait := q.breaker.acquire(time.Now())
+		if wait == 0 {
+			return true
+		}
+		select {
+		case <-time.After(wait):
+		case <-q.stopCh:
+			return false
+		}
+	}
+}
+
+// wait blocks until a message can be sent to the chat within the limits.
+// It returns false if the wait is interruptible and the queue is stopped meanwhile.
+func (q *Queue) wait(chatID int64, interruptible bool) bool {
+	timer := time.NewTimer(time.Until(q.limiter.reserve(chatID, time.Now())))
+	defer timer.Stop()
+
+	if !interruptible {
+		<-timer.C
+		return true
+	}
+	select {
+	case <-timer.C:
+		return true
+	case <-q.stopCh:
+		return false
+	}
 }
 
 // Stop gracefully shuts down the queue, waiting for all messages to be processed
 func (q *Queue) Stop() {
-	close(q.stopCh)   // Signal all retries to stop
-	close(q.messages) // Stop accepting new messages
-	q.wg.Wait()       // Wait for all processing to complete
+	close(q.stopCh) // Signal all retries to stop
+
+	// Stop accepting new messages
+	q.mu.Lock()
+	q.stopped = true
+	q.nonEmpty.Broadcast()
+	q.mu.Unlock()
+
+	q.wg.Wait() // Wait for all processing to complete
 	slog.Info("Message queue stopped")
 }
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+func TestQueue_Fairness(t *testing.T) {
+	q := NewQueue(&Config{Capacity: 100, ProjectCapacity: 3, Rate: 30}, nil, nil)
+	noisy, quiet := uuid.New(), uuid.New()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, q.Put(domain.Message{ProjectID: noisy, Text: "noisy"}))
+	}
+	// Only the project at its capacity is rejected
+	assert.ErrorIs(t, q.Put(domain.Message{ProjectID: noisy, Text: "noisy"}), ErrQueueFull)
+	require.NoError(t, q.Put(domain.Message{ProjectID: quiet, Text: "quiet"}))
+
+	// The quiet project doesn't wait for the noisy one
+	var texts []string
+	for range 4 {
+		msg, ok := q.next()
+		require.True(t, ok)
+		texts = append(texts, msg.Text)
+	}
+	assert.Equal(t, []string{"noisy", "quiet", "noisy", "noisy"}, texts)
+
+	q.Stop()
+	_, ok := q.next()
+	assert.False(t, ok)
+	assert.ErrorIs(t, q.Put(domain.Message{ProjectID: quiet}), ErrQueueStopped)
+}
+
+func TestQueue_PutAll(t *testing.T) {
+	project, other := uuid.New(), uuid.New()
+	messages := func(projectID uuid.UUID, n int) []domain.Message {
+		result := make([]domain.Message, n)
+		for i := range result {
+			result[i] = domain.Message{ProjectID: projectID}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		waiting  []domain.Message
+		messages []domain.Message
+		err      error
+	}{
+		{"fits the lane", nil, messages(project, 3), nil},
+		{"more than the lane holds", nil, messages(project, 4), ErrQueueFull},
+		{"more than is left in the lane", messages(project, 2), messages(project, 2), ErrQueueFull},
+		{"other lane", messages(other, 3), messages(project, 3), nil},
+		{"more than the queue holds", messages(other, 3), append(messages(project, 3), messages(uuid.New(), 1)...), ErrQueueFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(&Config{Capacity: 6, ProjectCapacity: 3, Rate: 30}, nil, nil)
+			require.NoError(t, q.PutAll(tt.waiting))
+
+			err := q.PutAll(tt.messages)
+			if tt.err != nil {
+				// None of the messages is queued
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, len(tt.waiting), q.Stats().Depth)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.waiting)+len(tt.messages), q.Stats().Depth)
+		})
+	}
+}
+
+type messageSenderStub struct {
+	err error
+}
+
+func (s messageSenderStub) SendMessage(domain.Message) error {
+	return s.err
+}
+
+type messagePostponerStub struct {
+	mu       sync.Mutex
+	messages []domain.Message
+}
+
+func (s *messagePostponerStub) Postpone(messages []domain.Message, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, messages...)
+	return nil
+}
+
+func (s *messagePostponerStub) postponed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	texts := make([]string, len(s.messages))
+	for i, msg := range s.messages {
+		texts[i] = msg.Text
+	}
+	return texts
+}
+
+func TestQueue_PostponeWhileUnavailable(t *testing.T) {
+	postponer := &messagePostponerStub{}
+	q := NewQueue(&Config{
+		Capacity:         10,
+		ProjectCapacity:  10,
+		Workers:          1,
+		Rate:             30,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+		MaxRetries:       3,
+	}, messageSenderStub{err: domain.ErrMessengerUnavailable}, postponer)
+	projectID := uuid.New()
+	require.NoError(t, q.PutAll([]domain.Message{
+		{ProjectID: projectID, Text: "first"},
+		{ProjectID: projectID, Text: "second"},
+		{ProjectID: projectID, Text: "third"},
+	}))
+
+	// The first message opens the breaker, the waiting ones are postponed right away
+	q.Start()
+	require.Eventually(t, func() bool { return len(postponer.postponed()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"second", "third"}, postponer.postponed())
+	assert.Zero(t, q.Stats().Depth)
+	assert.ErrorIs(t, q.Put(domain.Message{ProjectID: projectID}), ErrQueuePaused)
+
+	// The message being sent is postponed when the queue stops before Telegram is back
+	q.Stop()
+	assert.Equal(t, []string{"second", "third", "first"}, postponer.postponed())
+}
@@ -0,0 +1,13 @@
+package scheduler
+
+import "time"
+
+// Config holds configuration for the scheduler
+type Config struct {
+	// Interval between runs of the scheduled jobs
+	Interval time.Duration
+	// BatchSize limits the number of held messages released per run
+	BatchSize int
+	// HistoryRetention is how long notifications are kept in the history
+	HistoryRetention time.Duration
+}
@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sergeax/noteo/internal/app/queue"
+	"github.com/sergeax/noteo/internal/domain"
+)
+
+// Service periodically runs background jobs, such as releasing messages held during quiet hours,
+// sending digests and telling users when their pauses are over
+type Service struct {
+	config              *Config
+	notificationService *domain.NotificationService
+	historyService      *domain.HistoryService
+	signingService      *domain.SigningService
+	messageQueue        *queue.Queue
+	wg                  sync.WaitGroup
+	stopCh              chan struct{}
+}
+
+// NewService creates a new scheduler with the specified configuration
+func NewService(
+	cfg *Config,
+	notificationService *domain.NotificationService,
+	historyService *domain.HistoryService,
+	signingService *domain.SigningService,
+	messageQueue *queue.Queue,
+) *Service {
+	return &Service{
+		config:              cfg,
+		notificationService: notificationService,
+		historyService:      historyService,
+		signingService:      signingService,
+		messageQueue:        messageQueue,
+		stopCh:              make(chan struct{}),
+	}
+}
+
+// Start begins running the scheduled jobs
+func (s *Service) Start() {
+	slog.Info("Starting scheduler", "interval", s.config.Interval)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.releaseHeld()
+				s.sendDigests()
+				s.endPauses()
+				s.cleanup()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// releaseHeld queues the messages whose quiet hours are over and the messages postponed
+// while Telegram was unavailable, batch by batch until none are due or the queue is full
+func (s *Service) releaseHeld() {
+	for s.releaseHeldBatch() {
+	}
+}
+
+// releaseHeldBatch queues a batch of held messages, it returns true if more may be due.
+// Messages that fail are tried again later with a growing delay, so that they don't block the others.
+func (s *Service) releaseHeldBatch() bool {
+	held, err := s.notificationService.GetDueHeld(time.Now(), s.config.BatchSize)
+	if err != nil {
+		slog.Error("Failed to get held messages", "error", err)
+		return false
+	}
+
+	released := 0
+	for _, h := range held {
+		msg, ok, err := s.notificationService.Release(h)
+		if err != nil {
+			slog.Error("Failed to release held message", "error", err, "held_id", h.ID)
+			s.retryHeld(h)
+			continue
+		}
+
+		if ok {
+			if err := s.messageQueue.Put(msg); err != nil {
+				if postponed(err) {
+					// Try again on the next run
+					slog.Warn("Message queue doesn't accept messages, postponing held messages", "error", err)
+					return false
+				}
+				slog.Error("Failed to queue held message", "error", err, "held_id", h.ID)
+				s.retryHeld(h)
+				continue
+			}
+		}
+
+		if err := s.notificationService.DeleteHeld(h.ID); err != nil {
+			slog.Error("Failed to delete held message", "error", err, "held_id", h.ID)
+			continue
+		}
+		released++
+	}
+	return len(held) == s.config.BatchSize && released == len(held)
+}
+
+// retryHeld postpones a held message that failed to be released
+func (s *Service) retryHeld(h *domain.HeldMessage) {
+	kept, err := s.notificationService.RetryHeld(h, time.Now())
+	if err != nil {
+		slog.Error("Failed to retry held message", "error", err, "held_id", h.ID)
+		return
+	}
+	if !kept {
+		slog.Warn("Dropping held message after failed releases", "held_id", h.ID, "attempts", h.Attempts+1)
+	}
+}
+
+// postponed returns true if the queue can't take messages at the moment, they are kept until the next run
+func postponed(err error) bool {
+	return errors.Is(err, queue.ErrQueueFull) || errors.Is(err, queue.ErrQueuePaused)
+}
+
+// sendDigests queues the digests that are due
+func (s *Service) sendDigests() {
+	subscriptions, err := s.notificationService.GetDueDigests(time.Now())
+	if err != nil {
+		slog.Error("Failed to get due digests", "error", err)
+		return
+	}
+
+	for _, sub := range subscriptions {
+		pending, err := s.notificationService.PrepareDigest(sub, time.Now())
+		if err != nil {
+			slog.Error("Failed to prepare digest", "error", err, "chatId", sub.UserID, "project_id", sub.ProjectID)
+			continue
+		}
+		if pending == nil {
+			continue
+		}
+
+		if err := s.messageQueue.Put(pending.Message); err != nil {
+			if postponed(err) {
+				// Try again on the next run
+				slog.Warn("Message queue doesn't accept messages, postponing digests", "error", err)
+				return
+			}
+			slog.Error("Failed to queue digest", "error", err, "chatId", sub.UserID)
+			continue
+		}
+
+		if err := s.notificationService.CommitDigest(pending); err != nil {
+			slog.Error("Failed to save digest", "error", err, "chatId", sub.UserID, "project_id", sub.ProjectID)
+		}
+	}
+}
+
+// endPauses tells users that their paused subscriptions are active again,
+// together with the notifications held during the pause
+func (s *Service) endPauses() {
+	subscriptions, err := s.notificationService.GetEndedPauses(time.Now())
+	if err != nil {
+		slog.Error("Failed to get ended pauses", "error", err)
+		return
+	}
+
+	for _, sub := range subscriptions {
+		pending, err := s.notificationService.PrepareResume(sub, time.Now())
+		if err != nil {
+			slog.Error("Failed to prepare pause end message", "error", err, "chatId", sub.UserID, "project_id", sub.ProjectID)
+			continue
+		}
+		if pending == nil {
+			continue
+		}
+
+		if err := s.messageQueue.Put(pending.Message); err != nil {
+			if postponed(err) {
+				// Try again on the next run
+				slog.Warn("Message queue doesn't accept messages, postponing pause end messages", "error", err)
+				return
+			}
+			slog.Error("Failed to queue pause end message", "error", err, "chatId", sub.UserID)
+			continue
+		}
+
+		if err := s.notificationService.CommitDigest(pending); err != nil {
+			slog.Error("Failed to end pause", "error", err, "chatId", sub.UserID, "project_id", sub.ProjectID)
+		}
+	}
+}
+
+// cleanup removes data that is no longer needed
+func (s *Service) cleanup() {
+	if err := s.notificationService.CleanupDigests(time.Now()); err != nil {
+		slog.Error("Failed to clean up digests", "error", err)
+	}
+	if err := s.historyService.Cleanup(time.Now().Add(-s.config.HistoryRetention)); err != nil {
+		slog.Error("Failed to clean up notification history", "error", err)
+	}
+	if err := s.signingService.CleanupNonces(time.Now()); err != nil {
+		slog.Error("Failed to clean up request nonces", "error", err)
+	}
+}
+
+// Stop stops running the scheduled jobs, waiting for the current run to finish
+func (s *Service) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	slog.Info("Scheduler stopped")
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so that leaked keys are easy to recognize
+	APIKeyPrefix = "noteo_"
+	// apiKeyBytes is the number of random bytes in an API key
+	apiKeyBytes = 24
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// APIKey authenticates a publisher in the management API, each publisher has at most one key.
+// Only a keyed hash of the key is stored, like for project tokens.
+type APIKey struct {
+	PublisherID TelegramUserID
+	// Key is the full key, only known right after it is issued
+	Key string
+	// Prefix is the visible beginning of the key, to tell it apart
+	Prefix     string
+	Hash       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time // Nil means the key has never been used
+}
+
+// APIKeyVisiblePrefix returns the beginning of the key kept to tell it apart:
+// APIKeyPrefix followed by the first random characters
+func APIKeyVisiblePrefix(key string) string {
+	if n := len(APIKeyPrefix) + TokenPrefixLength; len(key) > n {
+		return key[:n]
+	}
+	return key
+}
+
+type APIKeyRepository interface {
+	// Get returns ErrAPIKeyNotFound if the publisher has no key
+	Get(publisherID TelegramUserID) (*APIKey, error)
+	// GetByHash returns ErrAPIKeyNotFound if there is no key with the hash
+	GetByHash(hash string) (*APIKey, error)
+	// Save creates the publisher's key or replaces the existing one
+	Save(key *APIKey) error
+	Delete(publisherID TelegramUserID) error
+	UpdateLastUsed(publisherID TelegramUserID, at time.Time) error
+}
+
+type APIKeyService struct {
+	repo   APIKeyRepository
+	hasher *TokenHasher
+}
+
+func NewAPIKeyService(repo APIKeyRepository, hasher *TokenHasher) *APIKeyService {
+	return &APIKeyService{repo: repo, hasher: hasher}
+}
+
+// generateAPIKey returns a new random API key
+func generateAPIKey() (string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Get returns the publisher's key, ErrAPIKeyNotFound if they have none
+func (s *APIKeyService) Get(publisherID TelegramUserID) (*APIKey, error) {
+	key, err := s.repo.Get(publisherID)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting api key: %w", err)
+	}
+	return key, nil
+}
+
+// Issue creates a new key for the publisher, the previous one stops working immediately.
+// The returned key is the only one with the full Key, it is meant to be shown once.
+func (s *APIKeyService) Issue(publisherID TelegramUserID) (*APIKey, error) {
+	value, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("generating api key: %w", err)
+	}
+
+	key := &APIKey{
+		PublisherID: publisherID,
+		Key:         value,
+		Prefix:      APIKeyVisiblePrefix(value),
+		Hash:        s.hasher.Hash(value),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.Save(key); err != nil {
+		return nil, fmt.Errorf("saving api key: %w", err)
+	}
+	return key, nil
+}
+
+// Revoke removes the publisher's key
+func (s *APIKeyService) Revoke(publisherID TelegramUserID) error {
+	if err := s.repo.Delete(publisherID); err != nil {
+		return fmt.Errorf("deleting api key: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns the publisher owning the key and records its use.
+// It returns ErrInvalidAPIKey if there is no such key.
+func (s *APIKeyService) Authenticate(value string, now time.Time) (TelegramUserID, error) {
+	key, err := s.repo.GetByHash(s.hasher.Hash(value))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return 0, ErrInvalidAPIKey
+		}
+		return 0, fmt.Errorf("getting api key: %w", err)
+	}
+
+	if err := s.repo.UpdateLastUsed(key.PublisherID, now); err != nil {
+		return 0, fmt.Errorf("updating api key last use: %w", err)
+	}
+	return key.PublisherID, nil
+}
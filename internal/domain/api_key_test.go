@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiKeyRepositoryStub keeps the keys in memory
+type apiKeyRepositoryStub struct {
+	APIKeyRepository
+	keys map[TelegramUserID]*APIKey
+}
+
+func (r *apiKeyRepositoryStub) GetByHash(hash string) (*APIKey, error) {
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (r *apiKeyRepositoryStub) Save(key *APIKey) error {
+	r.keys[key.PublisherID] = key
+	return nil
+}
+
+func (r *apiKeyRepositoryStub) Delete(publisherID TelegramUserID) error {
+	delete(r.keys, publisherID)
+	return nil
+}
+
+func (r *apiKeyRepositoryStub) UpdateLastUsed(publisherID TelegramUserID, at time.Time) error {
+	r.keys[publisherID].LastUsedAt = &at
+	return nil
+}
+
+func TestAPIKeyService(t *testing.T) {
+	repo := &apiKeyRepositoryStub{keys: make(map[TelegramUserID]*APIKey)}
+	hasher := NewTokenHasher([]byte("secret"))
+	service := NewAPIKeyService(repo, hasher)
+	publisherID := MustNewTelegramUserID(42)
+	now := time.Now()
+
+	first, err := service.Issue(publisherID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(first.Key, APIKeyPrefix))
+	assert.Equal(t, first.Key[:len(APIKeyPrefix)+TokenPrefixLength], first.Prefix)
+	assert.Equal(t, hasher.Hash(first.Key), first.Hash)
+	assert.NotContains(t, first.Hash, first.Key)
+
+	_, err = service.Authenticate(first.Prefix, now)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	owner, err := service.Authenticate(first.Key, now)
+	require.NoError(t, err)
+	assert.Equal(t, publisherID, owner)
+	assert.Equal(t, now, *repo.keys[publisherID].LastUsedAt)
+
+	// A new key replaces the previous one
+	second, err := service.Issue(publisherID)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Key, second.Key)
+	_, err = service.Authenticate(first.Key, now)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	require.NoError(t, service.Revoke(publisherID))
+	_, err = service.Authenticate(second.Key, now)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
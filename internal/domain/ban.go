@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSubscriberBanned = errors.New("user is banned from the project")
+)
+
+// Ban prevents a user from subscribing to a project
+type Ban struct {
+	ProjectID uuid.UUID
+	UserID    TelegramUserID
+	CreatedAt time.Time
+}
+
+type BanRepository interface {
+	Create(ban *Ban) error
+	Delete(projectID uuid.UUID, userID TelegramUserID) error
+	Exists(projectID uuid.UUID, userID TelegramUserID) (bool, error)
+	GetByProject(projectID uuid.UUID) ([]*Ban, error)
+}
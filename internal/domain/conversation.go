@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+)
+
+// Conversation is the state of a multi-step interaction of a user with the bot,
+// such as naming a new project. State and Data are defined by the bot,
+// Version is the version of the Data format.
+type Conversation struct {
+	UserID    TelegramUserID
+	State     int
+	Version   int
+	Data      []byte
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+// Expired returns true if the conversation is over at the given time
+func (c *Conversation) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+type ConversationRepository interface {
+	// Get returns ErrConversationNotFound if the user has no conversation
+	Get(userID TelegramUserID) (*Conversation, error)
+	// Save creates or replaces the conversation of the user
+	Save(conversation *Conversation) error
+	Delete(userID TelegramUserID) error
+	// GetExpired returns up to limit conversations expired at the given time
+	GetExpired(now time.Time, limit int) ([]*Conversation, error)
+	// DeleteExpired removes the conversation of the user if it is expired at the given time,
+	// it returns false if there was no such conversation
+	DeleteExpired(userID TelegramUserID, now time.Time) (bool, error)
+}
+
+// ConversationService keeps the state of conversations with the bot, so that they survive restarts
+type ConversationService struct {
+	repo ConversationRepository
+}
+
+func NewConversationService(repo ConversationRepository) *ConversationService {
+	return &ConversationService{repo: repo}
+}
+
+// Get returns the current conversation of the user, ErrConversationNotFound if there is none or it has expired
+func (s *ConversationService) Get(userID TelegramUserID) (*Conversation, error) {
+	conversation, err := s.repo.Get(userID)
+	if err != nil {
+		if errors.Is(err, ErrConversationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting conversation: %w", err)
+	}
+	if conversation.Expired(time.Now()) {
+		return nil, ErrConversationNotFound
+	}
+	return conversation, nil
+}
+
+// Start saves the state of a conversation of the user, replacing the previous one
+func (s *ConversationService) Start(userID TelegramUserID, state, version int, data []byte, ttl time.Duration) error {
+	now := time.Now()
+	conversation := &Conversation{
+		UserID:    userID,
+		State:     state,
+		Version:   version,
+		Data:      data,
+		ExpiresAt: now.Add(ttl),
+		UpdatedAt: now,
+	}
+	if err := s.repo.Save(conversation); err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	return nil
+}
+
+// End removes the conversation of the user
+func (s *ConversationService) End(userID TelegramUserID) error {
+	if err := s.repo.Delete(userID); err != nil {
+		return fmt.Errorf("deleting conversation: %w", err)
+	}
+	return nil
+}
+
+// GetExpired returns up to limit conversations that are over at the given time
+func (s *ConversationService) GetExpired(now time.Time, limit int) ([]*Conversation, error) {
+	conversations, err := s.repo.GetExpired(now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting expired conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+// Expire removes a conversation that is over. It returns false if the conversation
+// has been removed or restarted in the meantime, so the user shouldn't be told it expired.
+func (s *ConversationService) Expire(conversation *Conversation, now time.Time) (bool, error) {
+	deleted, err := s.repo.DeleteExpired(conversation.UserID, now)
+	if err != nil {
+		return false, fmt.Errorf("deleting expired conversation: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conversationRepositoryStub keeps a single conversation
+type conversationRepositoryStub struct {
+	ConversationRepository
+	conversation *Conversation
+}
+
+func (r *conversationRepositoryStub) Get(_ TelegramUserID) (*Conversation, error) {
+	if r.conversation == nil {
+		return nil, ErrConversationNotFound
+	}
+	return r.conversation, nil
+}
+
+func (r *conversationRepositoryStub) Save(conversation *Conversation) error {
+	r.conversation = conversation
+	return nil
+}
+
+func TestConversationService_Get(t *testing.T) {
+	userID := MustNewTelegramUserID(42)
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		started bool
+		found   bool
+	}{
+		{"no conversation", time.Minute, false, false},
+		{"active", time.Minute, true, true},
+		{"expired", -time.Minute, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewConversationService(&conversationRepositoryStub{})
+			if tt.started {
+				require.NoError(t, service.Start(userID, 1, 1, []byte(`{}`), tt.ttl))
+			}
+
+			conversation, err := service.Get(userID)
+			if !tt.found {
+				assert.ErrorIs(t, err, ErrConversationNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, conversation.State)
+			assert.False(t, conversation.Expired(time.Now()))
+		})
+	}
+}
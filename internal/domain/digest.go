@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DigestLatestItems is the number of latest notifications shown in a digest
+	DigestLatestItems = 5
+	// DigestItemPreviewLength is the maximum length of a notification preview in a digest, in characters
+	DigestItemPreviewLength = 200
+	// DigestRetention is how long sent digests are kept for the "show all" button
+	DigestRetention = 7 * 24 * time.Hour
+)
+
+var (
+	ErrDigestNotFound    = errors.New("digest not found")
+	ErrInvalidDigestTime = errors.New("invalid digest time")
+)
+
+// DigestMode defines how often notifications of a subscription are combined into a digest
+type DigestMode string
+
+const (
+	// DigestOff delivers every notification right away
+	DigestOff    DigestMode = ""
+	Digest15Min  DigestMode = "15m"
+	DigestHourly DigestMode = "hourly"
+	DigestDaily  DigestMode = "daily"
+)
+
+// Valid returns true if the mode is known
+func (m DigestMode) Valid() bool {
+	switch m {
+	case DigestOff, Digest15Min, DigestHourly, DigestDaily:
+		return true
+	}
+	return false
+}
+
+// Next returns the time of the next digest after now. For daily digests,
+// at is the time of day in minutes since midnight in now's location.
+func (m DigestMode) Next(now time.Time, at int) time.Time {
+	switch m {
+	case Digest15Min:
+		return now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+	case DigestHourly:
+		return now.Truncate(time.Hour).Add(time.Hour)
+	case DigestDaily:
+		y, mo, d := now.Date()
+		next := time.Date(y, mo, d, at/60, at%60, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		return now
+	}
+}
+
+// Describe returns a user-facing description of the schedule, at is the time of daily digests
+func (m DigestMode) Describe(at int) string {
+	switch m {
+	case Digest15Min:
+		return "every 15 minutes"
+	case DigestHourly:
+		return "hourly"
+	case DigestDaily:
+		return fmt.Sprintf("daily at %02d:%02d", at/60, at%60)
+	default:
+		return "immediately"
+	}
+}
+
+// ParseDigestTime parses the time of daily digests like "9:00" into minutes since midnight
+func ParseDigestTime(s string) (int, error) {
+	hour, minute, err := parseClock(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidDigestTime, s)
+	}
+	return hour*60 + minute, nil
+}
+
+// Digest is a combined delivery of several notifications of a project
+type Digest struct {
+	ID        uuid.UUID
+	UserID    TelegramUserID
+	ProjectID uuid.UUID
+	Count     int
+	CreatedAt time.Time
+}
+
+// DigestItem is a notification buffered for a digest.
+// DigestID is nil until the digest containing the item is sent.
+type DigestItem struct {
+	ID        uuid.UUID
+	UserID    TelegramUserID
+	ProjectID uuid.UUID
+	DigestID  *uuid.UUID
+	Text      string
+	CreatedAt time.Time
+}
+
+type DigestRepository interface {
+	AddItem(item *DigestItem) error
+	// GetPendingItems returns the items not sent yet, oldest first
+	GetPendingItems(userID TelegramUserID, projectID uuid.UUID) ([]*DigestItem, error)
+	// Create saves the digest and assigns the items to it
+	Create(digest *Digest, itemIDs []uuid.UUID) error
+	// GetByID returns ErrDigestNotFound if there is no such digest
+	GetByID(id uuid.UUID) (*Digest, error)
+	// GetItems returns the items of the digest, oldest first
+	GetItems(digestID uuid.UUID) ([]*DigestItem, error)
+	// DeleteOlderThan removes digests created before the given time with their items,
+	// and pending items created before it whose subscription doesn't exist anymore
+	DeleteOlderThan(t time.Time) error
+}
+
+// PendingDigest is a digest ready to be sent, it is saved with CommitDigest once queued
+type PendingDigest struct {
+	// Digest is nil if there are no notifications to save, e.g. when a pause ended without held notifications
+	Digest       *Digest
+	Message      Message
+	itemIDs      []uuid.UUID
+	subscription *Subscription
+	// reschedule sets the time of the next digest to next on commit
+	reschedule bool
+	next       *time.Time
+	// endPause clears the ended pause of the subscription on commit
+	endPause bool
+}
+
+// preview shortens a notification for a digest
+func preview(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= DigestItemPreviewLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:DigestItemPreviewLength-1])) + "…"
+}
+
+// notificationsNoun returns "notification" or "notifications" depending on the count
+func notificationsNoun(count int) string {
+	if count == 1 {
+		return "notification"
+	}
+	return "notifications"
+}
+
+// hasHiddenItems returns true if a digest of the items doesn't show all of them in full
+func hasHiddenItems(items []*DigestItem) bool {
+	if len(items) > DigestLatestItems {
+		return true
+	}
+	for _, item := range items {
+		if preview(item.Text) != strings.TrimSpace(item.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatDigest creates the text of a digest from its items, oldest first
+func FormatDigest(project *Project, items []*DigestItem) string {
+	return formatItems(fmt.Sprintf("📬 %s: %d new %s", project.Name, len(items), notificationsNoun(len(items))), items)
+}
+
+// FormatPauseSummary creates the text of the message sent when a pause of the subscription ends,
+// summarizing the notifications held during the pause, oldest first.
+// If ended is true, the message also tells the user that the subscription is active again.
+func FormatPauseSummary(project *Project, items []*DigestItem, ended bool) string {
+	var b strings.Builder
+	if ended {
+		fmt.Fprintf(&b, "▶️ Notifications from %s are active again.", project.Name)
+		if len(items) == 0 {
+			return b.String()
+		}
+		b.WriteString("\n\n")
+	}
+	b.WriteString(formatItems(fmt.Sprintf("📥 %s: %d %s while paused",
+		project.Name, len(items), notificationsNoun(len(items))), items))
+	return b.String()
+}
+
+// formatItems adds previews of the latest items, newest first, to the header
+func formatItems(header string, items []*DigestItem) string {
+	var b strings.Builder
+	b.WriteString(header)
+	if len(items) > DigestLatestItems {
+		fmt.Fprintf(&b, ", latest %d:", DigestLatestItems)
+	}
+
+	latest := items[max(0, len(items)-DigestLatestItems):]
+	for i := len(latest) - 1; i >= 0; i-- {
+		b.WriteString("\n\n• ")
+		b.WriteString(preview(latest[i].Text))
+	}
+	return b.String()
+}
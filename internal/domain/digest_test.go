@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestMode_Next(t *testing.T) {
+	now := time.Date(2025, time.March, 5, 14, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		mode     DigestMode
+		at       int
+		expected time.Time
+	}{
+		{DigestOff, 0, now},
+		{Digest15Min, 0, time.Date(2025, time.March, 5, 14, 15, 0, 0, time.UTC)},
+		{DigestHourly, 0, time.Date(2025, time.March, 5, 15, 0, 0, 0, time.UTC)},
+		{DigestDaily, 18 * 60, time.Date(2025, time.March, 5, 18, 0, 0, 0, time.UTC)},
+		{DigestDaily, 9 * 60, time.Date(2025, time.March, 6, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.Describe(tt.at), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.mode.Next(now, tt.at))
+		})
+	}
+}
+
+func TestParseDigestTime(t *testing.T) {
+	at, err := ParseDigestTime(" 8:30 ")
+	require.NoError(t, err)
+	assert.Equal(t, 8*60+30, at)
+
+	for _, input := range []string{"", "24:00", "8", "morning"} {
+		_, err := ParseDigestTime(input)
+		assert.ErrorIs(t, err, ErrInvalidDigestTime, input)
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	project := &Project{Name: "Builds"}
+
+	var items []*DigestItem
+	for _, text := range []string{"one", "two", "three", "four", "five", "six", strings.Repeat("x", 300)} {
+		items = append(items, &DigestItem{Text: text})
+	}
+
+	text := FormatDigest(project, items)
+	assert.True(t, strings.HasPrefix(text, "📬 Builds: 7 new notifications, latest 5:"))
+	assert.NotContains(t, text, "• one")
+	assert.NotContains(t, text, "• two")
+	assert.Contains(t, text, strings.Repeat("x", DigestItemPreviewLength-1)+"…")
+	assert.Less(t, strings.Index(text, "six"), strings.Index(text, "three"), "latest notifications come first")
+
+	assert.Equal(t, "📬 Builds: 1 new notification\n\n• one", FormatDigest(project, items[:1]))
+}
+
+func TestFormatPauseSummary(t *testing.T) {
+	project := &Project{Name: "Builds"}
+	items := []*DigestItem{{Text: "one"}, {Text: "two"}}
+
+	assert.Equal(t, "▶️ Notifications from Builds are active again.", FormatPauseSummary(project, nil, true))
+	assert.Equal(t, "▶️ Notifications from Builds are active again.\n\n📥 Builds: 2 notifications while paused\n\n• two\n\n• one",
+		FormatPauseSummary(project, items, true))
+	assert.Equal(t, "📥 Builds: 2 notifications while paused\n\n• two\n\n• one", FormatPauseSummary(project, items, false))
+}
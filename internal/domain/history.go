@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HistoryPageSize is the number of notifications on a page of the history
+const HistoryPageSize = 10
+
+// SentNotification is a notification sent by a project, kept for the history
+type SentNotification struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Text      string
+	// Recipients is the number of subscribers the notification was delivered to
+	Recipients int
+	CreatedAt  time.Time
+}
+
+type HistoryRepository interface {
+	// Create saves a notification together with its recipients
+	Create(notification *SentNotification, recipients []TelegramUserID) error
+	// GetByRecipient returns notifications of the project delivered to the user, newest first,
+	// and their total number. If query is not empty, only notifications containing it are returned.
+	GetByRecipient(userID TelegramUserID, projectID uuid.UUID, query string, offset, limit int) ([]*SentNotification, int, error)
+	// GetByProject returns notifications sent by the project, newest first, and their total number.
+	// If query is not empty, only notifications containing it are returned.
+	GetByProject(projectID uuid.UUID, query string, offset, limit int) ([]*SentNotification, int, error)
+	// DeleteOlderThan removes notifications created before the given time with their recipients
+	DeleteOlderThan(t time.Time) error
+}
+
+// HistoryPage is a page of the notification history, pages are numbered from 0 starting with the newest
+type HistoryPage struct {
+	Notifications []*SentNotification
+	Page          int
+	Pages         int
+	Total         int
+}
+
+// HistoryService gives access to the notifications delivered to users and sent by projects
+type HistoryService struct {
+	repo HistoryRepository
+}
+
+func NewHistoryService(repo HistoryRepository) *HistoryService {
+	return &HistoryService{repo: repo}
+}
+
+// GetUserHistory returns a page of the notifications of the project delivered to the user
+func (s *HistoryService) GetUserHistory(userID TelegramUserID, projectID uuid.UUID, query string, page int) (*HistoryPage, error) {
+	return s.getPage(page, func(offset, limit int) ([]*SentNotification, int, error) {
+		return s.repo.GetByRecipient(userID, projectID, strings.TrimSpace(query), offset, limit)
+	})
+}
+
+// GetProjectLog returns a page of the notifications sent by the project
+func (s *HistoryService) GetProjectLog(projectID uuid.UUID, query string, page int) (*HistoryPage, error) {
+	return s.getPage(page, func(offset, limit int) ([]*SentNotification, int, error) {
+		return s.repo.GetByProject(projectID, strings.TrimSpace(query), offset, limit)
+	})
+}
+
+// getPage loads a page of the history, pages past the end are replaced with the last one
+func (s *HistoryService) getPage(page int, get func(offset, limit int) ([]*SentNotification, int, error)) (*HistoryPage, error) {
+	page = max(page, 0)
+	notifications, total, err := get(page*HistoryPageSize, HistoryPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("getting history: %w", err)
+	}
+
+	pages := max(1, (total+HistoryPageSize-1)/HistoryPageSize)
+	if page >= pages {
+		// The history got shorter, e.g. because of the retention
+		page = pages - 1
+		notifications, total, err = get(page*HistoryPageSize, HistoryPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("getting history: %w", err)
+		}
+	}
+
+	return &HistoryPage{
+		Notifications: notifications,
+		Page:          page,
+		Pages:         pages,
+		Total:         total,
+	}, nil
+}
+
+// Cleanup removes notifications created before the given time
+func (s *HistoryService) Cleanup(before time.Time) error {
+	if err := s.repo.DeleteOlderThan(before); err != nil {
+		return fmt.Errorf("deleting old history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyRepositoryStub serves a fixed number of project notifications
+type historyRepositoryStub struct {
+	HistoryRepository
+	total int
+}
+
+func (r *historyRepositoryStub) GetByProject(_ uuid.UUID, _ string, offset, limit int) ([]*SentNotification, int, error) {
+	var result []*SentNotification
+	for i := offset; i < min(offset+limit, r.total); i++ {
+		result = append(result, &SentNotification{Recipients: i})
+	}
+	return result, r.total, nil
+}
+
+func TestHistoryService_GetProjectLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		page     int
+		expected HistoryPage
+		first    int
+	}{
+		{"empty", 0, 0, HistoryPage{Page: 0, Pages: 1, Total: 0}, -1},
+		{"first page", 25, 0, HistoryPage{Page: 0, Pages: 3, Total: 25}, 0},
+		{"last page", 25, 2, HistoryPage{Page: 2, Pages: 3, Total: 25}, 20},
+		{"past the end", 25, 7, HistoryPage{Page: 2, Pages: 3, Total: 25}, 20},
+		{"negative", 25, -1, HistoryPage{Page: 0, Pages: 3, Total: 25}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewHistoryService(&historyRepositoryStub{total: tt.total})
+			page, err := service.GetProjectLog(uuid.New(), "", tt.page)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected.Page, page.Page)
+			assert.Equal(t, tt.expected.Pages, page.Pages)
+			assert.Equal(t, tt.expected.Total, page.Total)
+			if tt.first < 0 {
+				assert.Empty(t, page.Notifications)
+			} else {
+				require.NotEmpty(t, page.Notifications)
+				assert.Equal(t, tt.first, page.Notifications[0].Recipients)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// inviteCodeBytes is the number of random bytes in an invite code.
+// 9 bytes give a 12-character URL-safe code.
+const inviteCodeBytes = 9
+
+var (
+	ErrInviteNotFound     = errors.New("invite link not found")
+	ErrInviteExpired      = errors.New("invite link has expired")
+	ErrInviteRevoked      = errors.New("invite link has been revoked")
+	ErrInviteExhausted    = errors.New("invite link has reached its usage limit")
+	ErrLegacyLinkDisabled = errors.New("legacy project links are disabled")
+)
+
+// InviteLink is a revocable link allowing users to subscribe to a project
+type InviteLink struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Code      string
+	ExpiresAt *time.Time // Nil means the link never expires
+	MaxUses   int        // Zero means the number of uses is unlimited
+	Uses      int
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Check returns an error if the link can't be used at the given time
+func (l *InviteLink) Check(now time.Time) error {
+	switch {
+	case l.RevokedAt != nil:
+		return ErrInviteRevoked
+	case l.ExpiresAt != nil && !now.Before(*l.ExpiresAt):
+		return ErrInviteExpired
+	case l.MaxUses > 0 && l.Uses >= l.MaxUses:
+		return ErrInviteExhausted
+	default:
+		return nil
+	}
+}
+
+// Active returns true if the link can currently be used
+func (l *InviteLink) Active() bool {
+	return l.Check(time.Now()) == nil
+}
+
+type InviteLinkRepository interface {
+	Create(link *InviteLink) error
+	GetByID(id uuid.UUID) (*InviteLink, error)
+	// GetByCode returns ErrInviteNotFound if there is no link with the code
+	GetByCode(code string) (*InviteLink, error)
+	GetByProject(projectID uuid.UUID) ([]*InviteLink, error)
+	// IncrementUses returns ErrInviteExhausted if the link has no uses left
+	IncrementUses(id uuid.UUID) error
+	// DecrementUses gives back a use counted by IncrementUses
+	DecrementUses(id uuid.UUID) error
+	Revoke(id uuid.UUID, at time.Time) error
+}
+
+type InviteService struct {
+	repo     InviteLinkRepository
+	projects ProjectRepository
+}
+
+func NewInviteService(repo InviteLinkRepository, projects ProjectRepository) *InviteService {
+	return &InviteService{
+		repo:     repo,
+		projects: projects,
+	}
+}
+
+// generateInviteCode returns a short random URL-safe code
+func generateInviteCode() (string, error) {
+	b := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create creates an invite link for the project.
+// Zero ttl means the link never expires, zero maxUses means it can be used any number of times.
+func (s *InviteService) Create(projectID uuid.UUID, ttl time.Duration, maxUses int) (*InviteLink, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, fmt.Errorf("generating invite code: %w", err)
+	}
+
+	now := time.Now()
+	link := &InviteLink{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		Code:      code,
+		MaxUses:   maxUses,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.Create(link); err != nil {
+		return nil, fmt.Errorf("creating invite link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *InviteService) GetByID(id uuid.UUID) (*InviteLink, error) {
+	link, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting invite link by id: %w", err)
+	}
+	return link, nil
+}
+
+// GetActiveByProject returns the project's links that can currently be used
+func (s *InviteService) GetActiveByProject(projectID uuid.UUID) ([]*InviteLink, error) {
+	links, err := s.repo.GetByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project invite links: %w", err)
+	}
+
+	active := make([]*InviteLink, 0, len(links))
+	for _, link := range links {
+		if link.Active() {
+			active = append(active, link)
+		}
+	}
+	return active, nil
+}
+
+func (s *InviteService) Revoke(id uuid.UUID) error {
+	if err := s.repo.Revoke(id, time.Now()); err != nil {
+		return fmt.Errorf("revoking invite link: %w", err)
+	}
+	return nil
+}
+
+// Resolve finds the project a deep-link payload points to.
+// The payload is either an invite code, or a project ID for legacy links,
+// in which case the returned link is nil.
+func (s *InviteService) Resolve(payload string) (*Project, *InviteLink, error) {
+	link, err := s.repo.GetByCode(payload)
+	if err == nil {
+		if err := link.Check(time.Now()); err != nil {
+			return nil, nil, err
+		}
+
+		project, err := s.projects.GetByID(link.ProjectID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting project by id: %w", err)
+		}
+		return project, link, nil
+	}
+	if !errors.Is(err, ErrInviteNotFound) {
+		return nil, nil, fmt.Errorf("getting invite link by code: %w", err)
+	}
+
+	// Fall back to legacy links containing the project ID
+	projectID, parseErr := uuid.Parse(payload)
+	if parseErr != nil {
+		return nil, nil, ErrInviteNotFound
+	}
+
+	project, err := s.projects.GetByID(projectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting project by id: %w", err)
+	}
+	if project.LegacyLinksDisabled {
+		return nil, nil, ErrLegacyLinkDisabled
+	}
+
+	return project, nil, nil
+}
+
+// Redeem counts one use of the link before the user subscribes with it.
+// It returns ErrInviteExhausted if the link has no uses left.
+func (s *InviteService) Redeem(link *InviteLink) error {
+	if err := s.repo.IncrementUses(link.ID); err != nil {
+		return fmt.Errorf("redeeming invite link: %w", err)
+	}
+	return nil
+}
+
+// Unredeem gives back the use counted by Redeem when the user didn't subscribe after all
+func (s *InviteService) Unredeem(link *InviteLink) error {
+	if err := s.repo.DecrementUses(link.ID); err != nil {
+		return fmt.Errorf("unredeeming invite link: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteLink_Check(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		link InviteLink
+		now  time.Time
+		err  error
+	}{
+		{"unlimited", InviteLink{Uses: 100}, now, nil},
+		{"before expiry", InviteLink{ExpiresAt: &expiresAt}, now, nil},
+		{"at expiry", InviteLink{ExpiresAt: &expiresAt}, expiresAt, ErrInviteExpired},
+		{"uses left", InviteLink{MaxUses: 3, Uses: 2}, now, nil},
+		{"no uses left", InviteLink{MaxUses: 3, Uses: 3}, now, ErrInviteExhausted},
+		{"revoked", InviteLink{RevokedAt: &now}, now, ErrInviteRevoked},
+		{"revoked and expired", InviteLink{RevokedAt: &now, ExpiresAt: &expiresAt}, expiresAt, ErrInviteRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.link.Check(tt.now)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+// inviteLinkRepositoryStub keeps the invite links in memory
+type inviteLinkRepositoryStub struct {
+	InviteLinkRepository
+	links map[string]*InviteLink
+}
+
+func (r *inviteLinkRepositoryStub) GetByCode(code string) (*InviteLink, error) {
+	link, ok := r.links[code]
+	if !ok {
+		return nil, ErrInviteNotFound
+	}
+	copied := *link
+	return &copied, nil
+}
+
+func (r *inviteLinkRepositoryStub) IncrementUses(id uuid.UUID) error {
+	for _, link := range r.links {
+		if link.ID == id {
+			if link.MaxUses > 0 && link.Uses >= link.MaxUses {
+				return ErrInviteExhausted
+			}
+			link.Uses++
+		}
+	}
+	return nil
+}
+
+func (r *inviteLinkRepositoryStub) DecrementUses(id uuid.UUID) error {
+	for _, link := range r.links {
+		if link.ID == id && link.Uses > 0 {
+			link.Uses--
+		}
+	}
+	return nil
+}
+
+func TestInviteService_Resolve(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	project := &Project{ID: uuid.New()}
+	legacyDisabled := &Project{ID: uuid.New(), LegacyLinksDisabled: true}
+	projects := &publisherProjectRepositoryStub{projects: []*Project{project, legacyDisabled}}
+	repo := &inviteLinkRepositoryStub{links: map[string]*InviteLink{
+		"active":    {ID: uuid.New(), ProjectID: project.ID, Code: "active", ExpiresAt: &later, MaxUses: 2, Uses: 1},
+		"expired":   {ID: uuid.New(), ProjectID: project.ID, Code: "expired", ExpiresAt: &expired},
+		"exhausted": {ID: uuid.New(), ProjectID: project.ID, Code: "exhausted", MaxUses: 2, Uses: 2},
+		"revoked":   {ID: uuid.New(), ProjectID: project.ID, Code: "revoked", RevokedAt: &expired},
+	}}
+	service := NewInviteService(repo, projects)
+
+	tests := []struct {
+		name     string
+		payload  string
+		project  *Project
+		withLink bool
+		err      error
+	}{
+		{"invite code", "active", project, true, nil},
+		{"expired", "expired", nil, false, ErrInviteExpired},
+		{"exhausted", "exhausted", nil, false, ErrInviteExhausted},
+		{"revoked", "revoked", nil, false, ErrInviteRevoked},
+		{"unknown code", "unknown", nil, false, ErrInviteNotFound},
+		{"legacy link", project.ID.String(), project, false, nil},
+		{"disabled legacy link", legacyDisabled.ID.String(), nil, false, ErrLegacyLinkDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, link, err := service.Resolve(tt.payload)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.project.ID, project.ID)
+			assert.Equal(t, tt.withLink, link != nil)
+		})
+	}
+}
+
+func TestInviteService_Redeem(t *testing.T) {
+	link := &InviteLink{ID: uuid.New(), ProjectID: uuid.New(), Code: "code", MaxUses: 2}
+	repo := &inviteLinkRepositoryStub{links: map[string]*InviteLink{link.Code: link}}
+	service := NewInviteService(repo, nil)
+
+	require.NoError(t, service.Redeem(link))
+	require.NoError(t, service.Redeem(link))
+	assert.ErrorIs(t, service.Redeem(link), ErrInviteExhausted)
+	assert.Equal(t, 2, link.Uses)
+
+	// A use given back can be redeemed again
+	require.NoError(t, service.Unredeem(link))
+	assert.NoError(t, service.Redeem(link))
+	assert.ErrorIs(t, service.Redeem(link), ErrInviteExhausted)
+}
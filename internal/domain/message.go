@@ -1,8 +1,28 @@
 package domain
 
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMessengerUnavailable is returned by message senders when Telegram can't be reached
+	// or fails on its side, the message can be sent once it is back
+	ErrMessengerUnavailable = errors.New("telegram is unavailable")
+)
+
 // Message represents a notification message to be sent
 type Message struct {
-	UserID TelegramUserID
-	Text   string
-	Muted  bool
+	UserID    TelegramUserID
+	ProjectID uuid.UUID
+	Text      string
+	Muted     bool
+	// WithButtons attaches the snooze and unsubscribe buttons to the message
+	WithButtons bool
+	// DigestID is set if the message is a digest, it attaches the button showing all its notifications
+	DigestID uuid.UUID
+	// Language is the language chosen by the user when the message was created,
+	// empty means the language of their Telegram app. It saves looking the settings up for every message sent.
+	Language string
 }
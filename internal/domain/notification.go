@@ -0,0 +1,523 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PauseEndNoticeWindow is how long after a pause ended the user is still told about it.
+	// Pauses that ended earlier without held notifications are cleared silently.
+	PauseEndNoticeWindow = 24 * time.Hour
+	// MaxHeldReleaseAttempts is how many times releasing a held message is tried before it is dropped
+	MaxHeldReleaseAttempts = 5
+	// heldRetryDelay is the delay before releasing a held message is tried again, doubled after each failure
+	heldRetryDelay = time.Minute
+)
+
+// HeldMessage is a notification kept until the recipient's quiet hours are over,
+// or until messages can be sent again after an outage of Telegram
+type HeldMessage struct {
+	ID        uuid.UUID
+	UserID    TelegramUserID
+	ProjectID uuid.UUID
+	Text      string
+	// Silent is set if the message was to be delivered without sound
+	Silent    bool
+	ReleaseAt time.Time
+	// Attempts is the number of times releasing the message failed
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type HeldMessageRepository interface {
+	Create(message *HeldMessage) error
+	// GetDue returns up to limit messages to be released at or before the given time, oldest first
+	GetDue(now time.Time, limit int) ([]*HeldMessage, error)
+	// Retry counts a failed release of the message and moves its release to the given time
+	Retry(id uuid.UUID, releaseAt time.Time) error
+	Delete(id uuid.UUID) error
+}
+
+// NotificationService decides how a notification is delivered to each subscriber of a project
+type NotificationService struct {
+	projects      ProjectRepository
+	subscriptions SubscriptionRepository
+	settings      UserSettingsRepository
+	held          HeldMessageRepository
+	digests       DigestRepository
+	history       HistoryRepository
+}
+
+func NewNotificationService(
+	projects ProjectRepository,
+	subscriptions SubscriptionRepository,
+	settings UserSettingsRepository,
+	held HeldMessageRepository,
+	digests DigestRepository,
+	history HistoryRepository,
+) *NotificationService {
+	return &NotificationService{
+		projects:      projects,
+		subscriptions: subscriptions,
+		settings:      settings,
+		held:          held,
+		digests:       digests,
+		history:       history,
+	}
+}
+
+// userSettings returns the settings of the given users, with defaults for users who have none saved
+func (s *NotificationService) userSettings(subscriptions []*Subscription) (map[TelegramUserID]*UserSettings, error) {
+	userIDs := make([]TelegramUserID, len(subscriptions))
+	for i, sub := range subscriptions {
+		userIDs[i] = sub.UserID
+	}
+
+	saved, err := s.settings.GetByUsers(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting user settings: %w", err)
+	}
+
+	result := make(map[TelegramUserID]*UserSettings, len(userIDs))
+	for _, settings := range saved {
+		result[settings.UserID] = settings
+	}
+	for _, userID := range userIDs {
+		if _, ok := result[userID]; !ok {
+			result[userID] = NewUserSettings(userID)
+		}
+	}
+	return result, nil
+}
+
+// Dispatch returns the messages to send right away for a notification of the project,
+// and saves the notification in the history of the project and its recipients.
+// Inactive subscriptions are skipped, paused subscriptions are skipped unless they hold notifications until the pause ends,
+// subscriptions in digest mode buffer the notification, and messages to subscribers
+// in quiet hours are either sent silently or held until the quiet hours are over.
+func (s *NotificationService) Dispatch(project *Project, text string, now time.Time) ([]Message, error) {
+	subscriptions, err := s.subscriptions.GetByProject(project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project subscriptions: %w", err)
+	}
+
+	settings, err := s.userSettings(subscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	var recipients []TelegramUserID
+	for _, sub := range subscriptions {
+		if sub.Inactive {
+			continue
+		}
+		if sub.Paused() {
+			if sub.HoldWhilePaused {
+				if err := s.addToDigest(sub, text); err != nil {
+					return nil, err
+				}
+				recipients = append(recipients, sub.UserID)
+			}
+			continue
+		}
+		recipients = append(recipients, sub.UserID)
+
+		if sub.DigestMode != DigestOff {
+			if err := s.addToDigest(sub, text); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		msg := newMessage(project, sub, settings[sub.UserID], text)
+		mode, until := settings[sub.UserID].Quiet(sub, now)
+		switch mode {
+		case QuietModeSilent:
+			msg.Muted = true
+		case QuietModeHold:
+			if err := s.hold(sub, text, until); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		messages = append(messages, msg)
+	}
+
+	notification := &SentNotification{
+		ID:         uuid.New(),
+		ProjectID:  project.ID,
+		Text:       text,
+		Recipients: len(recipients),
+		CreatedAt:  now,
+	}
+	if err := s.history.Create(notification, recipients); err != nil {
+		return nil, fmt.Errorf("saving notification history: %w", err)
+	}
+
+	return messages, nil
+}
+
+// hold keeps a notification until the given time
+func (s *NotificationService) hold(sub *Subscription, text string, until time.Time) error {
+	held := &HeldMessage{
+		ID:        uuid.New(),
+		UserID:    sub.UserID,
+		ProjectID: sub.ProjectID,
+		Text:      text,
+		ReleaseAt: until,
+		CreatedAt: time.Now(),
+	}
+	if err := s.held.Create(held); err != nil {
+		return fmt.Errorf("holding message: %w", err)
+	}
+	return nil
+}
+
+// addToDigest buffers a notification for the next digest of the subscription
+func (s *NotificationService) addToDigest(sub *Subscription, text string) error {
+	item := &DigestItem{
+		ID:        uuid.New(),
+		UserID:    sub.UserID,
+		ProjectID: sub.ProjectID,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	if err := s.digests.AddItem(item); err != nil {
+		return fmt.Errorf("adding digest item: %w", err)
+	}
+	return nil
+}
+
+// GetDueDigests returns subscriptions whose next digest is due
+func (s *NotificationService) GetDueDigests(now time.Time) ([]*Subscription, error) {
+	subscriptions, err := s.subscriptions.GetDueDigests(now)
+	if err != nil {
+		return nil, fmt.Errorf("getting due digests: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// PrepareDigest creates the digest of the notifications buffered for the subscription.
+// It returns nil if there is nothing to send now, rescheduling the digest if needed.
+func (s *NotificationService) PrepareDigest(sub *Subscription, now time.Time) (*PendingDigest, error) {
+	settings, err := s.userSettings([]*Subscription{sub})
+	if err != nil {
+		return nil, err
+	}
+	userSettings := settings[sub.UserID]
+	next := s.nextDigest(sub, now.In(userSettings.Location()))
+
+	mode, until := userSettings.Quiet(sub, now)
+	if mode == QuietModeHold {
+		return nil, s.scheduleDigest(sub, &until)
+	}
+
+	items, err := s.digests.GetPendingItems(sub.UserID, sub.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting pending digest items: %w", err)
+	}
+	if len(items) == 0 || sub.Paused() {
+		return nil, s.scheduleDigest(sub, next)
+	}
+
+	project, err := s.projects.GetByID(sub.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	msg := newMessage(project, sub, userSettings, FormatDigest(project, items))
+	msg.Muted = msg.Muted || mode == QuietModeSilent
+
+	pending := newPendingDigest(sub, msg, items)
+	pending.reschedule = true
+	pending.next = next
+	return pending, nil
+}
+
+// newPendingDigest creates a pending digest of the items delivered with the message,
+// adding the "show all" button if the message doesn't show all of them in full
+func newPendingDigest(sub *Subscription, msg Message, items []*DigestItem) *PendingDigest {
+	pending := &PendingDigest{
+		Message:      msg,
+		subscription: sub,
+	}
+	if len(items) == 0 {
+		return pending
+	}
+
+	pending.Digest = &Digest{
+		ID:        uuid.New(),
+		UserID:    sub.UserID,
+		ProjectID: sub.ProjectID,
+		Count:     len(items),
+		CreatedAt: time.Now(),
+	}
+	if hasHiddenItems(items) {
+		pending.Message.DigestID = pending.Digest.ID
+	}
+
+	pending.itemIDs = make([]uuid.UUID, len(items))
+	for i, item := range items {
+		pending.itemIDs[i] = item.ID
+	}
+	return pending
+}
+
+// CommitDigest saves a digest once it has been queued and updates the schedule of the subscription
+func (s *NotificationService) CommitDigest(pending *PendingDigest) error {
+	if pending.Digest != nil {
+		if err := s.digests.Create(pending.Digest, pending.itemIDs); err != nil {
+			return fmt.Errorf("creating digest: %w", err)
+		}
+	}
+
+	if pending.endPause {
+		if err := s.clearEndedPause(pending.subscription); err != nil {
+			return err
+		}
+	}
+
+	if pending.reschedule {
+		return s.scheduleDigest(pending.subscription, pending.next)
+	}
+	return nil
+}
+
+// nextDigest returns the time of the digest following now, nil if digests are off
+func (s *NotificationService) nextDigest(sub *Subscription, now time.Time) *time.Time {
+	if sub.DigestMode == DigestOff {
+		return nil
+	}
+	next := sub.DigestMode.Next(now, sub.DigestAt)
+	return &next
+}
+
+// scheduleDigest sets the time of the next digest of the subscription
+func (s *NotificationService) scheduleDigest(sub *Subscription, next *time.Time) error {
+	// Re-read the subscription, the user might have changed it in the meantime
+	current, err := s.subscriptions.GetByUserAndProject(sub.UserID, sub.ProjectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+	if current.DigestMode != sub.DigestMode || current.DigestAt != sub.DigestAt {
+		return nil
+	}
+
+	current.NextDigestAt = next
+	if err := s.subscriptions.Update(current); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+	return nil
+}
+
+// GetEndedPauses returns subscriptions whose pause is over but hasn't been cleared yet
+func (s *NotificationService) GetEndedPauses(now time.Time) ([]*Subscription, error) {
+	subscriptions, err := s.subscriptions.GetEndedPauses(now)
+	if err != nil {
+		return nil, fmt.Errorf("getting ended pauses: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// PrepareResume creates the message sent when a pause of the subscription ends, summarizing
+// the notifications held during the pause. If the pause has ended by itself, the message also
+// tells the user that the subscription is active again, and the pause is cleared on commit.
+// It returns nil if there is nothing to send, e.g. because the subscription is still paused.
+// Subscriptions in digest mode get their held notifications with the next digest instead.
+func (s *NotificationService) PrepareResume(sub *Subscription, now time.Time) (*PendingDigest, error) {
+	if sub.Paused() {
+		return nil, nil
+	}
+	ended := sub.PausedUntil != nil
+
+	var items []*DigestItem
+	if sub.DigestMode == DigestOff {
+		var err error
+		items, err = s.digests.GetPendingItems(sub.UserID, sub.ProjectID)
+		if err != nil {
+			return nil, fmt.Errorf("getting held notifications: %w", err)
+		}
+	}
+
+	if len(items) == 0 && (!ended || now.Sub(*sub.PausedUntil) > PauseEndNoticeWindow) {
+		if !ended {
+			return nil, nil
+		}
+		// Too late to tell the user, just clear the pause
+		return nil, s.clearEndedPause(sub)
+	}
+
+	project, err := s.projects.GetByID(sub.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+
+	settings, err := s.userSettings([]*Subscription{sub})
+	if err != nil {
+		return nil, err
+	}
+
+	// The message is never held, it would only postpone the held notifications further
+	msg := newMessage(project, sub, settings[sub.UserID], FormatPauseSummary(project, items, ended))
+	if mode, _ := settings[sub.UserID].Quiet(sub, now); mode != QuietModeOff {
+		msg.Muted = true
+	}
+
+	pending := newPendingDigest(sub, msg, items)
+	pending.endPause = ended
+	return pending, nil
+}
+
+// clearEndedPause removes the pause of the subscription once it is over
+func (s *NotificationService) clearEndedPause(sub *Subscription) error {
+	// Re-read the subscription, the user might have paused it again in the meantime
+	current, err := s.subscriptions.GetByUserAndProject(sub.UserID, sub.ProjectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+	if current.PausedUntil == nil || current.Paused() {
+		return nil
+	}
+
+	current.PausedUntil = nil
+	if err := s.subscriptions.Update(current); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+	return nil
+}
+
+// GetDigest returns a sent digest, ErrDigestNotFound if it doesn't exist or has been cleaned up
+func (s *NotificationService) GetDigest(id uuid.UUID) (*Digest, error) {
+	digest, err := s.digests.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting digest: %w", err)
+	}
+	return digest, nil
+}
+
+// GetDigestItems returns the notifications of a sent digest, oldest first
+func (s *NotificationService) GetDigestItems(id uuid.UUID) ([]*DigestItem, error) {
+	items, err := s.digests.GetItems(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting digest items: %w", err)
+	}
+	return items, nil
+}
+
+// CleanupDigests removes digests that are too old to be shown in full
+func (s *NotificationService) CleanupDigests(now time.Time) error {
+	if err := s.digests.DeleteOlderThan(now.Add(-DigestRetention)); err != nil {
+		return fmt.Errorf("deleting old digests: %w", err)
+	}
+	return nil
+}
+
+// GetDueHeld returns held messages whose quiet hours are over
+func (s *NotificationService) GetDueHeld(now time.Time, limit int) ([]*HeldMessage, error) {
+	held, err := s.held.GetDue(now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting due held messages: %w", err)
+	}
+	return held, nil
+}
+
+// Release returns the message to send for a held notification.
+// It returns false if the message shouldn't be sent anymore, e.g. because the user unsubscribed,
+// or if the subscription was paused meanwhile, in which case the notification is kept for the summary
+// sent when the pause ends if the subscription holds notifications while paused.
+// The held message must be deleted with DeleteHeld once it is queued.
+func (s *NotificationService) Release(held *HeldMessage) (Message, bool, error) {
+	sub, err := s.subscriptions.GetByUserAndProject(held.UserID, held.ProjectID)
+	if err != nil {
+		exists, existsErr := s.subscriptions.Exists(held.UserID, held.ProjectID)
+		if existsErr == nil && !exists {
+			return Message{}, false, nil
+		}
+		return Message{}, false, fmt.Errorf("getting subscription: %w", err)
+	}
+	if sub.Inactive {
+		return Message{}, false, nil
+	}
+	if sub.Paused() {
+		if sub.HoldWhilePaused {
+			if err := s.addToDigest(sub, held.Text); err != nil {
+				return Message{}, false, err
+			}
+		}
+		return Message{}, false, nil
+	}
+
+	project, err := s.projects.GetByID(held.ProjectID)
+	if err != nil {
+		return Message{}, false, fmt.Errorf("getting project: %w", err)
+	}
+	settings, err := s.userSettings([]*Subscription{sub})
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	msg := newMessage(project, sub, settings[sub.UserID], held.Text)
+	msg.Muted = msg.Muted || held.Silent
+	return msg, true, nil
+}
+
+// Postpone keeps messages that can't be queued now, e.g. during an outage of Telegram.
+// They are released like the messages held during quiet hours once they can be queued again.
+func (s *NotificationService) Postpone(messages []Message, now time.Time) error {
+	for _, msg := range messages {
+		held := &HeldMessage{
+			ID:        uuid.New(),
+			UserID:    msg.UserID,
+			ProjectID: msg.ProjectID,
+			Text:      msg.Text,
+			Silent:    msg.Muted,
+			ReleaseAt: now,
+			CreatedAt: now,
+		}
+		if err := s.held.Create(held); err != nil {
+			return fmt.Errorf("postponing message: %w", err)
+		}
+	}
+	return nil
+}
+
+// RetryHeld postpones a held message that failed to be released, so that it doesn't hold up the ones due after it.
+// Each retry waits twice as long as the previous one, after MaxHeldReleaseAttempts the message is dropped
+// and false is returned.
+func (s *NotificationService) RetryHeld(held *HeldMessage, now time.Time) (bool, error) {
+	if held.Attempts+1 >= MaxHeldReleaseAttempts {
+		if err := s.held.Delete(held.ID); err != nil {
+			return false, fmt.Errorf("deleting held message: %w", err)
+		}
+		return false, nil
+	}
+
+	if err := s.held.Retry(held.ID, now.Add(heldRetryDelay<<held.Attempts)); err != nil {
+		return false, fmt.Errorf("retrying held message: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteHeld removes a held message once it has been released
+func (s *NotificationService) DeleteHeld(id uuid.UUID) error {
+	if err := s.held.Delete(id); err != nil {
+		return fmt.Errorf("deleting held message: %w", err)
+	}
+	return nil
+}
+
+// newMessage creates a message delivering a notification of the project to a subscriber
+func newMessage(project *Project, sub *Subscription, settings *UserSettings, text string) Message {
+	return Message{
+		UserID:      sub.UserID,
+		ProjectID:   project.ID,
+		Text:        text,
+		Muted:       sub.IsMuted(),
+		WithButtons: !project.NotificationButtonsDisabled,
+		Language:    settings.Language,
+	}
+}
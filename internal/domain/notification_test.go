@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessage(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		project     Project
+		sub         Subscription
+		language    string
+		muted       bool
+		withButtons bool
+	}{
+		{"buttons", Project{}, Subscription{}, "", false, true},
+		{"buttons disabled", Project{NotificationButtonsDisabled: true}, Subscription{}, "", false, false},
+		{"muted", Project{}, Subscription{Muted: true}, "", true, true},
+		{"snoozed", Project{}, Subscription{Muted: true, MutedUntil: &future}, "", true, true},
+		{"snooze over", Project{}, Subscription{Muted: true, MutedUntil: &past}, "", false, true},
+		{"chosen language", Project{}, Subscription{}, "ru", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.project.ID = uuid.New()
+			tt.sub.UserID = 42
+
+			settings := NewUserSettings(42)
+			settings.Language = tt.language
+
+			msg := newMessage(&tt.project, &tt.sub, settings, "Deployed")
+			assert.Equal(t, Message{
+				UserID:      42,
+				ProjectID:   tt.project.ID,
+				Text:        "Deployed",
+				Muted:       tt.muted,
+				WithButtons: tt.withButtons,
+				Language:    tt.language,
+			}, msg)
+		})
+	}
+}
+
+// heldMessageRepositoryStub keeps the held messages in memory
+type heldMessageRepositoryStub struct {
+	HeldMessageRepository
+	held map[uuid.UUID]*HeldMessage
+}
+
+func (r *heldMessageRepositoryStub) Retry(id uuid.UUID, releaseAt time.Time) error {
+	r.held[id].Attempts++
+	r.held[id].ReleaseAt = releaseAt
+	return nil
+}
+
+func (r *heldMessageRepositoryStub) Delete(id uuid.UUID) error {
+	delete(r.held, id)
+	return nil
+}
+
+func TestNotificationService_RetryHeld(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	held := &HeldMessage{ID: uuid.New(), ReleaseAt: now}
+	repo := &heldMessageRepositoryStub{held: map[uuid.UUID]*HeldMessage{held.ID: held}}
+	service := NewNotificationService(nil, nil, nil, repo, nil, nil)
+
+	for _, delay := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute} {
+		kept, err := service.RetryHeld(held, now)
+		require.NoError(t, err)
+		assert.True(t, kept)
+		assert.Equal(t, now.Add(delay), held.ReleaseAt)
+	}
+	assert.Equal(t, MaxHeldReleaseAttempts-1, held.Attempts)
+
+	kept, err := service.RetryHeld(held, now)
+	require.NoError(t, err)
+	assert.False(t, kept)
+	assert.Empty(t, repo.held)
+}
+
+// userSettingsRepositoryStub keeps the saved settings of users in memory
+type userSettingsRepositoryStub struct {
+	UserSettingsRepository
+	settings []*UserSettings
+}
+
+func (r *userSettingsRepositoryStub) GetByUsers(userIDs []TelegramUserID) ([]*UserSettings, error) {
+	var result []*UserSettings
+	for _, settings := range r.settings {
+		for _, userID := range userIDs {
+			if settings.UserID == userID {
+				result = append(result, settings)
+			}
+		}
+	}
+	return result, nil
+}
+
+// digestRepositoryStub collects the digest items
+type digestRepositoryStub struct {
+	DigestRepository
+	items []*DigestItem
+}
+
+func (r *digestRepositoryStub) AddItem(item *DigestItem) error {
+	r.items = append(r.items, item)
+	return nil
+}
+
+func TestNotificationService_Release(t *testing.T) {
+	pausedUntil := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		sub      Subscription
+		released bool
+		// summarized is whether the notification is kept for the summary at the end of the pause
+		summarized bool
+	}{
+		{"active", Subscription{}, true, false},
+		{"inactive", Subscription{Inactive: true}, false, false},
+		{"paused", Subscription{PausedUntil: &pausedUntil}, false, false},
+		{"paused holding notifications", Subscription{PausedUntil: &pausedUntil, HoldWhilePaused: true}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := tt.sub
+			sub.UserID = 42
+			sub.ProjectID = uuid.New()
+			digests := &digestRepositoryStub{}
+			settings := NewUserSettings(sub.UserID)
+			settings.Language = "ru"
+			service := NewNotificationService(&projectRepositoryStub{},
+				&projectSubscriptionRepositoryStub{subscriptions: []*Subscription{&sub}},
+				&userSettingsRepositoryStub{settings: []*UserSettings{settings}}, nil, digests, nil)
+
+			held := &HeldMessage{ID: uuid.New(), UserID: sub.UserID, ProjectID: sub.ProjectID, Text: "Deployed", Silent: true}
+			msg, ok, err := service.Release(held)
+			require.NoError(t, err)
+			assert.Equal(t, tt.released, ok)
+			if ok {
+				assert.Equal(t, "Deployed", msg.Text)
+				assert.True(t, msg.Muted)
+				assert.Equal(t, "ru", msg.Language)
+			}
+
+			if tt.summarized {
+				require.Len(t, digests.items, 1)
+				assert.Equal(t, "Deployed", digests.items[0].Text)
+			} else {
+				assert.Empty(t, digests.items)
+			}
+		})
+	}
+}
@@ -1,48 +1,165 @@
 package domain
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// MaxProjectNameLength is the maximum length of a project name in characters
+	MaxProjectNameLength = 64
+	// MaxProjectDescriptionLength is the maximum length of a project description in characters
+	MaxProjectDescriptionLength = 500
+)
+
+var (
+	ErrProjectNotFound           = errors.New("project not found")
+	ErrInvalidProjectName        = errors.New("invalid project name")
+	ErrProjectNameTaken          = errors.New("project name is already taken")
+	ErrInvalidProjectDescription = errors.New("invalid project description")
+)
+
 type Project struct {
 	ID          uuid.UUID
 	Name        string
-	Token       string
+	Description string
 	PublisherID TelegramUserID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// RequiresApproval makes new subscriptions wait for the publisher's approval
+	RequiresApproval bool
+	// LegacyLinksDisabled turns off subscription links containing the project ID
+	LegacyLinksDisabled bool
+	// NotificationButtonsDisabled hides the snooze and unsubscribe buttons under notifications
+	NotificationButtonsDisabled bool
+	// LastHeartbeatAt is when the service behind the project last reported it is alive, nil if it never did
+	LastHeartbeatAt *time.Time
+	// SigningSecret is the encrypted secret requests of the project can be signed with, empty if there is none
+	SigningSecret string
+	// SigningScopes are the actions requests signed with the secret allow, like the scopes of a token
+	SigningScopes []TokenScope
+	// RequireSignedRequests rejects requests authenticated with a bearer token only
+	RequireSignedRequests bool
+}
+
+// HasSigningSecret returns true if requests of the project can be signed
+func (p *Project) HasSigningSecret() bool {
+	return p.SigningSecret != ""
+}
+
+// SigningAllows returns true if requests signed with the secret of the project allow the action
+func (p *Project) SigningAllows(scope TokenScope) bool {
+	return p.HasSigningSecret() && slices.Contains(p.SigningScopes, scope)
 }
 
 type ProjectRepository interface {
+	// Create returns ErrProjectNameTaken if the publisher has a project whose name differs only in case
 	Create(project *Project) error
+	// GetByID returns ErrProjectNotFound if there is no project with the ID
 	GetByID(id uuid.UUID) (*Project, error)
-	GetByToken(token string) (*Project, error)
 	GetByPublisher(publisherID TelegramUserID) ([]*Project, error)
+	// UpdateName returns ErrProjectNameTaken like Create
 	UpdateName(id uuid.UUID, name string) error
-	UpdateToken(id uuid.UUID, token string) error
+	UpdateDescription(id uuid.UUID, description string) error
+	UpdateRequiresApproval(id uuid.UUID, requiresApproval bool) error
+	UpdateLegacyLinksDisabled(id uuid.UUID, disabled bool) error
+	UpdateNotificationButtonsDisabled(id uuid.UUID, disabled bool) error
+	UpdateLastHeartbeat(id uuid.UUID, at time.Time) error
+	// UpdateSigningSecret replaces the secret together with the actions requests signed with it allow
+	UpdateSigningSecret(id uuid.UUID, sealed string, scopes []TokenScope) error
+	UpdateSigningScopes(id uuid.UUID, scopes []TokenScope) error
+	UpdateRequireSignedRequests(id uuid.UUID, required bool) error
+	// Delete removes the project together with its subscriptions, bans, subscription requests, invite links
+	// and tokens, either all of them or none
+	Delete(id uuid.UUID) error
 }
 
 type ProjectService struct {
-	repo ProjectRepository
+	repo          ProjectRepository
+	subscriptions SubscriptionRepository
 }
 
-func NewProjectService(repo ProjectRepository) *ProjectService {
+func NewProjectService(repo ProjectRepository, subscriptions SubscriptionRepository) *ProjectService {
 	return &ProjectService{
-		repo: repo,
+		repo:          repo,
+		subscriptions: subscriptions,
+	}
+}
+
+// ValidateProjectName normalizes a project name and checks it is acceptable
+func ValidateProjectName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: must not be empty", ErrInvalidProjectName)
+	}
+	if utf8.RuneCountInString(name) > MaxProjectNameLength {
+		return "", fmt.Errorf("%w: must be at most %d characters", ErrInvalidProjectName, MaxProjectNameLength)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return "", fmt.Errorf("%w: must be a single line", ErrInvalidProjectName)
+	}
+	return name, nil
+}
+
+// ValidateProjectDescription normalizes a project description and checks it is acceptable.
+// An empty description is allowed.
+func ValidateProjectDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > MaxProjectDescriptionLength {
+		return "", fmt.Errorf("%w: must be at most %d characters", ErrInvalidProjectDescription, MaxProjectDescriptionLength)
+	}
+	return description, nil
+}
+
+// checkNameAvailable makes sure the publisher has no other project with the same name.
+// The repository enforces it as well for concurrent changes.
+func (s *ProjectService) checkNameAvailable(publisherID TelegramUserID, name string, exceptID uuid.UUID) error {
+	projects, err := s.repo.GetByPublisher(publisherID)
+	if err != nil {
+		return fmt.Errorf("getting projects by publisher: %w", err)
 	}
+	for _, p := range projects {
+		if p.ID != exceptID && strings.EqualFold(p.Name, name) {
+			return ErrProjectNameTaken
+		}
+	}
+	return nil
 }
 
 func (s *ProjectService) Create(publisherID TelegramUserID, name string) (*Project, error) {
+	return s.CreateWithDescription(publisherID, name, "")
+}
+
+// CreateWithDescription creates a project with its description at once,
+// so that an invalid description leaves no project behind
+func (s *ProjectService) CreateWithDescription(publisherID TelegramUserID, name, description string) (*Project, error) {
+	name, err := ValidateProjectName(name)
+	if err != nil {
+		return nil, err
+	}
+	description, err = ValidateProjectDescription(description)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNameAvailable(publisherID, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+
 	project := &Project{
 		ID:          uuid.New(),
 		Name:        name,
-		Token:       uuid.New().String(),
+		Description: description,
 		PublisherID: publisherID,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+		// New projects are shared with invite links only
+		LegacyLinksDisabled: true,
 	}
 
 	if err := s.repo.Create(project); err != nil {
@@ -60,14 +177,6 @@ func (s *ProjectService) GetByID(id uuid.UUID) (*Project, error) {
 	return project, nil
 }
 
-func (s *ProjectService) GetByToken(token string) (*Project, error) {
-	project, err := s.repo.GetByToken(token)
-	if err != nil {
-		return nil, fmt.Errorf("getting project by token: %w", err)
-	}
-	return project, nil
-}
-
 func (s *ProjectService) GetByPublisher(publisherID TelegramUserID) ([]*Project, error) {
 	projects, err := s.repo.GetByPublisher(publisherID)
 	if err != nil {
@@ -77,16 +186,80 @@ func (s *ProjectService) GetByPublisher(publisherID TelegramUserID) ([]*Project,
 }
 
 func (s *ProjectService) UpdateName(id uuid.UUID, name string) error {
+	name, err := ValidateProjectName(name)
+	if err != nil {
+		return err
+	}
+
+	project, err := s.repo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("getting project by id: %w", err)
+	}
+	if err := s.checkNameAvailable(project.PublisherID, name, project.ID); err != nil {
+		return err
+	}
+
 	if err := s.repo.UpdateName(id, name); err != nil {
 		return fmt.Errorf("updating project name: %w", err)
 	}
 	return nil
 }
 
-func (s *ProjectService) RegenerateToken(id uuid.UUID) (string, error) {
-	token := uuid.New().String()
-	if err := s.repo.UpdateToken(id, token); err != nil {
-		return "", fmt.Errorf("regenerating project token: %w", err)
+func (s *ProjectService) UpdateDescription(id uuid.UUID, description string) error {
+	description, err := ValidateProjectDescription(description)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateDescription(id, description); err != nil {
+		return fmt.Errorf("updating project description: %w", err)
+	}
+	return nil
+}
+
+// SetRequiresApproval turns the approval of new subscriptions on or off
+func (s *ProjectService) SetRequiresApproval(id uuid.UUID, requiresApproval bool) error {
+	if err := s.repo.UpdateRequiresApproval(id, requiresApproval); err != nil {
+		return fmt.Errorf("updating project approval mode: %w", err)
 	}
-	return token, nil
+	return nil
+}
+
+// SetLegacyLinksDisabled turns the subscription links containing the project ID off or on
+func (s *ProjectService) SetLegacyLinksDisabled(id uuid.UUID, disabled bool) error {
+	if err := s.repo.UpdateLegacyLinksDisabled(id, disabled); err != nil {
+		return fmt.Errorf("updating project legacy links: %w", err)
+	}
+	return nil
+}
+
+// SetNotificationButtonsDisabled hides or shows the buttons attached to delivered notifications
+func (s *ProjectService) SetNotificationButtonsDisabled(id uuid.UUID, disabled bool) error {
+	if err := s.repo.UpdateNotificationButtonsDisabled(id, disabled); err != nil {
+		return fmt.Errorf("updating project notification buttons: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat records that the service behind the project is alive
+func (s *ProjectService) Heartbeat(id uuid.UUID, at time.Time) error {
+	if err := s.repo.UpdateLastHeartbeat(id, at); err != nil {
+		return fmt.Errorf("updating project heartbeat: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the project together with all its subscriptions, bans, pending requests, invite links and tokens.
+// It returns the removed subscriptions so the caller can notify subscribers.
+func (s *ProjectService) Delete(id uuid.UUID) ([]*Subscription, error) {
+	subscriptions, err := s.subscriptions.GetByProject(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting project subscriptions: %w", err)
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return nil, fmt.Errorf("deleting project: %w", err)
+	}
+
+	return subscriptions, nil
 }
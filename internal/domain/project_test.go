@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateProjectName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"plain", "Deployments", "Deployments", false},
+		{"trimmed", "  CI alerts \t", "CI alerts", false},
+		{"unicode at the limit", strings.Repeat("я", MaxProjectNameLength), strings.Repeat("я", MaxProjectNameLength), false},
+		{"empty", "", "", true},
+		{"blank", "   ", "", true},
+		{"too long", strings.Repeat("a", MaxProjectNameLength+1), "", true},
+		{"multiline", "CI\nalerts", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := ValidateProjectName(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidProjectName)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func TestValidateProjectDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"plain", "Deployments of the API", "Deployments of the API", false},
+		{"multiline", " Deployments\nof the API\n", "Deployments\nof the API", false},
+		{"empty", "", "", false},
+		{"blank", " \n ", "", false},
+		{"unicode at the limit", strings.Repeat("я", MaxProjectDescriptionLength), strings.Repeat("я", MaxProjectDescriptionLength), false},
+		{"too long", strings.Repeat("a", MaxProjectDescriptionLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description, err := ValidateProjectDescription(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidProjectDescription)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, description)
+		})
+	}
+}
+
+// publisherProjectRepositoryStub keeps the projects of publishers in memory
+type publisherProjectRepositoryStub struct {
+	ProjectRepository
+	projects []*Project
+}
+
+func (r *publisherProjectRepositoryStub) Create(project *Project) error {
+	r.projects = append(r.projects, project)
+	return nil
+}
+
+func (r *publisherProjectRepositoryStub) GetByID(id uuid.UUID) (*Project, error) {
+	for _, p := range r.projects {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, ErrProjectNotFound
+}
+
+func (r *publisherProjectRepositoryStub) GetByPublisher(publisherID TelegramUserID) ([]*Project, error) {
+	var projects []*Project
+	for _, p := range r.projects {
+		if p.PublisherID == publisherID {
+			projects = append(projects, p)
+		}
+	}
+	return projects, nil
+}
+
+func (r *publisherProjectRepositoryStub) UpdateName(id uuid.UUID, name string) error {
+	project, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	project.Name = name
+	return nil
+}
+
+func TestProjectService_checkNameAvailable(t *testing.T) {
+	deployments := &Project{ID: uuid.New(), Name: "Deployments", PublisherID: 1}
+	repo := &publisherProjectRepositoryStub{projects: []*Project{
+		deployments,
+		{ID: uuid.New(), Name: "Backups", PublisherID: 2},
+	}}
+	service := NewProjectService(repo, nil)
+
+	tests := []struct {
+		name        string
+		publisherID TelegramUserID
+		projectName string
+		exceptID    uuid.UUID
+		err         error
+	}{
+		{"new name", 1, "Backups", uuid.Nil, nil},
+		{"same name", 1, "Deployments", uuid.Nil, ErrProjectNameTaken},
+		{"different case", 1, "deployments", uuid.Nil, ErrProjectNameTaken},
+		{"name of another publisher", 2, "Deployments", uuid.Nil, nil},
+		{"own name when renaming", 1, "DEPLOYMENTS", deployments.ID, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.checkNameAvailable(tt.publisherID, tt.projectName, tt.exceptID)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProjectService_UpdateName(t *testing.T) {
+	repo := &publisherProjectRepositoryStub{}
+	service := NewProjectService(repo, nil)
+
+	deployments, err := service.Create(1, " Deployments ")
+	require.NoError(t, err)
+	assert.Equal(t, "Deployments", deployments.Name)
+	backups, err := service.Create(1, "Backups")
+	require.NoError(t, err)
+
+	_, err = service.Create(1, "backups")
+	assert.ErrorIs(t, err, ErrProjectNameTaken)
+	assert.ErrorIs(t, service.UpdateName(deployments.ID, "BACKUPS"), ErrProjectNameTaken)
+	assert.ErrorIs(t, service.UpdateName(deployments.ID, ""), ErrInvalidProjectName)
+
+	require.NoError(t, service.UpdateName(backups.ID, "backups"))
+	assert.Equal(t, "backups", backups.Name)
+}
@@ -0,0 +1,418 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxTokenNameLength is the maximum length of a token name in characters
+	MaxTokenNameLength = 64
+	// MaxTokenAllowedIPs is the maximum number of addresses and networks a token can be limited to
+	MaxTokenAllowedIPs = 20
+	// DefaultTokenName is the name of the token created along with a project
+	DefaultTokenName = "Default"
+	// TokenPrefixLength is the number of leading characters of a token kept to tell it apart
+	TokenPrefixLength = 8
+)
+
+var (
+	ErrTokenNotFound      = errors.New("project token not found")
+	ErrInvalidToken       = errors.New("invalid project token")
+	ErrTokenExpired       = errors.New("project token has expired")
+	ErrTokenScope         = errors.New("project token is not allowed to do this")
+	ErrTokenSource        = errors.New("project token is not allowed from this address")
+	ErrInvalidTokenName   = errors.New("invalid token name")
+	ErrInvalidTokenScopes = errors.New("invalid token scopes")
+	ErrInvalidAllowedIPs  = errors.New("invalid allowed addresses")
+)
+
+// TokenScope is an action a project token allows
+type TokenScope string
+
+const (
+	// TokenScopeNotify allows sending notifications
+	TokenScopeNotify TokenScope = "notify"
+	// TokenScopeHeartbeat allows reporting that the service behind the project is alive
+	TokenScopeHeartbeat TokenScope = "heartbeat"
+	// TokenScopeStatus allows reading the status of the project
+	TokenScopeStatus TokenScope = "status"
+)
+
+// TokenScopes are all the scopes in the order they are shown
+var TokenScopes = []TokenScope{TokenScopeNotify, TokenScopeHeartbeat, TokenScopeStatus}
+
+// ProjectToken authenticates requests on behalf of a project, a project can have several of them
+type ProjectToken struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Name      string
+	// Token is the full token, it is only known right after the token is created or rotated
+	Token string
+	// Prefix is the beginning of the token shown to tell it apart
+	Prefix string
+	// Hash is the keyed hash of the token, tokens are looked up by it
+	Hash   string
+	Scopes []TokenScope
+	// AllowedIPs limits the addresses the token can be used from, empty means any address
+	AllowedIPs []netip.Prefix
+	ExpiresAt  *time.Time // Nil means the token never expires
+	LastUsedAt *time.Time // Nil means the token has never been used
+	CreatedAt  time.Time
+}
+
+// HasScope returns true if the token allows the action
+func (t *ProjectToken) HasScope(scope TokenScope) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Allows returns true if the token can be used from the address
+func (t *ProjectToken) Allows(addr netip.Addr) bool {
+	if len(t.AllowedIPs) == 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, prefix := range t.AllowedIPs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired returns true if the token can't be used anymore at the given time
+func (t *ProjectToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Check returns an error if the token can't be used for the action from the address at the given time
+func (t *ProjectToken) Check(scope TokenScope, addr netip.Addr, now time.Time) error {
+	switch {
+	case t.Expired(now):
+		return ErrTokenExpired
+	case !t.Allows(addr):
+		return ErrTokenSource
+	case !t.HasScope(scope):
+		return fmt.Errorf("%w: requires the %s scope", ErrTokenScope, scope)
+	default:
+		return nil
+	}
+}
+
+// ValidateTokenName normalizes a token name and checks it is acceptable
+func ValidateTokenName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: must not be empty", ErrInvalidTokenName)
+	}
+	if utf8.RuneCountInString(name) > MaxTokenNameLength {
+		return "", fmt.Errorf("%w: must be at most %d characters", ErrInvalidTokenName, MaxTokenNameLength)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return "", fmt.Errorf("%w: must be a single line", ErrInvalidTokenName)
+	}
+	return name, nil
+}
+
+// ValidateTokenScopes checks the scopes are known and returns them in the standard order without duplicates
+func ValidateTokenScopes(scopes []TokenScope) ([]TokenScope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidTokenScopes)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(TokenScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidTokenScopes, scope)
+		}
+	}
+
+	var result []TokenScope
+	for _, scope := range TokenScopes {
+		if slices.Contains(scopes, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// ParseAllowedIPs parses a list of addresses and networks in CIDR notation separated by commas or spaces.
+// Single addresses are limited to themselves, an empty list allows any address.
+func ParseAllowedIPs(s string) ([]netip.Prefix, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	})
+
+	prefixes := make([]netip.Prefix, 0, len(fields))
+	for _, field := range fields {
+		prefix, err := parseAllowedIP(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return ValidateAllowedIPs(prefixes)
+}
+
+func parseAllowedIP(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %q is not a network like 10.0.0.0/8", ErrInvalidAllowedIPs, s)
+		}
+		return prefix, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %q is not an address like 203.0.113.7", ErrInvalidAllowedIPs, s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ValidateAllowedIPs normalizes the networks a token is limited to and checks there are not too many of them
+func ValidateAllowedIPs(prefixes []netip.Prefix) ([]netip.Prefix, error) {
+	if len(prefixes) > MaxTokenAllowedIPs {
+		return nil, fmt.Errorf("%w: at most %d addresses are allowed", ErrInvalidAllowedIPs, MaxTokenAllowedIPs)
+	}
+
+	result := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if !prefix.IsValid() {
+			return nil, fmt.Errorf("%w: invalid network", ErrInvalidAllowedIPs)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked()
+		if !slices.Contains(result, prefix) {
+			result = append(result, prefix)
+		}
+	}
+	return result, nil
+}
+
+// TokenHasher computes keyed hashes of project tokens, so that tokens can't be recovered from the database
+type TokenHasher struct {
+	key []byte
+}
+
+func NewTokenHasher(key []byte) *TokenHasher {
+	return &TokenHasher{key: key}
+}
+
+// Hash returns the HMAC-SHA256 of the token as a hex string
+func (h *TokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TokenPrefix returns the visible beginning of the token
+func TokenPrefix(token string) string {
+	if len(token) <= TokenPrefixLength {
+		return token
+	}
+	return token[:TokenPrefixLength]
+}
+
+type ProjectTokenRepository interface {
+	Create(token *ProjectToken) error
+	// GetByID returns ErrTokenNotFound if there is no token with the ID
+	GetByID(id uuid.UUID) (*ProjectToken, error)
+	// GetByHash returns ErrTokenNotFound if there is no token with the hash
+	GetByHash(hash string) (*ProjectToken, error)
+	GetByProject(projectID uuid.UUID) ([]*ProjectToken, error)
+	// Update saves the name, scopes, allowed addresses and expiry of the token
+	Update(token *ProjectToken) error
+	UpdateLastUsed(id uuid.UUID, at time.Time) error
+	Delete(id uuid.UUID) error
+}
+
+// TokenOptions are the settings of a new project token
+type TokenOptions struct {
+	Name string
+	// Scopes are the allowed actions, empty means all of them
+	Scopes []TokenScope
+	// AllowedIPs limits the addresses the token can be used from, empty means any address
+	AllowedIPs []netip.Prefix
+	// TTL is how long the token can be used, zero means it never expires
+	TTL time.Duration
+}
+
+type TokenService struct {
+	repo     ProjectTokenRepository
+	projects ProjectRepository
+	hasher   *TokenHasher
+}
+
+func NewTokenService(repo ProjectTokenRepository, projects ProjectRepository, hasher *TokenHasher) *TokenService {
+	return &TokenService{
+		repo:     repo,
+		projects: projects,
+		hasher:   hasher,
+	}
+}
+
+// validate normalizes the settings of the token and checks they are acceptable
+func (s *TokenService) validate(token *ProjectToken) error {
+	name, err := ValidateTokenName(token.Name)
+	if err != nil {
+		return err
+	}
+	scopes, err := ValidateTokenScopes(token.Scopes)
+	if err != nil {
+		return err
+	}
+	allowedIPs, err := ValidateAllowedIPs(token.AllowedIPs)
+	if err != nil {
+		return err
+	}
+
+	token.Name = name
+	token.Scopes = scopes
+	token.AllowedIPs = allowedIPs
+	return nil
+}
+
+// Create creates a token for the project. Only its hash is stored,
+// so the returned token is the only place where the full token can be seen.
+func (s *TokenService) Create(projectID uuid.UUID, options TokenOptions) (*ProjectToken, error) {
+	now := time.Now()
+	value := uuid.New().String()
+	token := &ProjectToken{
+		ID:         uuid.New(),
+		ProjectID:  projectID,
+		Name:       options.Name,
+		Token:      value,
+		Prefix:     TokenPrefix(value),
+		Hash:       s.hasher.Hash(value),
+		Scopes:     options.Scopes,
+		AllowedIPs: options.AllowedIPs,
+		CreatedAt:  now,
+	}
+	if len(token.Scopes) == 0 {
+		token.Scopes = TokenScopes
+	}
+	if options.TTL > 0 {
+		expiresAt := now.Add(options.TTL)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.validate(token); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(token); err != nil {
+		return nil, fmt.Errorf("creating project token: %w", err)
+	}
+	return token, nil
+}
+
+// GetByID returns the token, ErrTokenNotFound if there is none with the ID
+func (s *TokenService) GetByID(id uuid.UUID) (*ProjectToken, error) {
+	token, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting project token by id: %w", err)
+	}
+	return token, nil
+}
+
+// GetByProject returns all tokens of the project including the expired ones
+func (s *TokenService) GetByProject(projectID uuid.UUID) ([]*ProjectToken, error) {
+	tokens, err := s.repo.GetByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Update saves the changed name, scopes, allowed addresses and expiry of the token
+func (s *TokenService) Update(token *ProjectToken) error {
+	if err := s.validate(token); err != nil {
+		return err
+	}
+	if err := s.repo.Update(token); err != nil {
+		return fmt.Errorf("updating project token: %w", err)
+	}
+	return nil
+}
+
+// Rotate replaces the token with a new one having the same settings and lifetime.
+// The old token keeps working during the grace period, so that clients can be updated one by one,
+// zero grace revokes it immediately.
+func (s *TokenService) Rotate(id uuid.UUID, grace time.Duration, now time.Time) (*ProjectToken, error) {
+	old, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if old.ExpiresAt != nil {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+	token, err := s.Create(old.ProjectID, TokenOptions{
+		Name:       old.Name,
+		Scopes:     old.Scopes,
+		AllowedIPs: old.AllowedIPs,
+		TTL:        ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if grace <= 0 {
+		if err := s.Revoke(old.ID); err != nil {
+			return nil, err
+		}
+		return token, nil
+	}
+	graceEnd := now.Add(grace)
+	if old.ExpiresAt == nil || graceEnd.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &graceEnd
+		if err := s.repo.Update(old); err != nil {
+			return nil, fmt.Errorf("ending rotated project token: %w", err)
+		}
+	}
+	return token, nil
+}
+
+// Revoke removes the token, it stops working immediately
+func (s *TokenService) Revoke(id uuid.UUID) error {
+	if err := s.repo.Delete(id); err != nil {
+		return fmt.Errorf("deleting project token: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns the project of the token if it allows the action from the address, and records its use.
+// It returns ErrInvalidToken if there is no such token, and the error of ProjectToken.Check if it can't be used.
+func (s *TokenService) Authenticate(value string, scope TokenScope, addr netip.Addr, now time.Time) (*Project, error) {
+	token, err := s.repo.GetByHash(s.hasher.Hash(value))
+	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("getting project token: %w", err)
+	}
+	if err := token.Check(scope, addr, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateLastUsed(token.ID, now); err != nil {
+		return nil, fmt.Errorf("updating project token last use: %w", err)
+	}
+
+	project, err := s.projects.GetByID(token.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project of token: %w", err)
+	}
+	return project, nil
+}
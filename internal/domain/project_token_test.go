@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectToken_Check(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+	token := &ProjectToken{
+		Scopes:     []TokenScope{TokenScopeNotify},
+		AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("203.0.113.7/32")},
+		ExpiresAt:  &expiresAt,
+	}
+
+	tests := []struct {
+		name  string
+		scope TokenScope
+		addr  string
+		now   time.Time
+		err   error
+	}{
+		{"allowed network", TokenScopeNotify, "10.1.2.3", now, nil},
+		{"allowed address", TokenScopeNotify, "203.0.113.7", now, nil},
+		{"mapped address", TokenScopeNotify, "::ffff:10.1.2.3", now, nil},
+		{"other address", TokenScopeNotify, "203.0.113.8", now, ErrTokenSource},
+		{"missing scope", TokenScopeStatus, "10.1.2.3", now, ErrTokenScope},
+		{"expired", TokenScopeNotify, "10.1.2.3", expiresAt, ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := token.Check(tt.scope, netip.MustParseAddr(tt.addr), tt.now)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseAllowedIPs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		wantErr  bool
+	}{
+		{"", nil, false},
+		{"203.0.113.7", []string{"203.0.113.7/32"}, false},
+		{"10.0.0.0/8, 2001:db8::/32 192.168.1.1", []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1/32"}, false},
+		{"10.1.2.3/8", []string{"10.0.0.0/8"}, false},
+		{"example.com", nil, true},
+		{"10.0.0.0/33", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prefixes, err := ParseAllowedIPs(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAllowedIPs)
+				return
+			}
+			require.NoError(t, err)
+
+			var actual []string
+			for _, prefix := range prefixes {
+				actual = append(actual, prefix.String())
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// projectTokenRepositoryStub keeps the tokens in memory
+type projectTokenRepositoryStub struct {
+	ProjectTokenRepository
+	tokens map[uuid.UUID]*ProjectToken
+}
+
+func (r *projectTokenRepositoryStub) Create(token *ProjectToken) error {
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *projectTokenRepositoryStub) GetByID(id uuid.UUID) (*ProjectToken, error) {
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *projectTokenRepositoryStub) GetByHash(hash string) (*ProjectToken, error) {
+	for _, token := range r.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (r *projectTokenRepositoryStub) UpdateLastUsed(id uuid.UUID, at time.Time) error {
+	r.tokens[id].LastUsedAt = &at
+	return nil
+}
+
+func (r *projectTokenRepositoryStub) Update(token *ProjectToken) error {
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *projectTokenRepositoryStub) Delete(id uuid.UUID) error {
+	delete(r.tokens, id)
+	return nil
+}
+
+// projectRepositoryStub returns any project asked for
+type projectRepositoryStub struct {
+	ProjectRepository
+}
+
+func (r *projectRepositoryStub) GetByID(id uuid.UUID) (*Project, error) {
+	return &Project{ID: id}, nil
+}
+
+func TestTokenService_Authenticate(t *testing.T) {
+	repo := &projectTokenRepositoryStub{tokens: make(map[uuid.UUID]*ProjectToken)}
+	hasher := NewTokenHasher([]byte("secret"))
+	service := NewTokenService(repo, &projectRepositoryStub{}, hasher)
+	addr := netip.MustParseAddr("203.0.113.7")
+	now := time.Now()
+
+	token, err := service.Create(uuid.New(), TokenOptions{Name: DefaultTokenName})
+	require.NoError(t, err)
+	assert.Equal(t, token.Token[:TokenPrefixLength], token.Prefix)
+	assert.Equal(t, hasher.Hash(token.Token), token.Hash)
+	assert.NotContains(t, token.Hash, token.Token)
+
+	project, err := service.Authenticate(token.Token, TokenScopeNotify, addr, now)
+	require.NoError(t, err)
+	assert.Equal(t, token.ProjectID, project.ID)
+	assert.Equal(t, now, *repo.tokens[token.ID].LastUsedAt)
+
+	// The same token hashed with another key is unknown
+	other := NewTokenService(repo, &projectRepositoryStub{}, NewTokenHasher([]byte("other")))
+	_, err = other.Authenticate(token.Token, TokenScopeNotify, addr, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = service.Authenticate(token.Prefix, TokenScopeNotify, addr, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokenService_Rotate(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// grace is how long the old token keeps working, expiresIn is how long it actually does
+		grace     time.Duration
+		expiresIn time.Duration
+		revoked   bool
+	}{
+		{"immediately", 0, 0, 0, true},
+		{"grace period", 0, time.Hour, time.Hour, false},
+		{"grace period longer than lifetime", 30 * time.Minute, time.Hour, 30 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &projectTokenRepositoryStub{tokens: make(map[uuid.UUID]*ProjectToken)}
+			service := NewTokenService(repo, nil, NewTokenHasher([]byte("secret")))
+
+			old, err := service.Create(uuid.New(), TokenOptions{
+				Name:   "CI",
+				Scopes: []TokenScope{TokenScopeHeartbeat},
+				TTL:    tt.ttl,
+			})
+			require.NoError(t, err)
+
+			rotated, err := service.Rotate(old.ID, tt.grace, now)
+			require.NoError(t, err)
+			assert.NotEqual(t, old.Token, rotated.Token)
+			assert.Equal(t, old.Name, rotated.Name)
+			assert.Equal(t, old.Scopes, rotated.Scopes)
+			assert.Equal(t, tt.ttl != 0, rotated.ExpiresAt != nil)
+
+			kept, err := service.GetByID(old.ID)
+			if tt.revoked {
+				assert.ErrorIs(t, err, ErrTokenNotFound)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, kept.ExpiresAt)
+			assert.WithinDuration(t, now.Add(tt.expiresIn), *kept.ExpiresAt, time.Second)
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package domain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureWindow is how far the timestamp of a signed request can be from the server time
+	SignatureWindow = 5 * time.Minute
+	// NonceRetention is how long nonces are remembered: a request can arrive up to the window
+	// before its timestamp and be replayed up to the window after it
+	NonceRetention = 2 * SignatureWindow
+	// signingSecretBytes is the number of random bytes in a signing secret
+	signingSecretBytes = 32
+)
+
+var (
+	ErrInvalidSignature  = errors.New("invalid request signature")
+	ErrSignatureExpired  = errors.New("request timestamp is too far from the server time")
+	ErrNoSigningSecret   = errors.New("project has no signing secret")
+	ErrSignatureRequired = errors.New("project requires signed requests")
+	ErrSignatureScope    = errors.New("signing secret is not allowed to do this")
+	ErrRequestReplayed   = errors.New("request has already been received")
+)
+
+// SignedRequest is the part of an HTTP request covered by its signature
+type SignedRequest struct {
+	Method    string
+	Path      string
+	Timestamp time.Time
+	// Nonce is a unique value chosen by the client, so that the same request can't be replayed
+	Nonce string
+	Body  []byte
+}
+
+// StringToSign returns the text signed by the client: the method, path, unix timestamp, nonce
+// and hex SHA-256 of the body, each on its own line
+func (r SignedRequest) StringToSign() string {
+	bodyHash := sha256.Sum256(r.Body)
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.Path,
+		strconv.FormatInt(r.Timestamp.Unix(), 10),
+		r.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignRequest returns the hex HMAC-SHA256 of the request with the secret
+func SignRequest(secret string, r SignedRequest) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.StringToSign()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SecretBox encrypts secrets that have to be read back, like the signing secrets of projects
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a box encrypting with AES-GCM, the encryption key is derived from the given key
+func NewSecretBox(key []byte) *SecretBox {
+	derived := sha256.Sum256(append([]byte("noteo secret box\n"), key...))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		panic(fmt.Sprintf("creating secret box cipher: %v", err))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("creating secret box: %v", err))
+	}
+	return &SecretBox{aead: aead}
+}
+
+// Seal encrypts the secret, the result is safe to store
+func (b *SecretBox) Seal(secret string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	return base64.RawStdEncoding.EncodeToString(b.aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// Open decrypts a secret encrypted by Seal
+func (b *SecretBox) Open(sealed string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", errors.New("malformed sealed secret")
+	}
+	secret, err := b.aead.Open(nil, data[:b.aead.NonceSize()], data[b.aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// NonceRepository remembers the nonces of signed requests. They are stored rather than kept in memory,
+// so that a captured request can't be sent again after a restart.
+type NonceRepository interface {
+	// Use records the nonce of the project, returning false if it is already recorded
+	Use(projectID uuid.UUID, nonce string, at time.Time) (bool, error)
+	// DeleteOlderThan forgets the nonces recorded before the given time
+	DeleteOlderThan(t time.Time) error
+}
+
+// SigningService manages the secrets projects sign their requests with
+type SigningService struct {
+	projects ProjectRepository
+	nonces   NonceRepository
+	box      *SecretBox
+}
+
+func NewSigningService(projects ProjectRepository, nonces NonceRepository, box *SecretBox) *SigningService {
+	return &SigningService{
+		projects: projects,
+		nonces:   nonces,
+		box:      box,
+	}
+}
+
+// Generate creates a new signing secret for the project, replacing the previous one.
+// The new secret allows the same actions as the previous one, or every action if there was none.
+// Only the encrypted secret is stored, the returned one is meant to be shown once.
+func (s *SigningService) Generate(projectID uuid.UUID) (string, error) {
+	project, err := s.projects.GetByID(projectID)
+	if err != nil {
+		return "", fmt.Errorf("getting project: %w", err)
+	}
+	scopes := project.SigningScopes
+	if !project.HasSigningSecret() || len(scopes) == 0 {
+		scopes = TokenScopes
+	}
+
+	b := make([]byte, signingSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating signing secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return "", fmt.Errorf("encrypting signing secret: %w", err)
+	}
+	if err := s.projects.UpdateSigningSecret(projectID, sealed, scopes); err != nil {
+		return "", fmt.Errorf("updating signing secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Remove deletes the signing secret of the project, which then accepts bearer tokens again
+func (s *SigningService) Remove(projectID uuid.UUID) error {
+	if err := s.projects.UpdateRequireSignedRequests(projectID, false); err != nil {
+		return fmt.Errorf("updating signed requests requirement: %w", err)
+	}
+	if err := s.projects.UpdateSigningSecret(projectID, "", nil); err != nil {
+		return fmt.Errorf("removing signing secret: %w", err)
+	}
+	return nil
+}
+
+// SetRequired makes the project accept signed requests only, which needs a signing secret
+func (s *SigningService) SetRequired(project *Project, required bool) error {
+	if required && !project.HasSigningSecret() {
+		return ErrNoSigningSecret
+	}
+	if err := s.projects.UpdateRequireSignedRequests(project.ID, required); err != nil {
+		return fmt.Errorf("updating signed requests requirement: %w", err)
+	}
+	return nil
+}
+
+// SetScopes changes the actions requests signed with the secret of the project allow
+func (s *SigningService) SetScopes(project *Project, scopes []TokenScope) error {
+	if !project.HasSigningSecret() {
+		return ErrNoSigningSecret
+	}
+	scopes, err := ValidateTokenScopes(scopes)
+	if err != nil {
+		return err
+	}
+	if err := s.projects.UpdateSigningScopes(project.ID, scopes); err != nil {
+		return fmt.Errorf("updating signing scopes: %w", err)
+	}
+	return nil
+}
+
+// Verify returns the project if the signature of the request matches its secret, the request is recent,
+// the secret allows the action and the nonce hasn't been used. It returns ErrInvalidSignature for unknown projects,
+// projects without a secret or with a secret that can't be decrypted and wrong signatures, ErrSignatureExpired
+// for requests outside the SignatureWindow, ErrSignatureScope if the secret doesn't allow the action
+// and ErrRequestReplayed if the nonce has been used.
+func (s *SigningService) Verify(projectID uuid.UUID, r SignedRequest, signature string, scope TokenScope, now time.Time) (*Project, error) {
+	if d := now.Sub(r.Timestamp); d > SignatureWindow || d < -SignatureWindow {
+		return nil, ErrSignatureExpired
+	}
+
+	project, err := s.projects.GetByID(projectID)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, ErrInvalidSignature
+		}
+		return nil, fmt.Errorf("getting project: %w", err)
+	}
+	if !project.HasSigningSecret() {
+		return nil, ErrInvalidSignature
+	}
+
+	secret, err := s.box.Open(project.SigningSecret)
+	if err != nil {
+		// The secret can't be used anymore, e.g. after the token secret has changed, so the request is unauthorized
+		return nil, fmt.Errorf("%w: decrypting signing secret: %v", ErrInvalidSignature, err)
+	}
+	expected := SignRequest(secret, r)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, ErrInvalidSignature
+	}
+	// The scope is checked last, so that only the holder of the secret learns what it allows
+	if !project.SigningAllows(scope) {
+		return nil, ErrSignatureScope
+	}
+
+	// Nonces are only recorded for valid signatures, so that they can't be used up by others
+	fresh, err := s.nonces.Use(project.ID, r.Nonce, now)
+	if err != nil {
+		return nil, fmt.Errorf("recording nonce: %w", err)
+	}
+	if !fresh {
+		return nil, ErrRequestReplayed
+	}
+	return project, nil
+}
+
+// CleanupNonces forgets the nonces of requests too old to be accepted anyway
+func (s *SigningService) CleanupNonces(now time.Time) error {
+	if err := s.nonces.DeleteOlderThan(now.Add(-NonceRetention)); err != nil {
+		return fmt.Errorf("deleting old nonces: %w", err)
+	}
+	return nil
+}
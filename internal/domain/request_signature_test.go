@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signingProjectRepositoryStub keeps a single project in memory
+type signingProjectRepositoryStub struct {
+	ProjectRepository
+	project *Project
+}
+
+func (r *signingProjectRepositoryStub) GetByID(id uuid.UUID) (*Project, error) {
+	if id != r.project.ID {
+		return nil, ErrProjectNotFound
+	}
+	copied := *r.project
+	return &copied, nil
+}
+
+func (r *signingProjectRepositoryStub) UpdateSigningSecret(id uuid.UUID, sealed string, scopes []TokenScope) error {
+	r.project.SigningSecret = sealed
+	r.project.SigningScopes = scopes
+	return nil
+}
+
+func (r *signingProjectRepositoryStub) UpdateSigningScopes(id uuid.UUID, scopes []TokenScope) error {
+	r.project.SigningScopes = scopes
+	return nil
+}
+
+func (r *signingProjectRepositoryStub) UpdateRequireSignedRequests(id uuid.UUID, required bool) error {
+	r.project.RequireSignedRequests = required
+	return nil
+}
+
+// nonceRepositoryStub keeps the nonces in memory
+type nonceRepositoryStub struct {
+	used map[string]time.Time
+}
+
+func (r *nonceRepositoryStub) Use(projectID uuid.UUID, nonce string, at time.Time) (bool, error) {
+	if _, ok := r.used[projectID.String()+nonce]; ok {
+		return false, nil
+	}
+	r.used[projectID.String()+nonce] = at
+	return true, nil
+}
+
+func (r *nonceRepositoryStub) DeleteOlderThan(t time.Time) error {
+	for key, at := range r.used {
+		if at.Before(t) {
+			delete(r.used, key)
+		}
+	}
+	return nil
+}
+
+func TestSecretBox(t *testing.T) {
+	box := NewSecretBox([]byte("key"))
+
+	sealed, err := box.Seal("secret")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "secret")
+
+	opened, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", opened)
+
+	_, err = NewSecretBox([]byte("other key")).Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestSigningService_Verify(t *testing.T) {
+	repo := &signingProjectRepositoryStub{project: &Project{ID: uuid.New()}}
+	nonces := &nonceRepositoryStub{used: make(map[string]time.Time)}
+	service := NewSigningService(repo, nonces, NewSecretBox([]byte("key")))
+	now := time.Unix(1741631400, 0)
+
+	// Projects without a secret can't require signed requests nor be signed for
+	assert.ErrorIs(t, service.SetRequired(repo.project, true), ErrNoSigningSecret)
+	assert.ErrorIs(t, service.SetScopes(repo.project, []TokenScope{TokenScopeNotify}), ErrNoSigningSecret)
+
+	// A new secret allows every action
+	secret, err := service.Generate(repo.project.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, repo.project.SigningSecret)
+	assert.Equal(t, TokenScopes, repo.project.SigningScopes)
+	require.NoError(t, service.SetScopes(repo.project, []TokenScope{TokenScopeNotify}))
+	assert.ErrorIs(t, service.SetScopes(repo.project, nil), ErrInvalidTokenScopes)
+
+	request := SignedRequest{
+		Method:    "POST",
+		Path:      "/api/notify",
+		Timestamp: now,
+		Nonce:     "8f14e45f",
+		Body:      []byte(`{"body":"Deployed"}`),
+	}
+	signature := SignRequest(secret, request)
+
+	tests := []struct {
+		name      string
+		projectID uuid.UUID
+		modify    func(r *SignedRequest)
+		signature string
+		scope     TokenScope
+		now       time.Time
+		err       error
+	}{
+		{"valid", repo.project.ID, nil, signature, TokenScopeNotify, now, nil},
+		{"late but within window", repo.project.ID, nil, signature, TokenScopeNotify, now.Add(SignatureWindow), nil},
+		{"too old", repo.project.ID, nil, signature, TokenScopeNotify, now.Add(SignatureWindow + time.Second), ErrSignatureExpired},
+		{"from the future", repo.project.ID, nil, signature, TokenScopeNotify, now.Add(-SignatureWindow - time.Second), ErrSignatureExpired},
+		{"other project", uuid.New(), nil, signature, TokenScopeNotify, now, ErrInvalidSignature},
+		{"wrong signature", repo.project.ID, nil, SignRequest("other", request), TokenScopeNotify, now, ErrInvalidSignature},
+		{"changed body", repo.project.ID, func(r *SignedRequest) { r.Body = []byte(`{"body":"Hacked"}`) }, signature, TokenScopeNotify, now, ErrInvalidSignature},
+		{"changed path", repo.project.ID, func(r *SignedRequest) { r.Path = "/api/heartbeat" }, signature, TokenScopeNotify, now, ErrInvalidSignature},
+		{"changed nonce", repo.project.ID, func(r *SignedRequest) { r.Nonce = "c9f0f895" }, signature, TokenScopeNotify, now, ErrInvalidSignature},
+		{"action not allowed", repo.project.ID, nil, signature, TokenScopeStatus, now, ErrSignatureScope},
+		{"wrong signature for action not allowed", repo.project.ID, nil, SignRequest("other", request), TokenScopeStatus, now, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := request
+			if tt.modify != nil {
+				tt.modify(&r)
+			}
+			// Every case is a new request, replays are checked below
+			clear(nonces.used)
+			project, err := service.Verify(tt.projectID, r, tt.signature, tt.scope, tt.now)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, repo.project.ID, project.ID)
+		})
+	}
+
+	// A nonce is accepted once, and forgotten when requests using it are too old anyway
+	clear(nonces.used)
+	_, err = service.Verify(repo.project.ID, request, signature, TokenScopeNotify, now)
+	require.NoError(t, err)
+	_, err = service.Verify(repo.project.ID, request, signature, TokenScopeNotify, now.Add(time.Second))
+	assert.ErrorIs(t, err, ErrRequestReplayed)
+	require.NoError(t, service.CleanupNonces(now.Add(NonceRetention)))
+	assert.Len(t, nonces.used, 1)
+	require.NoError(t, service.CleanupNonces(now.Add(NonceRetention+time.Second)))
+	assert.Empty(t, nonces.used)
+
+	// A secret replacing another one allows the same actions
+	_, err = service.Generate(repo.project.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []TokenScope{TokenScopeNotify}, repo.project.SigningScopes)
+
+	// Removing the secret stops signed requests and the requirement
+	require.NoError(t, service.SetRequired(repo.project, true))
+	require.NoError(t, service.Remove(repo.project.ID))
+	assert.False(t, repo.project.RequireSignedRequests)
+	_, err = service.Verify(repo.project.ID, request, signature, TokenScopeNotify, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// Secrets encrypted with another key, e.g. before the token secret changed, are unauthorized
+	secret, err = service.Generate(repo.project.ID)
+	require.NoError(t, err)
+	other := NewSigningService(repo, nonces, NewSecretBox([]byte("other key")))
+	_, err = other.Verify(repo.project.ID, request, SignRequest(secret, request), TokenScopeNotify, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
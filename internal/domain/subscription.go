@@ -1,12 +1,20 @@
 package domain
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// SubscribersPageSize is the number of subscribers on a page of a project's subscriber list
+const SubscribersPageSize = 10
+
+var (
+	ErrAlreadySubscribed = errors.New("subscription already exists")
+)
+
 type Subscription struct {
 	ID          uuid.UUID
 	UserID      TelegramUserID
@@ -14,7 +22,24 @@ type Subscription struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Muted       bool       // Boolean flag for muted status
-	PausedUntil *time.Time // Time until notifications are paused
+	MutedUntil  *time.Time // Time until notifications are muted, nil means muted indefinitely
+	PausedUntil *time.Time // Time until notifications are paused, kept after the pause ends until the user is told
+	// HoldWhilePaused keeps notifications sent during a pause and delivers a summary when it ends
+	HoldWhilePaused bool
+	QuietMode       QuietMode // Overrides the user's quiet hours behavior, empty means no override
+	// DigestMode combines notifications into periodic digests instead of delivering them right away
+	DigestMode DigestMode
+	// DigestAt is the time of day of daily digests in minutes since midnight in the user's timezone
+	DigestAt int
+	// NextDigestAt is when the next digest is due, nil if no digest is scheduled
+	NextDigestAt *time.Time
+	// Inactive is set while the user can't receive messages, e.g. because they blocked the bot
+	Inactive bool
+}
+
+// IsMuted returns true if the subscription is currently muted
+func (s *Subscription) IsMuted() bool {
+	return s.Muted && (s.MutedUntil == nil || time.Now().Before(*s.MutedUntil))
 }
 
 // Paused returns true if the subscription is currently paused
@@ -29,17 +54,55 @@ type SubscriptionRepository interface {
 	GetByUser(userID TelegramUserID) ([]*Subscription, error)
 	Update(subscription *Subscription) error
 	GetByUserAndProject(userID TelegramUserID, projectID uuid.UUID) (*Subscription, error)
+	Exists(userID TelegramUserID, projectID uuid.UUID) (bool, error)
+	// GetDueDigests returns subscriptions whose next digest is due at or before the given time
+	GetDueDigests(now time.Time) ([]*Subscription, error)
+	// GetEndedPauses returns subscriptions paused until the given time or earlier
+	GetEndedPauses(now time.Time) ([]*Subscription, error)
+	// SetInactive changes whether all subscriptions of the user are inactive
+	SetInactive(userID TelegramUserID, inactive bool) error
 }
 
 type SubscriptionService struct {
-	repo SubscriptionRepository
+	repo     SubscriptionRepository
+	bans     BanRepository
+	requests SubscriptionRequestRepository
 }
 
-func NewSubscriptionService(repo SubscriptionRepository) *SubscriptionService {
-	return &SubscriptionService{repo: repo}
+func NewSubscriptionService(
+	repo SubscriptionRepository,
+	bans BanRepository,
+	requests SubscriptionRequestRepository,
+) *SubscriptionService {
+	return &SubscriptionService{repo: repo, bans: bans, requests: requests}
+}
+
+// checkCanSubscribe makes sure the user is neither banned nor already subscribed
+func (s *SubscriptionService) checkCanSubscribe(userID TelegramUserID, projectID uuid.UUID) error {
+	banned, err := s.bans.Exists(projectID, userID)
+	if err != nil {
+		return fmt.Errorf("checking ban: %w", err)
+	}
+	if banned {
+		return ErrSubscriberBanned
+	}
+
+	exists, err := s.repo.Exists(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("checking subscription: %w", err)
+	}
+	if exists {
+		return ErrAlreadySubscribed
+	}
+
+	return nil
 }
 
 func (s *SubscriptionService) Subscribe(userID TelegramUserID, projectID uuid.UUID) error {
+	if err := s.checkCanSubscribe(userID, projectID); err != nil {
+		return err
+	}
+
 	subscription := &Subscription{
 		ID:        uuid.New(),
 		UserID:    userID,
@@ -70,6 +133,25 @@ func (s *SubscriptionService) MuteNotifications(userID TelegramUserID, projectID
 	}
 
 	subscription.Muted = true
+	subscription.MutedUntil = nil
+	subscription.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// MuteNotificationsUntil mutes the subscription until the given time
+func (s *SubscriptionService) MuteNotificationsUntil(userID TelegramUserID, projectID uuid.UUID, until time.Time) error {
+	subscription, err := s.repo.GetByUserAndProject(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+
+	subscription.Muted = true
+	subscription.MutedUntil = &until
 	subscription.UpdatedAt = time.Now()
 
 	if err := s.repo.Update(subscription); err != nil {
@@ -86,6 +168,7 @@ func (s *SubscriptionService) UnmuteNotifications(userID TelegramUserID, project
 	}
 
 	subscription.Muted = false
+	subscription.MutedUntil = nil
 	subscription.UpdatedAt = time.Now()
 
 	if err := s.repo.Update(subscription); err != nil {
@@ -127,6 +210,88 @@ func (s *SubscriptionService) ResumeNotifications(userID TelegramUserID, project
 	return nil
 }
 
+// SetQuietMode overrides how quiet hours apply to the subscription
+func (s *SubscriptionService) SetQuietMode(userID TelegramUserID, projectID uuid.UUID, mode QuietMode) error {
+	switch mode {
+	case QuietModeDefault, QuietModeOff, QuietModeSilent, QuietModeHold:
+	default:
+		return fmt.Errorf("invalid quiet mode: %q", mode)
+	}
+
+	subscription, err := s.repo.GetByUserAndProject(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+
+	subscription.QuietMode = mode
+	subscription.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// SetHoldWhilePaused changes whether notifications are kept during pauses of the subscription
+func (s *SubscriptionService) SetHoldWhilePaused(userID TelegramUserID, projectID uuid.UUID, hold bool) error {
+	subscription, err := s.repo.GetByUserAndProject(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+
+	subscription.HoldWhilePaused = hold
+	subscription.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// SetDigest changes how often notifications of the subscription are combined into a digest.
+// The time of daily digests is interpreted in now's location. Turning digests off
+// schedules one last digest right away, so that no buffered notifications are lost.
+func (s *SubscriptionService) SetDigest(userID TelegramUserID, projectID uuid.UUID, mode DigestMode, at int, now time.Time) error {
+	if !mode.Valid() || at < 0 || at >= 24*60 {
+		return fmt.Errorf("invalid digest schedule: %q at %d", mode, at)
+	}
+
+	subscription, err := s.repo.GetByUserAndProject(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("getting subscription: %w", err)
+	}
+
+	next := mode.Next(now, at)
+	subscription.DigestMode = mode
+	subscription.DigestAt = at
+	subscription.NextDigestAt = &next
+	subscription.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(subscription); err != nil {
+		return fmt.Errorf("updating subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) GetSubscription(userID TelegramUserID, projectID uuid.UUID) (*Subscription, error) {
+	subscription, err := s.repo.GetByUserAndProject(userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+func (s *SubscriptionService) IsSubscribed(userID TelegramUserID, projectID uuid.UUID) (bool, error) {
+	exists, err := s.repo.Exists(userID, projectID)
+	if err != nil {
+		return false, fmt.Errorf("checking subscription: %w", err)
+	}
+	return exists, nil
+}
+
 func (s *SubscriptionService) GetProjectSubscriptions(projectID uuid.UUID) ([]*Subscription, error) {
 	subscriptions, err := s.repo.GetByProject(projectID)
 	if err != nil {
@@ -135,6 +300,37 @@ func (s *SubscriptionService) GetProjectSubscriptions(projectID uuid.UUID) ([]*S
 	return subscriptions, nil
 }
 
+// SubscribersPage is a page of a project's subscribers, pages are numbered from 0 starting with the oldest
+type SubscribersPage struct {
+	Subscriptions []*Subscription
+	Page          int
+	Pages         int
+	// Offset is the position of the first subscription of the page in the whole list
+	Offset int
+	Total  int
+}
+
+// GetSubscribersPage returns a page of the project's subscriptions, pages past the end are replaced with the last one
+func (s *SubscriptionService) GetSubscribersPage(projectID uuid.UUID, page int) (*SubscribersPage, error) {
+	subscriptions, err := s.GetProjectSubscriptions(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := max(1, (len(subscriptions)+SubscribersPageSize-1)/SubscribersPageSize)
+	page = min(max(page, 0), pages-1)
+	start := page * SubscribersPageSize
+	end := min(start+SubscribersPageSize, len(subscriptions))
+
+	return &SubscribersPage{
+		Subscriptions: subscriptions[start:end],
+		Page:          page,
+		Pages:         pages,
+		Offset:        start,
+		Total:         len(subscriptions),
+	}, nil
+}
+
 func (s *SubscriptionService) GetUserSubscriptions(userID TelegramUserID) ([]*Subscription, error) {
 	subscriptions, err := s.repo.GetByUser(userID)
 	if err != nil {
@@ -142,3 +338,107 @@ func (s *SubscriptionService) GetUserSubscriptions(userID TelegramUserID) ([]*Su
 	}
 	return subscriptions, nil
 }
+
+// BanSubscriber removes the user's subscription and prevents them from subscribing again
+func (s *SubscriptionService) BanSubscriber(projectID uuid.UUID, userID TelegramUserID) error {
+	if err := s.bans.Create(&Ban{
+		ProjectID: projectID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("creating ban: %w", err)
+	}
+
+	if err := s.repo.Delete(userID, projectID); err != nil {
+		return fmt.Errorf("deleting subscription: %w", err)
+	}
+
+	return nil
+}
+
+// UnbanSubscriber allows a previously banned user to subscribe again
+func (s *SubscriptionService) UnbanSubscriber(projectID uuid.UUID, userID TelegramUserID) error {
+	if err := s.bans.Delete(projectID, userID); err != nil {
+		return fmt.Errorf("deleting ban: %w", err)
+	}
+	return nil
+}
+
+func (s *SubscriptionService) GetProjectBans(projectID uuid.UUID) ([]*Ban, error) {
+	bans, err := s.bans.GetByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting project bans: %w", err)
+	}
+	return bans, nil
+}
+
+// RequestSubscription creates a pending request to subscribe to a project that requires approval
+func (s *SubscriptionService) RequestSubscription(userID TelegramUserID, projectID uuid.UUID) (*SubscriptionRequest, error) {
+	if err := s.checkCanSubscribe(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.requests.GetByUserAndProject(userID, projectID); err == nil {
+		return existing, ErrRequestPending
+	}
+
+	request := &SubscriptionRequest{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ProjectID: projectID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.requests.Create(request); err != nil {
+		return nil, fmt.Errorf("creating subscription request: %w", err)
+	}
+
+	return request, nil
+}
+
+func (s *SubscriptionService) GetRequest(id uuid.UUID) (*SubscriptionRequest, error) {
+	request, err := s.requests.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting subscription request: %w", err)
+	}
+	return request, nil
+}
+
+// ApproveRequest turns a pending request into an active subscription.
+// If the user was banned after asking, the request is discarded and ErrSubscriberBanned is returned with it.
+func (s *SubscriptionService) ApproveRequest(id uuid.UUID) (*SubscriptionRequest, error) {
+	request, err := s.GetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Subscribe(request.UserID, request.ProjectID); err != nil && !errors.Is(err, ErrAlreadySubscribed) {
+		if !errors.Is(err, ErrSubscriberBanned) {
+			return nil, err
+		}
+		if err := s.requests.Delete(id); err != nil {
+			return nil, fmt.Errorf("deleting subscription request of banned user: %w", err)
+		}
+		return request, err
+	}
+
+	if err := s.requests.Delete(id); err != nil {
+		return nil, fmt.Errorf("deleting subscription request: %w", err)
+	}
+
+	return request, nil
+}
+
+// RejectRequest discards a pending request
+func (s *SubscriptionService) RejectRequest(id uuid.UUID) (*SubscriptionRequest, error) {
+	request, err := s.GetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requests.Delete(id); err != nil {
+		return nil, fmt.Errorf("deleting subscription request: %w", err)
+	}
+
+	return request, nil
+}
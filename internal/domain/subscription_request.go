@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRequestPending = errors.New("subscription request is already pending")
+)
+
+// SubscriptionRequest is a pending request to subscribe to a project that requires approval
+type SubscriptionRequest struct {
+	ID        uuid.UUID
+	UserID    TelegramUserID
+	ProjectID uuid.UUID
+	CreatedAt time.Time
+}
+
+type SubscriptionRequestRepository interface {
+	Create(request *SubscriptionRequest) error
+	GetByID(id uuid.UUID) (*SubscriptionRequest, error)
+	GetByUserAndProject(userID TelegramUserID, projectID uuid.UUID) (*SubscriptionRequest, error)
+	Delete(id uuid.UUID) error
+}
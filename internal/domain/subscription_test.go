@@ -0,0 +1,315 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscriptionKey identifies a subscription or a ban in the stubs
+type subscriptionKey struct {
+	userID    TelegramUserID
+	projectID uuid.UUID
+}
+
+// projectSubscriptionRepositoryStub keeps the subscriptions in memory in the order they were created
+type projectSubscriptionRepositoryStub struct {
+	SubscriptionRepository
+	subscriptions []*Subscription
+}
+
+func (r *projectSubscriptionRepositoryStub) Create(subscription *Subscription) error {
+	r.subscriptions = append(r.subscriptions, subscription)
+	return nil
+}
+
+func (r *projectSubscriptionRepositoryStub) Delete(userID TelegramUserID, projectID uuid.UUID) error {
+	for i, s := range r.subscriptions {
+		if s.UserID == userID && s.ProjectID == projectID {
+			r.subscriptions = append(r.subscriptions[:i], r.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *projectSubscriptionRepositoryStub) Exists(userID TelegramUserID, projectID uuid.UUID) (bool, error) {
+	for _, s := range r.subscriptions {
+		if s.UserID == userID && s.ProjectID == projectID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *projectSubscriptionRepositoryStub) GetByUserAndProject(userID TelegramUserID, projectID uuid.UUID) (*Subscription, error) {
+	for _, s := range r.subscriptions {
+		if s.UserID == userID && s.ProjectID == projectID {
+			return s, nil
+		}
+	}
+	return nil, errors.New("subscription not found")
+}
+
+func (r *projectSubscriptionRepositoryStub) GetByProject(projectID uuid.UUID) ([]*Subscription, error) {
+	var subscriptions []*Subscription
+	for _, s := range r.subscriptions {
+		if s.ProjectID == projectID {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	return subscriptions, nil
+}
+
+// banRepositoryStub keeps the bans in memory
+type banRepositoryStub struct {
+	BanRepository
+	bans map[subscriptionKey]bool
+}
+
+func (r *banRepositoryStub) Create(ban *Ban) error {
+	r.bans[subscriptionKey{ban.UserID, ban.ProjectID}] = true
+	return nil
+}
+
+func (r *banRepositoryStub) Delete(projectID uuid.UUID, userID TelegramUserID) error {
+	delete(r.bans, subscriptionKey{userID, projectID})
+	return nil
+}
+
+func (r *banRepositoryStub) Exists(projectID uuid.UUID, userID TelegramUserID) (bool, error) {
+	return r.bans[subscriptionKey{userID, projectID}], nil
+}
+
+func TestSubscriptionService_Subscribe(t *testing.T) {
+	projectID := uuid.New()
+	otherProjectID := uuid.New()
+
+	tests := []struct {
+		name      string
+		banned    []subscriptionKey
+		existing  []subscriptionKey
+		userID    TelegramUserID
+		projectID uuid.UUID
+		err       error
+	}{
+		{"new subscriber", nil, nil, 1, projectID, nil},
+		{"already subscribed", nil, []subscriptionKey{{1, projectID}}, 1, projectID, ErrAlreadySubscribed},
+		{"banned", []subscriptionKey{{1, projectID}}, nil, 1, projectID, ErrSubscriberBanned},
+		{"banned and subscribed", []subscriptionKey{{1, projectID}}, []subscriptionKey{{1, projectID}}, 1, projectID, ErrSubscriberBanned},
+		{"banned from another project", []subscriptionKey{{1, otherProjectID}}, nil, 1, projectID, nil},
+		{"another user banned", []subscriptionKey{{2, projectID}}, nil, 1, projectID, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &projectSubscriptionRepositoryStub{}
+			for _, key := range tt.existing {
+				repo.subscriptions = append(repo.subscriptions, &Subscription{UserID: key.userID, ProjectID: key.projectID})
+			}
+			bans := &banRepositoryStub{bans: make(map[subscriptionKey]bool)}
+			for _, key := range tt.banned {
+				bans.bans[key] = true
+			}
+			service := NewSubscriptionService(repo, bans, nil)
+
+			err := service.Subscribe(tt.userID, tt.projectID)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Len(t, repo.subscriptions, len(tt.existing))
+				return
+			}
+			require.NoError(t, err)
+			subscribed, err := service.IsSubscribed(tt.userID, tt.projectID)
+			require.NoError(t, err)
+			assert.True(t, subscribed)
+		})
+	}
+}
+
+func TestSubscriptionService_BanSubscriber(t *testing.T) {
+	repo := &projectSubscriptionRepositoryStub{}
+	bans := &banRepositoryStub{bans: make(map[subscriptionKey]bool)}
+	service := NewSubscriptionService(repo, bans, nil)
+	projectID := uuid.New()
+
+	require.NoError(t, service.Subscribe(1, projectID))
+	require.NoError(t, service.BanSubscriber(projectID, 1))
+
+	subscribed, err := service.IsSubscribed(1, projectID)
+	require.NoError(t, err)
+	assert.False(t, subscribed)
+	assert.ErrorIs(t, service.Subscribe(1, projectID), ErrSubscriberBanned)
+
+	require.NoError(t, service.UnbanSubscriber(projectID, 1))
+	assert.NoError(t, service.Subscribe(1, projectID))
+}
+
+func TestSubscriptionService_GetSubscribersPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		page     int
+		expected SubscribersPage
+		size     int
+	}{
+		{"empty", 0, 0, SubscribersPage{Page: 0, Pages: 1, Offset: 0, Total: 0}, 0},
+		{"single page", 7, 0, SubscribersPage{Page: 0, Pages: 1, Offset: 0, Total: 7}, 7},
+		{"full pages", 20, 1, SubscribersPage{Page: 1, Pages: 2, Offset: 10, Total: 20}, 10},
+		{"last page", 25, 2, SubscribersPage{Page: 2, Pages: 3, Offset: 20, Total: 25}, 5},
+		{"past the end", 25, 7, SubscribersPage{Page: 2, Pages: 3, Offset: 20, Total: 25}, 5},
+		{"negative", 25, -1, SubscribersPage{Page: 0, Pages: 3, Offset: 0, Total: 25}, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := uuid.New()
+			repo := &projectSubscriptionRepositoryStub{}
+			for i := 0; i < tt.total; i++ {
+				repo.subscriptions = append(repo.subscriptions, &Subscription{UserID: TelegramUserID(i), ProjectID: projectID})
+			}
+			// Subscribers of other projects are not listed
+			repo.subscriptions = append(repo.subscriptions, &Subscription{UserID: 1, ProjectID: uuid.New()})
+			service := NewSubscriptionService(repo, nil, nil)
+
+			page, err := service.GetSubscribersPage(projectID, tt.page)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected.Page, page.Page)
+			assert.Equal(t, tt.expected.Pages, page.Pages)
+			assert.Equal(t, tt.expected.Offset, page.Offset)
+			assert.Equal(t, tt.expected.Total, page.Total)
+			require.Len(t, page.Subscriptions, tt.size)
+			if tt.size > 0 {
+				assert.Equal(t, TelegramUserID(tt.expected.Offset), page.Subscriptions[0].UserID)
+			}
+		})
+	}
+}
+
+// subscriptionRequestRepositoryStub keeps the pending requests in memory
+type subscriptionRequestRepositoryStub struct {
+	SubscriptionRequestRepository
+	requests map[uuid.UUID]*SubscriptionRequest
+}
+
+func (r *subscriptionRequestRepositoryStub) Create(request *SubscriptionRequest) error {
+	r.requests[request.ID] = request
+	return nil
+}
+
+func (r *subscriptionRequestRepositoryStub) GetByID(id uuid.UUID) (*SubscriptionRequest, error) {
+	request, ok := r.requests[id]
+	if !ok {
+		return nil, errors.New("request not found")
+	}
+	return request, nil
+}
+
+func (r *subscriptionRequestRepositoryStub) GetByUserAndProject(userID TelegramUserID, projectID uuid.UUID) (*SubscriptionRequest, error) {
+	for _, request := range r.requests {
+		if request.UserID == userID && request.ProjectID == projectID {
+			return request, nil
+		}
+	}
+	return nil, errors.New("request not found")
+}
+
+func (r *subscriptionRequestRepositoryStub) Delete(id uuid.UUID) error {
+	delete(r.requests, id)
+	return nil
+}
+
+func TestSubscriptionService_RequestSubscription(t *testing.T) {
+	projectID := uuid.New()
+	repo := &projectSubscriptionRepositoryStub{}
+	bans := &banRepositoryStub{bans: map[subscriptionKey]bool{{2, projectID}: true}}
+	requests := &subscriptionRequestRepositoryStub{requests: make(map[uuid.UUID]*SubscriptionRequest)}
+	service := NewSubscriptionService(repo, bans, requests)
+
+	request, err := service.RequestSubscription(1, projectID)
+	require.NoError(t, err)
+
+	again, err := service.RequestSubscription(1, projectID)
+	assert.ErrorIs(t, err, ErrRequestPending)
+	assert.Equal(t, request.ID, again.ID)
+
+	_, err = service.RequestSubscription(2, projectID)
+	assert.ErrorIs(t, err, ErrSubscriberBanned)
+
+	require.NoError(t, service.Subscribe(3, projectID))
+	_, err = service.RequestSubscription(3, projectID)
+	assert.ErrorIs(t, err, ErrAlreadySubscribed)
+
+	assert.Len(t, requests.requests, 1)
+}
+
+func TestSubscriptionService_ResolveRequest(t *testing.T) {
+	projectID := uuid.New()
+
+	tests := []struct {
+		name       string
+		approve    bool
+		subscribed bool
+		banned     bool
+		err        error
+		// kept is whether the request stays pending
+		kept       bool
+		subscribes bool
+	}{
+		{"approve", true, false, false, nil, false, true},
+		{"approve when already subscribed", true, true, false, nil, false, true},
+		{"approve banned user", true, false, true, ErrSubscriberBanned, false, false},
+		{"reject", false, false, false, nil, false, false},
+		{"reject banned user", false, false, true, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &projectSubscriptionRepositoryStub{}
+			bans := &banRepositoryStub{bans: make(map[subscriptionKey]bool)}
+			requests := &subscriptionRequestRepositoryStub{requests: make(map[uuid.UUID]*SubscriptionRequest)}
+			service := NewSubscriptionService(repo, bans, requests)
+
+			request, err := service.RequestSubscription(1, projectID)
+			require.NoError(t, err)
+			if tt.subscribed {
+				require.NoError(t, service.Subscribe(1, projectID))
+			}
+			if tt.banned {
+				bans.bans[subscriptionKey{1, projectID}] = true
+			}
+
+			var resolved *SubscriptionRequest
+			if tt.approve {
+				resolved, err = service.ApproveRequest(request.ID)
+			} else {
+				resolved, err = service.RejectRequest(request.ID)
+			}
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, resolved)
+			assert.Equal(t, request.ID, resolved.ID)
+
+			_, err = service.GetRequest(request.ID)
+			assert.Equal(t, tt.kept, err == nil)
+			subscribed, err := service.IsSubscribed(1, projectID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.subscribes, subscribed)
+		})
+	}
+
+	// Requests resolved by another publisher action are gone
+	service := NewSubscriptionService(&projectSubscriptionRepositoryStub{}, nil,
+		&subscriptionRequestRepositoryStub{requests: make(map[uuid.UUID]*SubscriptionRequest)})
+	_, err := service.ApproveRequest(uuid.New())
+	assert.Error(t, err)
+	_, err = service.RejectRequest(uuid.New())
+	assert.Error(t, err)
+}
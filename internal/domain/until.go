@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxUntilAhead limits how far in the future a pause or mute may last
+const MaxUntilAhead = 366 * 24 * time.Hour
+
+var (
+	ErrInvalidUntil = errors.New("invalid time")
+)
+
+var (
+	// Durations like "45m", "3 h", "2 days"
+	relativeRx = regexp.MustCompile(`^(\d+)\s*(m|min|mins|minutes?|h|hrs?|hours?|d|days?|w|weeks?)$`)
+	// Clock time like "9:00" or "18.30"
+	clockRx = regexp.MustCompile(`^(\d{1,2})[:.](\d{2})$`)
+)
+
+// dateLayouts are the accepted explicit date formats
+var dateLayouts = []string{
+	"2006-01-02",
+	"02.01.2006",
+	"2.1.2006",
+}
+
+// EndOfDay returns the midnight following now in now's location
+func EndOfDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+// NextMonday returns the start of the next Monday in now's location
+func NextMonday(now time.Time) time.Time {
+	days := (int(time.Monday) - int(now.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, now.Location())
+}
+
+// ParseUntil parses a user-entered point in time relative to now.
+// Times are interpreted in now's location. Supported forms are:
+//
+//	45m, 3h, 1h30m, 2d, 1w          - a duration from now
+//	18:30                           - the next occurrence of the clock time
+//	today 18:30, tomorrow, tomorrow 9:00
+//	monday, friday 17:00            - the next occurrence of the weekday
+//	2025-03-10, 10.03.2025 14:00    - an explicit date with an optional time
+func ParseUntil(input string, now time.Time) (time.Time, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if s == "" {
+		return time.Time{}, fmt.Errorf("%w: empty input", ErrInvalidUntil)
+	}
+
+	until, err := parseUntil(s, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !until.After(now) {
+		return time.Time{}, fmt.Errorf("%w: must be in the future", ErrInvalidUntil)
+	}
+	if until.Sub(now) > MaxUntilAhead {
+		return time.Time{}, fmt.Errorf("%w: must be within a year", ErrInvalidUntil)
+	}
+	return until, nil
+}
+
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	// Relative durations
+	if m := relativeRx.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %s", ErrInvalidUntil, s)
+		}
+		var unit time.Duration
+		switch m[2][0] {
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+
+	// A day followed by an optional clock time
+	day, clock, _ := strings.Cut(s, " ")
+	hour, minute := 0, 0
+	if clock != "" {
+		var err error
+		if hour, minute, err = parseClock(clock); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	y, mo, d := now.Date()
+	loc := now.Location()
+
+	switch day {
+	case "today":
+		return time.Date(y, mo, d, hour, minute, 0, 0, loc), nil
+	case "tomorrow":
+		return time.Date(y, mo, d+1, hour, minute, 0, 0, loc), nil
+	}
+
+	// A bare clock time means its next occurrence
+	if clock == "" && clockRx.MatchString(day) {
+		hour, minute, err := parseClock(day)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := time.Date(y, mo, d, hour, minute, 0, 0, loc)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	if weekday, ok := parseWeekday(day); ok {
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		t := time.Date(y, mo, d+days, hour, minute, 0, 0, loc)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 7)
+		}
+		return t, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, day, loc); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %s", ErrInvalidUntil, s)
+}
+
+// parseClock parses a clock time like "9:00" into hours and minutes
+func parseClock(s string) (int, int, error) {
+	m := clockRx.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("%w: bad clock time %s", ErrInvalidUntil, s)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("%w: bad clock time %s", ErrInvalidUntil, s)
+	}
+	return hour, minute, nil
+}
+
+// parseWeekday parses a full or three-letter English weekday name
+func parseWeekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, true
+		}
+	}
+	return 0, false
+}
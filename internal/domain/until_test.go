@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUntil(t *testing.T) {
+	// Wednesday
+	now := time.Date(2025, time.March, 5, 14, 20, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"45m", now.Add(45 * time.Minute)},
+		{"3h", now.Add(3 * time.Hour)},
+		{"3 hours", now.Add(3 * time.Hour)},
+		{"1h30m", now.Add(90 * time.Minute)},
+		{"2d", now.Add(48 * time.Hour)},
+		{"1w", now.Add(7 * 24 * time.Hour)},
+		{"18:30", time.Date(2025, time.March, 5, 18, 30, 0, 0, time.UTC)},
+		{"9:00", time.Date(2025, time.March, 6, 9, 0, 0, 0, time.UTC)},
+		{"today 23:00", time.Date(2025, time.March, 5, 23, 0, 0, 0, time.UTC)},
+		{"tomorrow", time.Date(2025, time.March, 6, 0, 0, 0, 0, time.UTC)},
+		{"Tomorrow 9:00", time.Date(2025, time.March, 6, 9, 0, 0, 0, time.UTC)},
+		{"friday 17:00", time.Date(2025, time.March, 7, 17, 0, 0, 0, time.UTC)},
+		{"wed", time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC)},
+		{"2025-03-10", time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"10.03.2025 14:00", time.Date(2025, time.March, 10, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			until, err := ParseUntil(tt.input, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, until)
+		})
+	}
+}
+
+func TestParseUntil_Invalid(t *testing.T) {
+	now := time.Date(2025, time.March, 5, 14, 20, 0, 0, time.UTC)
+
+	for _, input := range []string{
+		"",
+		"soon",
+		"25:00",
+		"today 10:00",
+		"2025-03-01",
+		"2027-01-01",
+		"-5m",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseUntil(input, now)
+			assert.ErrorIs(t, err, ErrInvalidUntil)
+		})
+	}
+}
+
+func TestPresets(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2025, time.March, 10, 22, 15, 0, 0, loc) // Monday
+
+	assert.Equal(t, time.Date(2025, time.March, 11, 0, 0, 0, 0, loc), EndOfDay(now))
+	assert.Equal(t, time.Date(2025, time.March, 17, 0, 0, 0, 0, loc), NextMonday(now))
+}
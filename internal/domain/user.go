@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUndeliverable is returned by message senders when the recipient can't receive messages anymore,
+	// retrying the message is pointless
+	ErrUndeliverable = errors.New("recipient can't receive messages")
+)
+
+// UserSeenInterval is how often the last interaction of a user is saved,
+// more frequent interactions only update a changed profile
+const UserSeenInterval = time.Hour
+
+// UserState tells whether the bot can reach a user
+type UserState string
+
+const (
+	// UserStateActive is the state of users who can receive messages from the bot
+	UserStateActive UserState = "active"
+	// UserStateBlocked is the state of users who blocked the bot
+	UserStateBlocked UserState = "blocked"
+	// UserStateDeactivated is the state of users who deleted their Telegram account
+	UserStateDeactivated UserState = "deactivated"
+	// UserStateUnreachable is the state of users whose chat with the bot is gone for another reason
+	UserStateUnreachable UserState = "unreachable"
+)
+
+// UndeliverableError tells why a message can't be delivered to a user, it matches ErrUndeliverable
+type UndeliverableError struct {
+	UserID TelegramUserID
+	// State is the state the user is put in
+	State UserState
+	// Reason is the error reported by Telegram
+	Reason string
+}
+
+func (e *UndeliverableError) Error() string {
+	return fmt.Sprintf("user %s is %s: %s", e.UserID, e.State, e.Reason)
+}
+
+func (e *UndeliverableError) Is(target error) bool {
+	return target == ErrUndeliverable
+}
+
+// User is a Telegram user who has interacted with the bot. The profile is updated
+// from Telegram on every interaction, preferences like the timezone are kept in UserSettings.
+type User struct {
+	ID        TelegramUserID
+	FirstName string
+	LastName  string
+	Username  string
+	// LanguageCode is the language of the user's Telegram app, like "en" or "pt-br"
+	LanguageCode string
+	State        UserState
+	FirstSeenAt  time.Time
+	LastSeenAt   time.Time
+	UpdatedAt    time.Time
+}
+
+// DisplayName returns a human-readable name of the user, like "John Smith (@jsmith)"
+func (u *User) DisplayName() string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	switch {
+	case name == "" && u.Username == "":
+		return fmt.Sprintf("User %s", u.ID)
+	case name == "":
+		return "@" + u.Username
+	case u.Username != "":
+		return name + " (@" + u.Username + ")"
+	default:
+		return name
+	}
+}
+
+// Outdated returns true if the saved user has to be updated after an interaction at the given time,
+// profile is their current profile in Telegram
+func (u *User) Outdated(profile *User, now time.Time) bool {
+	return u.FirstName != profile.FirstName ||
+		u.LastName != profile.LastName ||
+		u.Username != profile.Username ||
+		u.LanguageCode != profile.LanguageCode ||
+		u.State != UserStateActive ||
+		now.Sub(u.LastSeenAt) >= UserSeenInterval
+}
+
+type UserRepository interface {
+	// Get returns ErrUserNotFound if the user has never interacted with the bot
+	Get(id TelegramUserID) (*User, error)
+	// Save creates or replaces the user
+	Save(user *User) error
+}
+
+// UserService keeps the profiles of the users of the bot
+type UserService struct {
+	repo          UserRepository
+	subscriptions SubscriptionRepository
+}
+
+func NewUserService(repo UserRepository, subscriptions SubscriptionRepository) *UserService {
+	return &UserService{repo: repo, subscriptions: subscriptions}
+}
+
+// Get returns the user, ErrUserNotFound if they have never interacted with the bot
+func (s *UserService) Get(id TelegramUserID) (*User, error) {
+	user, err := s.repo.Get(id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	return user, nil
+}
+
+// Seen records an interaction of a user with the bot, profile holds their current profile in Telegram.
+// The user is created on the first interaction. A user who couldn't receive messages, e.g. because
+// they blocked the bot, becomes active again along with their subscriptions. It returns the saved user.
+func (s *UserService) Seen(profile *User) (*User, error) {
+	now := time.Now()
+	user, err := s.repo.Get(profile.ID)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		user = &User{ID: profile.ID}
+	case err != nil:
+		return nil, fmt.Errorf("getting user: %w", err)
+	case !user.Outdated(profile, now):
+		return user, nil
+	}
+
+	if user.State != UserStateActive && user.State != "" {
+		if err := s.subscriptions.SetInactive(user.ID, false); err != nil {
+			return nil, fmt.Errorf("reactivating subscriptions: %w", err)
+		}
+	}
+
+	user.FirstName = profile.FirstName
+	user.LastName = profile.LastName
+	user.Username = profile.Username
+	user.LanguageCode = profile.LanguageCode
+	user.State = UserStateActive
+	if user.FirstSeenAt.IsZero() {
+		user.FirstSeenAt = now
+	}
+	user.LastSeenAt = now
+	user.UpdatedAt = now
+	if err := s.repo.Save(user); err != nil {
+		return nil, fmt.Errorf("saving user: %w", err)
+	}
+	return user, nil
+}
+
+// Unreachable records that the user can't receive messages, e.g. because they blocked the bot.
+// Their subscriptions become inactive until the user is seen again.
+func (s *UserService) Unreachable(id TelegramUserID, state UserState) error {
+	user, err := s.repo.Get(id)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		// The user hasn't interacted with the bot since users are saved
+		user = &User{ID: id}
+	case err != nil:
+		return fmt.Errorf("getting user: %w", err)
+	}
+
+	if err := s.subscriptions.SetInactive(id, true); err != nil {
+		return fmt.Errorf("deactivating subscriptions: %w", err)
+	}
+
+	user.State = state
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Save(user); err != nil {
+		return fmt.Errorf("saving user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxQuietWindows is the maximum number of quiet-hour windows a user can have
+const MaxQuietWindows = 10
+
+var (
+	ErrUserSettingsNotFound = errors.New("user settings not found")
+	ErrInvalidTimezone      = errors.New("invalid timezone")
+	ErrInvalidQuietWindow   = errors.New("invalid quiet hours")
+	ErrTooManyQuietWindows  = errors.New("too many quiet hours windows")
+	ErrQuietWindowNotFound  = errors.New("quiet hours window not found")
+)
+
+// QuietMode defines what happens to notifications during quiet hours
+type QuietMode string
+
+const (
+	// QuietModeDefault makes a subscription follow the user's settings
+	QuietModeDefault QuietMode = ""
+	// QuietModeOff makes a subscription ignore quiet hours
+	QuietModeOff QuietMode = "off"
+	// QuietModeSilent delivers notifications without sound
+	QuietModeSilent QuietMode = "silent"
+	// QuietModeHold keeps notifications until quiet hours are over
+	QuietModeHold QuietMode = "hold"
+)
+
+// Weekdays is a set of days of the week
+type Weekdays uint8
+
+const (
+	AllWeek  Weekdays = 1<<7 - 1
+	WorkWeek Weekdays = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+	Weekend  Weekdays = 1<<time.Saturday | 1<<time.Sunday
+)
+
+// Has returns true if the day is in the set
+func (w Weekdays) Has(day time.Weekday) bool {
+	return w&(1<<day) != 0
+}
+
+// String returns a short human-readable description of the set
+func (w Weekdays) String() string {
+	switch w {
+	case AllWeek:
+		return "every day"
+	case WorkWeek:
+		return "weekdays"
+	case Weekend:
+		return "weekends"
+	}
+
+	var days []string
+	// Monday first
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		if w.Has(day) {
+			days = append(days, day.String()[:3])
+		}
+	}
+	return strings.Join(days, ", ")
+}
+
+// QuietWindow is a recurring period of quiet hours on selected days.
+// Start and End are minutes since midnight. A window ending before it starts
+// continues into the next day, and a window ending when it starts lasts the whole day.
+type QuietWindow struct {
+	Days  Weekdays
+	Start int
+	End   int
+}
+
+// duration returns the length of the window
+func (w QuietWindow) duration() time.Duration {
+	minutes := w.End - w.Start
+	if minutes <= 0 {
+		minutes += 24 * 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// String returns a human-readable description of the window
+func (w QuietWindow) String() string {
+	if w.Start == w.End {
+		return fmt.Sprintf("all day, %s", w.Days)
+	}
+	return fmt.Sprintf("%02d:%02d–%02d:%02d, %s", w.Start/60, w.Start%60, w.End/60, w.End%60, w.Days)
+}
+
+// activeUntil returns the end of the window occurrence containing t
+func (w QuietWindow) activeUntil(t time.Time) (time.Time, bool) {
+	y, m, d := t.Date()
+	// An occurrence started today or, for windows crossing midnight, yesterday
+	for _, offset := range []int{0, -1} {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, t.Location())
+		if !w.Days.Has(day.Weekday()) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+		end := start.Add(w.duration())
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	// Clock range like "23:00-08:00"
+	clockRangeRx = regexp.MustCompile(`^(\d{1,2}[:.]\d{2})\s*[-–]\s*(\d{1,2}[:.]\d{2})$`)
+	// Optional days followed by a clock range
+	trailingClockRangeRx = regexp.MustCompile(`^(.*?)\s*(\d{1,2}[:.]\d{2}\s*[-–]\s*\d{1,2}[:.]\d{2})$`)
+	// Day range like "mon-fri"
+	dayRangeRx = regexp.MustCompile(`^([a-z]+)\s*[-–]\s*([a-z]+)$`)
+)
+
+// ParseQuietWindow parses a single window of quiet hours entered by the user. Supported forms are:
+//
+//	23:00-08:00                  - every day
+//	weekdays 23:00-08:00         - on the given days
+//	mon-fri,sun 22:30-07:00
+//	weekends all day
+func ParseQuietWindow(input string) (QuietWindow, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if s == "" {
+		return QuietWindow{}, fmt.Errorf("%w: empty input", ErrInvalidQuietWindow)
+	}
+
+	var daysPart, timePart string
+	if strings.HasSuffix(s, "all day") {
+		daysPart = strings.TrimSpace(strings.TrimSuffix(s, "all day"))
+	} else if m := trailingClockRangeRx.FindStringSubmatch(s); m != nil {
+		daysPart, timePart = strings.TrimSpace(m[1]), m[2]
+	} else {
+		return QuietWindow{}, fmt.Errorf("%w: %s", ErrInvalidQuietWindow, s)
+	}
+
+	days := AllWeek
+	if daysPart != "" {
+		var err error
+		if days, err = parseWeekdays(daysPart); err != nil {
+			return QuietWindow{}, err
+		}
+	}
+
+	if timePart == "" {
+		return QuietWindow{Days: days}, nil
+	}
+
+	m := clockRangeRx.FindStringSubmatch(timePart)
+	if m == nil {
+		return QuietWindow{}, fmt.Errorf("%w: bad time range %s", ErrInvalidQuietWindow, timePart)
+	}
+	startHour, startMinute, err := parseClock(m[1])
+	if err != nil {
+		return QuietWindow{}, fmt.Errorf("%w: %w", ErrInvalidQuietWindow, err)
+	}
+	endHour, endMinute, err := parseClock(m[2])
+	if err != nil {
+		return QuietWindow{}, fmt.Errorf("%w: %w", ErrInvalidQuietWindow, err)
+	}
+
+	start, end := startHour*60+startMinute, endHour*60+endMinute
+	if start == end {
+		return QuietWindow{}, fmt.Errorf("%w: empty time range %s", ErrInvalidQuietWindow, timePart)
+	}
+	return QuietWindow{Days: days, Start: start, End: end}, nil
+}
+
+// parseWeekdays parses a comma-separated list of days, day ranges and day groups
+func parseWeekdays(s string) (Weekdays, error) {
+	var days Weekdays
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "daily", "every day", "everyday":
+			days |= AllWeek
+			continue
+		case "weekdays", "workdays":
+			days |= WorkWeek
+			continue
+		case "weekend", "weekends":
+			days |= Weekend
+			continue
+		}
+
+		if m := dayRangeRx.FindStringSubmatch(part); m != nil {
+			from, okFrom := parseWeekday(m[1])
+			to, okTo := parseWeekday(m[2])
+			if !okFrom || !okTo {
+				return 0, fmt.Errorf("%w: bad days %s", ErrInvalidQuietWindow, part)
+			}
+			for day := from; ; day = (day + 1) % 7 {
+				days |= 1 << day
+				if day == to {
+					break
+				}
+			}
+			continue
+		}
+
+		day, ok := parseWeekday(part)
+		if !ok {
+			return 0, fmt.Errorf("%w: bad days %s", ErrInvalidQuietWindow, part)
+		}
+		days |= 1 << day
+	}
+	return days, nil
+}
+
+// utcOffsetRx matches UTC offsets like "+3", "UTC-05:30" or "GMT+10"
+var utcOffsetRx = regexp.MustCompile(`^(?:utc|gmt)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// ParseTimezone parses an IANA timezone name or a UTC offset and returns its canonical form
+func ParseTimezone(input string) (string, error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return "", fmt.Errorf("%w: empty input", ErrInvalidTimezone)
+	}
+
+	lower := strings.ToLower(s)
+	if lower == "utc" || lower == "gmt" {
+		return "UTC", nil
+	}
+
+	if m := utcOffsetRx.FindStringSubmatch(lower); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes := 0
+		if m[3] != "" {
+			minutes, _ = strconv.Atoi(m[3])
+		}
+		if hours > 14 || minutes > 59 {
+			return "", fmt.Errorf("%w: bad offset %s", ErrInvalidTimezone, s)
+		}
+		if hours == 0 && minutes == 0 {
+			return "UTC", nil
+		}
+		return fmt.Sprintf("UTC%s%02d:%02d", m[1], hours, minutes), nil
+	}
+
+	// IANA names are case-sensitive, so look them up as entered
+	loc, err := time.LoadLocation(s)
+	if err != nil || loc.String() == "Local" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidTimezone, s)
+	}
+	return loc.String(), nil
+}
+
+// LoadTimezone returns the location of a timezone in canonical form, UTC if it is empty or invalid
+func LoadTimezone(name string) *time.Location {
+	if m := utcOffsetRx.FindStringSubmatch(strings.ToLower(name)); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		offset := hours*60*60 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset)
+	}
+
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// UserSettings holds the preferences of a user that apply to all their subscriptions
+type UserSettings struct {
+	UserID TelegramUserID
+	// Timezone is an IANA name or a UTC offset like "UTC+03:00", empty means UTC
+	Timezone string
+	// QuietMode is either QuietModeSilent or QuietModeHold
+	QuietMode    QuietMode
+	QuietWindows []QuietWindow
+	// Language is the language chosen by the user, empty means the language of their Telegram app
+	Language  string
+	UpdatedAt time.Time
+}
+
+// NewUserSettings returns the default settings of a user
+func NewUserSettings(userID TelegramUserID) *UserSettings {
+	return &UserSettings{
+		UserID:    userID,
+		QuietMode: QuietModeSilent,
+	}
+}
+
+// Location returns the timezone of the user
+func (s *UserSettings) Location() *time.Location {
+	return LoadTimezone(s.Timezone)
+}
+
+// QuietUntil returns the end of the user's quiet hours if t falls within them.
+// Adjacent windows are merged, so the end is when notifications are allowed again.
+func (s *UserSettings) QuietUntil(t time.Time) (time.Time, bool) {
+	t = t.In(s.Location())
+	var until time.Time
+	quiet := false
+
+	// Each window can extend the quiet time, a week of chained windows is enough
+	for i := 0; i < 2*len(s.QuietWindows)+7; i++ {
+		extended := false
+		for _, w := range s.QuietWindows {
+			if end, ok := w.activeUntil(t); ok {
+				t, until, quiet, extended = end, end, true, true
+			}
+		}
+		if !extended {
+			break
+		}
+	}
+
+	return until, quiet
+}
+
+// Quiet returns how a notification of the subscription is delivered at time t,
+// QuietModeOff means it is delivered normally
+func (s *UserSettings) Quiet(sub *Subscription, t time.Time) (QuietMode, time.Time) {
+	mode := sub.QuietMode
+	if mode == QuietModeDefault {
+		mode = s.QuietMode
+	}
+	if mode == QuietModeDefault {
+		mode = QuietModeSilent
+	}
+	if mode == QuietModeOff {
+		return QuietModeOff, time.Time{}
+	}
+
+	until, quiet := s.QuietUntil(t)
+	if !quiet {
+		return QuietModeOff, time.Time{}
+	}
+	return mode, until
+}
+
+type UserSettingsRepository interface {
+	// Get returns ErrUserSettingsNotFound if the user has no settings saved
+	Get(userID TelegramUserID) (*UserSettings, error)
+	GetByUsers(userIDs []TelegramUserID) ([]*UserSettings, error)
+	Save(settings *UserSettings) error
+}
+
+type UserSettingsService struct {
+	repo UserSettingsRepository
+}
+
+func NewUserSettingsService(repo UserSettingsRepository) *UserSettingsService {
+	return &UserSettingsService{repo: repo}
+}
+
+// Get returns the settings of the user, or the defaults if the user has none saved
+func (s *UserSettingsService) Get(userID TelegramUserID) (*UserSettings, error) {
+	settings, err := s.repo.Get(userID)
+	if errors.Is(err, ErrUserSettingsNotFound) {
+		return NewUserSettings(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user settings: %w", err)
+	}
+	return settings, nil
+}
+
+// Location returns the timezone of the user, UTC if it can't be determined
+func (s *UserSettingsService) Location(userID TelegramUserID) *time.Location {
+	settings, err := s.Get(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return settings.Location()
+}
+
+// update applies a change to the user's settings and saves them
+func (s *UserSettingsService) update(userID TelegramUserID, change func(settings *UserSettings) error) error {
+	settings, err := s.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := change(settings); err != nil {
+		return err
+	}
+	settings.UpdatedAt = time.Now()
+
+	if err := s.repo.Save(settings); err != nil {
+		return fmt.Errorf("saving user settings: %w", err)
+	}
+	return nil
+}
+
+// SetTimezone validates and sets the user's timezone
+func (s *UserSettingsService) SetTimezone(userID TelegramUserID, timezone string) error {
+	timezone, err := ParseTimezone(timezone)
+	if err != nil {
+		return err
+	}
+	return s.update(userID, func(settings *UserSettings) error {
+		settings.Timezone = timezone
+		return nil
+	})
+}
+
+// SetQuietMode chooses whether notifications are silent or held during quiet hours
+func (s *UserSettingsService) SetQuietMode(userID TelegramUserID, mode QuietMode) error {
+	if mode != QuietModeSilent && mode != QuietModeHold {
+		return fmt.Errorf("invalid quiet mode: %q", mode)
+	}
+	return s.update(userID, func(settings *UserSettings) error {
+		settings.QuietMode = mode
+		return nil
+	})
+}
+
+// AddQuietWindow adds a quiet hours window to the user's settings
+func (s *UserSettingsService) AddQuietWindow(userID TelegramUserID, window QuietWindow) error {
+	return s.update(userID, func(settings *UserSettings) error {
+		if len(settings.QuietWindows) >= MaxQuietWindows {
+			return ErrTooManyQuietWindows
+		}
+		settings.QuietWindows = append(settings.QuietWindows, window)
+		return nil
+	})
+}
+
+// RemoveQuietWindow removes the quiet hours window with the given index
+func (s *UserSettingsService) RemoveQuietWindow(userID TelegramUserID, index int) error {
+	return s.update(userID, func(settings *UserSettings) error {
+		if index < 0 || index >= len(settings.QuietWindows) {
+			return ErrQuietWindowNotFound
+		}
+		settings.QuietWindows = append(settings.QuietWindows[:index], settings.QuietWindows[index+1:]...)
+		return nil
+	})
+}
+
+// SetLanguage sets the language chosen by the user, empty means the language of their Telegram app
+func (s *UserSettingsService) SetLanguage(userID TelegramUserID, language string) error {
+	return s.update(userID, func(settings *UserSettings) error {
+		settings.Language = language
+		return nil
+	})
+}
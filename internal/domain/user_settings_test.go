@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuietWindow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected QuietWindow
+	}{
+		{"23:00-08:00", QuietWindow{Days: AllWeek, Start: 23 * 60, End: 8 * 60}},
+		{"weekdays 23:00 - 08:00", QuietWindow{Days: WorkWeek, Start: 23 * 60, End: 8 * 60}},
+		{"Weekends all day", QuietWindow{Days: Weekend}},
+		{"mon-fri 22:30-07:00", QuietWindow{Days: WorkWeek, Start: 22*60 + 30, End: 7 * 60}},
+		{"fri-mon 1:00-2:00", QuietWindow{Days: Weekend | 1<<time.Friday | 1<<time.Monday, Start: 60, End: 120}},
+		{"sat,sun 00:00-10:00", QuietWindow{Days: Weekend, Start: 0, End: 10 * 60}},
+		{"mon-fri,sun 22:30-07:00", QuietWindow{Days: WorkWeek | 1<<time.Sunday, Start: 22*60 + 30, End: 7 * 60}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			window, err := ParseQuietWindow(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, window)
+		})
+	}
+
+	for _, input := range []string{"", "always", "23:00", "25:00-08:00", "someday 23:00-08:00", "10:00-10:00",
+		"mon-fri 22:30-07:00, sat,sun all day"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseQuietWindow(input)
+			assert.ErrorIs(t, err, ErrInvalidQuietWindow)
+		})
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	tests := map[string]string{
+		"Europe/Berlin": "Europe/Berlin",
+		"utc":           "UTC",
+		"+3":            "UTC+03:00",
+		"UTC-05:30":     "UTC-05:30",
+		"GMT+10":        "UTC+10:00",
+		"+0":            "UTC",
+	}
+	for input, expected := range tests {
+		timezone, err := ParseTimezone(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, timezone, input)
+	}
+
+	for _, input := range []string{"", "Mars/Olympus", "+15", "Local"} {
+		_, err := ParseTimezone(input)
+		assert.ErrorIs(t, err, ErrInvalidTimezone, input)
+	}
+
+	_, offset := time.Date(2025, time.March, 5, 12, 0, 0, 0, LoadTimezone("UTC-05:30")).Zone()
+	assert.Equal(t, -(5*60+30)*60, offset)
+}
+
+func TestUserSettings_QuietUntil(t *testing.T) {
+	settings := &UserSettings{
+		Timezone: "UTC+03:00",
+		QuietWindows: []QuietWindow{
+			{Days: WorkWeek, Start: 23 * 60, End: 8 * 60},
+			{Days: Weekend},
+		},
+	}
+	loc := settings.Location()
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		until time.Time
+		quiet bool
+	}{
+		{"weekday daytime", time.Date(2025, time.March, 5, 14, 0, 0, 0, loc), time.Time{}, false},
+		{"weekday night", time.Date(2025, time.March, 5, 23, 30, 0, 0, loc), time.Date(2025, time.March, 6, 8, 0, 0, 0, loc), true},
+		{"after midnight", time.Date(2025, time.March, 6, 2, 0, 0, 0, loc), time.Date(2025, time.March, 6, 8, 0, 0, 0, loc), true},
+		// Friday night runs into the weekend, which runs into Monday morning
+		{"friday night", time.Date(2025, time.March, 7, 23, 30, 0, 0, loc), time.Date(2025, time.March, 10, 0, 0, 0, 0, loc), true},
+		{"other timezone", time.Date(2025, time.March, 5, 20, 30, 0, 0, time.UTC), time.Date(2025, time.March, 6, 8, 0, 0, 0, loc), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := settings.QuietUntil(tt.at)
+			assert.Equal(t, tt.quiet, quiet)
+			assert.True(t, tt.until.Equal(until), "expected %s, got %s", tt.until, until)
+		})
+	}
+}
+
+func TestUserSettings_Quiet(t *testing.T) {
+	settings := &UserSettings{
+		QuietMode:    QuietModeHold,
+		QuietWindows: []QuietWindow{{Days: AllWeek, Start: 22 * 60, End: 7 * 60}},
+	}
+	night := time.Date(2025, time.March, 5, 23, 0, 0, 0, time.UTC)
+	day := time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC)
+
+	mode, until := settings.Quiet(&Subscription{}, night)
+	assert.Equal(t, QuietModeHold, mode)
+	assert.Equal(t, time.Date(2025, time.March, 6, 7, 0, 0, 0, time.UTC), until)
+
+	mode, _ = settings.Quiet(&Subscription{QuietMode: QuietModeSilent}, night)
+	assert.Equal(t, QuietModeSilent, mode)
+
+	mode, _ = settings.Quiet(&Subscription{QuietMode: QuietModeOff}, night)
+	assert.Equal(t, QuietModeOff, mode)
+
+	mode, _ = settings.Quiet(&Subscription{}, day)
+	assert.Equal(t, QuietModeOff, mode)
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userRepositoryStub keeps a single user and counts the saves
+type userRepositoryStub struct {
+	UserRepository
+	user  *User
+	saves int
+}
+
+func (r *userRepositoryStub) Get(_ TelegramUserID) (*User, error) {
+	if r.user == nil {
+		return nil, ErrUserNotFound
+	}
+	saved := *r.user
+	return &saved, nil
+}
+
+func (r *userRepositoryStub) Save(user *User) error {
+	r.user = user
+	r.saves++
+	return nil
+}
+
+// subscriptionRepositoryStub records whether the subscriptions of the user are inactive
+type subscriptionRepositoryStub struct {
+	SubscriptionRepository
+	inactive map[TelegramUserID]bool
+}
+
+func (r *subscriptionRepositoryStub) SetInactive(userID TelegramUserID, inactive bool) error {
+	r.inactive[userID] = inactive
+	return nil
+}
+
+func TestUserService_Seen(t *testing.T) {
+	profile := &User{ID: MustNewTelegramUserID(42), FirstName: "John", Username: "jsmith", LanguageCode: "en"}
+	recently := time.Now().Add(-time.Minute)
+	longAgo := time.Now().Add(-2 * UserSeenInterval)
+
+	tests := []struct {
+		name        string
+		saved       *User
+		saves       int
+		reactivated bool
+	}{
+		{"new user", nil, 1, false},
+		{"seen recently", &User{ID: profile.ID, FirstName: "John", Username: "jsmith", LanguageCode: "en",
+			State: UserStateActive, LastSeenAt: recently}, 0, false},
+		{"seen long ago", &User{ID: profile.ID, FirstName: "John", Username: "jsmith", LanguageCode: "en",
+			State: UserStateActive, LastSeenAt: longAgo}, 1, false},
+		{"changed profile", &User{ID: profile.ID, FirstName: "Johnny", LanguageCode: "en",
+			State: UserStateActive, LastSeenAt: recently}, 1, false},
+		{"blocked the bot", &User{ID: profile.ID, FirstName: "John", Username: "jsmith", LanguageCode: "en",
+			State: UserStateBlocked, LastSeenAt: recently}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &userRepositoryStub{user: tt.saved}
+			subscriptions := &subscriptionRepositoryStub{inactive: make(map[TelegramUserID]bool)}
+			user, err := NewUserService(repo, subscriptions).Seen(profile)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.saves, repo.saves)
+			_, reactivated := subscriptions.inactive[profile.ID]
+			assert.Equal(t, tt.reactivated, reactivated)
+			assert.Equal(t, "John (@jsmith)", user.DisplayName())
+			assert.Equal(t, UserStateActive, user.State)
+		})
+	}
+}
+
+func TestUserService_Unreachable(t *testing.T) {
+	userID := MustNewTelegramUserID(42)
+	repo := &userRepositoryStub{}
+	subscriptions := &subscriptionRepositoryStub{inactive: make(map[TelegramUserID]bool)}
+	service := NewUserService(repo, subscriptions)
+
+	require.NoError(t, service.Unreachable(userID, UserStateBlocked))
+	assert.Equal(t, UserStateBlocked, repo.user.State)
+	assert.True(t, subscriptions.inactive[userID])
+
+	// Unblocking the bot is seen as an interaction
+	_, err := service.Seen(&User{ID: userID, FirstName: "John"})
+	require.NoError(t, err)
+	assert.Equal(t, UserStateActive, repo.user.State)
+	assert.False(t, subscriptions.inactive[userID])
+}
+
+func TestUser_DisplayName(t *testing.T) {
+	tests := []struct {
+		user     User
+		expected string
+	}{
+		{User{FirstName: "John", LastName: "Smith", Username: "jsmith"}, "John Smith (@jsmith)"},
+		{User{FirstName: "John"}, "John"},
+		{User{Username: "jsmith"}, "@jsmith"},
+		{User{ID: MustNewTelegramUserID(42)}, "User 42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.user.DisplayName())
+		})
+	}
+}
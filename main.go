@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	_ "time/tzdata" // Timezone database for images without one
 
 	"github.com/sergeax/noteo/internal/app"
 )